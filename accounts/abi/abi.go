@@ -17,9 +17,14 @@
 package abi
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/crypto"
 )
 
 // The ABI holds information about a contract's context and available
@@ -137,3 +142,37 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// revertSelector is the method id of the Error(string) payload returned by the
+// Solidity revert and require statements.
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// UnpackRevert resolves the abi-encoded revert reason from the data returned by
+// a reverted execution, which Solidity encodes as an Error(string) call.
+//
+// The payload is returned by arbitrary contracts, so its offset and length words
+// are checked against the size of the data before anything is sliced.
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 || !bytes.Equal(data[:4], revertSelector) {
+		return "", errBadRevert
+	}
+	data = data[4:]
+	if len(data) < 32 {
+		return "", errBadRevert
+	}
+	size := big.NewInt(int64(len(data)))
+
+	// The offset word points at the length word of the string
+	offset := new(big.Int).SetBytes(data[:32])
+	if new(big.Int).Add(offset, common.Big32).Cmp(size) > 0 {
+		return "", errBadRevert
+	}
+	start := int(offset.Int64()) + 32
+
+	// The length word is followed by the string itself
+	length := new(big.Int).SetBytes(data[start-32 : start])
+	if new(big.Int).Add(length, big.NewInt(int64(start))).Cmp(size) > 0 {
+		return "", errBadRevert
+	}
+	return string(data[start : start+int(length.Int64())]), nil
+}
@@ -418,3 +418,40 @@ func TestBareEvents(t *testing.T) {
 		}
 	}
 }
+
+func TestUnpackRevert(t *testing.T) {
+	tests := []struct {
+		input  string
+		reason string
+		fail   bool
+	}{
+		{"", "", true},
+		{"08c379a1", "", true},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020", "", true},
+		{"08c379a000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000000", "", false},
+		{"08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000046e6f706500000000000000000000000000000000000000000000000000000000", "nope", false},
+		// Truncated payload: the string is shorter than its length
+		{"08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000046e6f70", "", true},
+		// Offset past the end of the payload
+		{"08c379a0000000000000000000000000000000000000000000000000000000000000004000000000000000000000000000000000000000000000000000000000000000046e6f706500000000000000000000000000000000000000000000000000000000", "", true},
+		{"08c379a000000000000000000000000000000000000000000000000080000000000000000000000000000000000000000000000000000000000000000000000000000004", "", true},
+		// Oversized length
+		{"08c379a000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000007fffffffffffffff6e6f706500000000000000000000000000000000000000000000000000000000", "", true},
+		{"08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000001000000000000000000000000000000000000000000000000006e6f706500000000000000000000000000000000000000000000000000000000", "", true},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff6e6f706500000000000000000000000000000000000000000000000000000000", "", true},
+	}
+	for i, tt := range tests {
+		reason, err := UnpackRevert(common.Hex2Bytes(tt.input))
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: unpacked invalid payload: %q", i, reason)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to unpack payload: %v", i, err)
+		} else if reason != tt.reason {
+			t.Errorf("test %d: reason mismatch: have %q, want %q", i, reason, tt.reason)
+		}
+	}
+}
@@ -268,7 +268,7 @@ func TestUnpack(t *testing.T) {
 		}
 		encb, err := hex.DecodeString(test.enc)
 		if err != nil {
-			t.Fatalf("invalid hex: %s" + test.enc)
+			t.Fatalf("invalid hex: %s", test.enc)
 		}
 		outptr := reflect.New(reflect.TypeOf(test.want))
 		err = abi.Unpack(outptr.Interface(), "method", encb)
@@ -59,6 +59,8 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.AncientFlag,
+		utils.DatabaseBackendFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
 		utils.DashboardEnabledFlag,
@@ -85,8 +87,10 @@ var (
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
+		utils.GCModeFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
+		utils.CheckpointFlag,
 		utils.LightKDFFlag,
 		utils.CacheFlag,
 		utils.TrieCacheGenFlag,
@@ -117,7 +121,10 @@ var (
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
+		utils.GpoSlowPercentileFlag,
+		utils.GpoFastPercentileFlag,
 		utils.ExtraDataFlag,
+		utils.MinerStrategyFlag,
 		configFileFlag,
 	}
 
@@ -126,11 +133,23 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.RPCVirtualHostsFlag,
+		utils.RPCReadTimeoutFlag,
+		utils.RPCWriteTimeoutFlag,
+		utils.RPCIdleTimeoutFlag,
+		utils.RPCBodyLimitFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
+		utils.RPCAuthFlag,
+		utils.RPCAuthSecretFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateBurstFlag,
+		utils.RPCMethodLimitsFlag,
+		utils.RPCMaxConcurrentFlag,
+		utils.RPCAccessLogFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 	}
@@ -155,6 +174,7 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		snapshotCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
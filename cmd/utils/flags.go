@@ -47,6 +47,7 @@ import (
 	"github.com/apolo-technologies/zerium/lzrm"
 	"github.com/apolo-technologies/zerium/log"
 	"github.com/apolo-technologies/zerium/metrics"
+	"github.com/apolo-technologies/zerium/miner"
 	"github.com/apolo-technologies/zerium/node"
 	"github.com/apolo-technologies/zerium/p2p"
 	"github.com/apolo-technologies/zerium/p2p/discover"
@@ -54,6 +55,7 @@ import (
 	"github.com/apolo-technologies/zerium/p2p/nat"
 	"github.com/apolo-technologies/zerium/p2p/netutil"
 	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/rpc"
 	whisper "github.com/apolo-technologies/zerium/whisper/whisperv5"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -117,6 +119,15 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+	}
+	DatabaseBackendFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Backing database implementation to use ('leveldb' or 'boltdb')",
+		Value: zrmdb.DefaultBackend,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -166,9 +177,14 @@ var (
 	defaultSyncMode = zrm.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
+		Usage: `Blockchain sync mode ("fast", "snap", "full", or "light")`,
 		Value: &defaultSyncMode,
 	}
+	GCModeFlag = cli.StringFlag{
+		Name:  "gcmode",
+		Usage: `Blockchain garbage collection mode ("full", "archive")`,
+		Value: "full",
+	}
 
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
@@ -180,6 +196,10 @@ var (
 		Usage: "Maximum number of LES client peers",
 		Value: 20,
 	}
+	CheckpointFlag = cli.StringFlag{
+		Name:  "checkpoint",
+		Usage: `Trusted checkpoint to start syncing from ("<section index>,<section head>,<CHT root>,<bloom root>")`,
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -329,6 +349,11 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerStrategyFlag = cli.StringFlag{
+		Name:  "minerstrategy",
+		Usage: "Transaction ordering strategy of the miner (" + strings.Join(miner.Strategies(), ", ") + ")",
+		Value: miner.PriceGreedyStrategy,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -382,11 +407,67 @@ var (
 		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
 		Value: "",
 	}
+	RPCVirtualHostsFlag = cli.StringFlag{
+		Name:  "rpcvhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
+		Value: strings.Join(node.DefaultConfig.HTTPVirtualHosts, ","),
+	}
 	RPCApiFlag = cli.StringFlag{
 		Name:  "rpcapi",
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	RPCReadTimeoutFlag = cli.DurationFlag{
+		Name:  "rpcreadtimeout",
+		Usage: "Maximum duration for reading an entire HTTP-RPC request",
+		Value: node.DefaultConfig.HTTPTimeouts.ReadTimeout,
+	}
+	RPCWriteTimeoutFlag = cli.DurationFlag{
+		Name:  "rpcwritetimeout",
+		Usage: "Maximum duration for writing an HTTP-RPC response",
+		Value: node.DefaultConfig.HTTPTimeouts.WriteTimeout,
+	}
+	RPCIdleTimeoutFlag = cli.DurationFlag{
+		Name:  "rpcidletimeout",
+		Usage: "Maximum duration to wait for the next HTTP-RPC request on keep-alive connections",
+		Value: node.DefaultConfig.HTTPTimeouts.IdleTimeout,
+	}
+	RPCBodyLimitFlag = cli.Int64Flag{
+		Name:  "rpcbodylimit",
+		Usage: "Maximum size in bytes of HTTP-RPC request bodies (0 = default)",
+		Value: 0,
+	}
+	RPCAuthFlag = cli.BoolFlag{
+		Name:  "rpcauth",
+		Usage: "Require JWT bearer token authentication on the HTTP-RPC and WS-RPC servers",
+	}
+	RPCAuthSecretFlag = cli.StringFlag{
+		Name:  "rpcauthsecret",
+		Usage: "File holding the hex encoded JWT secret (default = generated within the datadir)",
+		Value: "",
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpcratelimit",
+		Usage: "Maximum number of HTTP-RPC and WS-RPC calls per second of each client IP (0 = unlimited)",
+	}
+	RPCRateBurstFlag = cli.IntFlag{
+		Name:  "rpcrateburst",
+		Usage: "Maximum number of HTTP-RPC and WS-RPC calls of each client IP at once",
+		Value: 1,
+	}
+	RPCMethodLimitsFlag = cli.StringFlag{
+		Name:  "rpcmethodlimits",
+		Usage: "Comma separated call rate limits of methods and namespaces as name=rate[:burst] (e.g. zrm_getLogs=5:10,debug=1)",
+		Value: "",
+	}
+	RPCMaxConcurrentFlag = cli.IntFlag{
+		Name:  "rpcmaxconcurrent",
+		Usage: "Maximum number of HTTP-RPC and WS-RPC calls executing at once (0 = unlimited)",
+	}
+	RPCAccessLogFlag = cli.BoolFlag{
+		Name:  "rpcaccesslog",
+		Usage: "Log every call served by the HTTP-RPC and WS-RPC servers",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -503,6 +584,16 @@ var (
 		Usage: "Suggested gas price is the given percentile of a set of recent transaction gas prices",
 		Value: zrm.DefaultConfig.GPO.Percentile,
 	}
+	GpoSlowPercentileFlag = cli.IntFlag{
+		Name:  "gposlowpercentile",
+		Usage: "Suggested slow gas price is the given percentile of recent transaction gas prices, weighted by gas used",
+		Value: zrm.DefaultConfig.GPO.SlowPercentile,
+	}
+	GpoFastPercentileFlag = cli.IntFlag{
+		Name:  "gpofastpercentile",
+		Usage: "Suggested fast gas price is the given percentile of recent transaction gas prices, weighted by gas used",
+		Value: zrm.DefaultConfig.GPO.FastPercentile,
+	}
 	WhisperEnabledFlag = cli.BoolFlag{
 		Name:  "shh",
 		Usage: "Enable Whisper",
@@ -681,6 +772,21 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(RPCApiFlag.Name) {
 		cfg.HTTPModules = splitAndTrim(ctx.GlobalString(RPCApiFlag.Name))
 	}
+	if ctx.GlobalIsSet(RPCVirtualHostsFlag.Name) {
+		cfg.HTTPVirtualHosts = splitAndTrim(ctx.GlobalString(RPCVirtualHostsFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCReadTimeoutFlag.Name) {
+		cfg.HTTPTimeouts.ReadTimeout = ctx.GlobalDuration(RPCReadTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCWriteTimeoutFlag.Name) {
+		cfg.HTTPTimeouts.WriteTimeout = ctx.GlobalDuration(RPCWriteTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCIdleTimeoutFlag.Name) {
+		cfg.HTTPTimeouts.IdleTimeout = ctx.GlobalDuration(RPCIdleTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBodyLimitFlag.Name) {
+		cfg.HTTPBodyLimit = ctx.GlobalInt64(RPCBodyLimitFlag.Name)
+	}
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -704,6 +810,80 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setRPCAuth configures the JWT authentication of the HTTP and WebSocket RPC
+// endpoints from the set command line flags.
+func setRPCAuth(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCAuthFlag.Name) {
+		cfg.JWTAuth = ctx.GlobalBool(RPCAuthFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCAuthSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(RPCAuthSecretFlag.Name)
+	}
+}
+
+// setRPCLimits configures the rate and concurrency limits of the HTTP and
+// WebSocket RPC endpoints from the set command line flags.
+func setRPCLimits(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCLimits.Client.Rate = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCRateBurstFlag.Name) {
+		cfg.RPCLimits.Client.Burst = ctx.GlobalInt(RPCRateBurstFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCMethodLimitsFlag.Name) {
+		for _, entry := range splitAndTrim(ctx.GlobalString(RPCMethodLimitsFlag.Name)) {
+			name, limit, err := parseRateLimit(entry)
+			if err != nil {
+				Fatalf("Option %q: %v", RPCMethodLimitsFlag.Name, err)
+			}
+			// Names with a separator denote methods, the rest namespaces
+			if strings.Contains(name, "_") {
+				if cfg.RPCLimits.Methods == nil {
+					cfg.RPCLimits.Methods = make(map[string]rpc.RateLimit)
+				}
+				cfg.RPCLimits.Methods[name] = limit
+			} else {
+				if cfg.RPCLimits.Namespaces == nil {
+					cfg.RPCLimits.Namespaces = make(map[string]rpc.RateLimit)
+				}
+				cfg.RPCLimits.Namespaces[name] = limit
+			}
+		}
+	}
+	if ctx.GlobalIsSet(RPCMaxConcurrentFlag.Name) {
+		cfg.RPCLimits.MaxConcurrent = ctx.GlobalInt(RPCMaxConcurrentFlag.Name)
+	}
+}
+
+// setRPCAccessLog configures the access logging of the HTTP and WebSocket RPC
+// endpoints from the set command line flags.
+func setRPCAccessLog(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCAccessLogFlag.Name) {
+		cfg.RPCAccessLog = ctx.GlobalBool(RPCAccessLogFlag.Name)
+	}
+}
+
+// parseRateLimit parses a name=rate[:burst] rate limit specification.
+func parseRateLimit(entry string) (string, rpc.RateLimit, error) {
+	parts := strings.SplitN(entry, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", rpc.RateLimit{}, fmt.Errorf("invalid rate limit %q, want name=rate[:burst]", entry)
+	}
+	spec := strings.SplitN(parts[1], ":", 2)
+
+	rate, err := strconv.ParseFloat(spec[0], 64)
+	if err != nil || rate < 0 {
+		return "", rpc.RateLimit{}, fmt.Errorf("invalid rate in %q", entry)
+	}
+	limit := rpc.RateLimit{Rate: rate, Burst: 1}
+	if len(spec) == 2 {
+		if limit.Burst, err = strconv.Atoi(spec[1]); err != nil || limit.Burst < 1 {
+			return "", rpc.RateLimit{}, fmt.Errorf("invalid burst in %q", entry)
+		}
+	}
+	return parts[0], limit, nil
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -842,6 +1022,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
+	setRPCAuth(ctx, cfg)
+	setRPCLimits(ctx, cfg)
+	setRPCAccessLog(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 
 	switch {
@@ -855,6 +1038,13 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 		cfg.DataDir = filepath.Join(node.DefaultDataDir(), "rinkeby")
 	}
 
+	if ctx.GlobalIsSet(DatabaseBackendFlag.Name) {
+		backend := ctx.GlobalString(DatabaseBackendFlag.Name)
+		if !isDatabaseBackend(backend) {
+			Fatalf("Invalid --%s: unknown engine %q, available: %v", DatabaseBackendFlag.Name, backend, zrmdb.Backends())
+		}
+		cfg.DatabaseBackend = backend
+	}
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
 	}
@@ -866,6 +1056,17 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// isDatabaseBackend reports whether the given name is a registered database
+// backend.
+func isDatabaseBackend(name string) bool {
+	for _, backend := range zrmdb.Backends() {
+		if backend == name {
+			return true
+		}
+	}
+	return false
+}
+
 func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
 	if ctx.GlobalIsSet(GpoBlocksFlag.Name) {
 		cfg.Blocks = ctx.GlobalInt(GpoBlocksFlag.Name)
@@ -873,6 +1074,12 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
 	if ctx.GlobalIsSet(GpoPercentileFlag.Name) {
 		cfg.Percentile = ctx.GlobalInt(GpoPercentileFlag.Name)
 	}
+	if ctx.GlobalIsSet(GpoSlowPercentileFlag.Name) {
+		cfg.SlowPercentile = ctx.GlobalInt(GpoSlowPercentileFlag.Name)
+	}
+	if ctx.GlobalIsSet(GpoFastPercentileFlag.Name) {
+		cfg.FastPercentile = ctx.GlobalInt(GpoFastPercentileFlag.Name)
+	}
 }
 
 func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
@@ -929,6 +1136,35 @@ func setAbthash(ctx *cli.Context, cfg *zrm.Config) {
 	}
 }
 
+// setCheckpoint parses the trusted checkpoint of the form
+// "<section index>,<section head>,<CHT root>,<bloom root>" from the command line.
+func setCheckpoint(ctx *cli.Context, cfg *zrm.Config) {
+	if !ctx.GlobalIsSet(CheckpointFlag.Name) {
+		return
+	}
+	parts := strings.Split(ctx.GlobalString(CheckpointFlag.Name), ",")
+	if len(parts) != 4 {
+		Fatalf("Invalid --%s: expected <section index>,<section head>,<CHT root>,<bloom root>", CheckpointFlag.Name)
+	}
+	index, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil {
+		Fatalf("Invalid --%s section index: %v", CheckpointFlag.Name, err)
+	}
+	var hashes [3]common.Hash
+	for i, part := range parts[1:] {
+		if err := hashes[i].UnmarshalText([]byte(strings.TrimSpace(part))); err != nil {
+			Fatalf("Invalid --%s hash %q: %v", CheckpointFlag.Name, part, err)
+		}
+	}
+	cfg.Checkpoint = &params.TrustedCheckpoint{
+		Name:         "command line",
+		SectionIndex: index,
+		SectionHead:  hashes[0],
+		CHTRoot:      hashes[1],
+		BloomRoot:    hashes[2],
+	}
+}
+
 func checkExclusive(ctx *cli.Context, flags ...cli.Flag) {
 	set := make([]string, 0, 1)
 	for _, flag := range flags {
@@ -962,6 +1198,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *zrm.Config) {
 	setGPO(ctx, &cfg.GPO)
 	setTxPool(ctx, &cfg.TxPool)
 	setAbthash(ctx, cfg)
+	setCheckpoint(ctx, cfg)
 
 	switch {
 	case ctx.GlobalIsSet(SyncModeFlag.Name):
@@ -985,6 +1222,16 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *zrm.Config) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name)
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(GCModeFlag.Name) {
+		if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
+			Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
+		}
+		cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	}
 
 	if ctx.GlobalIsSet(MinerThreadsFlag.Name) {
 		cfg.MinerThreads = ctx.GlobalInt(MinerThreadsFlag.Name)
@@ -998,6 +1245,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *zrm.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerStrategyFlag.Name) {
+		cfg.MinerStrategy = ctx.GlobalString(MinerStrategyFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1128,6 +1378,18 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) zrmdb.Database {
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
+	// Full chain databases keep their ancient blocks in a separate freezer
+	if _, ok := chainDb.(*zrmdb.MemDatabase); !ok && !ctx.GlobalBool(LightModeFlag.Name) {
+		freezer := ctx.GlobalString(AncientFlag.Name)
+		if freezer != "" {
+			freezer = stack.ResolvePath(freezer)
+		}
+		adb, err := zrmdb.NewDatabaseWithFreezer(chainDb, freezer)
+		if err != nil {
+			Fatalf("Could not open ancient database: %v", err)
+		}
+		return adb
+	}
 	return chainDb
 }
 
@@ -1165,8 +1427,16 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 			)
 		}
 	}
+	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
+		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
+	}
+	cache := &core.CacheConfig{
+		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
+		TrieNodeLimit: zrm.DefaultConfig.TrieCache,
+		TrieTimeLimit: zrm.DefaultConfig.TrieTimeout,
+	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
-	chain, err = core.NewBlockChain(chainDb, config, engine, vmcfg)
+	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
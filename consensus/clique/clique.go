@@ -405,6 +405,23 @@ func (c *Clique) snapshot(chain consensus.ChainReader, number uint64, hash commo
 			log.Trace("Stored genesis voting snapshot to disk")
 			break
 		}
+		// If we're at an epoch header below the trusted checkpoint, on the chain leading
+		// to it, the headers before it may have been imported unverified, so trust its
+		// signer list instead
+		if cp := chain.Config().Checkpoint; cp != nil && number%c.config.Epoch == 0 && number <= cp.HeadNumber() && checkpointed(chain, cp, number, hash, headers) {
+			if checkpoint := chain.GetHeader(hash, number); checkpoint != nil {
+				signers := make([]common.Address, (len(checkpoint.Extra)-extraVanity-extraSeal)/common.AddressLength)
+				for i := 0; i < len(signers); i++ {
+					copy(signers[i][:], checkpoint.Extra[extraVanity+i*common.AddressLength:])
+				}
+				snap = newSnapshot(c.config, c.signatures, number, hash, signers)
+				if err := snap.store(c.db); err != nil {
+					return nil, err
+				}
+				log.Info("Stored checkpoint voting snapshot to disk", "number", number, "hash", hash)
+				break
+			}
+		}
 		// No snapshot for this header, gather the header and move backward
 		var header *types.Header
 		if len(parents) > 0 {
@@ -444,6 +461,23 @@ func (c *Clique) snapshot(chain consensus.ChainReader, number uint64, hash commo
 	return snap, err
 }
 
+// checkpointed returns whether the header with the given number and hash is the
+// head of the trusted checkpoint's section, or one of its ancestors. The headers
+// already gathered on top of it are consulted first, as they may not be stored
+// in the chain yet.
+func checkpointed(chain consensus.ChainReader, cp *params.TrustedCheckpoint, number uint64, hash common.Hash, descendants []*types.Header) bool {
+	for _, header := range descendants {
+		if header.Number.Uint64() == cp.HeadNumber() {
+			return header.Hash() == cp.SectionHead
+		}
+	}
+	header := chain.GetHeader(cp.SectionHead, cp.HeadNumber())
+	for header != nil && header.Number.Uint64() > number {
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return header != nil && header.Hash() == hash
+}
+
 // VerifyUncles implements consensus.Engine, always returning an error for any
 // uncles as this consensus mechanism doesn't permit uncles.
 func (c *Clique) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
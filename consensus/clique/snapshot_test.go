@@ -23,6 +23,7 @@ import (
 	"testing"
 
 	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/consensus"
 	"github.com/apolo-technologies/zerium/core"
 	"github.com/apolo-technologies/zerium/core/types"
 	"github.com/apolo-technologies/zerium/crypto"
@@ -403,3 +404,107 @@ func TestVoting(t *testing.T) {
 		}
 	}
 }
+
+// testerCheckpointReader implements consensus.ChainReader over a set of headers
+// without their ancestry, as imported below a trusted checkpoint.
+type testerCheckpointReader struct {
+	config  *params.ChainConfig
+	headers map[common.Hash]*types.Header
+}
+
+func (r *testerCheckpointReader) Config() *params.ChainConfig               { return r.config }
+func (r *testerCheckpointReader) CurrentHeader() *types.Header              { panic("not supported") }
+func (r *testerCheckpointReader) GetBlock(common.Hash, uint64) *types.Block { panic("not supported") }
+func (r *testerCheckpointReader) GetHeaderByHash(common.Hash) *types.Header { panic("not supported") }
+func (r *testerCheckpointReader) GetHeaderByNumber(uint64) *types.Header    { panic("not supported") }
+func (r *testerCheckpointReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := r.headers[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+// Tests that the signer lists of epoch headers below the trusted checkpoint are
+// trusted without their ancestry, but not those of headers above it or of headers
+// not leading up to the checkpoint.
+func TestCheckpointSnapshot(t *testing.T) {
+	accounts := newTesterAccountPool()
+
+	cliqueConfig := &params.CliqueConfig{Period: 1, Epoch: 30}
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = cliqueConfig
+	config.Checkpoint = &params.TrustedCheckpoint{SectionIndex: 0}
+
+	// Create epoch headers on both sides of the checkpoint, and a follow-up header
+	signers := []common.Address{accounts.address("A"), accounts.address("B")}
+	if bytes.Compare(signers[0][:], signers[1][:]) > 0 {
+		signers[0], signers[1] = signers[1], signers[0]
+	}
+	epoch := func(number uint64) *types.Header {
+		header := &types.Header{
+			Number:     new(big.Int).SetUint64(number),
+			ParentHash: common.Hash{0xff},
+			Extra:      make([]byte, extraVanity+common.AddressLength*len(signers)+extraSeal),
+		}
+		for i, signer := range signers {
+			copy(header.Extra[extraVanity+i*common.AddressLength:], signer[:])
+		}
+		return header
+	}
+	head := config.Checkpoint.HeadNumber()
+	below, above := epoch(30), epoch(head+cliqueConfig.Epoch-head%cliqueConfig.Epoch)
+	next := &types.Header{
+		Number:     big.NewInt(31),
+		ParentHash: below.Hash(),
+		Time:       big.NewInt(1),
+		Extra:      make([]byte, extraVanity+extraSeal),
+	}
+	accounts.sign(next, "A")
+
+	chain := &testerCheckpointReader{
+		config:  &config,
+		headers: map[common.Hash]*types.Header{below.Hash(): below, above.Hash(): above, next.Hash(): next},
+	}
+	// Link the epoch below the checkpoint up to the section head
+	parent := next
+	for number := next.Number.Uint64() + 1; number <= head; number++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), ParentHash: parent.Hash()}
+		chain.headers[header.Hash()] = header
+		parent = header
+	}
+	config.Checkpoint.SectionHead = parent.Hash()
+
+	// An epoch header at the same height forking off the checkpointed chain
+	fork := epoch(30)
+	fork.Time = big.NewInt(1)
+	chain.headers[fork.Hash()] = fork
+
+	// The epoch header below the checkpoint must be trusted, allowing later headers
+	// to be verified against its signer list
+	db, _ := zrmdb.NewMemDatabase()
+	engine := New(cliqueConfig, db)
+
+	snap, err := engine.snapshot(chain, next.Number.Uint64(), next.Hash(), []*types.Header{next})
+	if err != nil {
+		t.Fatalf("failed to create snapshot from checkpointed epoch: %v", err)
+	}
+	if result := snap.signers(); len(result) != len(signers) || result[0] != signers[0] || result[1] != signers[1] {
+		t.Errorf("signers mismatch: have %x, want %x", result, signers)
+	}
+	if snap.Number != next.Number.Uint64() || snap.Hash != next.Hash() {
+		t.Errorf("snapshot position mismatch: have #%d [%x], want #%d [%x]", snap.Number, snap.Hash, next.Number, next.Hash())
+	}
+	// The epoch header above the checkpoint must not be trusted
+	if _, err := engine.snapshot(chain, above.Number.Uint64(), above.Hash(), nil); err != consensus.ErrUnknownAncestor {
+		t.Errorf("epoch above checkpoint error mismatch: have %v, want %v", err, consensus.ErrUnknownAncestor)
+	}
+	// The epoch header not leading up to the checkpoint must not be trusted
+	if _, err := engine.snapshot(chain, fork.Number.Uint64(), fork.Hash(), nil); err != consensus.ErrUnknownAncestor {
+		t.Errorf("epoch off the checkpointed chain error mismatch: have %v, want %v", err, consensus.ErrUnknownAncestor)
+	}
+	// Without a checkpoint, the epoch below it must not be trusted either
+	config.Checkpoint = nil
+	if _, err := New(cliqueConfig, db).snapshot(chain, below.Number.Uint64(), below.Hash(), nil); err != consensus.ErrUnknownAncestor {
+		t.Errorf("epoch without checkpoint error mismatch: have %v, want %v", err, consensus.ErrUnknownAncestor)
+	}
+}
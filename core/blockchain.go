@@ -31,6 +31,7 @@ import (
 	"github.com/apolo-technologies/zerium/common/mclock"
 	"github.com/apolo-technologies/zerium/consensus"
 	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/core/state/snapshot"
 	"github.com/apolo-technologies/zerium/core/types"
 	"github.com/apolo-technologies/zerium/core/vm"
 	"github.com/apolo-technologies/zerium/crypto"
@@ -42,6 +43,7 @@ import (
 	"github.com/apolo-technologies/zerium/rlp"
 	"github.com/apolo-technologies/zerium/trie"
 	"github.com/hashicorp/golang-lru"
+	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
 var (
@@ -56,11 +58,20 @@ const (
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
+	triesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	BlockChainVersion = 3
 )
 
+// CacheConfig contains the configuration values for the trie caching/pruning
+// that's resident in a blockchain.
+type CacheConfig struct {
+	Disabled      bool          // Whether to disable trie write caching (archive node)
+	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
+	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+}
+
 // BlockChain represents the canonical chain given a database with a genesis
 // block. The Blockchain manages chain imports, reverts, chain reorganisations.
 //
@@ -76,7 +87,8 @@ const (
 // included in the canonical one where as GetBlockByNumber always represents the
 // canonical chain.
 type BlockChain struct {
-	config *params.ChainConfig // chain & network configuration
+	config      *params.ChainConfig // chain & network configuration
+	cacheConfig *CacheConfig        // Cache configuration for pruning
 
 	hc            *HeaderChain
 	chainDb       zrmdb.Database
@@ -97,6 +109,9 @@ type BlockChain struct {
 	currentFastBlock *types.Block // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
+	snaps        *snapshot.Tree // Flat state snapshots of the recent blocks for fast state reads
+	triegc       *prque.Prque   // Priority queue mapping block numbers to tries to gc
+	gcproc       time.Duration  // Accumulates canonical block processing for trie dumping
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
@@ -118,8 +133,16 @@ type BlockChain struct {
 
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Zerium Validator and
-// Processor.
-func NewBlockChain(chainDb zrmdb.Database, config *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+// Processor. A nil cache configuration keeps the state of every block (archive
+// node), state pruning needs to be enabled explicitly.
+func NewBlockChain(chainDb zrmdb.Database, cacheConfig *CacheConfig, config *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config) (*BlockChain, error) {
+	if cacheConfig == nil {
+		cacheConfig = &CacheConfig{
+			Disabled:      true,
+			TrieNodeLimit: 256,
+			TrieTimeLimit: 5 * time.Minute,
+		}
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
@@ -128,8 +151,10 @@ func NewBlockChain(chainDb zrmdb.Database, config *params.ChainConfig, engine co
 
 	bc := &BlockChain{
 		config:       config,
+		cacheConfig:  cacheConfig,
 		chainDb:      chainDb,
 		stateCache:   state.NewDatabase(chainDb),
+		triegc:       prque.New(),
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
 		bodyRLPCache: bodyRLPCache,
@@ -167,8 +192,36 @@ func NewBlockChain(chainDb zrmdb.Database, config *params.ChainConfig, engine co
 			}
 		}
 	}
+	// Load the flat state snapshot, generating it for fresh databases and in the
+	// background if it doesn't match the head state (e.g. after a crash)
+	bc.snaps = snapshot.New(chainDb)
+	if head := bc.CurrentBlock(); bc.snaps.Snapshot(head.Root()) == nil {
+		if head.NumberU64() == 0 {
+			if err := bc.snaps.Rebuild(bc.stateCache.TrieDB(), head.Root()); err != nil {
+				return nil, err
+			}
+		} else {
+			log.Warn("State snapshot out of sync, regenerating", "number", head.Number(), "root", head.Root())
+
+			done := bc.snaps.Regenerate(bc.stateCache.TrieDB(), head.Root(), bc.quit)
+
+			bc.wg.Add(1)
+			go func() {
+				defer bc.wg.Done()
+				if err := <-done; err != nil {
+					log.Error("Failed to regenerate state snapshot", "err", err)
+				}
+			}()
+		}
+	}
 	// Take ownership of this particular state
 	go bc.update()
+
+	// Start migrating ancient blocks out of the key-value store if supported
+	if ancients, ok := bc.chainDb.(zrmdb.AncientStore); ok {
+		bc.wg.Add(1)
+		go bc.freeze(ancients)
+	}
 	return bc, nil
 }
 
@@ -195,9 +248,13 @@ func (bc *BlockChain) loadLastState() error {
 	}
 	// Make sure the state associated with the block is available
 	if _, err := state.New(currentBlock.Root(), bc.stateCache); err != nil {
-		// Dangling block without a state associated, init from scratch
-		log.Warn("Head state missing, resetting chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
-		return bc.Reset()
+		// Dangling block without a state associated, likely a pruned state that
+		// was never flushed to disk. Rewind to the last block with a state.
+		log.Warn("Head state missing, repairing chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
+		if err := bc.repair(&currentBlock); err != nil {
+			log.Warn("Chain repair failed, resetting chain", "err", err)
+			return bc.Reset()
+		}
 	}
 	// Everything seems to be fine, set as the head block
 	bc.currentBlock = currentBlock
@@ -231,6 +288,28 @@ func (bc *BlockChain) loadLastState() error {
 	return nil
 }
 
+// repair tries to repair the current blockchain by rolling back the current block
+// until one with associated state is found. This is needed to fix incomplete db
+// writes caused either by crashes/power outages, or simply non-committed tries.
+//
+// This method only rolls back the current block. The current header and current
+// fast block are left intact.
+func (bc *BlockChain) repair(head **types.Block) error {
+	for {
+		// Abort if we've rewound to a head block that does have associated state
+		if _, err := state.New((*head).Root(), bc.stateCache); err == nil {
+			log.Info("Rewound blockchain to past state", "number", (*head).Number(), "hash", (*head).Hash())
+			return nil
+		}
+		// Otherwise rewind one block and recheck state availability there
+		parent := bc.GetBlock((*head).ParentHash(), (*head).NumberU64()-1)
+		if parent == nil {
+			return fmt.Errorf("missing block %d [%x…]", (*head).NumberU64()-1, (*head).ParentHash().Bytes()[:4])
+		}
+		*head = parent
+	}
+}
+
 // SetHead rewinds the local chain to a new head. In the case of headers, everything
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
@@ -248,6 +327,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Drop any frozen blocks above the new head, they are no longer canonical
+	if ancients, ok := bc.chainDb.(zrmdb.AncientStore); ok {
+		if err := ancients.TruncateAncients(currentHeader.Number.Uint64() + 1); err != nil {
+			log.Crit("Failed to truncate ancient store", "err", err)
+		}
+	}
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -301,6 +387,9 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	bc.mu.Unlock()
 
 	log.Info("Committed new head block", "number", block.Number(), "hash", hash)
+	if bc.snaps.Snapshot(block.Root()) == nil {
+		log.Warn("State snapshot unavailable after sync, rebuild it for faster state access", "number", block.Number(), "root", block.Root())
+	}
 	return nil
 }
 
@@ -382,7 +471,21 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshot(root, bc.stateCache, bc.snaps)
+}
+
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
+// RebuildSnapshot regenerates the flat state snapshot from the state trie of the
+// current head block, iterating the entire state.
+func (bc *BlockChain) RebuildSnapshot() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	return bc.snaps.Rebuild(bc.stateCache.TrieDB(), bc.currentBlock.Root())
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -519,8 +622,7 @@ func (bc *BlockChain) HasBlock(hash common.Hash, number uint64) bool {
 	if bc.blockCache.Contains(hash) {
 		return true
 	}
-	ok, _ := bc.chainDb.Has(blockBodyKey(hash, number))
-	return ok
+	return HasBody(bc.chainDb, hash, number)
 }
 
 // HasBlockAndState checks if a block and associated state trie is fully present
@@ -532,7 +634,13 @@ func (bc *BlockChain) HasBlockAndState(hash common.Hash) bool {
 		return false
 	}
 	// Ensure the associated state is also present
-	_, err := bc.stateCache.OpenTrie(block.Root())
+	return bc.HasState(block.Root())
+}
+
+// HasState checks if the state trie with the given root is fully present in
+// the database or in the in-memory trie cache.
+func (bc *BlockChain) HasState(root common.Hash) bool {
+	_, err := bc.stateCache.OpenTrie(root)
 	return err == nil
 }
 
@@ -606,6 +714,37 @@ func (bc *BlockChain) Stop() {
 	atomic.StoreInt32(&bc.procInterrupt, 1)
 
 	bc.wg.Wait()
+
+	// Ensure the state of a few recent blocks is stored to disk before exiting,
+	// so a restart can resume from the head, its parent (in case of a reorg) or,
+	// failing that, a block within the in-memory window.
+	if !bc.cacheConfig.Disabled {
+		triedb := bc.stateCache.TrieDB()
+		for _, offset := range []uint64{0, 1, triesInMemory - 1} {
+			if number := bc.CurrentBlock().NumberU64(); number >= offset {
+				recent := bc.GetBlockByNumber(number - offset)
+				if recent == nil {
+					continue
+				}
+				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
+				if err := triedb.Commit(recent.Root()); err != nil {
+					log.Error("Failed to commit recent state trie", "err", err)
+				}
+			}
+		}
+		for !bc.triegc.Empty() {
+			triedb.Dereference(bc.triegc.PopItem().(common.Hash))
+		}
+		if nodes := triedb.Nodes(); nodes != 0 {
+			log.Error("Dangling trie nodes after full cleanup", "nodes", nodes)
+		}
+	}
+	// Flatten the snapshot of the head state to disk, so a restart can resume it
+	if root := bc.CurrentBlock().Root(); bc.snaps.Snapshot(root) != nil {
+		if err := bc.snaps.Cap(root, 0); err != nil {
+			log.Error("Failed to persist state snapshot", "err", err)
+		}
+	}
 	log.Info("Blockchain manager stopped")
 }
 
@@ -782,8 +921,27 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 	return 0, nil
 }
 
-// WriteBlock writes the block to the chain.
+// WriteBlockWithoutState writes only the block and its metadata to the database,
+// but does not write any state. This is used to construct competing side forks
+// up until they exceed the canonical total difficulty.
+func (bc *BlockChain) WriteBlockWithoutState(block *types.Block, td *big.Int) error {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	if err := bc.hc.WriteTd(block.Hash(), block.NumberU64(), td); err != nil {
+		return err
+	}
+	return WriteBlock(bc.chainDb, block)
+}
+
+// WriteBlockAndState writes the block and all associated state to the database.
 func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.Receipt, state *state.StateDB) (status WriteStatus, err error) {
+	return bc.writeBlockAndState(block, receipts, state, 0)
+}
+
+// writeBlockAndState writes the block and all associated state to the database,
+// accounting the given block processing time towards the next trie flush.
+func (bc *BlockChain) writeBlockAndState(block *types.Block, receipts []*types.Receipt, state *state.StateDB, proctime time.Duration) (status WriteStatus, err error) {
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
@@ -808,7 +966,11 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	if err := WriteBlock(batch, block); err != nil {
 		return NonStatTy, err
 	}
-	if _, err := state.CommitTo(batch, bc.config.IsEIP158(block.Number())); err != nil {
+	root, err := state.Commit(batch, bc.config.IsEIP158(block.Number()))
+	if err != nil {
+		return NonStatTy, err
+	}
+	if err := bc.gcState(block, root, proctime); err != nil {
 		return NonStatTy, err
 	}
 	if err := WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
@@ -849,11 +1011,71 @@ func (bc *BlockChain) WriteBlockAndState(block *types.Block, receipts []*types.R
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+
+		// Flatten the snapshot layers falling out of the reorg window to disk
+		if bc.snaps.Snapshot(block.Root()) != nil {
+			if err := bc.snaps.Cap(block.Root(), triesInMemory); err != nil {
+				log.Warn("Failed to flatten state snapshot", "root", block.Root(), "err", err)
+			}
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
 }
 
+// gcState keeps the state trie of a freshly committed block in memory, flushing
+// a recent trie to disk if the memory or processing time allowance is exceeded
+// and garbage collecting the tries that fell out of the in-memory window. In
+// archive mode every trie is flushed to disk straight away.
+func (bc *BlockChain) gcState(block *types.Block, root common.Hash, proctime time.Duration) error {
+	triedb := bc.stateCache.TrieDB()
+	if bc.cacheConfig.Disabled {
+		return triedb.Commit(root)
+	}
+	// Full but not archive node, do proper garbage collection
+	triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
+	bc.triegc.Push(root, -float32(block.NumberU64()))
+
+	bc.gcproc += proctime
+
+	current := block.NumberU64()
+	if current <= triesInMemory {
+		return nil
+	}
+	// Find the next state trie we need to commit
+	chosen := current - triesInMemory
+
+	// If we exceeded our memory or time allowance, flush the chosen trie to disk
+	limit := common.StorageSize(bc.cacheConfig.TrieNodeLimit) * 1024 * 1024
+	if size := triedb.Size(); size > limit || bc.gcproc > bc.cacheConfig.TrieTimeLimit {
+		// If the header is missing (canonical chain behind), we're reorging a low
+		// diff sidechain. Suspend committing until this operation is completed.
+		if header := bc.GetHeaderByNumber(chosen); header == nil {
+			log.Warn("Reorg in progress, trie commit postponed", "number", chosen)
+		} else {
+			if bc.gcproc > bc.cacheConfig.TrieTimeLimit {
+				log.Info("State in memory for too long, committing", "time", bc.gcproc, "allowance", bc.cacheConfig.TrieTimeLimit)
+			} else {
+				log.Info("State cache too large, committing", "size", size, "allowance", limit)
+			}
+			if err := triedb.Commit(header.Root); err != nil {
+				return err
+			}
+			bc.gcproc = 0
+		}
+	}
+	// Garbage collect anything below our required write retention
+	for !bc.triegc.Empty() {
+		root, number := bc.triegc.Pop()
+		if uint64(-number) > chosen {
+			bc.triegc.Push(root, number)
+			break
+		}
+		triedb.Dereference(root.(common.Hash))
+	}
+	return nil
+}
+
 // InsertChain attempts to insert the given batch of blocks in to the canonical
 // chain or, otherwise, create a fork. If an error is returned it will return
 // the index number of the failing block as well an error describing what went
@@ -952,8 +1174,40 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 				continue
 			}
 
-			bc.reportBlock(block, nil, err)
-			return i, events, coalescedLogs, err
+			if err != consensus.ErrPrunedAncestor {
+				bc.reportBlock(block, nil, err)
+				return i, events, coalescedLogs, err
+			}
+			// Block competing with the canonical chain, store in the db, but don't
+			// process until the competitor TD goes above the canonical TD
+			localTd := bc.GetTd(bc.currentBlock.Hash(), bc.currentBlock.NumberU64())
+			externTd := new(big.Int).Add(bc.GetTd(block.ParentHash(), block.NumberU64()-1), block.Difficulty())
+			if localTd.Cmp(externTd) > 0 {
+				if err = bc.WriteBlockWithoutState(block, externTd); err != nil {
+					return i, events, coalescedLogs, err
+				}
+				continue
+			}
+			// Competitor chain beat canonical, gather all blocks from the common ancestor
+			var winner []*types.Block
+
+			parent := bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+			for !bc.HasState(parent.Root()) {
+				winner = append(winner, parent)
+				parent = bc.GetBlock(parent.ParentHash(), parent.NumberU64()-1)
+			}
+			for j := 0; j < len(winner)/2; j++ {
+				winner[j], winner[len(winner)-1-j] = winner[len(winner)-1-j], winner[j]
+			}
+			// Import all the pruned blocks to make the state available
+			bc.chainmu.Unlock()
+			_, evs, logs, err := bc.insertChain(winner)
+			bc.chainmu.Lock()
+			events, coalescedLogs = append(events, evs...), append(coalescedLogs, logs...)
+
+			if err != nil {
+				return i, events, coalescedLogs, err
+			}
 		}
 		// Create a new statedb using the parent block and report an
 		// error if it fails.
@@ -963,7 +1217,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		} else {
 			parent = chain[i-1]
 		}
-		state, err := state.New(parent.Root(), bc.stateCache)
+		state, err := state.NewWithSnapshot(parent.Root(), bc.stateCache, bc.snaps)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
@@ -979,8 +1233,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
+		proctime := time.Since(bstart)
+
 		// Write the block to the chain and get the status.
-		status, err := bc.WriteBlockAndState(block, receipts, state)
+		status, err := bc.writeBlockAndState(block, receipts, state, proctime)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
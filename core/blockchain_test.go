@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/consensus"
 	"github.com/apolo-technologies/zerium/consensus/abthash"
 	"github.com/apolo-technologies/zerium/core/state"
 	"github.com/apolo-technologies/zerium/core/types"
@@ -32,6 +33,7 @@ import (
 	"github.com/apolo-technologies/zerium/crypto"
 	"github.com/apolo-technologies/zerium/zrmdb"
 	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/rlp"
 )
 
 // newTestBlockChain creates a blockchain without validation.
@@ -46,7 +48,7 @@ func newTestBlockChain(fake bool) *BlockChain {
 	if !fake {
 		engine = abthash.NewTester()
 	}
-	blockchain, err := NewBlockChain(db, gspec.Config, engine, vm.Config{})
+	blockchain, err := NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
 	if err != nil {
 		panic(err)
 	}
@@ -496,7 +498,7 @@ func testReorgBadHashes(t *testing.T, full bool) {
 	}
 
 	// Create a new BlockChain and check that it rolled back the state.
-	ncm, err := NewBlockChain(bc.chainDb, bc.config, abthash.NewFaker(), vm.Config{})
+	ncm, err := NewBlockChain(bc.chainDb, nil, bc.config, abthash.NewFaker(), vm.Config{})
 	if err != nil {
 		t.Fatalf("failed to create new chain manager: %v", err)
 	}
@@ -609,7 +611,7 @@ func TestFastVsFullChains(t *testing.T) {
 	// Import the chain as an archive node for the comparison baseline
 	archiveDb, _ := zrmdb.NewMemDatabase()
 	gspec.MustCommit(archiveDb)
-	archive, _ := NewBlockChain(archiveDb, gspec.Config, abthash.NewFaker(), vm.Config{})
+	archive, _ := NewBlockChain(archiveDb, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	defer archive.Stop()
 
 	if n, err := archive.InsertChain(blocks); err != nil {
@@ -618,7 +620,7 @@ func TestFastVsFullChains(t *testing.T) {
 	// Fast import the chain as a non-archive node to test
 	fastDb, _ := zrmdb.NewMemDatabase()
 	gspec.MustCommit(fastDb)
-	fast, _ := NewBlockChain(fastDb, gspec.Config, abthash.NewFaker(), vm.Config{})
+	fast, _ := NewBlockChain(fastDb, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	defer fast.Stop()
 
 	headers := make([]*types.Header, len(blocks))
@@ -696,7 +698,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	archiveDb, _ := zrmdb.NewMemDatabase()
 	gspec.MustCommit(archiveDb)
 
-	archive, _ := NewBlockChain(archiveDb, gspec.Config, abthash.NewFaker(), vm.Config{})
+	archive, _ := NewBlockChain(archiveDb, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	if n, err := archive.InsertChain(blocks); err != nil {
 		t.Fatalf("failed to process block %d: %v", n, err)
 	}
@@ -709,7 +711,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	// Import the chain as a non-archive node and ensure all pointers are updated
 	fastDb, _ := zrmdb.NewMemDatabase()
 	gspec.MustCommit(fastDb)
-	fast, _ := NewBlockChain(fastDb, gspec.Config, abthash.NewFaker(), vm.Config{})
+	fast, _ := NewBlockChain(fastDb, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	defer fast.Stop()
 
 	headers := make([]*types.Header, len(blocks))
@@ -730,7 +732,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 	lightDb, _ := zrmdb.NewMemDatabase()
 	gspec.MustCommit(lightDb)
 
-	light, _ := NewBlockChain(lightDb, gspec.Config, abthash.NewFaker(), vm.Config{})
+	light, _ := NewBlockChain(lightDb, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	if n, err := light.InsertHeaderChain(headers, 1); err != nil {
 		t.Fatalf("failed to insert header %d: %v", n, err)
 	}
@@ -799,7 +801,7 @@ func TestChainTxReorgs(t *testing.T) {
 		}
 	})
 	// Import the chain. This runs all block validation rules.
-	blockchain, _ := NewBlockChain(db, gspec.Config, abthash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	if i, err := blockchain.InsertChain(chain); err != nil {
 		t.Fatalf("failed to insert original chain[%d]: %v", i, err)
 	}
@@ -870,7 +872,7 @@ func TestLogReorgs(t *testing.T) {
 		signer  = types.NewEIP155Signer(gspec.Config.EnvId)
 	)
 
-	blockchain, _ := NewBlockChain(db, gspec.Config, abthash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	defer blockchain.Stop()
 
 	rmLogsCh := make(chan RemovedLogsEvent)
@@ -917,7 +919,7 @@ func TestReorgSideEvent(t *testing.T) {
 		signer  = types.NewEIP155Signer(gspec.Config.EnvId)
 	)
 
-	blockchain, _ := NewBlockChain(db, gspec.Config, abthash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	defer blockchain.Stop()
 
 	chain, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, gen *BlockGen) {})
@@ -1037,13 +1039,13 @@ func TestEIP155Transition(t *testing.T) {
 		funds      = big.NewInt(1000000000)
 		deleteAddr = common.Address{1}
 		gspec      = &Genesis{
-			Config: &params.ChainConfig{envId: big.NewInt(1), EIP155Block: big.NewInt(2), HomesteadBlock: new(big.Int)},
+			Config: &params.ChainConfig{EnvId: big.NewInt(1), EIP155Block: big.NewInt(2), HomesteadBlock: new(big.Int)},
 			Alloc:  GenesisAlloc{address: {Balance: funds}, deleteAddr: {Balance: new(big.Int)}},
 		}
 		genesis = gspec.MustCommit(db)
 	)
 
-	blockchain, _ := NewBlockChain(db, gspec.Config, abthash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	defer blockchain.Stop()
 
 	blocks, _ := GenerateChain(gspec.Config, genesis, db, 4, func(i int, block *BlockGen) {
@@ -1108,7 +1110,7 @@ func TestEIP155Transition(t *testing.T) {
 	}
 
 	// generate an invalid chain id transaction
-	config := &params.ChainConfig{envId: big.NewInt(2), EIP155Block: big.NewInt(2), HomesteadBlock: new(big.Int)}
+	config := &params.ChainConfig{EnvId: big.NewInt(2), EIP155Block: big.NewInt(2), HomesteadBlock: new(big.Int)}
 	blocks, _ = GenerateChain(config, blocks[len(blocks)-1], db, 4, func(i int, block *BlockGen) {
 		var (
 			tx      *types.Transaction
@@ -1142,7 +1144,7 @@ func TestEIP161AccountRemoval(t *testing.T) {
 		theAddr = common.Address{1}
 		gspec   = &Genesis{
 			Config: &params.ChainConfig{
-				envId:        big.NewInt(1),
+				EnvId:        big.NewInt(1),
 				HomesteadBlock: new(big.Int),
 				EIP155Block:    new(big.Int),
 				EIP158Block:    big.NewInt(2),
@@ -1151,7 +1153,7 @@ func TestEIP161AccountRemoval(t *testing.T) {
 		}
 		genesis = gspec.MustCommit(db)
 	)
-	blockchain, _ := NewBlockChain(db, gspec.Config, abthash.NewFaker(), vm.Config{})
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
 	defer blockchain.Stop()
 
 	blocks, _ := GenerateChain(gspec.Config, genesis, db, 3, func(i int, block *BlockGen) {
@@ -1197,3 +1199,187 @@ func TestEIP161AccountRemoval(t *testing.T) {
 		t.Error("account should not exist")
 	}
 }
+
+// newPruningTestChain creates a pruning blockchain on top of a fresh database,
+// along with a canonical chain of the given length generated on the side.
+func newPruningTestChain(t *testing.T, blocks int) (zrmdb.Database, *BlockChain, []*types.Block) {
+	var (
+		db, _    = zrmdb.NewMemDatabase()
+		gendb, _ = zrmdb.NewMemDatabase()
+		gspec    = &Genesis{Config: params.TestChainConfig}
+		genesis  = gspec.MustCommit(gendb)
+	)
+	gspec.MustCommit(db)
+
+	chain, _ := GenerateChain(gspec.Config, genesis, gendb, blocks, nil)
+	blockchain, err := NewBlockChain(db, &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute}, gspec.Config, abthash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create pruning blockchain: %v", err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return db, blockchain, chain
+}
+
+// Tests that a nil cache configuration creates an archive node, writing the
+// state of every block to disk.
+func TestArchiveByDefault(t *testing.T) {
+	var (
+		db, _   = zrmdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	chain, _ := GenerateChain(gspec.Config, genesis, db, 3, nil)
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for _, block := range chain {
+		if ok, _ := db.Has(block.Root().Bytes()); !ok {
+			t.Errorf("block #%d: state not written to disk", block.NumberU64())
+		}
+	}
+}
+
+// Tests that stopping a pruning blockchain persists the state of the head, its
+// parent and the oldest block in memory, and that the restarted chain resumes
+// from the head.
+func TestPrunedStatePersistedOnStop(t *testing.T) {
+	blocks := 2 * triesInMemory
+	db, blockchain, chain := newPruningTestChain(t, blocks)
+
+	recent := []*types.Block{chain[blocks-1], chain[blocks-2], chain[blocks-triesInMemory]}
+	for _, block := range recent {
+		if ok, _ := db.Has(block.Root().Bytes()); ok {
+			t.Fatalf("block #%d: state written to disk before stop", block.NumberU64())
+		}
+	}
+	blockchain.Stop()
+
+	for _, block := range recent {
+		if ok, _ := db.Has(block.Root().Bytes()); !ok {
+			t.Errorf("block #%d: state not written to disk on stop", block.NumberU64())
+		}
+	}
+	restarted, err := NewBlockChain(db, &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute}, params.TestChainConfig, abthash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to restart blockchain: %v", err)
+	}
+	defer restarted.Stop()
+
+	if head := restarted.CurrentBlock(); head.Hash() != chain[blocks-1].Hash() {
+		t.Errorf("restarted head mismatch: have #%d, want #%d", head.NumberU64(), blocks)
+	}
+}
+
+// Tests that blocks building on an ancestor with pruned state are reported as
+// such, stored without state while lighter than the canonical chain, and that
+// the state is regenerated once the side chain becomes heavier.
+func TestPrunedAncestor(t *testing.T) {
+	blocks := 2 * triesInMemory
+	_, blockchain, chain := newPruningTestChain(t, blocks)
+	defer blockchain.Stop()
+
+	gendb, _ := zrmdb.NewMemDatabase()
+	genesis := (&Genesis{Config: params.TestChainConfig}).MustCommit(gendb)
+	canon, _ := GenerateChain(params.TestChainConfig, genesis, gendb, 10, nil)
+
+	fork, _ := GenerateChain(params.TestChainConfig, canon[9], gendb, blocks, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	if blockchain.HasState(chain[9].Root()) {
+		t.Fatalf("ancestor state not pruned")
+	}
+	if err := blockchain.Validator().ValidateBody(fork[0]); err != consensus.ErrPrunedAncestor {
+		t.Fatalf("pruned ancestor error mismatch: have %v, want %v", err, consensus.ErrPrunedAncestor)
+	}
+	if _, err := blockchain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert side chain: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != fork[len(fork)-1].Hash() {
+		t.Fatalf("head mismatch: have #%d [%x], want #%d [%x]", head.NumberU64(), head.Hash(), fork[len(fork)-1].NumberU64(), fork[len(fork)-1].Hash())
+	}
+	if !blockchain.HasState(fork[len(fork)-1].Root()) {
+		t.Errorf("side chain head state missing")
+	}
+}
+
+// Tests that a pruning blockchain which wasn't stopped cleanly rewinds its head
+// to the last block with state on disk when restarted.
+func TestPrunedRepair(t *testing.T) {
+	db, blockchain, _ := newPruningTestChain(t, 10)
+	defer blockchain.Stop()
+
+	restarted, err := NewBlockChain(db, &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute}, params.TestChainConfig, abthash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to restart blockchain: %v", err)
+	}
+	defer restarted.Stop()
+
+	if head := restarted.CurrentBlock(); head.NumberU64() != 0 {
+		t.Errorf("repaired head mismatch: have #%d, want #0", head.NumberU64())
+	}
+	if header := restarted.CurrentHeader(); header.Number.Uint64() != 10 {
+		t.Errorf("head header mismatch: have #%d, want #10", header.Number)
+	}
+}
+
+// Tests that a blockchain restarted with a flat state snapshot not matching its
+// head state regenerates the snapshot in the background, tracking the blocks
+// imported meanwhile.
+func TestSnapshotRegeneration(t *testing.T) {
+	var (
+		db, _   = zrmdb.NewMemDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	chain, _ := GenerateChain(gspec.Config, genesis, db, 6, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{byte(i)})
+	})
+	if _, err := blockchain.InsertChain(chain[:5]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Restart without stopping, leaving the persisted snapshot at the genesis
+	restarted, err := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to restart blockchain: %v", err)
+	}
+	defer restarted.Stop()
+
+	if _, err := restarted.InsertChain(chain[5:]); err != nil {
+		t.Fatalf("failed to insert chain after restart: %v", err)
+	}
+	head := restarted.CurrentBlock()
+	statedb, err := restarted.State()
+	if err != nil {
+		t.Fatalf("failed to retrieve head state: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		snap := restarted.snaps.Snapshot(head.Root())
+		if snap == nil {
+			t.Fatalf("head snapshot missing")
+		}
+		// The coinbase of the first block is only present in the disk layer
+		blob, err := snap.AccountRLP(crypto.Keccak256Hash(common.Address{0}.Bytes()))
+		if err == nil {
+			var account state.Account
+			if err := rlp.DecodeBytes(blob, &account); err != nil {
+				t.Fatalf("failed to decode snapshot account: %v", err)
+			}
+			if want := statedb.GetBalance(common.Address{0}); account.Balance.Cmp(want) != 0 {
+				t.Errorf("snapshot balance mismatch: have %v, want %v", account.Balance, want)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshot not regenerated: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
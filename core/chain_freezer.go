@@ -0,0 +1,172 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/rlp"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// freezerThreshold is the number of recent blocks kept in the key-value store.
+// Blocks older than this are deemed immutable and moved into the ancient store.
+var freezerThreshold uint64 = 90000
+
+const (
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before deleting them from the key-value store.
+	freezerBatchLimit = 30000
+
+	// freezerRecheckInterval is the frequency to check the key-value database
+	// for chain progression that might permit new blocks to be frozen.
+	freezerRecheckInterval = time.Minute
+)
+
+// freeze is a background thread that periodically checks the blockchain for any
+// import progress and moves ancient data from the key-value database into the
+// ancient store.
+func (bc *BlockChain) freeze(ancients zrmdb.AncientStore) {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(freezerRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		bc.freezeBlocks(ancients)
+
+		select {
+		case <-ticker.C:
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// freezeBlocks moves the canonical blocks that became older than the freezer
+// threshold into the ancient store, and deletes them from the key-value store
+// once they have been synced to disk, along with the side chains forking off
+// at or below them.
+func (bc *BlockChain) freezeBlocks(ancients zrmdb.AncientStore) {
+	head := bc.CurrentBlock().NumberU64()
+	if head < freezerThreshold {
+		return
+	}
+	var (
+		first = ancients.Ancients()
+		limit = head - freezerThreshold
+	)
+	if first > limit {
+		return
+	}
+	if limit-first >= freezerBatchLimit {
+		limit = first + freezerBatchLimit - 1
+	}
+	// Move the data of each canonical block into the ancient store
+	var (
+		start  = time.Now()
+		hashes []common.Hash
+	)
+	for number := first; number <= limit; number++ {
+		hash := GetCanonicalHash(bc.chainDb, number)
+		if hash == (common.Hash{}) {
+			log.Error("Canonical hash missing, can't freeze", "number", number)
+			break
+		}
+		header := GetHeaderRLP(bc.chainDb, hash, number)
+		if len(header) == 0 {
+			log.Error("Block header missing, can't freeze", "number", number, "hash", hash)
+			break
+		}
+		body := GetBodyRLP(bc.chainDb, hash, number)
+		if len(body) == 0 {
+			log.Error("Block body missing, can't freeze", "number", number, "hash", hash)
+			break
+		}
+		td := getTdRLP(bc.chainDb, hash, number)
+		if len(td) == 0 {
+			log.Error("Total difficulty missing, can't freeze", "number", number, "hash", hash)
+			break
+		}
+		// Blocks without transactions might not have stored receipts
+		receipts := getBlockReceiptsRLP(bc.chainDb, hash, number)
+		if len(receipts) == 0 {
+			receipts = rlp.EmptyList
+		}
+		if err := ancients.AppendAncient(number, hash[:], header, body, receipts, td); err != nil {
+			log.Error("Failed to freeze block", "number", number, "hash", hash, "err", err)
+			break
+		}
+		hashes = append(hashes, hash)
+	}
+	if len(hashes) == 0 {
+		return
+	}
+	if err := ancients.Sync(); err != nil {
+		log.Crit("Failed to flush frozen blocks", "err", err)
+	}
+	// Wipe out the frozen data from the key-value store. The hash to number
+	// mappings are kept, as they're needed to look up blocks by hash. Side chain
+	// blocks at the frozen heights can never become canonical anymore, so they
+	// are deleted entirely, along with their descendants.
+	var (
+		batch    = bc.chainDb.NewBatch()
+		dangling = make(map[common.Hash]struct{})
+		flush    = func() {
+			if batch.ValueSize() >= zrmdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					log.Crit("Failed to delete frozen blocks", "err", err)
+				}
+				batch.Reset()
+			}
+		}
+	)
+	for i, hash := range hashes {
+		number := first + uint64(i)
+		for _, side := range GetAllHashes(bc.chainDb, number) {
+			if side != hash {
+				DeleteBlock(batch, side, number)
+				dangling[side] = struct{}{}
+			}
+		}
+		deleteFrozenBlock(batch, hash, number)
+		flush()
+	}
+	sides := len(dangling)
+	for number := first + uint64(len(hashes)); len(dangling) > 0; number++ {
+		children := make(map[common.Hash]struct{})
+		for _, hash := range GetAllHashes(bc.chainDb, number) {
+			header := GetHeader(bc.chainDb, hash, number)
+			if header == nil {
+				continue
+			}
+			if _, ok := dangling[header.ParentHash]; ok {
+				DeleteBlock(batch, hash, number)
+				children[hash] = struct{}{}
+			}
+		}
+		sides += len(children)
+		dangling = children
+		flush()
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete frozen blocks", "err", err)
+	}
+	log.Info("Moved blocks into ancient store", "count", len(hashes), "number", first+uint64(len(hashes))-1, "sidechain", sides, "elapsed", common.PrettyDuration(time.Since(start)))
+}
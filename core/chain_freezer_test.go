@@ -0,0 +1,190 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/consensus/abthash"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/core/vm"
+	"github.com/apolo-technologies/zerium/crypto"
+	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// Tests that blocks older than the freezer threshold are moved, along with their
+// receipts, into the ancient store, and can still be read back from there.
+func TestFreezeAncientBlocks(t *testing.T) {
+	defer func(threshold uint64) { freezerThreshold = threshold }(freezerThreshold)
+	freezerThreshold = 16
+
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatalf("failed to create temporary freezer directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	memdb, _ := zrmdb.NewMemDatabase()
+	db, err := zrmdb.NewDatabaseWithFreezer(memdb, dir)
+	if err != nil {
+		t.Fatalf("failed to create ancient database: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.MakeSigner(gspec.Config, common.Big1)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, db, 64, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(address), common.Address{0xaa}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil), signer, key)
+		gen.AddTx(tx)
+	})
+	blocks = append([]*types.Block{genesis}, blocks...)
+
+	chain, _ := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
+	if _, err := chain.InsertChain(blocks[1:]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Stop the background freezer and move the ancient blocks manually
+	chain.Stop()
+
+	tds := make([]*big.Int, len(blocks))
+	for i, block := range blocks {
+		tds[i] = GetTd(db, block.Hash(), uint64(i))
+	}
+	chain.freezeBlocks(db)
+
+	frozen := uint64(len(blocks)) - freezerThreshold
+	if ancients := db.Ancients(); ancients != frozen {
+		t.Fatalf("frozen block count mismatch: have %d, want %d", ancients, frozen)
+	}
+	for i, block := range blocks {
+		number, hash := uint64(i), block.Hash()
+
+		if have := GetCanonicalHash(db, number); have != hash {
+			t.Errorf("block #%d: canonical hash mismatch: have %x, want %x", number, have, hash)
+		}
+		if header := GetHeader(db, hash, number); header == nil || header.Hash() != hash {
+			t.Errorf("block #%d: header mismatch: have %v", number, header)
+		}
+		if body := GetBody(db, hash, number); body == nil || types.DeriveSha(types.Transactions(body.Transactions)) != block.TxHash() {
+			t.Errorf("block #%d: body mismatch: have %v", number, body)
+		}
+		if td := GetTd(db, hash, number); td == nil || td.Cmp(tds[i]) != 0 {
+			t.Errorf("block #%d: total difficulty mismatch: have %v, want %v", number, td, tds[i])
+		}
+		if number > 0 {
+			if receipts := GetBlockReceipts(db, hash, number); len(receipts) != 1 || types.DeriveSha(receipts) != block.ReceiptHash() {
+				t.Errorf("block #%d: receipts mismatch: have %v", number, receipts)
+			}
+		}
+		// Frozen blocks must be gone from the key-value store, recent ones kept
+		if stored := len(GetHeaderRLP(memdb, hash, number)) > 0; stored != (number >= frozen) {
+			t.Errorf("block #%d: key-value store presence mismatch: have %v, want %v", number, stored, number >= frozen)
+		}
+		if stored := len(GetBodyRLP(memdb, hash, number)) > 0; stored != (number >= frozen) {
+			t.Errorf("block #%d: key-value body presence mismatch: have %v, want %v", number, stored, number >= frozen)
+		}
+	}
+	// Freezing again without chain progression must be a no-op
+	chain.freezeBlocks(db)
+	if ancients := db.Ancients(); ancients != frozen {
+		t.Fatalf("frozen block count changed: have %d, want %d", ancients, frozen)
+	}
+}
+
+// Tests that side chain blocks at frozen heights, along with their descendants,
+// are deleted from the key-value store when the canonical blocks are frozen,
+// while side chains forking off above the frozen range are kept.
+func TestFreezeDeletesSideChains(t *testing.T) {
+	defer func(threshold uint64) { freezerThreshold = threshold }(freezerThreshold)
+	freezerThreshold = 16
+
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatalf("failed to create temporary freezer directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	memdb, _ := zrmdb.NewMemDatabase()
+	db, err := zrmdb.NewDatabaseWithFreezer(memdb, dir)
+	if err != nil {
+		t.Fatalf("failed to create ancient database: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, db, 64, nil)
+	fork := func(parent int, n int) []*types.Block {
+		side, _ := GenerateChain(gspec.Config, blocks[parent-1], db, n, func(i int, gen *BlockGen) {
+			gen.SetCoinbase(common.Address{0x01})
+		})
+		return side
+	}
+	var (
+		buried   = fork(10, 5)  // #11-#15, entirely below the frozen range
+		crossing = fork(45, 10) // #46-#55, crossing the frozen boundary
+		recent   = fork(55, 3)  // #56-#58, entirely above the frozen range
+	)
+	chain, _ := NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
+	for _, chunk := range [][]*types.Block{blocks, buried, crossing, recent} {
+		if _, err := chain.InsertChain(chunk); err != nil {
+			t.Fatalf("failed to insert chain: %v", err)
+		}
+	}
+	if head := chain.CurrentBlock().Hash(); head != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, blocks[len(blocks)-1].Hash())
+	}
+	chain.Stop()
+	chain.freezeBlocks(db)
+
+	for _, block := range append(buried, crossing...) {
+		number, hash := block.NumberU64(), block.Hash()
+		if GetHeader(db, hash, number) != nil {
+			t.Errorf("side block #%d: header not deleted", number)
+		}
+		if GetBody(db, hash, number) != nil {
+			t.Errorf("side block #%d: body not deleted", number)
+		}
+		if GetBlockNumber(db, hash) != missingNumber {
+			t.Errorf("side block #%d: number mapping not deleted", number)
+		}
+	}
+	for _, block := range recent {
+		number, hash := block.NumberU64(), block.Hash()
+		if GetHeader(db, hash, number) == nil || GetBody(db, hash, number) == nil {
+			t.Errorf("side block #%d: deleted above the frozen range", number)
+		}
+	}
+	for _, block := range blocks {
+		number, hash := block.NumberU64(), block.Hash()
+		if GetHeader(db, hash, number) == nil || GetBody(db, hash, number) == nil {
+			t.Errorf("canonical block #%d: missing after freezing", number)
+		}
+	}
+}
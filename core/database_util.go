@@ -89,8 +89,36 @@ func encodeBlockNumber(number uint64) []byte {
 	return enc
 }
 
+// ancientStore returns the ancient store attached to a database if the given
+// block number was already moved into it, nil otherwise.
+func ancientStore(db DatabaseReader, number uint64) zrmdb.AncientReader {
+	if ancients, ok := db.(zrmdb.AncientReader); ok && number < ancients.Ancients() {
+		return ancients
+	}
+	return nil
+}
+
+// getAncient retrieves a block's data of the given kind from the ancient store
+// attached to a database, or nil if the block wasn't frozen. As only canonical
+// blocks are ever frozen, the hash is checked against the frozen one.
+func getAncient(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	ancients := ancientStore(db, number)
+	if ancients == nil {
+		return nil
+	}
+	if frozen, _ := ancients.Ancient(zrmdb.FreezerHashTable, number); common.BytesToHash(frozen) != hash {
+		return nil
+	}
+	data, _ := ancients.Ancient(kind, number)
+	return data
+}
+
 // GetCanonicalHash retrieves a hash assigned to a canonical block number.
 func GetCanonicalHash(db DatabaseReader, number uint64) common.Hash {
+	if ancients := ancientStore(db, number); ancients != nil {
+		data, _ := ancients.Ancient(zrmdb.FreezerHashTable, number)
+		return common.BytesToHash(data)
+	}
 	data, _ := db.Get(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
 	if len(data) == 0 {
 		return common.Hash{}
@@ -98,6 +126,23 @@ func GetCanonicalHash(db DatabaseReader, number uint64) common.Hash {
 	return common.BytesToHash(data)
 }
 
+// GetAllHashes retrieves the hashes of all the block headers, canonical or not,
+// stored in the key-value store under the given number.
+func GetAllHashes(db zrmdb.Iteratee, number uint64) []common.Hash {
+	prefix := append(append([]byte{}, headerPrefix...), encodeBlockNumber(number)...)
+
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	var hashes []common.Hash
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(prefix):]))
+		}
+	}
+	return hashes
+}
+
 // missingNumber is returned by GetBlockNumber if no header with the
 // given block hash has been stored in the database
 const missingNumber = uint64(0xffffffffffffffff)
@@ -149,10 +194,22 @@ func GetHeadFastBlockHash(db DatabaseReader) common.Hash {
 // GetHeaderRLP retrieves a block header in its raw RLP database encoding, or nil
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if data := getAncient(db, zrmdb.FreezerHeaderTable, hash, number); len(data) > 0 {
+		return data
+	}
 	data, _ := db.Get(headerKey(hash, number))
 	return data
 }
 
+// HasHeader verifies the existence of a block header corresponding to the hash.
+func HasHeader(db zrmdb.Database, hash common.Hash, number uint64) bool {
+	if data := getAncient(db, zrmdb.FreezerHashTable, hash, number); len(data) > 0 {
+		return true
+	}
+	ok, _ := db.Has(headerKey(hash, number))
+	return ok
+}
+
 // GetHeader retrieves the block header corresponding to the hash, nil if none
 // found.
 func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header {
@@ -170,10 +227,22 @@ func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header
 
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if data := getAncient(db, zrmdb.FreezerBodiesTable, hash, number); len(data) > 0 {
+		return data
+	}
 	data, _ := db.Get(blockBodyKey(hash, number))
 	return data
 }
 
+// HasBody verifies the existence of a block body corresponding to the hash.
+func HasBody(db zrmdb.Database, hash common.Hash, number uint64) bool {
+	if data := getAncient(db, zrmdb.FreezerHashTable, hash, number); len(data) > 0 {
+		return true
+	}
+	ok, _ := db.Has(blockBodyKey(hash, number))
+	return ok
+}
+
 func headerKey(hash common.Hash, number uint64) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
@@ -200,7 +269,7 @@ func GetBody(db DatabaseReader, hash common.Hash, number uint64) *types.Body {
 // GetTd retrieves a block's total difficulty corresponding to the hash, nil if
 // none found.
 func GetTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	data := getTdRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -212,6 +281,15 @@ func GetTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
 	return td
 }
 
+// getTdRLP retrieves a block's total difficulty in its raw RLP database encoding.
+func getTdRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if data := getAncient(db, zrmdb.FreezerDifficultyTable, hash, number); len(data) > 0 {
+		return data
+	}
+	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	return data
+}
+
 // GetBlock retrieves an entire block corresponding to the hash, assembling it
 // back from the stored header and body. If either the header or body could not
 // be retrieved nil is returned.
@@ -235,7 +313,7 @@ func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
-	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	data := getBlockReceiptsRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -251,6 +329,16 @@ func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.
 	return receipts
 }
 
+// getBlockReceiptsRLP retrieves the receipts of a block in their raw RLP storage
+// encoding.
+func getBlockReceiptsRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	if data := getAncient(db, zrmdb.FreezerReceiptTable, hash, number); len(data) > 0 {
+		return data
+	}
+	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	return data
+}
+
 // GetTxLookupEntry retrieves the positional metadata associated with a transaction
 // hash to allow retrieving the transaction or receipt by hash.
 func GetTxLookupEntry(db DatabaseReader, hash common.Hash) (common.Hash, uint64, uint64) {
@@ -527,6 +615,16 @@ func DeleteBlockReceipts(db DatabaseDeleter, hash common.Hash, number uint64) {
 	db.Delete(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
 }
 
+// deleteFrozenBlock removes all the data of a canonical block that was moved
+// into the ancient store, keeping only its hash to number mapping.
+func deleteFrozenBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
+	DeleteCanonicalHash(db, number)
+	db.Delete(append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
+	DeleteBody(db, hash, number)
+	DeleteTd(db, hash, number)
+	DeleteBlockReceipts(db, hash, number)
+}
+
 // DeleteTxLookupEntry removes all transaction data associated with a hash.
 func DeleteTxLookupEntry(db DatabaseDeleter, hash common.Hash) {
 	db.Delete(append(lookupPrefix, hash.Bytes()...))
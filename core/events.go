@@ -22,7 +22,60 @@ import (
 )
 
 // TxPreEvent is posted when a transaction enters the transaction pool.
-type TxPreEvent struct{ Tx *types.Transaction }
+type TxPreEvent struct {
+	Tx      *types.Transaction
+	Private bool // Whether the transaction must not be announced to the network
+}
+
+// TxDropReason describes why a transaction was dropped from the transaction pool.
+type TxDropReason string
+
+const (
+	// TxDropUnderpriced is used for transactions evicted from a full pool by
+	// better priced ones, or falling below the pool's minimum gas price.
+	TxDropUnderpriced TxDropReason = "underpriced"
+
+	// TxDropReplaced is used for transactions replaced by another one with the
+	// same nonce and a sufficiently higher gas price.
+	TxDropReplaced TxDropReason = "replaced"
+
+	// TxDropIncluded is used for transactions included in the chain the pool
+	// was reset to.
+	TxDropIncluded TxDropReason = "included"
+
+	// TxDropNonceTooLow is used for transactions whose nonce was already used up
+	// on chain by a competing transaction (e.g. after a reorg).
+	TxDropNonceTooLow TxDropReason = "nonceTooLow"
+
+	// TxDropStale is used for transactions whose nonce was already used up on
+	// chain, when the pool can't tell whether by the transaction itself or by a
+	// competing one (e.g. after a reorg too deep for the pool to process).
+	TxDropStale TxDropReason = "stale"
+
+	// TxDropUnpayable is used for transactions their sender can no longer pay
+	// for, or that exceed the current block gas limit.
+	TxDropUnpayable TxDropReason = "unpayable"
+
+	// TxDropExpired is used for queued transactions of accounts inactive for
+	// longer than the pool's lifetime.
+	TxDropExpired TxDropReason = "expired"
+
+	// TxDropQueueOverflow is used for non-executable transactions exceeding the
+	// per account or global queue limits.
+	TxDropQueueOverflow TxDropReason = "queueOverflow"
+
+	// TxDropPendingOverflow is used for executable transactions evicted to keep
+	// the pending pool fair among accounts.
+	TxDropPendingOverflow TxDropReason = "pendingOverflow"
+)
+
+// TxDropEvent is posted when a transaction is removed from the transaction pool
+// other than by being promoted, along with the reason of the removal.
+type TxDropEvent struct {
+	Tx          *types.Transaction
+	Reason      TxDropReason
+	Replacement *types.Transaction // Transaction superseding the dropped one, if replaced
+}
 
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
@@ -21,8 +21,9 @@ import (
 	"sync"
 
 	"github.com/apolo-technologies/zerium/common"
-	"github.com/apolo-technologies/zerium/zrmdb"
+	"github.com/apolo-technologies/zerium/rlp"
 	"github.com/apolo-technologies/zerium/trie"
+	"github.com/apolo-technologies/zerium/zrmdb"
 	lru "github.com/hashicorp/golang-lru"
 )
 
@@ -50,6 +51,9 @@ type Database interface {
 	ContractCodeSize(addrHash, codeHash common.Hash) (int, error)
 	// CopyTrie returns an independent copy of the given trie.
 	CopyTrie(Trie) Trie
+	// TrieDB retrieves the in-memory trie node database committed tries are
+	// cached and garbage collected in, or nil if not supported.
+	TrieDB() *trie.NodeDatabase
 }
 
 // Trie is a Zerium Merkle Trie.
@@ -61,17 +65,33 @@ type Trie interface {
 	Hash() common.Hash
 	NodeIterator(startKey []byte) trie.NodeIterator
 	GetKey([]byte) []byte // TODO(fjl): remove this when SecureTrie is removed
+	Prove(key []byte, fromLevel uint, proofDb trie.DatabaseWriter) error
 }
 
 // NewDatabase creates a backing store for state. The returned database is safe for
 // concurrent use and retains cached trie nodes in memory.
 func NewDatabase(db zrmdb.Database) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
-	return &cachingDB{db: db, codeSizeCache: csc}
+	return &cachingDB{
+		db:            db,
+		triedb:        trie.NewNodeDatabase(db, storageTrieRoots),
+		codeSizeCache: csc,
+	}
+}
+
+// storageTrieRoots resolves the storage trie referenced by an account trie leaf,
+// keeping storage tries alive as long as the accounts owning them.
+func storageTrieRoots(leaf []byte) []common.Hash {
+	var account Account
+	if err := rlp.DecodeBytes(leaf, &account); err != nil {
+		return nil
+	}
+	return []common.Hash{account.Root}
 }
 
 type cachingDB struct {
 	db            zrmdb.Database
+	triedb        *trie.NodeDatabase
 	mu            sync.Mutex
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
@@ -86,7 +106,7 @@ func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
 			return cachedTrie{db.pastTries[i].Copy(), db}, nil
 		}
 	}
-	tr, err := trie.NewSecure(root, db.db, MaxTrieCacheGen)
+	tr, err := trie.NewSecure(root, db.triedb, MaxTrieCacheGen)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +126,7 @@ func (db *cachingDB) pushTrie(t *trie.SecureTrie) {
 }
 
 func (db *cachingDB) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
-	return trie.NewSecure(root, db.db, 0)
+	return trie.NewSecure(root, db.triedb, 0)
 }
 
 func (db *cachingDB) CopyTrie(t Trie) Trie {
@@ -120,6 +140,12 @@ func (db *cachingDB) CopyTrie(t Trie) Trie {
 	}
 }
 
+// TrieDB retrieves the in-memory trie node database committed tries are cached
+// and garbage collected in.
+func (db *cachingDB) TrieDB() *trie.NodeDatabase {
+	return db.triedb
+}
+
 func (db *cachingDB) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
 	code, err := db.db.Get(codeHash[:])
 	if err == nil {
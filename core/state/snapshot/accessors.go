@@ -0,0 +1,125 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+var (
+	snapshotRootKey = []byte("SnapshotRoot") // snapshotRootKey tracks the state root of the persisted snapshot
+
+	accountPrefix = []byte("a") // accountPrefix + account hash -> account trie leaf
+	storagePrefix = []byte("o") // storagePrefix + account hash + slot hash -> storage trie leaf
+)
+
+const (
+	accountKeyLength = 1 + common.HashLength
+	storageKeyLength = 1 + 2*common.HashLength
+)
+
+// accountKey = accountPrefix + hash
+func accountKey(hash common.Hash) []byte {
+	return append(append([]byte{}, accountPrefix...), hash[:]...)
+}
+
+// storageKey = storagePrefix + account hash + slot hash
+func storageKey(accountHash, storageHash common.Hash) []byte {
+	key := make([]byte, 0, storageKeyLength)
+	key = append(append(key, storagePrefix...), accountHash[:]...)
+	return append(key, storageHash[:]...)
+}
+
+// ReadSnapshotRoot retrieves the state root of the persisted snapshot, or an
+// empty hash if there is none.
+func ReadSnapshotRoot(db zrmdb.Database) common.Hash {
+	data, _ := db.Get(snapshotRootKey)
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// writeSnapshotRoot stores the state root of the persisted snapshot.
+func writeSnapshotRoot(db zrmdb.Putter, root common.Hash) {
+	if err := db.Put(snapshotRootKey, root[:]); err != nil {
+		log.Crit("Failed to store snapshot root", "err", err)
+	}
+}
+
+// deleteSnapshotRoot invalidates the persisted snapshot, done before modifying
+// it so that an interrupted update doesn't leave a corrupted snapshot behind.
+func deleteSnapshotRoot(db zrmdb.Deleter) {
+	if err := db.Delete(snapshotRootKey); err != nil {
+		log.Crit("Failed to remove snapshot root", "err", err)
+	}
+}
+
+// wipeStorage deletes all the persisted storage slots of an account, invoking
+// the given callback with the database key of every deleted slot.
+func wipeStorage(db zrmdb.Database, batch zrmdb.Deleter, accountHash common.Hash, onDelete func(key []byte)) error {
+	it := db.NewIteratorWithPrefix(append(append([]byte{}, storagePrefix...), accountHash[:]...))
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == storageKeyLength {
+			key = common.CopyBytes(key)
+			if err := batch.Delete(key); err != nil {
+				return err
+			}
+			onDelete(key)
+		}
+	}
+	return it.Error()
+}
+
+// wipeSnapshot deletes all the persisted snapshot data from the database. Only
+// keys of the exact snapshot entry lengths are deleted as the prefixes are not
+// exclusive to the snapshot (e.g. trie node hashes).
+func wipeSnapshot(db zrmdb.Database) error {
+	deleteSnapshotRoot(db)
+
+	for _, wipe := range []struct {
+		prefix []byte
+		length int
+	}{{accountPrefix, accountKeyLength}, {storagePrefix, storageKeyLength}} {
+		batch := db.NewBatch()
+		it := db.NewIteratorWithPrefix(wipe.prefix)
+		for it.Next() {
+			if key := it.Key(); len(key) == wipe.length {
+				batch.Delete(common.CopyBytes(key))
+				if batch.ValueSize() >= zrmdb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						it.Release()
+						return err
+					}
+					batch.Reset()
+				}
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
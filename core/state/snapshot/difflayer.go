@@ -0,0 +1,217 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// diffLayer is the state transition of a block on top of its parent snapshot
+// layer, kept in memory until it's flattened into the disk layer. The content
+// of a diff layer is immutable, only its parent changes when the layers below
+// get flattened.
+type diffLayer struct {
+	parent snapshot    // Parent snapshot modified by this one, never nil
+	root   common.Hash // Root hash of the state the snapshot represents
+	stale  bool        // Signals that the layer was flattened into the disk layer
+
+	destructs map[common.Hash]struct{}               // Accounts deleted or recreated, wiping their storage
+	accounts  map[common.Hash][]byte                 // Changed accounts, nil meaning deleted
+	storage   map[common.Hash]map[common.Hash][]byte // Changed storage slots, nil meaning deleted
+
+	lock sync.RWMutex
+}
+
+// newDiffLayer creates a new diff layer on top of an existing snapshot.
+func newDiffLayer(parent snapshot, root common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer {
+	return &diffLayer{
+		parent:    parent,
+		root:      root,
+		destructs: destructs,
+		accounts:  accounts,
+		storage:   storage,
+	}
+}
+
+// Root returns the root hash of the state the snapshot represents.
+func (dl *diffLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent returns the subsequent layer of the diff layer.
+func (dl *diffLayer) Parent() snapshot {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.parent
+}
+
+// Stale returns whether the layer was flattened into the disk layer.
+func (dl *diffLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// markStale flags the layer flattened, failing any further data accesses.
+func (dl *diffLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// AccountRLP retrieves the RLP encoded account trie leaf of an account hash,
+// falling back to the parent layers if the account wasn't changed by this one.
+func (dl *diffLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.stale {
+		dl.lock.RUnlock()
+		return nil, ErrSnapshotStale
+	}
+	if data, ok := dl.accounts[hash]; ok {
+		dl.lock.RUnlock()
+		return data, nil
+	}
+	if _, ok := dl.destructs[hash]; ok {
+		dl.lock.RUnlock()
+		return nil, nil
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.AccountRLP(hash)
+}
+
+// Storage retrieves the RLP encoded storage value of an account storage slot,
+// falling back to the parent layers if the slot wasn't changed by this one.
+func (dl *diffLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.stale {
+		dl.lock.RUnlock()
+		return nil, ErrSnapshotStale
+	}
+	if data, ok := dl.storage[accountHash][storageHash]; ok {
+		dl.lock.RUnlock()
+		return data, nil
+	}
+	if _, ok := dl.destructs[accountHash]; ok {
+		dl.lock.RUnlock()
+		return nil, nil
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.Storage(accountHash, storageHash)
+}
+
+// flatten merges the diff layer and all the diff layers below it into the disk
+// layer, returning the new disk layer representing the state of this layer.
+// All the merged layers are marked stale. The caller must hold the tree lock.
+func (dl *diffLayer) flatten(diskdb zrmdb.Database) (*diskLayer, error) {
+	// Collect the layers to merge, oldest first
+	var (
+		diffs []*diffLayer
+		base  *diskLayer
+	)
+	for layer := snapshot(dl); base == nil; {
+		switch l := layer.(type) {
+		case *diffLayer:
+			diffs = append([]*diffLayer{l}, diffs...)
+			layer = l.parent
+		case *diskLayer:
+			base = l
+		}
+	}
+	// Merge the diffs into a single one, a destruct superseding all the earlier
+	// changes of an account
+	var (
+		destructs = make(map[common.Hash]struct{})
+		accounts  = make(map[common.Hash][]byte)
+		storage   = make(map[common.Hash]map[common.Hash][]byte)
+	)
+	for _, diff := range diffs {
+		for hash := range diff.destructs {
+			destructs[hash] = struct{}{}
+			delete(accounts, hash)
+			delete(storage, hash)
+		}
+		for hash, data := range diff.accounts {
+			accounts[hash] = data
+		}
+		for hash, slots := range diff.storage {
+			merged := storage[hash]
+			if merged == nil {
+				merged = make(map[common.Hash][]byte, len(slots))
+				storage[hash] = merged
+			}
+			for slot, data := range slots {
+				merged[slot] = data
+			}
+		}
+	}
+	// Invalidate the old disk layer before touching the database, so no reads
+	// mix the old and the new state
+	base.markStale()
+	for _, diff := range diffs {
+		diff.markStale()
+	}
+	deleteSnapshotRoot(diskdb)
+
+	batch := diskdb.NewBatch()
+	for hash := range destructs {
+		key := accountKey(hash)
+		batch.Delete(key)
+		base.cache.Add(string(key), []byte(nil))
+
+		err := wipeStorage(diskdb, batch, hash, func(key []byte) {
+			base.cache.Add(string(key), []byte(nil))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for hash, data := range accounts {
+		key := accountKey(hash)
+		if len(data) == 0 {
+			batch.Delete(key)
+		} else {
+			batch.Put(key, data)
+		}
+		base.cache.Add(string(key), data)
+	}
+	for accountHash, slots := range storage {
+		for storageHash, data := range slots {
+			key := storageKey(accountHash, storageHash)
+			if len(data) == 0 {
+				batch.Delete(key)
+			} else {
+				batch.Put(key, data)
+			}
+			base.cache.Add(string(key), data)
+		}
+	}
+	writeSnapshotRoot(batch, dl.root)
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	return &diskLayer{diskdb: diskdb, cache: base.cache, root: dl.root}, nil
+}
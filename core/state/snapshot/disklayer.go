@@ -0,0 +1,115 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/zrmdb"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Number of recently accessed snapshot entries to keep in memory.
+const diskCacheItems = 256 * 1024
+
+// diskLayer is the snapshot persisted in the database, fronted by a cache of
+// the recently accessed entries.
+type diskLayer struct {
+	diskdb zrmdb.Database // Database storing the snapshot entries
+	cache  *lru.Cache     // Cache of the recently accessed entries, keyed by database key
+	root   common.Hash    // Root hash of the state the snapshot represents
+	stale  bool           // Signals that the layer was superseded by a newer disk layer
+
+	generating bool // Signals that the snapshot data is still being generated
+
+	lock sync.RWMutex
+}
+
+// newDiskLayer creates the layer of the snapshot persisted in the database.
+func newDiskLayer(diskdb zrmdb.Database, root common.Hash) *diskLayer {
+	cache, _ := lru.New(diskCacheItems)
+	return &diskLayer{
+		diskdb: diskdb,
+		cache:  cache,
+		root:   root,
+	}
+}
+
+// Root returns the root hash of the state the snapshot represents.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent always returns nil as there's no layer below the disk layer.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale returns whether the layer was superseded by a newer disk layer.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// markStale flags the layer superseded, failing any further data accesses.
+func (dl *diskLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// isGenerating returns whether the snapshot data is still being generated.
+func (dl *diskLayer) isGenerating() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.generating
+}
+
+// AccountRLP retrieves the RLP encoded account trie leaf of an account hash.
+func (dl *diskLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	return dl.get(accountKey(hash))
+}
+
+// Storage retrieves the RLP encoded storage value of an account storage slot.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	return dl.get(storageKey(accountHash, storageHash))
+}
+
+// get retrieves a snapshot entry from the cache or the database, caching the
+// absence of entries too.
+func (dl *diskLayer) get(key []byte) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if dl.generating {
+		return nil, ErrSnapshotGenerating
+	}
+	if blob, ok := dl.cache.Get(string(key)); ok {
+		return blob.([]byte), nil
+	}
+	blob, _ := dl.diskdb.Get(key)
+	dl.cache.Add(string(key), blob)
+	return blob, nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/rlp"
+	"github.com/apolo-technologies/zerium/trie"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// account is the consensus representation of accounts, duplicated from the
+// state package to access the storage roots of the accounts.
+type account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// Generate regenerates the persisted snapshot from the state trie of the given
+// root, replacing any previously persisted snapshot. It iterates the entire
+// state, so it may take a long time on large databases.
+func Generate(diskdb zrmdb.Database, triedb trie.Database, root common.Hash) error {
+	return generate(diskdb, triedb, root, nil)
+}
+
+// generate regenerates the persisted snapshot from the state trie of the given
+// root, bailing out if the abort channel is closed.
+func generate(diskdb zrmdb.Database, triedb trie.Database, root common.Hash, abort <-chan struct{}) error {
+	log.Info("Generating state snapshot", "root", root)
+
+	if err := wipeSnapshot(diskdb); err != nil {
+		return err
+	}
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	var (
+		batch  = diskdb.NewBatch()
+		start  = time.Now()
+		logged = time.Now()
+
+		accounts, slots int
+	)
+	// flush writes out the batch if it's full, or unconditionally if forced
+	flush := func(force bool) error {
+		if !force && batch.ValueSize() < zrmdb.IdealBatchSize {
+			return nil
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+		return nil
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(nil))
+	for accIt.Next() {
+		select {
+		case <-abort:
+			return errSnapshotAborted
+		default:
+		}
+		accountHash := common.BytesToHash(accIt.Key)
+		batch.Put(accountKey(accountHash), common.CopyBytes(accIt.Value))
+		accounts++
+
+		var acc account
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			return err
+		}
+		if acc.Root != emptyRoot {
+			storeTrie, err := trie.New(acc.Root, triedb)
+			if err != nil {
+				return err
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+			for storeIt.Next() {
+				batch.Put(storageKey(accountHash, common.BytesToHash(storeIt.Key)), common.CopyBytes(storeIt.Value))
+				slots++
+
+				if err := flush(false); err != nil {
+					return err
+				}
+			}
+			if storeIt.Err != nil {
+				return storeIt.Err
+			}
+		}
+		if err := flush(false); err != nil {
+			return err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Generating state snapshot", "at", accountHash, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if accIt.Err != nil {
+		return accIt.Err
+	}
+	writeSnapshotRoot(batch, root)
+	if err := flush(true); err != nil {
+		return err
+	}
+	log.Info("Generated state snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// Rebuild regenerates the persisted snapshot from the state trie of the given
+// root and resets the tree onto it, dropping all the in-memory layers.
+func (t *Tree) Rebuild(triedb trie.Database, root common.Hash) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if disk := t.disk(); disk != nil && disk.isGenerating() {
+		return ErrSnapshotGenerating
+	}
+	for _, layer := range t.layers {
+		layer.markStale()
+	}
+	t.layers = make(map[common.Hash]snapshot)
+
+	if err := Generate(t.diskdb, triedb, root); err != nil {
+		return err
+	}
+	t.layers[root] = newDiskLayer(t.diskdb, root)
+	return nil
+}
+
+// Regenerate resets the tree onto the snapshot of the given root, dropping all
+// the in-memory layers, and regenerates the persisted snapshot from the state
+// trie in the background, delivering the result on the returned channel. Until
+// the generation finishes, diff layers can be added on top of the new disk
+// layer, but data accesses falling through to it fail and the diffs are kept in
+// memory instead of being flattened.
+//
+// If the generation fails or is aborted by closing the abort channel, the tree
+// is emptied, the snapshot being unavailable until rebuilt.
+func (t *Tree) Regenerate(triedb trie.Database, root common.Hash, abort <-chan struct{}) <-chan error {
+	result := make(chan error, 1)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if disk := t.disk(); disk != nil && disk.isGenerating() {
+		result <- ErrSnapshotGenerating
+		return result
+	}
+	for _, layer := range t.layers {
+		layer.markStale()
+	}
+	base := newDiskLayer(t.diskdb, root)
+	base.generating = true
+	t.layers = map[common.Hash]snapshot{root: base}
+
+	go func() {
+		err := generate(t.diskdb, triedb, root, abort)
+
+		t.lock.Lock()
+		if err != nil {
+			for _, layer := range t.layers {
+				layer.markStale()
+			}
+			t.layers = make(map[common.Hash]snapshot)
+		} else {
+			base.lock.Lock()
+			base.generating = false
+			base.lock.Unlock()
+		}
+		t.lock.Unlock()
+
+		result <- err
+	}()
+	return result
+}
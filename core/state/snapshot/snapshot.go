@@ -0,0 +1,212 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat key-value view of the Zerium state.
+//
+// The snapshot of a state root maps account hashes to their RLP encoded trie
+// leaves and account/slot hash pairs to their RLP encoded storage values, so
+// that state reads need a single database lookup instead of a trie walk. A
+// single snapshot is persisted to disk, the states of the recent blocks on top
+// of it being tracked as in-memory diff layers, which are flattened into the
+// disk layer as they fall out of the reorg window.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been flattened into the disk layer and is not usable any more.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrSnapshotGenerating is returned from data accessors if the persisted
+	// snapshot data is still being generated, so it's not usable yet.
+	ErrSnapshotGenerating = errors.New("snapshot generating")
+
+	// errSnapshotAborted is returned if the snapshot generation was aborted.
+	errSnapshotAborted = errors.New("snapshot generation aborted")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+type Snapshot interface {
+	// Root returns the root hash of the state the snapshot represents.
+	Root() common.Hash
+
+	// AccountRLP directly retrieves the RLP encoded account trie leaf associated
+	// with a particular account hash, or nil if the account doesn't exist.
+	AccountRLP(hash common.Hash) ([]byte, error)
+
+	// Storage directly retrieves the RLP encoded storage value associated with a
+	// particular account hash and storage slot hash, or nil if the slot is empty.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports
+// walking down the layers to the persisted one.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of the snapshot, or nil for the disk
+	// layer.
+	Parent() snapshot
+
+	// Stale returns whether the layer had been flattened into the disk layer.
+	Stale() bool
+
+	// markStale flags the layer unusable, failing any further data accesses.
+	markStale()
+}
+
+// Tree is an in-memory tree of snapshot layers, the root of which is the state
+// persisted to disk and each other layer being the diff of a block on top of
+// its parent layer. Only the state transitions of recent blocks are kept in
+// memory, the older ones being flattened into the disk layer by Cap.
+type Tree struct {
+	diskdb zrmdb.Database           // Persistent database storing the disk layer
+	layers map[common.Hash]snapshot // Collection of all known layers, keyed by root
+
+	lock sync.RWMutex
+}
+
+// New loads the snapshot persisted in the given database. If there's none, the
+// tree is empty and won't track any state until a snapshot is generated.
+func New(diskdb zrmdb.Database) *Tree {
+	snaps := &Tree{
+		diskdb: diskdb,
+		layers: make(map[common.Hash]snapshot),
+	}
+	if root := ReadSnapshotRoot(diskdb); root != (common.Hash{}) {
+		snaps.layers[root] = newDiskLayer(diskdb, root)
+	}
+	return snaps
+}
+
+// Snapshot retrieves the snapshot layer of the given state root, or nil if the
+// state is not tracked by the tree.
+func (t *Tree) Snapshot(root common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if layer, ok := t.layers[root]; ok {
+		return layer
+	}
+	return nil
+}
+
+// disk returns the disk layer of the tree, or nil if the tree is empty. The
+// caller must hold the tree lock.
+func (t *Tree) disk() *diskLayer {
+	for _, layer := range t.layers {
+		if disk, ok := layer.(*diskLayer); ok {
+			return disk
+		}
+	}
+	return nil
+}
+
+// Update adds a new diff layer on top of the snapshot of the parent state. The
+// destructed accounts have all their previous storage wiped before applying
+// the account and storage changes of the same layer. Nil values denote deleted
+// accounts and storage slots.
+func (t *Tree) Update(root, parent common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	if root == parent {
+		return errSnapshotCycle
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.layers[root]; ok {
+		return nil
+	}
+	base, ok := t.layers[parent]
+	if !ok {
+		return fmt.Errorf("parent snapshot [%x…] missing", parent[:4])
+	}
+	t.layers[root] = newDiffLayer(base, root, destructs, accounts, storage)
+	return nil
+}
+
+// Cap flattens all but the given number of diff layers below the snapshot of
+// the given root into the disk layer, dropping any layers on side chains that
+// fork off below the new disk layer. A zero limit flattens the given layer too,
+// making it the new disk layer.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	layer, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("snapshot [%x…] missing", root[:4])
+	}
+	diff, ok := layer.(*diffLayer)
+	if !ok {
+		return nil // Already persisted, nothing to flatten
+	}
+	// Keep all the diffs in memory while the disk layer is being generated, as
+	// the generator would overwrite any flattened data
+	if t.disk().isGenerating() {
+		return nil
+	}
+	// Find the topmost layer to retain and the bottom diff layer to flatten
+	var keep *diffLayer
+	if layers > 0 {
+		keep = diff
+		for i := 1; i < layers; i++ {
+			parent, ok := keep.parent.(*diffLayer)
+			if !ok {
+				return nil // Less diffs than the retention limit
+			}
+			keep = parent
+		}
+		if diff, ok = keep.parent.(*diffLayer); !ok {
+			return nil
+		}
+	}
+	base, err := diff.flatten(t.diskdb)
+	if err != nil {
+		return err
+	}
+	if keep != nil {
+		keep.lock.Lock()
+		keep.parent = base
+		keep.lock.Unlock()
+	}
+	// Drop all the layers that got flattened or built on top of flattened ones
+	for root, layer := range t.layers {
+		for l := layer; l != nil; l = l.Parent() {
+			if l.Stale() {
+				layer.markStale()
+				delete(t.layers, root)
+				break
+			}
+		}
+	}
+	t.layers[base.root] = base
+
+	log.Debug("Flattened state snapshot", "root", base.root, "layers", len(t.layers))
+	return nil
+}
@@ -0,0 +1,301 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/rlp"
+	"github.com/apolo-technologies/zerium/trie"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// newTestTree creates a snapshot tree with a persisted disk layer at root 0x01,
+// containing two accounts with a storage slot each.
+func newTestTree() (*zrmdb.MemDatabase, *Tree) {
+	db, _ := zrmdb.NewMemDatabase()
+	db.Put(accountKey(common.Hash{0xa1}), []byte("a1"))
+	db.Put(accountKey(common.Hash{0xa2}), []byte("a2"))
+	db.Put(storageKey(common.Hash{0xa1}, common.Hash{0x01}), []byte("a1-01"))
+	db.Put(storageKey(common.Hash{0xa2}, common.Hash{0x01}), []byte("a2-01"))
+	writeSnapshotRoot(db, common.Hash{0x01})
+
+	return db, New(db)
+}
+
+// checkAccount ensures an account of a snapshot layer has the expected content.
+func checkAccount(t *testing.T, snap Snapshot, hash common.Hash, want string) {
+	if snap == nil {
+		t.Fatalf("snapshot missing")
+	}
+	have, err := snap.AccountRLP(hash)
+	if err != nil {
+		t.Fatalf("account %x in snapshot %x: failed to retrieve: %v", hash[:1], snap.Root().Bytes()[:1], err)
+	}
+	if string(have) != want {
+		t.Errorf("account %x in snapshot %x: content mismatch: have %q, want %q", hash[:1], snap.Root().Bytes()[:1], have, want)
+	}
+}
+
+// checkStorage ensures a storage slot of a snapshot layer has the expected content.
+func checkStorage(t *testing.T, snap Snapshot, account, slot common.Hash, want string) {
+	if snap == nil {
+		t.Fatalf("snapshot missing")
+	}
+	have, err := snap.Storage(account, slot)
+	if err != nil {
+		t.Fatalf("slot %x/%x in snapshot %x: failed to retrieve: %v", account[:1], slot[:1], snap.Root().Bytes()[:1], err)
+	}
+	if string(have) != want {
+		t.Errorf("slot %x/%x in snapshot %x: content mismatch: have %q, want %q", account[:1], slot[:1], snap.Root().Bytes()[:1], have, want)
+	}
+}
+
+// Tests that diff layers shadow the layers below them, destructed accounts
+// hiding all their previous storage.
+func TestDiffLayerLookups(t *testing.T) {
+	_, snaps := newTestTree()
+
+	err := snaps.Update(common.Hash{0x02}, common.Hash{0x01}, nil,
+		map[common.Hash][]byte{{0xa1}: []byte("a1'"), {0xa3}: []byte("a3")},
+		map[common.Hash]map[common.Hash][]byte{{0xa1}: {{0x02}: []byte("a1-02")}},
+	)
+	if err != nil {
+		t.Fatalf("failed to add first diff layer: %v", err)
+	}
+	err = snaps.Update(common.Hash{0x03}, common.Hash{0x02},
+		map[common.Hash]struct{}{{0xa1}: {}, {0xa2}: {}},
+		map[common.Hash][]byte{{0xa2}: []byte("a2'")},
+		map[common.Hash]map[common.Hash][]byte{{0xa2}: {{0x02}: []byte("a2-02")}},
+	)
+	if err != nil {
+		t.Fatalf("failed to add second diff layer: %v", err)
+	}
+	if err := snaps.Update(common.Hash{0x04}, common.Hash{0x05}, nil, nil, nil); err == nil {
+		t.Errorf("diff layer added on top of unknown parent")
+	}
+	disk, first, second := snaps.Snapshot(common.Hash{0x01}), snaps.Snapshot(common.Hash{0x02}), snaps.Snapshot(common.Hash{0x03})
+
+	checkAccount(t, disk, common.Hash{0xa1}, "a1")
+	checkAccount(t, disk, common.Hash{0xa3}, "")
+	checkStorage(t, disk, common.Hash{0xa1}, common.Hash{0x02}, "")
+
+	checkAccount(t, first, common.Hash{0xa1}, "a1'")
+	checkAccount(t, first, common.Hash{0xa2}, "a2")
+	checkAccount(t, first, common.Hash{0xa3}, "a3")
+	checkStorage(t, first, common.Hash{0xa1}, common.Hash{0x01}, "a1-01")
+	checkStorage(t, first, common.Hash{0xa1}, common.Hash{0x02}, "a1-02")
+
+	checkAccount(t, second, common.Hash{0xa1}, "")
+	checkAccount(t, second, common.Hash{0xa2}, "a2'")
+	checkAccount(t, second, common.Hash{0xa3}, "a3")
+	checkStorage(t, second, common.Hash{0xa1}, common.Hash{0x01}, "")
+	checkStorage(t, second, common.Hash{0xa1}, common.Hash{0x02}, "")
+	checkStorage(t, second, common.Hash{0xa2}, common.Hash{0x01}, "")
+	checkStorage(t, second, common.Hash{0xa2}, common.Hash{0x02}, "a2-02")
+}
+
+// Tests that capping the tree flattens the bottom diff layers into the disk,
+// invalidating the flattened layers and the side chains forking off them.
+func TestCapFlattening(t *testing.T) {
+	db, snaps := newTestTree()
+
+	// Build a chain of three diffs, with a side chain forking off the first one
+	snaps.Update(common.Hash{0x02}, common.Hash{0x01}, nil,
+		map[common.Hash][]byte{{0xa1}: []byte("a1'")},
+		map[common.Hash]map[common.Hash][]byte{{0xa1}: {{0x02}: []byte("a1-02")}},
+	)
+	snaps.Update(common.Hash{0x03}, common.Hash{0x02},
+		map[common.Hash]struct{}{{0xa1}: {}},
+		map[common.Hash][]byte{{0xa1}: []byte("a1''")},
+		map[common.Hash]map[common.Hash][]byte{{0xa1}: {{0x03}: []byte("a1-03")}},
+	)
+	snaps.Update(common.Hash{0x04}, common.Hash{0x03}, nil, map[common.Hash][]byte{{0xa2}: nil}, nil)
+	snaps.Update(common.Hash{0x13}, common.Hash{0x02}, nil, map[common.Hash][]byte{{0xa3}: []byte("a3")}, nil)
+
+	disk, first, side := snaps.Snapshot(common.Hash{0x01}), snaps.Snapshot(common.Hash{0x02}), snaps.Snapshot(common.Hash{0x13})
+
+	// Flatten all but the last diff, ensuring the persisted data is correct
+	if err := snaps.Cap(common.Hash{0x04}, 1); err != nil {
+		t.Fatalf("failed to cap snapshot tree: %v", err)
+	}
+	if root := ReadSnapshotRoot(db); root != (common.Hash{0x03}) {
+		t.Errorf("persisted root mismatch: have %x, want %x", root, common.Hash{0x03})
+	}
+	for _, snap := range []Snapshot{disk, first, side} {
+		if _, err := snap.AccountRLP(common.Hash{0xa1}); err != ErrSnapshotStale {
+			t.Errorf("snapshot %x: stale error mismatch: have %v, want %v", snap.Root().Bytes()[:1], err, ErrSnapshotStale)
+		}
+	}
+	for _, root := range []common.Hash{{0x01}, {0x02}, {0x13}} {
+		if snaps.Snapshot(root) != nil {
+			t.Errorf("snapshot %x: stale layer retained", root[:1])
+		}
+	}
+	base := snaps.Snapshot(common.Hash{0x03})
+	if _, ok := base.(*diskLayer); !ok {
+		t.Fatalf("flattened layer type mismatch: have %T, want %T", base, new(diskLayer))
+	}
+	checkAccount(t, base, common.Hash{0xa1}, "a1''")
+	checkStorage(t, base, common.Hash{0xa1}, common.Hash{0x01}, "")
+	checkStorage(t, base, common.Hash{0xa1}, common.Hash{0x02}, "")
+	checkStorage(t, base, common.Hash{0xa1}, common.Hash{0x03}, "a1-03")
+	checkStorage(t, base, common.Hash{0xa2}, common.Hash{0x01}, "a2-01")
+
+	checkAccount(t, snaps.Snapshot(common.Hash{0x04}), common.Hash{0xa1}, "a1''")
+	checkAccount(t, snaps.Snapshot(common.Hash{0x04}), common.Hash{0xa2}, "")
+
+	// Flatten the last diff too and ensure the tree reloads from disk
+	if err := snaps.Cap(common.Hash{0x04}, 0); err != nil {
+		t.Fatalf("failed to flatten snapshot tree: %v", err)
+	}
+	reloaded := New(db).Snapshot(common.Hash{0x04})
+	checkAccount(t, reloaded, common.Hash{0xa1}, "a1''")
+	checkAccount(t, reloaded, common.Hash{0xa2}, "")
+	checkStorage(t, reloaded, common.Hash{0xa2}, common.Hash{0x01}, "a2-01")
+}
+
+// newTestState commits a state with a few accounts, one of them having storage,
+// into the given trie database, returning its account trie.
+func newTestState(triedb trie.Database) *trie.Trie {
+	storage, _ := trie.New(common.Hash{}, triedb)
+	storage.Update(common.Hash{0x01}.Bytes(), []byte{0x01})
+	storage.Update(common.Hash{0x02}.Bytes(), []byte{0x02})
+	storageRoot, _ := storage.CommitTo(triedb)
+
+	accounts, _ := trie.New(common.Hash{}, triedb)
+	for i := byte(0); i < 10; i++ {
+		acc := account{Nonce: uint64(i), Balance: big.NewInt(int64(i)), Root: emptyRoot, CodeHash: []byte{i}}
+		if i == 5 {
+			acc.Root = storageRoot
+		}
+		blob, _ := rlp.EncodeToBytes(&acc)
+		accounts.Update(common.Hash{i}.Bytes(), blob)
+	}
+	accounts.CommitTo(triedb)
+	return accounts
+}
+
+// Tests that snapshots generated from a state trie contain all the accounts and
+// storage slots, and that stale snapshot entries are wiped.
+func TestGenerate(t *testing.T) {
+	db, _ := newTestTree()
+	triedb := trie.NewNodeDatabase(db, nil)
+
+	accounts := newTestState(triedb)
+	root := accounts.Hash()
+
+	nodeKey := append(append([]byte{}, accountPrefix...), make([]byte, common.HashLength-1)...)
+	db.Put(nodeKey, []byte("node"))
+
+	snaps := New(db)
+	if err := snaps.Rebuild(triedb, root); err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	if have := ReadSnapshotRoot(db); have != root {
+		t.Errorf("persisted root mismatch: have %x, want %x", have, root)
+	}
+	snap := snaps.Snapshot(root)
+	for i := byte(0); i < 10; i++ {
+		want, _ := accounts.TryGet(common.Hash{i}.Bytes())
+		checkAccount(t, snap, common.Hash{i}, string(want))
+	}
+	checkStorage(t, snap, common.Hash{5}, common.Hash{0x01}, "\x01")
+	checkStorage(t, snap, common.Hash{5}, common.Hash{0x02}, "\x02")
+
+	checkAccount(t, snap, common.Hash{0xa1}, "")
+	checkStorage(t, snap, common.Hash{0xa1}, common.Hash{0x01}, "")
+
+	// Ensure entries sharing the snapshot prefixes were not wiped
+	if _, err := db.Get(nodeKey); err != nil {
+		t.Errorf("non-snapshot entry wiped: %v", err)
+	}
+}
+
+// Tests that while the disk layer is being generated, data accesses falling
+// through to it fail and the diff layers on top of it are not flattened.
+func TestGeneratingLayer(t *testing.T) {
+	_, snaps := newTestTree()
+	snaps.disk().generating = true
+
+	err := snaps.Update(common.Hash{0x02}, common.Hash{0x01}, nil, map[common.Hash][]byte{{0xa1}: []byte("a1'")}, nil)
+	if err != nil {
+		t.Fatalf("failed to add diff layer: %v", err)
+	}
+	snap := snaps.Snapshot(common.Hash{0x02})
+	checkAccount(t, snap, common.Hash{0xa1}, "a1'")
+	if _, err := snap.AccountRLP(common.Hash{0xa2}); err != ErrSnapshotGenerating {
+		t.Errorf("generating disk layer access error mismatch: have %v, want %v", err, ErrSnapshotGenerating)
+	}
+	if err := snaps.Cap(common.Hash{0x02}, 0); err != nil {
+		t.Fatalf("failed to cap generating snapshot: %v", err)
+	}
+	if snaps.Snapshot(common.Hash{0x01}) == nil {
+		t.Errorf("diff layer flattened into generating disk layer")
+	}
+	if err := snaps.Rebuild(nil, common.Hash{0x03}); err != ErrSnapshotGenerating {
+		t.Errorf("concurrent rebuild error mismatch: have %v, want %v", err, ErrSnapshotGenerating)
+	}
+}
+
+// Tests that snapshots regenerated in the background can be built upon while
+// generating, and that aborted generations empty the tree.
+func TestRegenerate(t *testing.T) {
+	db, snaps := newTestTree()
+	triedb := trie.NewNodeDatabase(db, nil)
+
+	accounts := newTestState(triedb)
+	root := accounts.Hash()
+
+	done := snaps.Regenerate(triedb, root, nil)
+	if snaps.Snapshot(common.Hash{0x01}) != nil {
+		t.Errorf("old snapshot retained")
+	}
+	if err := snaps.Update(common.Hash{0x02}, root, nil, map[common.Hash][]byte{{0x01}: []byte("01'")}, nil); err != nil {
+		t.Fatalf("failed to add diff layer: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("failed to regenerate snapshot: %v", err)
+	}
+	snap := snaps.Snapshot(common.Hash{0x02})
+	checkAccount(t, snap, common.Hash{0x01}, "01'")
+
+	want, _ := accounts.TryGet(common.Hash{0x02}.Bytes())
+	checkAccount(t, snap, common.Hash{0x02}, string(want))
+
+	if err := snaps.Cap(common.Hash{0x02}, 0); err != nil {
+		t.Fatalf("failed to flatten regenerated snapshot: %v", err)
+	}
+	if have := ReadSnapshotRoot(db); have != (common.Hash{0x02}) {
+		t.Errorf("persisted root mismatch: have %x, want %x", have, common.Hash{0x02})
+	}
+	// Abort a regeneration and ensure the snapshot is gone
+	abort := make(chan struct{})
+	close(abort)
+
+	if err := <-snaps.Regenerate(triedb, root, abort); err != errSnapshotAborted {
+		t.Fatalf("aborted regeneration error mismatch: have %v, want %v", err, errSnapshotAborted)
+	}
+	if snaps.Snapshot(root) != nil || snaps.Snapshot(common.Hash{0x02}) != nil {
+		t.Errorf("snapshot available after aborted regeneration")
+	}
+	if have := ReadSnapshotRoot(db); have != (common.Hash{}) {
+		t.Errorf("persisted root after aborted regeneration: have %x", have)
+	}
+}
@@ -18,12 +18,14 @@
 package state
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"sort"
 	"sync"
 
 	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/state/snapshot"
 	"github.com/apolo-technologies/zerium/core/types"
 	"github.com/apolo-technologies/zerium/crypto"
 	"github.com/apolo-technologies/zerium/log"
@@ -45,6 +47,14 @@ type StateDB struct {
 	db   Database
 	trie Trie
 
+	// Flat state snapshot to serve reads from instead of the tries if available,
+	// along with the changes to apply on top of it on commit.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
@@ -77,19 +87,43 @@ type StateDB struct {
 
 // Create a new state from a given trie
 func New(root common.Hash, db Database) (*StateDB, error) {
+	return NewWithSnapshot(root, db, nil)
+}
+
+// NewWithSnapshot creates a new state from a given trie, reading accounts and
+// storage from the flat state snapshot of the root if the tree tracks it. The
+// state changes are added to the tree as a new snapshot layer on commit.
+func NewWithSnapshot(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error) {
 	tr, err := db.OpenTrie(root)
 	if err != nil {
 		return nil, err
 	}
-	return &StateDB{
+	state := &StateDB{
 		db:                db,
 		trie:              tr,
+		snaps:             snaps,
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		refund:            new(big.Int),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
-	}, nil
+	}
+	state.openSnapshot(root)
+	return state, nil
+}
+
+// openSnapshot retrieves the snapshot layer of the given root, if there is one,
+// and resets the pending snapshot changes.
+func (self *StateDB) openSnapshot(root common.Hash) {
+	self.snap, self.snapDestructs, self.snapAccounts, self.snapStorage = nil, nil, nil, nil
+	if self.snaps == nil {
+		return
+	}
+	if self.snap = self.snaps.Snapshot(root); self.snap != nil {
+		self.snapDestructs = make(map[common.Hash]struct{})
+		self.snapAccounts = make(map[common.Hash][]byte)
+		self.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
 }
 
 // setError remembers the first non-nil error it is called with.
@@ -111,6 +145,7 @@ func (self *StateDB) Reset(root common.Hash) error {
 		return err
 	}
 	self.trie = tr
+	self.openSnapshot(root)
 	self.stateObjects = make(map[common.Address]*stateObject)
 	self.stateObjectsDirty = make(map[common.Address]struct{})
 	self.thash = common.Hash{}
@@ -236,6 +271,34 @@ func (self *StateDB) GetState(a common.Address, b common.Hash) common.Hash {
 	return common.Hash{}
 }
 
+// GetProof returns the Merkle proof of an account in the state trie, the nodes
+// being ordered from the root down.
+func (self *StateDB) GetProof(a common.Address) ([][]byte, error) {
+	var proof proofList
+	err := self.trie.Prove(a[:], 0, &proof)
+	return proof, err
+}
+
+// GetStorageProof returns the Merkle proof of a storage slot in the storage trie
+// of an account. The proof is empty for non-existent accounts.
+func (self *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byte, error) {
+	var proof proofList
+	trie := self.StorageTrie(a)
+	if trie == nil {
+		return proof, nil
+	}
+	err := trie.Prove(key[:], 0, &proof)
+	return proof, err
+}
+
+// proofList collects the encoded nodes of a Merkle proof in insertion order.
+type proofList [][]byte
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, common.CopyBytes(value))
+	return nil
+}
+
 // StorageTrie returns the storage trie of an account.
 // The return value is a copy and is nil for non-existent accounts.
 func (self *StateDB) StorageTrie(a common.Address) Trie {
@@ -343,6 +406,34 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	if self.snap != nil {
+		self.snapDestructs[stateObject.addrHash] = struct{}{}
+		delete(self.snapAccounts, stateObject.addrHash)
+		delete(self.snapStorage, stateObject.addrHash)
+	}
+}
+
+// updateSnapStorage records the pending storage changes of the given object to
+// be applied to the snapshot on commit.
+func (self *StateDB) updateSnapStorage(stateObject *stateObject) {
+	if self.snap == nil || len(stateObject.dirtyStorage) == 0 {
+		return
+	}
+	storage := self.snapStorage[stateObject.addrHash]
+	if storage == nil {
+		storage = make(map[common.Hash][]byte, len(stateObject.dirtyStorage))
+		self.snapStorage[stateObject.addrHash] = storage
+	}
+	for key, value := range stateObject.dirtyStorage {
+		hash := crypto.Keccak256Hash(key[:])
+		if (value == common.Hash{}) {
+			storage[hash] = nil
+			continue
+		}
+		// Encoding []byte cannot fail, ok to ignore the error.
+		storage[hash], _ = rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
+	}
 }
 
 // Retrieve a state object given my the address. Returns nil if not found.
@@ -355,8 +446,17 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot if available, from the trie otherwise.
+	var (
+		enc []byte
+		err error
+	)
+	if self.snap != nil {
+		enc, err = self.snap.AccountRLP(crypto.Keccak256Hash(addr[:]))
+	}
+	if self.snap == nil || err != nil {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -400,7 +500,13 @@ func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObjec
 	if prev == nil {
 		self.journal = append(self.journal, createObjectChange{account: &addr})
 	} else {
-		self.journal = append(self.journal, resetObjectChange{prev: prev})
+		// The storage of the previous account must not leak into the snapshot
+		var prevdestruct bool
+		if self.snap != nil {
+			_, prevdestruct = self.snapDestructs[prev.addrHash]
+			self.snapDestructs[prev.addrHash] = struct{}{}
+		}
+		self.journal = append(self.journal, resetObjectChange{prev: prev, prevdestruct: prevdestruct})
 	}
 	self.setStateObject(newobj)
 	return newobj, prev
@@ -453,7 +559,9 @@ func (self *StateDB) Copy() *StateDB {
 	// Copy all the basic fields, initialize the memory ones
 	state := &StateDB{
 		db:                self.db,
-		trie:              self.trie,
+		trie:              self.db.CopyTrie(self.trie),
+		snaps:             self.snaps,
+		snap:              self.snap,
 		stateObjects:      make(map[common.Address]*stateObject, len(self.stateObjectsDirty)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.stateObjectsDirty)),
 		refund:            new(big.Int).Set(self.refund),
@@ -473,6 +581,24 @@ func (self *StateDB) Copy() *StateDB {
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	if self.snap != nil {
+		state.snapDestructs = make(map[common.Hash]struct{}, len(self.snapDestructs))
+		for hash := range self.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(self.snapAccounts))
+		for hash, data := range self.snapAccounts {
+			state.snapAccounts[hash] = data
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(self.snapStorage))
+		for hash, slots := range self.snapStorage {
+			storage := make(map[common.Hash][]byte, len(slots))
+			for slot, data := range slots {
+				storage[slot] = data
+			}
+			state.snapStorage[hash] = storage
+		}
+	}
 	return state
 }
 
@@ -520,6 +646,7 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 		if stateObject.suicided || (deleteEmptyObjects && stateObject.empty()) {
 			s.deleteStateObject(stateObject)
 		} else {
+			s.updateSnapStorage(stateObject)
 			stateObject.updateRoot(s.db)
 			s.updateStateObject(stateObject)
 		}
@@ -573,6 +700,23 @@ func (s *StateDB) clearJournalAndRefund() {
 
 // CommitTo writes the state to the given database.
 func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (root common.Hash, err error) {
+	return s.commit(dbw, dbw, deleteEmptyObjects)
+}
+
+// Commit writes the state tries into the trie node database of the underlying
+// state database, where they are cached and garbage collected until flushed to
+// disk. Contract code is never garbage collected, so it's written to the given
+// database directly. If the state database has no trie node database, the
+// tries are written to the given database too.
+func (s *StateDB) Commit(codew trie.DatabaseWriter, deleteEmptyObjects bool) (root common.Hash, err error) {
+	if triedb := s.db.TrieDB(); triedb != nil {
+		return s.commit(codew, triedb, deleteEmptyObjects)
+	}
+	return s.commit(codew, codew, deleteEmptyObjects)
+}
+
+// commit writes the contract code and the state tries to the given databases.
+func (s *StateDB) commit(codew, triew trie.DatabaseWriter, deleteEmptyObjects bool) (root common.Hash, err error) {
 	defer s.clearJournalAndRefund()
 
 	// Commit objects to the trie.
@@ -586,22 +730,37 @@ func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (ro
 		case isDirty:
 			// Write any contract code associated with the state object
 			if stateObject.code != nil && stateObject.dirtyCode {
-				if err := dbw.Put(stateObject.CodeHash(), stateObject.code); err != nil {
+				if err := codew.Put(stateObject.CodeHash(), stateObject.code); err != nil {
 					return common.Hash{}, err
 				}
 				stateObject.dirtyCode = false
 			}
 			// Write any storage changes in the state object to its storage trie.
-			if err := stateObject.CommitTrie(s.db, dbw); err != nil {
+			s.updateSnapStorage(stateObject)
+			if err := stateObject.CommitTrie(s.db, triew); err != nil {
 				return common.Hash{}, err
 			}
 			// Update the object in the main account trie.
 			s.updateStateObject(stateObject)
+			if s.snap != nil {
+				s.snapAccounts[stateObject.addrHash], _ = rlp.EncodeToBytes(stateObject)
+			}
 		}
 		delete(s.stateObjectsDirty, addr)
 	}
 	// Write trie changes.
-	root, err = s.trie.CommitTo(dbw)
+	root, err = s.trie.CommitTo(triew)
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
+
+	// Add the state changes to the snapshot tree as a new layer. The snapshot
+	// is not valid for the new state, so subsequent reads go to the tries.
+	if err == nil && s.snap != nil {
+		if parent := s.snap.Root(); parent != root {
+			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+				log.Debug("Failed to update state snapshot", "from", parent, "to", root, "err", err)
+			}
+		}
+		s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
+	}
 	return root, err
 }
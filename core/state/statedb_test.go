@@ -31,6 +31,7 @@ import (
 	check "gopkg.in/check.v1"
 
 	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/state/snapshot"
 	"github.com/apolo-technologies/zerium/core/types"
 	"github.com/apolo-technologies/zerium/zrmdb"
 )
@@ -117,6 +118,71 @@ func TestIntermediateLeaks(t *testing.T) {
 	}
 }
 
+// Tests that states opened on top of a flat snapshot read the same accounts and
+// storage as the tries, and that committing them extends the snapshot tree with
+// the state changes, including the wiping of destructed accounts' storage.
+func TestFlatSnapshotReads(t *testing.T) {
+	db, _ := zrmdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+
+	var (
+		addrs = []common.Address{{0x01}, {0x02}, {0x03}, {0x04}}
+		keys  = []common.Hash{{0x01}, {0x02}, {0x03}}
+	)
+	// Create a base state and generate its snapshot
+	base, _ := New(common.Hash{}, sdb)
+	for i, addr := range addrs[:3] {
+		base.SetBalance(addr, big.NewInt(int64(i+1)))
+		base.SetState(addr, keys[0], common.Hash{byte(i + 1)})
+		base.SetState(addr, keys[1], common.Hash{byte(i + 1), 0x02})
+	}
+	root, _ := base.Commit(db, false)
+	sdb.TrieDB().Commit(root)
+
+	snaps := snapshot.New(db)
+	if err := snaps.Rebuild(sdb.TrieDB(), root); err != nil {
+		t.Fatalf("failed to generate snapshot: %v", err)
+	}
+	// Modify the state across a few transactions and commit it
+	state, _ := NewWithSnapshot(root, sdb, snaps)
+	if state.snap == nil {
+		t.Fatalf("snapshot not opened for tracked root")
+	}
+	state.SetState(addrs[0], keys[0], common.Hash{0xff})
+	state.SetState(addrs[0], keys[1], common.Hash{})
+	state.Suicide(addrs[1])
+	state.AddBalance(addrs[3], big.NewInt(4))
+	state.Finalise(false)
+
+	state.CreateAccount(addrs[1])
+	state.SetState(addrs[1], keys[2], common.Hash{0x03})
+	state.SetBalance(addrs[2], big.NewInt(33))
+	root, _ = state.Commit(db, false)
+
+	if snaps.Snapshot(root) == nil {
+		t.Fatalf("snapshot of committed state missing")
+	}
+	// Ensure the snapshot backed state matches the trie backed one
+	flat, _ := NewWithSnapshot(root, sdb, snaps)
+	tries, _ := New(root, sdb)
+	for _, addr := range addrs {
+		if have, want := flat.GetBalance(addr), tries.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("account %x: balance mismatch: have %v, want %v", addr, have, want)
+		}
+		for _, key := range keys {
+			if have, want := flat.GetState(addr, key), tries.GetState(addr, key); have != want {
+				t.Errorf("account %x, slot %x: value mismatch: have %x, want %x", addr, key, have, want)
+			}
+		}
+	}
+	if value := flat.GetState(addrs[1], keys[0]); value != (common.Hash{}) {
+		t.Errorf("storage of destructed account leaked: %x", value)
+	}
+	if err := flat.Error(); err != nil {
+		t.Errorf("state access failed: %v", err)
+	}
+}
+
 func TestSnapshotRandom(t *testing.T) {
 	config := &quick.Config{MaxCount: 1000}
 	err := quick.Check((*snapshotTest).run, config)
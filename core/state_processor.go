@@ -88,19 +88,36 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
 func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int, cfg vm.Config) (*types.Receipt, *big.Int, error) {
+	receipt, _, gas, err := ApplyTransactionWithResult(config, bc, author, gp, statedb, header, tx, usedGas, cfg)
+	return receipt, gas, err
+}
+
+// ApplyTransactionWithResult is like ApplyTransaction, but additionally returns
+// the data returned by the execution (e.g. the revert reason of failed ones).
+func ApplyTransactionWithResult(config *params.ChainConfig, bc *BlockChain, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int, cfg vm.Config) (*types.Receipt, []byte, *big.Int, error) {
 	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	// Create a new context to be used in the EVM environment
 	context := NewEVMContext(msg, header, bc, author)
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
+
+	return ApplyTransactionInEVM(vmenv, msg, gp, statedb, header, tx, usedGas)
+}
+
+// ApplyTransactionInEVM is like ApplyTransactionWithResult, but executes the
+// transaction's message in an EVM environment supplied (and e.g. cancelled) by
+// the caller.
+func ApplyTransactionInEVM(vmenv *vm.EVM, msg Message, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int) (*types.Receipt, []byte, *big.Int, error) {
+	config := vmenv.ChainConfig()
+
 	// Apply the transaction to the current state (included in the env)
-	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
+	ret, gas, failed, err := ApplyMessage(vmenv, msg, gp)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	// Update the state with pending changes
@@ -126,5 +143,5 @@ func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common
 	receipt.Logs = statedb.GetLogs(tx.Hash())
 	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
 
-	return receipt, gas, err
+	return receipt, ret, gas, err
 }
@@ -50,3 +50,42 @@ func TestStrictTxListAdd(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the running slot count of a list is kept in sync with its contents
+// across all the operations modifying it.
+func TestTxListSlots(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+
+	validate := func(list *txList, op string) {
+		slots := 0
+		for _, tx := range list.txs.items {
+			slots += TxSlots(tx)
+		}
+		if have := list.Slots(); have != slots {
+			t.Fatalf("%s: slot count mismatch: have %d, want %d", op, have, slots)
+		}
+	}
+	list := newTxList(true)
+	for i := 0; i < 64; i++ {
+		list.Add(pricedDataTransaction(uint64(i), big.NewInt(100000), big.NewInt(1), key, uint64(rand.Intn(3*txSlotSize))), DefaultTxPoolConfig.PriceBump)
+	}
+	validate(list, "add")
+
+	list.Add(pricedDataTransaction(10, big.NewInt(100000), big.NewInt(2), key, 4*txSlotSize), DefaultTxPoolConfig.PriceBump)
+	validate(list, "replace")
+
+	list.Forward(4)
+	validate(list, "forward")
+
+	list.Remove(list.txs.Get(60))
+	validate(list, "remove")
+
+	list.Cap(40)
+	validate(list, "cap")
+
+	list.Filter(big.NewInt(100000), big.NewInt(100000))
+	validate(list, "filter")
+
+	list.Ready(8)
+	validate(list, "ready")
+}
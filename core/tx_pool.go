@@ -40,6 +40,22 @@ const (
 	chainHeadChanSize = 10
 	// rmTxChanSize is the size of channel listening to RemovedTransactionEvent.
 	rmTxChanSize = 10
+
+	// txSlotSize is used to calculate how many data slots a single transaction
+	// takes up based on its size. The slots are used as DoS protection, ensuring
+	// that a few transactions with huge payloads cost as much of the pool's
+	// capacity as the many small ones that would take up the same memory.
+	txSlotSize = 4 * 1024
+
+	// txMaxSize is the maximum size a single transaction can have. Larger
+	// transactions are significantly harder and more expensive to propagate, so
+	// they are rejected outright instead of being accounted for in slots.
+	txMaxSize = 8 * txSlotSize // 32KB
+
+	// privateTxRetention is the time a private transaction is still remembered
+	// as such after being mined, so that it isn't gossiped if a reorg returns it
+	// into the pool.
+	privateTxRetention = time.Hour
 )
 
 var (
@@ -189,6 +205,8 @@ type TxPool struct {
 	chain        blockChain
 	gasPrice     *big.Int
 	txFeed       event.Feed
+	dropFeed     event.Feed
+	drops        []TxDropEvent // Drop events collected under the lock, sent on unlock
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -202,11 +220,16 @@ type TxPool struct {
 	locals  *accountSet // Set of local transaction to exepmt from evicion rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
-	pending map[common.Address]*txList         // All currently processable transactions
-	queue   map[common.Address]*txList         // Queued but non-processable transactions
-	beats   map[common.Address]time.Time       // Last heartbeat from each known account
-	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
-	priced  *txPricedList                      // All transactions sorted by price
+	private     map[common.Hash]time.Time // Private transactions (with mining time if no longer pooled)
+	privJournal *txJournal                // Journal of private transactions to back up to disk
+
+	included map[common.Hash]struct{} // Transactions included by the head being reset to (nil = unknown)
+
+	pending map[common.Address]*txList   // All currently processable transactions
+	queue   map[common.Address]*txList   // Queued but non-processable transactions
+	beats   map[common.Address]time.Time // Last heartbeat from each known account
+	all     *txLookup                    // All transactions to allow lookups
+	priced  *txPricedList                // All transactions sorted by price
 
 	wg sync.WaitGroup // for shutdown sync
 
@@ -228,24 +251,27 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		pending:     make(map[common.Address]*txList),
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
-		all:         make(map[common.Hash]*types.Transaction),
+		all:         newTxLookup(),
+		private:     make(map[common.Hash]time.Time),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
 	pool.locals = newAccountSet(pool.signer)
-	pool.priced = newTxPricedList(&pool.all)
+	pool.priced = newTxPricedList(pool.all)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 	// If local transactions and journaling is enabled, load from disk
 	if !config.NoLocals && config.Journal != "" {
 		pool.journal = newTxJournal(config.Journal)
+		pool.privJournal = newTxJournal(config.Journal + ".private")
 
+		if err := pool.privJournal.load(pool.AddPrivate); err != nil {
+			log.Warn("Failed to load private transaction journal", "err", err)
+		}
 		if err := pool.journal.load(pool.AddLocal); err != nil {
 			log.Warn("Failed to load transaction journal", "err", err)
 		}
-		if err := pool.journal.rotate(pool.local()); err != nil {
-			log.Warn("Failed to rotate transaction journal", "err", err)
-		}
+		pool.rotateJournals()
 	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
@@ -291,7 +317,7 @@ func (pool *TxPool) loop() {
 				pool.reset(head.Header(), ev.Block.Header())
 				head = ev.Block
 
-				pool.mu.Unlock()
+				pool.unlock()
 			}
 		// Be unsubscribed due to system stopped
 		case <-pool.chainHeadSub.Err():
@@ -320,19 +346,23 @@ func (pool *TxPool) loop() {
 				// Any non-locals old enough should be removed
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					for _, tx := range pool.queue[addr].Flatten() {
-						pool.removeTx(tx.Hash())
+						pool.removeTx(tx.Hash(), TxDropExpired)
 					}
 				}
 			}
-			pool.mu.Unlock()
+			// Forget about private transactions mined long enough ago
+			for hash, mined := range pool.private {
+				if !mined.IsZero() && time.Since(mined) > privateTxRetention {
+					delete(pool.private, hash)
+				}
+			}
+			pool.unlock()
 
 		// Handle local transaction journal rotation
 		case <-journal.C:
 			if pool.journal != nil {
 				pool.mu.Lock()
-				if err := pool.journal.rotate(pool.local()); err != nil {
-					log.Warn("Failed to rotate local tx journal", "err", err)
-				}
+				pool.rotateJournals()
 				pool.mu.Unlock()
 			}
 		}
@@ -343,7 +373,7 @@ func (pool *TxPool) loop() {
 // manner. This method is only ever used in the tester!
 func (pool *TxPool) lockedReset(oldHead, newHead *types.Header) {
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
+	defer pool.unlock()
 
 	pool.reset(oldHead, newHead)
 }
@@ -352,7 +382,10 @@ func (pool *TxPool) lockedReset(oldHead, newHead *types.Header) {
 // of the transaction pool is valid with regard to the chain state.
 func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// If we're reorging an old state, reinject all dropped transactions
-	var reinject types.Transactions
+	var (
+		reinject, included types.Transactions
+		complete           bool // Whether all the included transactions are known
+	)
 
 	if oldHead != nil && oldHead.Hash() != newHead.ParentHash {
 		// If the reorg is too deep, avoid doing it (will happen during fast sync)
@@ -363,7 +396,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 			log.Warn("Skipping deep transaction reorg", "depth", depth)
 		} else {
 			// Reorg seems shallow enough to pull in all transactions into memory
-			var discarded, included types.Transactions
+			var discarded types.Transactions
 
 			var (
 				rem = pool.chain.GetBlock(oldHead.Hash(), oldHead.Number.Uint64())
@@ -396,8 +429,23 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 				}
 			}
 			reinject = types.TxDifference(discarded, included)
+			complete = true
+		}
+	} else if oldHead != nil {
+		// Plain chain extension, only the new head's transactions were included
+		if block := pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64()); block != nil {
+			included, complete = block.Transactions(), true
 		}
 	}
+	// Track the included transactions to tell them apart from stale ones when dropped
+	if complete {
+		pool.included = make(map[common.Hash]struct{}, len(included))
+		for _, tx := range included {
+			pool.included[tx.Hash()] = struct{}{}
+		}
+		defer func() { pool.included = nil }()
+	}
+
 	// Initialize the internal state to the current head
 	if newHead == nil {
 		newHead = pool.chain.CurrentBlock().Header() // Special case during testing
@@ -442,6 +490,7 @@ func (pool *TxPool) Stop() {
 
 	if pool.journal != nil {
 		pool.journal.close()
+		pool.privJournal.close()
 	}
 	log.Info("Transaction pool stopped")
 }
@@ -452,6 +501,56 @@ func (pool *TxPool) SubscribeTxPreEvent(ch chan<- TxPreEvent) event.Subscription
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeTxDropEvent registers a subscription of TxDropEvent and starts sending
+// an event to the given channel whenever a transaction is dropped or replaced.
+func (pool *TxPool) SubscribeTxDropEvent(ch chan<- TxDropEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
+// notifyDrop notifies the subsystems that a transaction has been removed from
+// the pool for the given reason, superseded by replacement if non-nil. The event
+// is only sent once the pool lock is released, see unlock.
+//
+// Private transactions are forgotten unless they were dropped due to their nonce
+// being used up, in which case they are retained for a while in case of reorgs.
+func (pool *TxPool) notifyDrop(tx *types.Transaction, reason TxDropReason, replacement *types.Transaction) {
+	if _, ok := pool.private[tx.Hash()]; ok {
+		if reason == TxDropIncluded || reason == TxDropNonceTooLow || reason == TxDropStale {
+			pool.private[tx.Hash()] = time.Now()
+		} else {
+			delete(pool.private, tx.Hash())
+		}
+	}
+	pool.drops = append(pool.drops, TxDropEvent{Tx: tx, Reason: reason, Replacement: replacement})
+}
+
+// unlock releases the pool lock, and sends the drop events collected while it
+// was held, in the order the transactions were dropped.
+func (pool *TxPool) unlock() {
+	drops := pool.drops
+	pool.drops = nil
+	pool.mu.Unlock()
+
+	for _, ev := range drops {
+		pool.dropFeed.Send(ev)
+	}
+}
+
+// staleReason returns the reason of dropping a transaction whose nonce was used
+// up: its own inclusion by the head the pool is being reset to, or a competing
+// transaction. The included transactions are only known for reorgs the pool
+// processed in full (at most 64 blocks deep); otherwise the transaction is
+// reported as stale without telling the two cases apart.
+func (pool *TxPool) staleReason(tx *types.Transaction) TxDropReason {
+	if pool.included == nil {
+		return TxDropStale
+	}
+	if _, ok := pool.included[tx.Hash()]; ok {
+		return TxDropIncluded
+	}
+	return TxDropNonceTooLow
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -464,11 +563,11 @@ func (pool *TxPool) GasPrice() *big.Int {
 // new transaction, and drops all transactions below this threshold.
 func (pool *TxPool) SetGasPrice(price *big.Int) {
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
+	defer pool.unlock()
 
 	pool.gasPrice = price
 	for _, tx := range pool.priced.Cap(price, pool.locals) {
-		pool.removeTx(tx.Hash())
+		pool.removeTx(tx.Hash(), TxDropUnderpriced)
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
@@ -504,6 +603,32 @@ func (pool *TxPool) stats() (int, int) {
 	return pending, queued
 }
 
+// Usage retrieves the current memory usage of the pool, namely the number of
+// data slots taken up by all the tracked transactions and their total size.
+func (pool *TxPool) Usage() (int, common.StorageSize) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.all.Slots(), pool.all.Size()
+}
+
+// Locals retrieves the accounts currently considered local by the pool.
+func (pool *TxPool) Locals() []common.Address {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.locals.flatten()
+}
+
+// Arrival returns the time the transaction with the given hash entered the pool,
+// or the zero time if it isn't pooled.
+func (pool *TxPool) Arrival(hash common.Hash) time.Time {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.all.Arrival(hash)
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and sorted by nonce.
 func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
@@ -536,26 +661,50 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 }
 
 // local retrieves all currently known local transactions, groupped by origin
-// account and sorted by nonce. The returned transaction set is a copy and can be
-// freely modified by calling code.
-func (pool *TxPool) local() map[common.Address]types.Transactions {
-	txs := make(map[common.Address]types.Transactions)
+// account and sorted by nonce, split into public and private ones. The returned
+// transaction sets are copies and can be freely modified by calling code.
+func (pool *TxPool) local() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	public := make(map[common.Address]types.Transactions)
+	private := make(map[common.Address]types.Transactions)
+
 	for addr := range pool.locals.accounts {
+		var txs types.Transactions
 		if pending := pool.pending[addr]; pending != nil {
-			txs[addr] = append(txs[addr], pending.Flatten()...)
+			txs = append(txs, pending.Flatten()...)
 		}
 		if queued := pool.queue[addr]; queued != nil {
-			txs[addr] = append(txs[addr], queued.Flatten()...)
+			txs = append(txs, queued.Flatten()...)
+		}
+		for _, tx := range txs {
+			if _, ok := pool.private[tx.Hash()]; ok {
+				private[addr] = append(private[addr], tx)
+			} else {
+				public[addr] = append(public[addr], tx)
+			}
 		}
 	}
-	return txs
+	return public, private
+}
+
+// rotateJournals regenerates the local and private transaction journals based
+// on the current contents of the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) rotateJournals() {
+	public, private := pool.local()
+	if err := pool.journal.rotate(public); err != nil {
+		log.Warn("Failed to rotate local tx journal", "err", err)
+	}
+	if err := pool.privJournal.rotate(private); err != nil {
+		log.Warn("Failed to rotate private tx journal", "err", err)
+	}
 }
 
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
-	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
-	if tx.Size() > 32*1024 {
+	// Reject transactions over the size cap to prevent DOS attacks
+	if tx.Size() > txMaxSize {
 		return ErrOversizedData
 	}
 	// Transactions can't be negative. This may never happen using RLP decoded
@@ -604,10 +753,14 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
-	if pool.all[hash] != nil {
+	if pool.all.Get(hash) != nil {
 		log.Trace("Discarding already known transaction", "hash", hash)
 		return false, fmt.Errorf("known transaction: %x", hash)
 	}
+	// If a mined private transaction is being reinjected, it's pooled again
+	if _, ok := pool.private[hash]; ok {
+		pool.private[hash] = time.Time{}
+	}
 	// If the transaction fails basic validation, discard it
 	if err := pool.validateTx(tx, local); err != nil {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
@@ -615,7 +768,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		return false, err
 	}
 	// If the transaction pool is full, discard underpriced transactions
-	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
+	if uint64(pool.all.Slots()+TxSlots(tx)) > pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
 		if pool.priced.Underpriced(tx, pool.locals) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "price", tx.GasPrice())
@@ -623,11 +776,11 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			return false, ErrUnderpriced
 		}
 		// New transaction is better than our worse ones, make room for it
-		drop := pool.priced.Discard(len(pool.all)-int(pool.config.GlobalSlots+pool.config.GlobalQueue-1), pool.locals)
+		drop := pool.priced.Discard(pool.all.Slots()-int(pool.config.GlobalSlots+pool.config.GlobalQueue)+TxSlots(tx), pool.locals)
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
-			pool.removeTx(tx.Hash())
+			pool.removeTx(tx.Hash(), TxDropUnderpriced)
 		}
 	}
 	// If the transaction is replacing an already pending one, do directly
@@ -641,18 +794,19 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		}
 		// New transaction is better, replace old one
 		if old != nil {
-			delete(pool.all, old.Hash())
+			pool.all.Remove(old.Hash())
 			pool.priced.Removed()
 			pendingReplaceCounter.Inc(1)
+			pool.notifyDrop(old, TxDropReplaced, tx)
 		}
-		pool.all[tx.Hash()] = tx
+		pool.all.Add(tx)
 		pool.priced.Put(tx)
 		pool.journalTx(from, tx)
 
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 
 		// We've directly injected a replacement transaction, notify subsystems
-		go pool.txFeed.Send(TxPreEvent{tx})
+		go pool.txFeed.Send(TxPreEvent{Tx: tx, Private: pool.isPrivate(hash)})
 
 		return old != nil, nil
 	}
@@ -688,22 +842,30 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	}
 	// Discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.all.Remove(old.Hash())
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
+		pool.notifyDrop(old, TxDropReplaced, tx)
 	}
-	pool.all[hash] = tx
+	pool.all.Add(tx)
 	pool.priced.Put(tx)
 	return old != nil, nil
 }
 
 // journalTx adds the specified transaction to the local disk journal if it is
-// deemed to have been sent from a local account.
+// deemed to have been sent from a local account, or to the private journal if
+// it was submitted privately.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
 	// Only journal if it's enabled and the transaction is local
 	if pool.journal == nil || !pool.locals.contains(from) {
 		return
 	}
+	if pool.isPrivate(tx.Hash()) {
+		if err := pool.privJournal.insert(tx); err != nil {
+			log.Warn("Failed to journal private transaction", "err", err)
+		}
+		return
+	}
 	if err := pool.journal.insert(tx); err != nil {
 		log.Warn("Failed to journal local transaction", "err", err)
 	}
@@ -722,29 +884,31 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	inserted, old := list.Add(tx, pool.config.PriceBump)
 	if !inserted {
 		// An older transaction was better, discard this
-		delete(pool.all, hash)
+		pool.all.Remove(hash)
 		pool.priced.Removed()
 
 		pendingDiscardCounter.Inc(1)
+		pool.notifyDrop(tx, TxDropUnderpriced, nil)
 		return
 	}
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.all.Remove(old.Hash())
 		pool.priced.Removed()
 
 		pendingReplaceCounter.Inc(1)
+		pool.notifyDrop(old, TxDropReplaced, tx)
 	}
 	// Failsafe to work around direct pending inserts (tests)
-	if pool.all[hash] == nil {
-		pool.all[hash] = tx
+	if pool.all.Get(hash) == nil {
+		pool.all.Add(tx)
 		pool.priced.Put(tx)
 	}
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr, tx.Nonce()+1)
 
-	go pool.txFeed.Send(TxPreEvent{tx})
+	go pool.txFeed.Send(TxPreEvent{Tx: tx, Private: pool.isPrivate(hash)})
 }
 
 // AddLocal enqueues a single transaction into the pool if it is valid, marking
@@ -754,6 +918,50 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 	return pool.addTx(tx, !pool.config.NoLocals)
 }
 
+// AddPrivate enqueues a single transaction into the pool as a local one, marking
+// it private so that it's only included by the local miner and never announced
+// to the network.
+func (pool *TxPool) AddPrivate(tx *types.Transaction) error {
+	pool.mu.Lock()
+	defer pool.unlock()
+
+	// Mark the transaction private before adding, as the pool announces it on insert
+	hash := tx.Hash()
+
+	known := pool.isPrivate(hash)
+	if !known {
+		pool.private[hash] = time.Time{}
+	}
+	replace, err := pool.add(tx, !pool.config.NoLocals)
+	if err != nil {
+		if !known {
+			delete(pool.private, hash)
+		}
+		return err
+	}
+	if !replace {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		pool.promoteExecutables([]common.Address{from})
+	}
+	return nil
+}
+
+// IsPrivate returns whether the transaction with the given hash was submitted
+// privately and must not be announced to the network.
+func (pool *TxPool) IsPrivate(hash common.Hash) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.isPrivate(hash)
+}
+
+// isPrivate returns whether the transaction with the given hash is private,
+// whilst assuming the transaction pool lock is already held.
+func (pool *TxPool) isPrivate(hash common.Hash) bool {
+	_, ok := pool.private[hash]
+	return ok
+}
+
 // AddRemote enqueues a single transaction into the pool if it is valid. If the
 // sender is not among the locally tracked ones, full pricing constraints will
 // apply.
@@ -778,7 +986,7 @@ func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
 // addTx enqueues a single transaction into the pool if it is valid.
 func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
+	defer pool.unlock()
 
 	// Try to inject the transaction and update any state
 	replace, err := pool.add(tx, local)
@@ -796,7 +1004,7 @@ func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
 // addTxs attempts to queue a batch of transactions if they are valid.
 func (pool *TxPool) addTxs(txs []*types.Transaction, local bool) []error {
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
+	defer pool.unlock()
 
 	return pool.addTxsLocked(txs, local)
 }
@@ -836,7 +1044,7 @@ func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
 
 	status := make([]TxStatus, len(hashes))
 	for i, hash := range hashes {
-		if tx := pool.all[hash]; tx != nil {
+		if tx := pool.all.Get(hash); tx != nil {
 			from, _ := types.Sender(pool.signer, tx) // already validated
 			if pool.pending[from].txs.items[tx.Nonce()] != nil {
 				status[i] = TxStatusPending
@@ -854,22 +1062,24 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
-	return pool.all[hash]
+	return pool.all.Get(hash)
 }
 
 // removeTx removes a single transaction from the queue, moving all subsequent
-// transactions back to the future queue.
-func (pool *TxPool) removeTx(hash common.Hash) {
+// transactions back to the future queue. Subscribers are notified of the drop
+// with the given reason.
+func (pool *TxPool) removeTx(hash common.Hash, reason TxDropReason) {
 	// Fetch the transaction we wish to delete
-	tx, ok := pool.all[hash]
-	if !ok {
+	tx := pool.all.Get(hash)
+	if tx == nil {
 		return
 	}
 	addr, _ := types.Sender(pool.signer, tx) // already validated during insertion
 
 	// Remove it from the list of known transactions
-	delete(pool.all, hash)
+	pool.all.Remove(hash)
 	pool.priced.Removed()
+	pool.notifyDrop(tx, reason, nil)
 
 	// Remove the transaction from the pending lists and reset the account nonce
 	if pending := pool.pending[addr]; pending != nil {
@@ -921,17 +1131,19 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		for _, tx := range list.Forward(pool.currentState.GetNonce(addr)) {
 			hash := tx.Hash()
 			log.Trace("Removed old queued transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.all.Remove(hash)
 			pool.priced.Removed()
+			pool.notifyDrop(tx, pool.staleReason(tx), nil)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable queued transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.all.Remove(hash)
 			pool.priced.Removed()
 			queuedNofundsCounter.Inc(1)
+			pool.notifyDrop(tx, TxDropUnpayable, nil)
 		}
 		// Gather all executable transactions and promote them
 		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
@@ -943,9 +1155,10 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		if !pool.locals.contains(addr) {
 			for _, tx := range list.Cap(int(pool.config.AccountQueue)) {
 				hash := tx.Hash()
-				delete(pool.all, hash)
+				pool.all.Remove(hash)
 				pool.priced.Removed()
 				queuedRateLimitCounter.Inc(1)
+				pool.notifyDrop(tx, TxDropQueueOverflow, nil)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 		}
@@ -957,7 +1170,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 	// If the pending limit is overflown, start equalizing allowances
 	pending := uint64(0)
 	for _, list := range pool.pending {
-		pending += uint64(list.Len())
+		pending += uint64(list.Slots())
 	}
 	if pending > pool.config.GlobalSlots {
 		pendingBeforeCap := pending
@@ -965,8 +1178,8 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		spammers := prque.New()
 		for addr, list := range pool.pending {
 			// Only evict transactions from high rollers
-			if !pool.locals.contains(addr) && uint64(list.Len()) > pool.config.AccountSlots {
-				spammers.Push(addr, float32(list.Len()))
+			if !pool.locals.contains(addr) && uint64(list.Slots()) > pool.config.AccountSlots {
+				spammers.Push(addr, float32(list.Slots()))
 			}
 		}
 		// Gradually drop transactions from offenders
@@ -979,48 +1192,36 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			// Equalize balances until all the same or below threshold
 			if len(offenders) > 1 {
 				// Calculate the equalization threshold for all current offenders
-				threshold := pool.pending[offender.(common.Address)].Len()
+				threshold := pool.pending[offender.(common.Address)].Slots()
 
 				// Iteratively reduce all offenders until below limit or threshold reached
-				for pending > pool.config.GlobalSlots && pool.pending[offenders[len(offenders)-2]].Len() > threshold {
+				for pending > pool.config.GlobalSlots {
+					reduced := false
 					for i := 0; i < len(offenders)-1; i++ {
-						list := pool.pending[offenders[i]]
-						for _, tx := range list.Cap(list.Len() - 1) {
-							// Drop the transaction from the global pools too
-							hash := tx.Hash()
-							delete(pool.all, hash)
-							pool.priced.Removed()
-
-							// Update the account nonce to the dropped transaction
-							if nonce := tx.Nonce(); pool.pendingState.GetNonce(offenders[i]) > nonce {
-								pool.pendingState.SetNonce(offenders[i], nonce)
-							}
-							log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+						if pool.pending[offenders[i]].Slots() <= threshold {
+							continue
 						}
-						pending--
+						pending -= pool.capPending(offenders[i])
+						reduced = true
+					}
+					if !reduced {
+						break
 					}
 				}
 			}
 		}
 		// If still above threshold, reduce to limit or min allowance
-		if pending > pool.config.GlobalSlots && len(offenders) > 0 {
-			for pending > pool.config.GlobalSlots && uint64(pool.pending[offenders[len(offenders)-1]].Len()) > pool.config.AccountSlots {
-				for _, addr := range offenders {
-					list := pool.pending[addr]
-					for _, tx := range list.Cap(list.Len() - 1) {
-						// Drop the transaction from the global pools too
-						hash := tx.Hash()
-						delete(pool.all, hash)
-						pool.priced.Removed()
-
-						// Update the account nonce to the dropped transaction
-						if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
-							pool.pendingState.SetNonce(addr, nonce)
-						}
-						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
-					}
-					pending--
+		for pending > pool.config.GlobalSlots && len(offenders) > 0 {
+			reduced := false
+			for _, addr := range offenders {
+				if uint64(pool.pending[addr].Slots()) <= pool.config.AccountSlots {
+					continue
 				}
+				pending -= pool.capPending(addr)
+				reduced = true
+			}
+			if !reduced {
+				break
 			}
 		}
 		pendingRateLimitCounter.Inc(int64(pendingBeforeCap - pending))
@@ -1028,7 +1229,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 	// If we've queued more transactions than the hard limit, drop oldest ones
 	queued := uint64(0)
 	for _, list := range pool.queue {
-		queued += uint64(list.Len())
+		queued += uint64(list.Slots())
 	}
 	if queued > pool.config.GlobalQueue {
 		// Sort all accounts with queued transactions by heartbeat
@@ -1048,25 +1249,55 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			addresses = addresses[:len(addresses)-1]
 
 			// Drop all transactions if they are less than the overflow
-			if size := uint64(list.Len()); size <= drop {
-				for _, tx := range list.Flatten() {
-					pool.removeTx(tx.Hash())
+			if size := uint64(list.Slots()); size <= drop {
+				txs := list.Flatten()
+				for _, tx := range txs {
+					pool.removeTx(tx.Hash(), TxDropQueueOverflow)
 				}
 				drop -= size
-				queuedRateLimitCounter.Inc(int64(size))
+				queuedRateLimitCounter.Inc(int64(len(txs)))
 				continue
 			}
 			// Otherwise drop only last few transactions
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
-				pool.removeTx(txs[i].Hash())
-				drop--
+				pool.removeTx(txs[i].Hash(), TxDropQueueOverflow)
+				if slots := uint64(TxSlots(txs[i])); slots < drop {
+					drop -= slots
+				} else {
+					drop = 0
+				}
 				queuedRateLimitCounter.Inc(1)
 			}
 		}
 	}
 }
 
+// capPending drops the highest nonce transaction of an account's pending list,
+// returning the number of slots freed up.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) capPending(addr common.Address) uint64 {
+	list := pool.pending[addr]
+
+	slots := uint64(0)
+	for _, tx := range list.Cap(list.Len() - 1) {
+		// Drop the transaction from the global pools too
+		hash := tx.Hash()
+		pool.all.Remove(hash)
+		pool.priced.Removed()
+		pool.notifyDrop(tx, TxDropPendingOverflow, nil)
+		slots += uint64(TxSlots(tx))
+
+		// Update the account nonce to the dropped transaction
+		if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
+			pool.pendingState.SetNonce(addr, nonce)
+		}
+		log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
+	}
+	return slots
+}
+
 // demoteUnexecutables removes invalid and processed transactions from the pools
 // executable/pending queue and any subsequent transactions that become unexecutable
 // are moved back into the future queue.
@@ -1079,17 +1310,19 @@ func (pool *TxPool) demoteUnexecutables() {
 		for _, tx := range list.Forward(nonce) {
 			hash := tx.Hash()
 			log.Trace("Removed old pending transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.all.Remove(hash)
 			pool.priced.Removed()
+			pool.notifyDrop(tx, pool.staleReason(tx), nil)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.all.Remove(hash)
 			pool.priced.Removed()
 			pendingNofundsCounter.Inc(1)
+			pool.notifyDrop(tx, TxDropUnpayable, nil)
 		}
 		for _, tx := range invalids {
 			hash := tx.Hash()
@@ -1159,3 +1392,95 @@ func (as *accountSet) containsTx(tx *types.Transaction) bool {
 func (as *accountSet) add(addr common.Address) {
 	as.accounts[addr] = struct{}{}
 }
+
+// flatten returns the list of addresses within this set.
+func (as *accountSet) flatten() []common.Address {
+	accounts := make([]common.Address, 0, len(as.accounts))
+	for account := range as.accounts {
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+// txLookup is used internally by TxPool to track transactions while allowing
+// lookups by hash, and to account for the data slots they take up.
+//
+// Note, the lookup is not safe for concurrent use, the pool lock must be held!
+type txLookup struct {
+	all      map[common.Hash]*types.Transaction
+	arrivals map[common.Hash]time.Time
+	slots    int
+	size     common.StorageSize
+}
+
+// newTxLookup returns a new txLookup structure.
+func newTxLookup() *txLookup {
+	return &txLookup{
+		all:      make(map[common.Hash]*types.Transaction),
+		arrivals: make(map[common.Hash]time.Time),
+	}
+}
+
+// Range calls f on each key and value present in the map.
+func (t *txLookup) Range(f func(hash common.Hash, tx *types.Transaction) bool) {
+	for key, value := range t.all {
+		if !f(key, value) {
+			break
+		}
+	}
+}
+
+// Get returns a transaction if it exists in the lookup, or nil if not found.
+func (t *txLookup) Get(hash common.Hash) *types.Transaction {
+	return t.all[hash]
+}
+
+// Arrival returns the time a transaction was added to the lookup, or the zero
+// time if not found.
+func (t *txLookup) Arrival(hash common.Hash) time.Time {
+	return t.arrivals[hash]
+}
+
+// Count returns the current number of transactions in the lookup.
+func (t *txLookup) Count() int {
+	return len(t.all)
+}
+
+// Slots returns the current number of slots used in the lookup.
+func (t *txLookup) Slots() int {
+	return t.slots
+}
+
+// Size returns the total encoded size of the transactions in the lookup.
+func (t *txLookup) Size() common.StorageSize {
+	return t.size
+}
+
+// Add adds a transaction to the lookup. Adding an already tracked transaction
+// is a noop, as demoted transactions are re-added to the queue.
+func (t *txLookup) Add(tx *types.Transaction) {
+	hash := tx.Hash()
+	if _, ok := t.all[hash]; ok {
+		return
+	}
+	t.slots += TxSlots(tx)
+	t.size += tx.Size()
+
+	t.all[hash] = tx
+	t.arrivals[hash] = time.Now()
+}
+
+// Remove removes a transaction from the lookup.
+func (t *txLookup) Remove(hash common.Hash) {
+	if tx, ok := t.all[hash]; ok {
+		t.slots -= TxSlots(tx)
+		t.size -= tx.Size()
+		delete(t.all, hash)
+		delete(t.arrivals, hash)
+	}
+}
+
+// TxSlots calculates the number of slots needed for a single transaction.
+func TxSlots(tx *types.Transaction) int {
+	return int((uint64(tx.Size()) + txSlotSize - 1) / txSlotSize)
+}
@@ -77,6 +77,11 @@ func pricedTransaction(nonce uint64, gaslimit, gasprice *big.Int, key *ecdsa.Pri
 	return tx
 }
 
+func pricedDataTransaction(nonce uint64, gaslimit, gasprice *big.Int, key *ecdsa.PrivateKey, bytes uint64) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), gaslimit, gasprice, make([]byte, bytes)), types.HomesteadSigner{}, key)
+	return tx
+}
+
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	db, _ := zrmdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
@@ -95,12 +100,23 @@ func validateTxPoolInternals(pool *TxPool) error {
 
 	// Ensure the total transaction set is consistent with pending + queued
 	pending, queued := pool.stats()
-	if total := len(pool.all); total != pending+queued {
+	if total := pool.all.Count(); total != pending+queued {
 		return fmt.Errorf("total transaction count %d != %d pending + %d queued", total, pending, queued)
 	}
 	if priced := pool.priced.items.Len() - pool.priced.stales; priced != pending+queued {
 		return fmt.Errorf("total priced transaction count %d != %d pending + %d queued", priced, pending, queued)
 	}
+	// Ensure the slot accounting is consistent with the tracked transactions
+	slots := 0
+	for _, list := range pool.pending {
+		slots += list.Slots()
+	}
+	for _, list := range pool.queue {
+		slots += list.Slots()
+	}
+	if total := pool.all.Slots(); total != slots {
+		return fmt.Errorf("total slot count %d != %d pending + queued slots", total, slots)
+	}
 	// Ensure the next nonce to assign is the correct one
 	for addr, txs := range pool.pending {
 		// Find the last transaction
@@ -350,7 +366,7 @@ func TestTransactionChainFork(t *testing.T) {
 	if _, err := pool.add(tx, false); err != nil {
 		t.Error("didn't expect error", err)
 	}
-	pool.removeTx(tx.Hash())
+	pool.removeTx(tx.Hash(), TxDropUnderpriced)
 
 	// reset the pool's internal state
 	resetState()
@@ -405,8 +421,8 @@ func TestTransactionDoubleNonce(t *testing.T) {
 		t.Errorf("transaction mismatch: have %x, want %x", tx.Hash(), tx2.Hash())
 	}
 	// Ensure the total transaction count is correct
-	if len(pool.all) != 1 {
-		t.Error("expected 1 total transactions, got", len(pool.all))
+	if pool.all.Count() != 1 {
+		t.Error("expected 1 total transactions, got", pool.all.Count())
 	}
 }
 
@@ -428,8 +444,8 @@ func TestTransactionMissingNonce(t *testing.T) {
 	if pool.queue[addr].Len() != 1 {
 		t.Error("expected 1 queued transaction, got", pool.queue[addr].Len())
 	}
-	if len(pool.all) != 1 {
-		t.Error("expected 1 total transactions, got", len(pool.all))
+	if pool.all.Count() != 1 {
+		t.Error("expected 1 total transactions, got", pool.all.Count())
 	}
 }
 
@@ -492,8 +508,8 @@ func TestTransactionDropping(t *testing.T) {
 	if pool.queue[account].Len() != 3 {
 		t.Errorf("queued transaction mismatch: have %d, want %d", pool.queue[account].Len(), 3)
 	}
-	if len(pool.all) != 6 {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), 6)
+	if pool.all.Count() != 6 {
+		t.Errorf("total transaction mismatch: have %d, want %d", pool.all.Count(), 6)
 	}
 	pool.lockedReset(nil, nil)
 	if pool.pending[account].Len() != 3 {
@@ -502,8 +518,8 @@ func TestTransactionDropping(t *testing.T) {
 	if pool.queue[account].Len() != 3 {
 		t.Errorf("queued transaction mismatch: have %d, want %d", pool.queue[account].Len(), 3)
 	}
-	if len(pool.all) != 6 {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), 6)
+	if pool.all.Count() != 6 {
+		t.Errorf("total transaction mismatch: have %d, want %d", pool.all.Count(), 6)
 	}
 	// Reduce the balance of the account, and check that invalidated transactions are dropped
 	pool.currentState.AddBalance(account, big.NewInt(-650))
@@ -527,8 +543,8 @@ func TestTransactionDropping(t *testing.T) {
 	if _, ok := pool.queue[account].txs.items[tx12.Nonce()]; ok {
 		t.Errorf("out-of-fund queued transaction present: %v", tx11)
 	}
-	if len(pool.all) != 4 {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), 4)
+	if pool.all.Count() != 4 {
+		t.Errorf("total transaction mismatch: have %d, want %d", pool.all.Count(), 4)
 	}
 	// Reduce the block gas limit, check that invalidated transactions are dropped
 	pool.chain.(*testBlockChain).gasLimit = big.NewInt(100)
@@ -546,8 +562,8 @@ func TestTransactionDropping(t *testing.T) {
 	if _, ok := pool.queue[account].txs.items[tx11.Nonce()]; ok {
 		t.Errorf("over-gased queued transaction present: %v", tx11)
 	}
-	if len(pool.all) != 2 {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), 2)
+	if pool.all.Count() != 2 {
+		t.Errorf("total transaction mismatch: have %d, want %d", pool.all.Count(), 2)
 	}
 }
 
@@ -583,8 +599,8 @@ func TestTransactionPostponing(t *testing.T) {
 	if len(pool.queue) != 0 {
 		t.Errorf("queued transaction mismatch: have %d, want %d", pool.queue[account].Len(), 0)
 	}
-	if len(pool.all) != len(txns) {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), len(txns))
+	if pool.all.Count() != len(txns) {
+		t.Errorf("total transaction mismatch: have %d, want %d", pool.all.Count(), len(txns))
 	}
 	pool.lockedReset(nil, nil)
 	if pool.pending[account].Len() != len(txns) {
@@ -593,8 +609,8 @@ func TestTransactionPostponing(t *testing.T) {
 	if len(pool.queue) != 0 {
 		t.Errorf("queued transaction mismatch: have %d, want %d", pool.queue[account].Len(), 0)
 	}
-	if len(pool.all) != len(txns) {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), len(txns))
+	if pool.all.Count() != len(txns) {
+		t.Errorf("total transaction mismatch: have %d, want %d", pool.all.Count(), len(txns))
 	}
 	// Reduce the balance of the account, and check that transactions are reorganised
 	pool.currentState.AddBalance(account, big.NewInt(-750))
@@ -623,8 +639,8 @@ func TestTransactionPostponing(t *testing.T) {
 			}
 		}
 	}
-	if len(pool.all) != len(txns)/2 {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), len(txns)/2)
+	if pool.all.Count() != len(txns)/2 {
+		t.Errorf("total transaction mismatch: have %d, want %d", pool.all.Count(), len(txns)/2)
 	}
 }
 
@@ -715,8 +731,8 @@ func TestTransactionQueueAccountLimiting(t *testing.T) {
 			}
 		}
 	}
-	if len(pool.all) != int(testTxPoolConfig.AccountQueue) {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), testTxPoolConfig.AccountQueue)
+	if pool.all.Count() != int(testTxPoolConfig.AccountQueue) {
+		t.Errorf("total transaction mismatch: have %d, want %d", pool.all.Count(), testTxPoolConfig.AccountQueue)
 	}
 }
 
@@ -911,8 +927,8 @@ func TestTransactionPendingLimiting(t *testing.T) {
 			t.Errorf("tx %d: queue size mismatch: have %d, want %d", i, pool.queue[account].Len(), 0)
 		}
 	}
-	if len(pool.all) != int(testTxPoolConfig.AccountQueue+5) {
-		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), testTxPoolConfig.AccountQueue+5)
+	if pool.all.Count() != int(testTxPoolConfig.AccountQueue+5) {
+		t.Errorf("total transaction mismatch: have %d, want %d", pool.all.Count(), testTxPoolConfig.AccountQueue+5)
 	}
 	if err := validateEvents(events, int(testTxPoolConfig.AccountQueue+5)); err != nil {
 		t.Fatalf("event firing failed: %v", err)
@@ -962,8 +978,8 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	if len(pool1.queue) != len(pool2.queue) {
 		t.Errorf("queued transaction count mismatch: one-by-one algo: %d, batch algo: %d", len(pool1.queue), len(pool2.queue))
 	}
-	if len(pool1.all) != len(pool2.all) {
-		t.Errorf("total transaction count mismatch: one-by-one algo %d, batch algo %d", len(pool1.all), len(pool2.all))
+	if pool1.all.Count() != pool2.all.Count() {
+		t.Errorf("total transaction count mismatch: one-by-one algo %d, batch algo %d", pool1.all.Count(), pool2.all.Count())
 	}
 	if err := validateTxPoolInternals(pool1); err != nil {
 		t.Errorf("pool 1 internal state corrupted: %v", err)
@@ -1022,6 +1038,91 @@ func TestTransactionPendingGlobalLimiting(t *testing.T) {
 	}
 }
 
+// Tests that if the transaction count belonging to multiple accounts go above
+// some hard threshold, the pool accounts for transactions by the slots their
+// size takes up, evicting large ones until the slot allowance is met.
+func TestTransactionPendingGlobalSlotLimiting(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the limit enforcement with
+	db, _ := zrmdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.GlobalSlots = config.AccountSlots * 10
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Generate and queue a batch of large transactions, each taking up multiple
+	// slots, but fewer in count than the global slot allowance
+	nonces := make(map[common.Address]uint64)
+
+	txs := types.Transactions{}
+	for _, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		for j := 0; j < int(config.GlobalSlots)/len(keys)/2; j++ {
+			txs = append(txs, pricedDataTransaction(nonces[addr], big.NewInt(200000), big.NewInt(1), key, 3*txSlotSize))
+			nonces[addr]++
+		}
+	}
+	if len(txs) >= int(config.GlobalSlots) {
+		t.Fatalf("test transaction count too high: %d >= %d", len(txs), config.GlobalSlots)
+	}
+	// Import the batch and verify that slot limits have been enforced
+	pool.AddRemotes(txs)
+
+	pending := 0
+	for _, list := range pool.pending {
+		pending += list.Slots()
+	}
+	if pending > int(config.GlobalSlots) {
+		t.Fatalf("total pending slots overflow allowance: %d > %d", pending, config.GlobalSlots)
+	}
+	if count, _ := pool.Stats(); count == len(txs) {
+		t.Fatalf("no large transactions evicted")
+	}
+	if slots, size := pool.Usage(); slots != pending || size == 0 {
+		t.Fatalf("pool usage mismatch: have %d slots (%v), want %d slots", slots, size, pending)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that transactions above the hard size cap are rejected, while ones just
+// below it are accepted.
+func TestTransactionOversizedData(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// Find the largest payload still fitting into the size cap
+	overhead := uint64(pricedDataTransaction(0, big.NewInt(200000), big.NewInt(1), key, 0).Size())
+
+	tx := pricedDataTransaction(0, big.NewInt(200000), big.NewInt(1), key, txMaxSize-overhead-16)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("failed to add transaction of size %v: %v", tx.Size(), err)
+	}
+	tx = pricedDataTransaction(1, big.NewInt(200000), big.NewInt(1), key, txMaxSize)
+	if err := pool.AddRemote(tx); err != ErrOversizedData {
+		t.Fatalf("oversized transaction error mismatch: have %v, want %v", err, ErrOversizedData)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that if transactions start being capped, transactions are also removed from 'all'
 func TestTransactionCapClearsFromAll(t *testing.T) {
 	t.Parallel()
@@ -1369,6 +1470,114 @@ func TestTransactionPoolUnderpricing(t *testing.T) {
 	}
 }
 
+// Tests that privately submitted transactions are never announced as public ones,
+// retaining their private flag across pool resets and journal reloads.
+func TestTransactionPrivate(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary file for the journal
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+	defer os.Remove(journal + ".private")
+
+	file.Close()
+	os.Remove(journal)
+
+	// Create the pool and subscribe to the transaction announcements
+	db, _ := zrmdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Journal = journal
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	events := make(chan TxPreEvent, 32)
+	sub := pool.txFeed.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(addr, big.NewInt(1000000000))
+
+	// Add a private and a public transaction and check their announcements
+	private := transaction(0, big.NewInt(100000), key)
+	public := transaction(1, big.NewInt(100000), key)
+
+	if err := pool.AddPrivate(private); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if err := pool.AddLocal(public); err != nil {
+		t.Fatalf("failed to add public transaction: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-events:
+			if want := ev.Tx.Hash() == private.Hash(); ev.Private != want {
+				t.Errorf("transaction %x private flag mismatch: have %v, want %v", ev.Tx.Hash(), ev.Private, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("announcement #%d not fired", i)
+		}
+	}
+	if !pool.IsPrivate(private.Hash()) || pool.IsPrivate(public.Hash()) {
+		t.Fatalf("private tracking mismatch: private %v, public %v", pool.IsPrivate(private.Hash()), pool.IsPrivate(public.Hash()))
+	}
+	// Re-adding a publicly known transaction privately must not hide it
+	if err := pool.AddPrivate(public); err == nil {
+		t.Fatalf("added known transaction privately")
+	}
+	if pool.IsPrivate(public.Hash()) {
+		t.Fatalf("known public transaction marked private")
+	}
+	// Mine the private transaction, and ensure it's still private when reorged back
+	statedb.SetNonce(addr, 1)
+	pool.lockedReset(nil, nil)
+
+	if pool.all.Get(private.Hash()) != nil {
+		t.Fatalf("mined private transaction still pooled")
+	}
+	if !pool.IsPrivate(private.Hash()) {
+		t.Fatalf("mined private transaction forgotten")
+	}
+	statedb.SetNonce(addr, 0)
+	pool.lockedReset(nil, nil)
+
+	if err := pool.AddRemote(private); err != nil {
+		t.Fatalf("failed to reinject private transaction: %v", err)
+	}
+	for i := 0; i < 2; i++ { // the gapped public transaction is promoted again too
+		select {
+		case ev := <-events:
+			if want := ev.Tx.Hash() == private.Hash(); ev.Private != want {
+				t.Errorf("reinjected transaction %x private flag mismatch: have %v, want %v", ev.Tx.Hash(), ev.Private, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("reinjected announcement #%d not fired", i)
+		}
+	}
+	// Restart the pool and ensure the private flags survive the journal reload
+	pool.Stop()
+	blockchain = &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	if !pool.IsPrivate(private.Hash()) || pool.IsPrivate(public.Hash()) {
+		t.Fatalf("reloaded private tracking mismatch: private %v, public %v", pool.IsPrivate(private.Hash()), pool.IsPrivate(public.Hash()))
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that the pool rejects replacement transactions that don't meet the minimum
 // price bump required.
 func TestTransactionReplacement(t *testing.T) {
@@ -1449,6 +1658,176 @@ func TestTransactionReplacement(t *testing.T) {
 	}
 }
 
+// includingBlockChain is a testBlockChain which returns a preset block, allowing
+// transactions to be included by a chain head.
+type includingBlockChain struct {
+	*testBlockChain
+	block *types.Block
+}
+
+func (bc *includingBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	if bc.block != nil && bc.block.Hash() == hash {
+		return bc.block
+	}
+	return bc.testBlockChain.GetBlock(hash, number)
+}
+
+// Tests that transactions leaving the pool are announced on the drop feed along
+// with the reason of their removal.
+func TestTransactionDropEvents(t *testing.T) {
+	// Reduce the eviction interval to a testable amount
+	defer func(old time.Duration) { evictionInterval = old }(evictionInterval)
+	evictionInterval = time.Second
+
+	db, _ := zrmdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &includingBlockChain{testBlockChain: &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}}
+
+	config := testTxPoolConfig
+	config.Lifetime = time.Second
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	drops := make(chan TxDropEvent, 32)
+	sub := pool.SubscribeTxDropEvent(drops)
+	defer sub.Unsubscribe()
+
+	// Replace a pending transaction and ensure the original is reported
+	original := pricedTransaction(0, big.NewInt(100000), big.NewInt(1), key)
+	replacement := pricedTransaction(0, big.NewInt(100000), big.NewInt(2), key)
+	queued := pricedTransaction(2, big.NewInt(100000), big.NewInt(1), key)
+
+	if err := pool.AddRemote(original); err != nil {
+		t.Fatalf("failed to add original transaction: %v", err)
+	}
+	if err := pool.AddRemote(replacement); err != nil {
+		t.Fatalf("failed to replace original transaction: %v", err)
+	}
+	if err := pool.AddRemote(queued); err != nil {
+		t.Fatalf("failed to add queued transaction: %v", err)
+	}
+	select {
+	case ev := <-drops:
+		if ev.Tx.Hash() != original.Hash() || ev.Reason != TxDropReplaced {
+			t.Fatalf("replacement drop mismatch: have %x/%s, want %x/%s", ev.Tx.Hash(), ev.Reason, original.Hash(), TxDropReplaced)
+		}
+		if ev.Replacement == nil || ev.Replacement.Hash() != replacement.Hash() {
+			t.Fatalf("replacement transaction mismatch: have %v, want %x", ev.Replacement, replacement.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("replacement drop event not fired")
+	}
+	// Reprice the pool and ensure both remaining transactions are reported
+	pool.SetGasPrice(big.NewInt(3))
+
+	dropped := make(map[common.Hash]TxDropReason)
+	for len(dropped) < 2 {
+		select {
+		case ev := <-drops:
+			dropped[ev.Tx.Hash()] = ev.Reason
+		case <-time.After(time.Second):
+			t.Fatalf("underpriced drop events not fired: have %d, want %d", len(dropped), 2)
+		}
+	}
+	for _, tx := range []*types.Transaction{replacement, queued} {
+		if reason, ok := dropped[tx.Hash()]; !ok || reason != TxDropUnderpriced {
+			t.Errorf("transaction %x drop reason mismatch: have %q, want %q", tx.Hash(), reason, TxDropUnderpriced)
+		}
+	}
+	// Include one pending transaction in a new head, and use up the nonce of the
+	// other one by a competing transaction, ensuring the two are told apart
+	included := pricedTransaction(0, big.NewInt(100000), big.NewInt(3), key)
+	stale := pricedTransaction(1, big.NewInt(100000), big.NewInt(3), key)
+	competing := pricedTransaction(1, big.NewInt(100000), big.NewInt(4), key)
+
+	for i, tx := range []*types.Transaction{included, stale} {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add pending transaction %d: %v", i, err)
+		}
+	}
+	parent := blockchain.CurrentBlock().Header()
+	blockchain.block = types.NewBlock(&types.Header{ParentHash: parent.Hash(), Number: big.NewInt(1), GasLimit: parent.GasLimit}, []*types.Transaction{included, competing}, nil, nil)
+
+	pool.currentState.SetNonce(crypto.PubkeyToAddress(key.PublicKey), 2)
+	pool.lockedReset(parent, blockchain.block.Header())
+
+	dropped = make(map[common.Hash]TxDropReason)
+	for len(dropped) < 2 {
+		select {
+		case ev := <-drops:
+			dropped[ev.Tx.Hash()] = ev.Reason
+		case <-time.After(time.Second):
+			t.Fatalf("used up nonce drop events not fired: have %d, want %d", len(dropped), 2)
+		}
+	}
+	if reason := dropped[included.Hash()]; reason != TxDropIncluded {
+		t.Errorf("included transaction drop reason mismatch: have %q, want %q", reason, TxDropIncluded)
+	}
+	if reason := dropped[stale.Hash()]; reason != TxDropNonceTooLow {
+		t.Errorf("stale transaction drop reason mismatch: have %q, want %q", reason, TxDropNonceTooLow)
+	}
+	// Queue up a transaction of an inactive account and ensure it expires
+	expiring := pricedTransaction(3, big.NewInt(100000), big.NewInt(3), key)
+	if err := pool.AddRemote(expiring); err != nil {
+		t.Fatalf("failed to add queued transaction: %v", err)
+	}
+	select {
+	case ev := <-drops:
+		if ev.Tx.Hash() != expiring.Hash() || ev.Reason != TxDropExpired {
+			t.Fatalf("expiry drop mismatch: have %x/%s, want %x/%s", ev.Tx.Hash(), ev.Reason, expiring.Hash(), TxDropExpired)
+		}
+	case <-time.After(3 * config.Lifetime):
+		t.Fatalf("expiry drop event not fired")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the drop events of a pool operation are delivered in the order the
+// transactions were dropped, and that transactions with used up nonces are only
+// reported as included or replaced if the pool knows the included transactions.
+func TestTransactionDropEventsOrdered(t *testing.T) {
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	drops := make(chan TxDropEvent, 32)
+	sub := pool.SubscribeTxDropEvent(drops)
+	defer sub.Unsubscribe()
+
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(account, big.NewInt(1000000000))
+
+	txs := make([]*types.Transaction, 5)
+	for i := range txs {
+		txs[i] = transaction(uint64(i), big.NewInt(100000), key)
+		if err := pool.AddRemote(txs[i]); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	// Use up all nonces without the pool seeing the chain progression
+	pool.currentState.SetNonce(account, uint64(len(txs)))
+	pool.lockedReset(nil, nil)
+
+	for i, tx := range txs {
+		select {
+		case ev := <-drops:
+			if ev.Tx.Hash() != tx.Hash() || ev.Reason != TxDropStale {
+				t.Fatalf("drop %d mismatch: have %x/%s, want %x/%s", i, ev.Tx.Hash(), ev.Reason, tx.Hash(), TxDropStale)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("drop event %d not fired", i)
+		}
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that local transactions are journaled to disk, but remote transactions
 // get discarded between restarts.
 func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false) }
@@ -1464,6 +1843,7 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	}
 	journal := file.Name()
 	defer os.Remove(journal)
+	defer os.Remove(journal + ".private")
 
 	// Clean up the temporary file, we only need the path for now
 	file.Close()
@@ -19,6 +19,7 @@ package vm
 import (
 	"math/big"
 	"sync/atomic"
+	"time"
 
 	"github.com/apolo-technologies/zerium/common"
 	"github.com/apolo-technologies/zerium/crypto"
@@ -135,6 +136,10 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	if evm.vmConfig.Debug {
+		start := evm.captureBegin(CALL, caller.Address(), addr, input, gas, value)
+		defer evm.captureEnd(start, gas, &ret, &leftOverGas, &err)
+	}
 
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
@@ -191,6 +196,10 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	if evm.vmConfig.Debug {
+		start := evm.captureBegin(CALLCODE, caller.Address(), addr, input, gas, value)
+		defer evm.captureEnd(start, gas, &ret, &leftOverGas, &err)
+	}
 
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
@@ -230,6 +239,10 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	if evm.vmConfig.Debug {
+		start := evm.captureBegin(DELEGATECALL, caller.Address(), addr, input, gas, nil)
+		defer evm.captureEnd(start, gas, &ret, &leftOverGas, &err)
+	}
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, ErrDepth
@@ -262,6 +275,10 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		return nil, gas, nil
 	}
+	if evm.vmConfig.Debug {
+		start := evm.captureBegin(STATICCALL, caller.Address(), addr, input, gas, nil)
+		defer evm.captureEnd(start, gas, &ret, &leftOverGas, &err)
+	}
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, ErrDepth
@@ -299,7 +316,12 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 
 // Create creates a new contract using code as deployment code.
 func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
-
+	// Report the creation to the tracer at the address it will deploy to
+	nonce := evm.StateDB.GetNonce(caller.Address())
+	if evm.vmConfig.Debug {
+		start := evm.captureBegin(CREATE, caller.Address(), crypto.CreateAddress(caller.Address(), nonce), code, gas, value)
+		defer evm.captureEnd(start, gas, &ret, &leftOverGas, &err)
+	}
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if evm.depth > int(params.CallCreateDepth) {
@@ -309,7 +331,6 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 		return nil, common.Address{}, gas, ErrInsufficientBalance
 	}
 	// Ensure there's no existing contract already at the designated address
-	nonce := evm.StateDB.GetNonce(caller.Address())
 	evm.StateDB.SetNonce(caller.Address(), nonce+1)
 
 	contractAddr = crypto.CreateAddress(caller.Address(), nonce)
@@ -366,6 +387,29 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	return ret, contractAddr, contract.Gas, err
 }
 
+// captureBegin notifies the tracer about a new call frame: the outermost one
+// starts the trace, any other is entered from its caller. It returns the time
+// the frame started at.
+func (evm *EVM) captureBegin(typ OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) time.Time {
+	if evm.depth == 0 {
+		evm.vmConfig.Tracer.CaptureStart(from, to, typ == CREATE, input, gas, value)
+	} else {
+		evm.vmConfig.Tracer.CaptureEnter(typ, from, to, input, gas, value)
+	}
+	return time.Now()
+}
+
+// captureEnd notifies the tracer about the results of the call frame started
+// by captureBegin. It is meant to be deferred, so the results are passed by
+// reference to be read after the frame returned.
+func (evm *EVM) captureEnd(start time.Time, gas uint64, ret *[]byte, leftOverGas *uint64, err *error) {
+	if evm.depth == 0 {
+		evm.vmConfig.Tracer.CaptureEnd(*ret, gas-*leftOverGas, time.Since(start), *err)
+	} else {
+		evm.vmConfig.Tracer.CaptureExit(*ret, gas-*leftOverGas, *err)
+	}
+}
+
 // ChainConfig returns the evmironment's chain configuration
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
 
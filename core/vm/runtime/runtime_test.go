@@ -17,9 +17,12 @@
 package runtime
 
 import (
+	"fmt"
 	"math/big"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apolo-technologies/zerium/accounts/abi"
 	"github.com/apolo-technologies/zerium/common"
@@ -117,6 +120,68 @@ func TestCall(t *testing.T) {
 	}
 }
 
+// hookTracer is a vm.Tracer recording the call frame hooks it receives.
+type hookTracer struct {
+	events []string
+}
+
+func (t *hookTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.events = append(t.events, fmt.Sprintf("start %x", to[19:]))
+	return nil
+}
+func (t *hookTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+func (t *hookTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	t.events = append(t.events, fmt.Sprintf("fault %v", op))
+	return nil
+}
+func (t *hookTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	t.events = append(t.events, fmt.Sprintf("enter %v %x", typ, to[19:]))
+	return nil
+}
+func (t *hookTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	t.events = append(t.events, fmt.Sprintf("exit %v", err))
+	return nil
+}
+func (t *hookTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.events = append(t.events, fmt.Sprintf("end %v", err))
+	return nil
+}
+
+func TestTracerCallFrames(t *testing.T) {
+	db, _ := zrmdb.NewMemDatabase()
+	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	// 0x0a calls the identity precompile and then 0x0b, which hits an invalid opcode
+	call := func(to byte) []byte {
+		return []byte{
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), to, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+		}
+	}
+	code := append(call(0x04), call(0x0b)...)
+	state.SetCode(common.HexToAddress("0x0a"), append(code, byte(vm.STOP)))
+	state.SetCode(common.HexToAddress("0x0b"), []byte{0xfe})
+
+	tracer := new(hookTracer)
+	if _, _, err := Call(common.HexToAddress("0x0a"), nil, &Config{State: state, EVMConfig: vm.Config{Debug: true, Tracer: tracer}}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	want := []string{
+		"start 0a",
+		"enter CALL 04",
+		"exit <nil>",
+		"enter CALL 0b",
+		"fault Missing opcode 0xfe",
+		"exit invalid opcode 0xfe",
+		"end <nil>",
+	}
+	if !reflect.DeepEqual(tracer.events, want) {
+		t.Errorf("tracer events mismatch:\nhave %q\nwant %q", tracer.events, want)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
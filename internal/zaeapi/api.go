@@ -25,17 +25,20 @@ import (
 	"time"
 
 	"github.com/apolo-technologies/zerium/accounts"
+	"github.com/apolo-technologies/zerium/accounts/abi"
 	"github.com/apolo-technologies/zerium/accounts/keystore"
 	"github.com/apolo-technologies/zerium/common"
 	"github.com/apolo-technologies/zerium/common/hexutil"
 	"github.com/apolo-technologies/zerium/common/math"
 	"github.com/apolo-technologies/zerium/consensus/abthash"
 	"github.com/apolo-technologies/zerium/core"
+	"github.com/apolo-technologies/zerium/core/state"
 	"github.com/apolo-technologies/zerium/core/types"
 	"github.com/apolo-technologies/zerium/core/vm"
 	"github.com/apolo-technologies/zerium/crypto"
 	"github.com/apolo-technologies/zerium/log"
 	"github.com/apolo-technologies/zerium/p2p"
+	"github.com/apolo-technologies/zerium/p2p/discover"
 	"github.com/apolo-technologies/zerium/params"
 	"github.com/apolo-technologies/zerium/rlp"
 	"github.com/apolo-technologies/zerium/rpc"
@@ -64,6 +67,57 @@ func (s *PublicZeriumAPI) GasPrice(ctx context.Context) (*big.Int, error) {
 	return s.b.SuggestPrice(ctx)
 }
 
+// GasPriceTiers is a set of gas price suggestions for different inclusion urgencies.
+type GasPriceTiers struct {
+	Slow     *hexutil.Big `json:"slow"`
+	Standard *hexutil.Big `json:"standard"`
+	Fast     *hexutil.Big `json:"fast"`
+}
+
+// GasPriceTiers returns slow, standard and fast gas price suggestions.
+func (s *PublicZeriumAPI) GasPriceTiers(ctx context.Context) (*GasPriceTiers, error) {
+	slow, standard, fast, err := s.b.SuggestPriceTiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &GasPriceTiers{
+		Slow:     (*hexutil.Big)(slow),
+		Standard: (*hexutil.Big)(standard),
+		Fast:     (*hexutil.Big)(fast),
+	}, nil
+}
+
+// FeeHistoryResult is the gas usage and pricing history of a range of blocks.
+type FeeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// FeeHistory returns the ratio of gas used to the gas limit of a range of blocks
+// ending with lastBlock, along with the requested percentiles of the gas prices
+// paid in each of them, weighted by the gas used by each transaction.
+func (s *PublicZeriumAPI) FeeHistory(ctx context.Context, blockCount hexutil.Uint, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*FeeHistoryResult, error) {
+	oldest, reward, gasUsedRatio, err := s.b.FeeHistory(ctx, int(blockCount), lastBlock, rewardPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	result := &FeeHistoryResult{
+		OldestBlock:  (*hexutil.Big)(oldest),
+		GasUsedRatio: gasUsedRatio,
+	}
+	if reward != nil {
+		result.Reward = make([][]*hexutil.Big, len(reward))
+		for i, prices := range reward {
+			result.Reward[i] = make([]*hexutil.Big, len(prices))
+			for j, price := range prices {
+				result.Reward[i][j] = (*hexutil.Big)(price)
+			}
+		}
+	}
+	return result, nil
+}
+
 // ProtocolVersion returns the current Zerium protocol version this node supports
 func (s *PublicZeriumAPI) ProtocolVersion() hexutil.Uint {
 	return hexutil.Uint(s.b.ProtocolVersion())
@@ -130,13 +184,58 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	return content
 }
 
-// Status returns the number of pending and queued transaction in the pool.
+// Status returns the number of pending and queued transaction in the pool, along
+// with the number of data slots and bytes of memory they take up.
 func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	pending, queue := s.b.Stats()
+	slots, size := s.b.TxPoolUsage()
 	return map[string]hexutil.Uint{
 		"pending": hexutil.Uint(pending),
 		"queued":  hexutil.Uint(queue),
+		"slots":   hexutil.Uint(slots),
+		"bytes":   hexutil.Uint(size),
+	}
+}
+
+// RPCDroppedTransaction is the notification sent to dropped transaction
+// subscribers, detailing why a transaction left the pool.
+type RPCDroppedTransaction struct {
+	Hash        common.Hash       `json:"hash"`
+	Reason      core.TxDropReason `json:"reason"`
+	Replacement *common.Hash      `json:"replacement,omitempty"`
+}
+
+// Dropped creates a subscription that is triggered each time a transaction is
+// dropped from or replaced within the transaction pool.
+func (s *PublicTxPoolAPI) Dropped(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		drops := make(chan core.TxDropEvent, 128)
+		sub := s.b.SubscribeTxDropEvent(drops)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-drops:
+				dropped := &RPCDroppedTransaction{Hash: ev.Tx.Hash(), Reason: ev.Reason}
+				if ev.Replacement != nil {
+					hash := ev.Replacement.Hash()
+					dropped.Replacement = &hash
+				}
+				notifier.Notify(rpcSub.ID, dropped)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
 }
 
 // Inspect retrieves the content of the transaction pool and flattens it into an
@@ -570,6 +669,73 @@ func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.A
 	return res[:], state.Error()
 }
 
+// AccountResult is the Merkle proof of an account and some of its storage slots
+// as returned by GetProof.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the Merkle proof of a single storage slot of an account.
+type StorageResult struct {
+	Key   string          `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// GetProof returns the Merkle proof of the given account and of the requested
+// storage keys of it, in the state of the given block number. The
+// rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block numbers are also
+// allowed.
+func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	storageHash := types.EmptyRootHash
+	if storageTrie := state.StorageTrie(address); storageTrie != nil {
+		storageHash = storageTrie.Hash()
+	}
+	// Create the proofs for the requested storage keys
+	storageProof := make([]StorageResult, len(storageKeys))
+	for i, key := range storageKeys {
+		proof, err := state.GetStorageProof(address, common.HexToHash(key))
+		if err != nil {
+			return nil, err
+		}
+		value := state.GetState(address, common.HexToHash(key)).Big()
+		storageProof[i] = StorageResult{key, (*hexutil.Big)(value), toHexSlice(proof)}
+	}
+	// Create the account proof itself
+	accountProof, err := state.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountResult{
+		Address:      address,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(state.GetBalance(address)),
+		CodeHash:     state.GetCodeHash(address),
+		Nonce:        hexutil.Uint64(state.GetNonce(address)),
+		StorageHash:  storageHash,
+		StorageProof: storageProof,
+	}, state.Error()
+}
+
+// toHexSlice converts a list of binary blobs into their hex encoded form.
+func toHexSlice(b [][]byte) []hexutil.Bytes {
+	r := make([]hexutil.Bytes, len(b))
+	for i := range b {
+		r[i] = b[i]
+	}
+	return r
+}
+
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
 	From     common.Address  `json:"from"`
@@ -580,17 +746,63 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
+// OverrideAccount specifies the fields of an account that should be replaced
+// in the state before a message call is executed on top of it.
+type OverrideAccount struct {
+	Nonce   *hexutil.Uint64             `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Balance *hexutil.Big                `json:"balance"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// StateOverride is the collection of overridden accounts, keyed by address.
+type StateOverride map[common.Address]OverrideAccount
+
+// Apply writes the overridden account fields into the given state.
+func (diff StateOverride) Apply(statedb *state.StateDB) {
+	for addr, account := range diff {
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, uint64(*account.Nonce))
+		}
+		if account.Code != nil {
+			statedb.SetCode(addr, *account.Code)
+		}
+		if account.Balance != nil {
+			statedb.SetBalance(addr, (*big.Int)(account.Balance))
+		}
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+}
+
 func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config) ([]byte, *big.Int, bool, error) {
+	return DoCall(ctx, s.b, args, blockNr, nil, vmCfg)
+}
+
+// DoCall executes the given call message on top of the state of the requested
+// block, after applying any state overrides. It returns the return data, the
+// gas used and whether the execution failed.
+func DoCall(ctx context.Context, b Backend, args CallArgs, blockNr rpc.BlockNumber, overrides StateOverride, vmCfg vm.Config) ([]byte, *big.Int, bool, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
-	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	state, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, common.Big0, false, err
 	}
+	return DoCallAt(ctx, b, args, state, header, overrides, vmCfg)
+}
+
+// DoCallAt executes the given call message on top of the given state and header,
+// after applying any state overrides. It returns the return data, the gas used
+// and whether the execution failed.
+func DoCallAt(ctx context.Context, b Backend, args CallArgs, state *state.StateDB, header *types.Header, overrides StateOverride, vmCfg vm.Config) ([]byte, *big.Int, bool, error) {
+	overrides.Apply(state)
+
 	// Set sender address or use a default if none specified
 	addr := args.From
 	if addr == (common.Address{}) {
-		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
+		if wallets := b.AccountManager().Wallets(); len(wallets) > 0 {
 			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
 				addr = accounts[0].Address
 			}
@@ -621,10 +833,13 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	defer func() { cancel() }()
 
 	// Get a new instance of the EVM.
-	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
+	evm, vmError, err := b.GetEVM(ctx, msg, state, header, vmCfg)
 	if err != nil {
 		return nil, common.Big0, false, err
 	}
+	if tracer, ok := vmCfg.Tracer.(StateTracer); ok && vmCfg.Debug {
+		evm.StateDB = tracer.HookStateDB(evm.StateDB)
+	}
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
 	go func() {
@@ -644,11 +859,44 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
+//
+// If the execution is reverted, the returned error carries the revert payload as
+// its data, and the decoded reason in its message if the payload is a Solidity
+// Error(string).
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{DisableGasMetering: true})
+	result, _, failed, err := s.doCall(ctx, args, blockNr, vm.Config{DisableGasMetering: true})
+	if err == nil && failed && len(result) > 0 {
+		return nil, newRevertError(result)
+	}
 	return (hexutil.Bytes)(result), err
 }
 
+// revertError is an API error carrying the return data of a reverted execution.
+type revertError struct {
+	error
+	reason string // revert payload, hex encoded
+}
+
+// newRevertError creates a revertError from the return data of a reverted
+// execution, decoding its reason into the message if possible.
+func newRevertError(ret []byte) *revertError {
+	err := errors.New("execution reverted")
+	if reason, errUnpack := abi.UnpackRevert(ret); errUnpack == nil {
+		err = fmt.Errorf("execution reverted: %v", reason)
+	}
+	return &revertError{error: err, reason: hexutil.Encode(ret)}
+}
+
+// ErrorCode returns the JSON-RPC error code of reverted executions.
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+// ErrorData returns the hex encoded revert payload.
+func (e *revertError) ErrorData() interface{} {
+	return e.reason
+}
+
 // EstimateGas returns an estimate of the amount of gas needed to execute the
 // given transaction against the current pending block.
 func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*hexutil.Big, error) {
@@ -671,26 +919,34 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*
 	cap = hi
 
 	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
+	// and the return data of the failed execution if any
+	executable := func(gas uint64) (bool, []byte) {
 		(*big.Int)(&args.Gas).SetUint64(gas)
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{})
-		if err != nil || failed {
-			return false
+		res, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{})
+		if err != nil {
+			return false, nil
 		}
-		return true
+		if failed {
+			return false, res
+		}
+		return true, nil
 	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		if ok, _ := executable(mid); !ok {
 			lo = mid
 		} else {
 			hi = mid
 		}
 	}
-	// Reject the transaction as invalid if it still fails at the highest allowance
+	// Reject the transaction as invalid if it still fails at the highest allowance,
+	// reporting the revert reason if the execution was reverted
 	if hi == cap {
-		if !executable(hi) {
+		if ok, ret := executable(hi); !ok {
+			if len(ret) > 0 {
+				return nil, newRevertError(ret)
+			}
 			return nil, fmt.Errorf("gas required exceeds allowance or always failing transaction")
 		}
 	}
@@ -1169,6 +1425,27 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 	return submitTransaction(ctx, s.b, tx)
 }
 
+// SendPrivateTransaction will add the signed transaction to the local pool for
+// inclusion by the node's own miner, without gossiping it to the network. The
+// transaction is only forwarded to the given trusted peers, if any, failing if
+// none of them is connected.
+func (s *PublicTransactionPoolAPI) SendPrivateTransaction(ctx context.Context, encodedTx hexutil.Bytes, trusted *[]discover.NodeID) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+		return common.Hash{}, err
+	}
+	var peers []discover.NodeID
+	if trusted != nil {
+		peers = *trusted
+	}
+	forwarded, err := s.b.SendPrivateTx(ctx, tx, peers)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("Submitted private transaction", "fullhash", tx.Hash().Hex(), "peers", len(peers), "forwarded", forwarded)
+	return tx.Hash(), nil
+}
+
 // Sign calculates an ECDSA signature for:
 // keccack256("\x19Zerium Signed Message:\n" + len(message) + message).
 //
@@ -0,0 +1,100 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zaeapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+func TestStateOverrideApply(t *testing.T) {
+	db, _ := zrmdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	var (
+		touched   = common.HexToAddress("0x01")
+		untouched = common.HexToAddress("0x02")
+	)
+	statedb.SetBalance(touched, big.NewInt(1))
+	statedb.SetState(touched, common.Hash{0x01}, common.Hash{0x01})
+	statedb.SetBalance(untouched, big.NewInt(2))
+
+	var overrides StateOverride
+	input := `{
+		"0x0000000000000000000000000000000000000001": {
+			"nonce": "0x5",
+			"balance": "0x64",
+			"code": "0x6001",
+			"storage": {"0x0200000000000000000000000000000000000000000000000000000000000000": "0x0300000000000000000000000000000000000000000000000000000000000000"}
+		}
+	}`
+	if err := json.Unmarshal([]byte(input), &overrides); err != nil {
+		t.Fatalf("failed to decode overrides: %v", err)
+	}
+	overrides.Apply(statedb)
+
+	if nonce := statedb.GetNonce(touched); nonce != 5 {
+		t.Errorf("nonce mismatch: have %d, want %d", nonce, 5)
+	}
+	if balance := statedb.GetBalance(touched); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", balance, 100)
+	}
+	if code := statedb.GetCode(touched); !bytes.Equal(code, []byte{0x60, 0x01}) {
+		t.Errorf("code mismatch: have %x, want %x", code, []byte{0x60, 0x01})
+	}
+	if value := statedb.GetState(touched, common.Hash{0x02}); value != (common.Hash{0x03}) {
+		t.Errorf("overridden slot mismatch: have %x, want %x", value, common.Hash{0x03})
+	}
+	if value := statedb.GetState(touched, common.Hash{0x01}); value != (common.Hash{0x01}) {
+		t.Errorf("untouched slot mismatch: have %x, want %x", value, common.Hash{0x01})
+	}
+	if balance := statedb.GetBalance(untouched); balance.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("untouched balance mismatch: have %v, want %v", balance, 2)
+	}
+}
+
+// Tests that reverted executions are reported with their decoded reason and the
+// raw revert payload as error data.
+func TestRevertError(t *testing.T) {
+	payload := common.Hex2Bytes("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"6e6f706500000000000000000000000000000000000000000000000000000000")
+
+	err := newRevertError(payload)
+	if err.Error() != "execution reverted: nope" {
+		t.Errorf("message mismatch: have %q, want %q", err.Error(), "execution reverted: nope")
+	}
+	if err.ErrorCode() != 3 {
+		t.Errorf("code mismatch: have %d, want %d", err.ErrorCode(), 3)
+	}
+	if data := err.ErrorData(); data != hexutil.Encode(payload) {
+		t.Errorf("data mismatch: have %v, want %v", data, hexutil.Encode(payload))
+	}
+	// Custom payloads should be retained without a reason
+	err = newRevertError([]byte{0xde, 0xad})
+	if err.Error() != "execution reverted" || err.ErrorData() != "0xdead" {
+		t.Errorf("custom revert mismatch: message %q, data %v", err.Error(), err.ErrorData())
+	}
+}
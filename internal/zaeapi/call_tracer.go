@@ -0,0 +1,150 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zaeapi
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/core/vm"
+)
+
+// callFrame is a single call, create or selfdestruct in the call tree.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     hexutil.Uint64  `json:"gas"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []*callFrame    `json:"calls,omitempty"`
+}
+
+// newCallFrame creates a call frame from the parameters the EVM reports when
+// entering it.
+func newCallFrame(typ string, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) *callFrame {
+	frame := &callFrame{
+		Type:  typ,
+		From:  from,
+		To:    &to,
+		Gas:   hexutil.Uint64(gas),
+		Input: common.CopyBytes(input),
+	}
+	if frame.Input == nil {
+		frame.Input = []byte{}
+	}
+	if value != nil {
+		frame.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+	return frame
+}
+
+// finish fills in the results of a call frame.
+func (f *callFrame) finish(output []byte, gasUsed uint64, err error) {
+	f.GasUsed = hexutil.Uint64(gasUsed)
+	f.Output = common.CopyBytes(output)
+	if err != nil {
+		f.Error = err.Error()
+	}
+}
+
+// CallTracer is a native Go tracer that builds the tree of calls, creates and
+// selfdestructs made during a transaction execution.
+type CallTracer struct {
+	callstack []*callFrame // frames entered but not yet returned, root first
+	finalized bool         // whether CaptureEnd was already invoked
+}
+
+// NewCallTracer creates a new native call tracer.
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+// CaptureStart implements the Tracer interface, opening the root frame.
+func (t *CallTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	typ := vm.CALL
+	if create {
+		typ = vm.CREATE
+	}
+	t.callstack = []*callFrame{newCallFrame(typ.String(), from, to, input, gas, value)}
+	return nil
+}
+
+// CaptureState implements the Tracer interface, recording selfdestructs as
+// they carry no call frame of their own.
+func (t *CallTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if op != vm.SELFDESTRUCT || err != nil || len(t.callstack) == 0 {
+		return nil
+	}
+	frame := newCallFrame(op.String(), contract.Address(), common.BigToAddress(stack.Back(0)), nil, 0, env.StateDB.GetBalance(contract.Address()))
+
+	current := t.callstack[len(t.callstack)-1]
+	current.Calls = append(current.Calls, frame)
+	return nil
+}
+
+// CaptureFault implements the Tracer interface. Failures are reported on the
+// exit of the frame they occurred in.
+func (t *CallTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface, opening an inner call frame.
+func (t *CallTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	t.callstack = append(t.callstack, newCallFrame(typ.String(), from, to, input, gas, value))
+	return nil
+}
+
+// CaptureExit implements the Tracer interface, closing the innermost call frame
+// and attaching it to its parent.
+func (t *CallTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	if len(t.callstack) < 2 {
+		return nil
+	}
+	frame := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+	frame.finish(output, gasUsed, err)
+
+	parent := t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, frame)
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface, closing the root frame.
+func (t *CallTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	if len(t.callstack) != 1 {
+		return nil
+	}
+	t.callstack[0].finish(output, gasUsed, err)
+	t.finalized = true
+	return nil
+}
+
+// GetResult returns the root of the call tree, or an error if the tracing
+// never finished.
+func (t *CallTracer) GetResult() (interface{}, error) {
+	if !t.finalized {
+		return nil, errors.New("call tracer not finalized")
+	}
+	return t.callstack[0], nil
+}
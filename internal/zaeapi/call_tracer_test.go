@@ -0,0 +1,145 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zaeapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/core"
+	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/core/vm"
+	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// tracerTest is a transaction to execute on top of a pre-state, along with
+// the result expected from a native tracer.
+type tracerTest struct {
+	Alloc core.GenesisAlloc `json:"alloc"`
+	Tx    struct {
+		From     common.Address  `json:"from"`
+		To       *common.Address `json:"to"`
+		Gas      hexutil.Big     `json:"gas"`
+		GasPrice hexutil.Big     `json:"gasPrice"`
+		Value    hexutil.Big     `json:"value"`
+		Input    hexutil.Bytes   `json:"input"`
+	} `json:"tx"`
+	Config json.RawMessage `json:"config,omitempty"`
+	Result json.RawMessage `json:"result"`
+}
+
+// runTracerTest executes the transaction of a tracer test with the given
+// tracer attached.
+func runTracerTest(t *testing.T, test *tracerTest, tracer vm.Tracer) {
+	db, _ := zrmdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	for addr, account := range test.Alloc {
+		statedb.SetCode(addr, account.Code)
+		statedb.SetNonce(addr, account.Nonce)
+		statedb.SetBalance(addr, account.Balance)
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      test.Tx.From,
+		GasPrice:    test.Tx.GasPrice.ToInt(),
+		GasLimit:    test.Tx.Gas.ToInt(),
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+		Difficulty:  big.NewInt(1),
+	}
+	msg := types.NewMessage(test.Tx.From, test.Tx.To, 0, test.Tx.Value.ToInt(), test.Tx.Gas.ToInt(), test.Tx.GasPrice.ToInt(), test.Tx.Input, false)
+
+	var vmdb vm.StateDB = statedb
+	if tracer, ok := tracer.(StateTracer); ok {
+		vmdb = tracer.HookStateDB(vmdb)
+	}
+	evm := vm.NewEVM(context, vmdb, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	if _, _, _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(test.Tx.Gas.ToInt())); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+}
+
+// resultTracer is a native tracer producing a JSON-encodable result.
+type resultTracer interface {
+	vm.Tracer
+	GetResult() (interface{}, error)
+}
+
+// runTracerFixtures iterates over all the input-output datasets in the tracer
+// test harness with the given name prefix and runs a fresh tracer against them.
+func runTracerFixtures(t *testing.T, prefix string, newTracer func(test *tracerTest) (resultTracer, error)) {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), prefix) {
+			continue
+		}
+		file := file // capture range variable
+		t.Run(strings.TrimSuffix(strings.TrimPrefix(file.Name(), prefix), ".json"), func(t *testing.T) {
+			blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
+			if err != nil {
+				t.Fatalf("failed to read testcase: %v", err)
+			}
+			test := new(tracerTest)
+			if err := json.Unmarshal(blob, test); err != nil {
+				t.Fatalf("failed to parse testcase: %v", err)
+			}
+			tracer, err := newTracer(test)
+			if err != nil {
+				t.Fatalf("failed to create tracer: %v", err)
+			}
+			runTracerTest(t, test, tracer)
+
+			res, err := tracer.GetResult()
+			if err != nil {
+				t.Fatalf("failed to retrieve trace result: %v", err)
+			}
+			have, _ := json.Marshal(res)
+
+			var haveRes, wantRes interface{}
+			json.Unmarshal(have, &haveRes)
+			json.Unmarshal(test.Result, &wantRes)
+			if !reflect.DeepEqual(haveRes, wantRes) {
+				want, _ := json.Marshal(wantRes)
+				t.Fatalf("trace mismatch:\nhave %s\nwant %s", have, want)
+			}
+		})
+	}
+}
+
+// Iterates over all the input-output datasets in the tracer test harness and
+// runs the native call tracer against them.
+func TestCallTracer(t *testing.T) {
+	runTracerFixtures(t, "call_tracer_", func(test *tracerTest) (resultTracer, error) {
+		return NewCallTracer(), nil
+	})
+}
@@ -0,0 +1,293 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zaeapi
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/core/vm"
+)
+
+// StateTracer is a tracer that observes the state database the EVM executes on,
+// not only the opcodes it runs. The returned database must be used in place of
+// the original one for the traced execution.
+type StateTracer interface {
+	vm.Tracer
+	HookStateDB(db vm.StateDB) vm.StateDB
+}
+
+// prestateAccount is the state of an account as reported by the prestate
+// tracer. In diff mode post-state accounts only carry the changed fields.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// prestateDiff is the result of the prestate tracer in diff mode, holding the
+// modified accounts before and after the execution.
+type prestateDiff struct {
+	Pre  map[common.Address]*prestateAccount `json:"pre"`
+	Post map[common.Address]*prestateAccount `json:"post"`
+}
+
+// PrestateTracerConfig are the configuration options of the prestate tracer.
+type PrestateTracerConfig struct {
+	DiffMode bool `json:"diffMode"` // If true, report the modified accounts before and after execution
+}
+
+// PrestateTracer is a native Go tracer that records the state, prior to the
+// execution, of every account and storage slot a transaction accessed.
+type PrestateTracer struct {
+	config  PrestateTracerConfig
+	db      vm.StateDB                          // state database the execution runs on
+	pre     map[common.Address]*prestateAccount // accounts as first accessed
+	existed map[common.Address]bool             // whether accounts existed when first accessed
+}
+
+// NewPrestateTracer creates a new native prestate tracer.
+func NewPrestateTracer(config PrestateTracerConfig) *PrestateTracer {
+	return &PrestateTracer{
+		config:  config,
+		pre:     make(map[common.Address]*prestateAccount),
+		existed: make(map[common.Address]bool),
+	}
+}
+
+// HookStateDB implements StateTracer, wrapping the database so that all
+// accesses are recorded before they are served.
+func (t *PrestateTracer) HookStateDB(db vm.StateDB) vm.StateDB {
+	t.db = db
+	return &prestateDB{StateDB: db, tracer: t}
+}
+
+// lookupAccount records the state of an account on its first access.
+func (t *PrestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.pre[addr]; ok {
+		return
+	}
+	t.pre[addr] = &prestateAccount{
+		Balance: (*hexutil.Big)(new(big.Int).Set(t.db.GetBalance(addr))),
+		Nonce:   t.db.GetNonce(addr),
+		Code:    common.CopyBytes(t.db.GetCode(addr)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	t.existed[addr] = t.db.Exist(addr)
+}
+
+// lookupStorage records the value of a storage slot on its first access.
+func (t *PrestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.pre[addr].Storage[key]; ok {
+		return
+	}
+	t.pre[addr].Storage[key] = t.db.GetState(addr, key)
+}
+
+// CaptureStart implements the Tracer interface. Accounts are recorded through
+// the hooked state database instead.
+func (t *PrestateTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState implements the Tracer interface.
+func (t *PrestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureFault implements the Tracer interface.
+func (t *PrestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter implements the Tracer interface.
+func (t *PrestateTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureExit implements the Tracer interface.
+func (t *PrestateTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface.
+func (t *PrestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the recorded pre-state, or in diff mode the modified
+// accounts before and after the execution.
+func (t *PrestateTracer) GetResult() (interface{}, error) {
+	if t.db == nil {
+		return nil, errors.New("prestate tracer not attached to a state database")
+	}
+	if !t.config.DiffMode {
+		return t.pre, nil
+	}
+	diff := &prestateDiff{
+		Pre:  make(map[common.Address]*prestateAccount),
+		Post: make(map[common.Address]*prestateAccount),
+	}
+	for addr, prev := range t.pre {
+		pre := &prestateAccount{
+			Balance: prev.Balance,
+			Nonce:   prev.Nonce,
+			Code:    prev.Code,
+			Storage: make(map[common.Hash]common.Hash),
+		}
+		// Self-destructed accounts are gone from the post-state entirely
+		if t.db.HasSuicided(addr) {
+			for key, val := range prev.Storage {
+				if val != (common.Hash{}) {
+					pre.Storage[key] = val
+				}
+			}
+			if t.existed[addr] {
+				diff.Pre[addr] = pre
+			}
+			continue
+		}
+		var (
+			post     = &prestateAccount{Storage: make(map[common.Hash]common.Hash)}
+			modified bool
+		)
+		if balance := t.db.GetBalance(addr); balance.Cmp(prev.Balance.ToInt()) != 0 {
+			post.Balance, modified = (*hexutil.Big)(new(big.Int).Set(balance)), true
+		}
+		if nonce := t.db.GetNonce(addr); nonce != prev.Nonce {
+			post.Nonce, modified = nonce, true
+		}
+		if code := t.db.GetCode(addr); !bytes.Equal(code, prev.Code) {
+			post.Code, modified = common.CopyBytes(code), true
+		}
+		// Only changed slots are reported, zero values denoting empty slots
+		for key, val := range prev.Storage {
+			next := t.db.GetState(addr, key)
+			if next == val {
+				continue
+			}
+			modified = true
+			if val != (common.Hash{}) {
+				pre.Storage[key] = val
+			}
+			if next != (common.Hash{}) {
+				post.Storage[key] = next
+			}
+		}
+		if !modified {
+			continue
+		}
+		if t.existed[addr] {
+			diff.Pre[addr] = pre
+		}
+		diff.Post[addr] = post
+	}
+	return diff, nil
+}
+
+// prestateDB is a vm.StateDB wrapper reporting every account and storage slot
+// accessed through it to the prestate tracer.
+type prestateDB struct {
+	vm.StateDB
+	tracer *PrestateTracer
+}
+
+func (db *prestateDB) CreateAccount(addr common.Address) {
+	db.tracer.lookupAccount(addr)
+	db.StateDB.CreateAccount(addr)
+}
+
+func (db *prestateDB) SubBalance(addr common.Address, amount *big.Int) {
+	db.tracer.lookupAccount(addr)
+	db.StateDB.SubBalance(addr, amount)
+}
+
+func (db *prestateDB) AddBalance(addr common.Address, amount *big.Int) {
+	db.tracer.lookupAccount(addr)
+	db.StateDB.AddBalance(addr, amount)
+}
+
+func (db *prestateDB) GetBalance(addr common.Address) *big.Int {
+	db.tracer.lookupAccount(addr)
+	return db.StateDB.GetBalance(addr)
+}
+
+func (db *prestateDB) GetNonce(addr common.Address) uint64 {
+	db.tracer.lookupAccount(addr)
+	return db.StateDB.GetNonce(addr)
+}
+
+func (db *prestateDB) SetNonce(addr common.Address, nonce uint64) {
+	db.tracer.lookupAccount(addr)
+	db.StateDB.SetNonce(addr, nonce)
+}
+
+func (db *prestateDB) GetCodeHash(addr common.Address) common.Hash {
+	db.tracer.lookupAccount(addr)
+	return db.StateDB.GetCodeHash(addr)
+}
+
+func (db *prestateDB) GetCode(addr common.Address) []byte {
+	db.tracer.lookupAccount(addr)
+	return db.StateDB.GetCode(addr)
+}
+
+func (db *prestateDB) SetCode(addr common.Address, code []byte) {
+	db.tracer.lookupAccount(addr)
+	db.StateDB.SetCode(addr, code)
+}
+
+func (db *prestateDB) GetCodeSize(addr common.Address) int {
+	db.tracer.lookupAccount(addr)
+	return db.StateDB.GetCodeSize(addr)
+}
+
+func (db *prestateDB) GetState(addr common.Address, key common.Hash) common.Hash {
+	db.tracer.lookupStorage(addr, key)
+	return db.StateDB.GetState(addr, key)
+}
+
+func (db *prestateDB) SetState(addr common.Address, key common.Hash, value common.Hash) {
+	db.tracer.lookupStorage(addr, key)
+	db.StateDB.SetState(addr, key, value)
+}
+
+func (db *prestateDB) Suicide(addr common.Address) bool {
+	db.tracer.lookupAccount(addr)
+	return db.StateDB.Suicide(addr)
+}
+
+func (db *prestateDB) HasSuicided(addr common.Address) bool {
+	db.tracer.lookupAccount(addr)
+	return db.StateDB.HasSuicided(addr)
+}
+
+func (db *prestateDB) Exist(addr common.Address) bool {
+	db.tracer.lookupAccount(addr)
+	return db.StateDB.Exist(addr)
+}
+
+func (db *prestateDB) Empty(addr common.Address) bool {
+	db.tracer.lookupAccount(addr)
+	return db.StateDB.Empty(addr)
+}
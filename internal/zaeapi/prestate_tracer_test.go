@@ -0,0 +1,44 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zaeapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Iterates over all the input-output datasets in the tracer test harness and
+// runs the native prestate tracer against them.
+func TestPrestateTracer(t *testing.T) {
+	runTracerFixtures(t, "prestate_tracer_", func(test *tracerTest) (resultTracer, error) {
+		var config PrestateTracerConfig
+		if len(test.Config) > 0 {
+			if err := json.Unmarshal(test.Config, &config); err != nil {
+				return nil, err
+			}
+		}
+		return NewPrestateTracer(config), nil
+	})
+}
+
+// Tests that the prestate tracer refuses to report results if it was never
+// hooked into the state database of an execution.
+func TestPrestateTracerUnhooked(t *testing.T) {
+	if _, err := NewPrestateTracer(PrestateTracerConfig{}).GetResult(); err == nil {
+		t.Fatalf("unhooked tracer produced a result")
+	}
+}
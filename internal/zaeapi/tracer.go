@@ -212,12 +212,15 @@ type JavascriptTracer struct {
 	dbvalue       otto.Value             // JS view of `db`
 	contract      *contractWrapper       // Wrapper around the contract object
 	contractvalue otto.Value             // JS view of `contract`
+	hasEnter      bool                   // Whether the tracer defines `enter`
+	hasExit       bool                   // Whether the tracer defines `exit`
 	err           error                  // Error, if one has occurred
 }
 
 // NewJavascriptTracer instantiates a new JavascriptTracer instance.
 // code specifies a Javascript snippet, which must evaluate to an expression
-// returning an object with 'step' and 'result' functions.
+// returning an object with 'step' and 'result' functions. The object may also
+// define 'enter' and 'exit' functions, called for every inner call frame.
 func NewJavascriptTracer(code string) (*JavascriptTracer, error) {
 	vm := otto.New()
 	vm.Interrupt = make(chan func(), 1)
@@ -248,6 +251,16 @@ func NewJavascriptTracer(code string) (*JavascriptTracer, error) {
 		return nil, fmt.Errorf("Trace object must expose a function result()")
 	}
 
+	// Check for the optional call frame functions
+	enter, err := jstracer.Get("enter")
+	if err != nil {
+		return nil, err
+	}
+	exit, err := jstracer.Get("exit")
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the persistent log object
 	log := make(map[string]interface{})
 	logvalue, _ := vm.ToValue(log)
@@ -271,6 +284,8 @@ func NewJavascriptTracer(code string) (*JavascriptTracer, error) {
 		dbvalue:       db.toValue(vm),
 		contract:      contract,
 		contractvalue: contract.toValue(vm),
+		hasEnter:      enter.IsFunction(),
+		hasExit:       exit.IsFunction(),
 		err:           nil,
 	}, nil
 }
@@ -345,9 +360,59 @@ func (jst *JavascriptTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode,
 	return nil
 }
 
+// CaptureStart implements the Tracer interface. The outermost call is visible
+// to the tracer through the contract of its first step.
+func (jst *JavascriptTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureFault implements the Tracer interface, passing the failing step to
+// the 'step' function with its error set.
+func (jst *JavascriptTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return jst.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+}
+
+// CaptureEnter is called when the EVM enters a new call frame, invoking the
+// optional 'enter' function of the tracer.
+func (jst *JavascriptTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) error {
+	if jst.err != nil || !jst.hasEnter {
+		return nil
+	}
+	frame := map[string]interface{}{
+		"type":  typ.String(),
+		"from":  from,
+		"to":    to,
+		"input": input,
+		"gas":   gas,
+		"value": value,
+	}
+	if _, err := jst.callSafely("enter", frame); err != nil {
+		jst.err = wrapError("enter", err)
+	}
+	return nil
+}
+
+// CaptureExit is called when the EVM returns from a call frame, invoking the
+// optional 'exit' function of the tracer.
+func (jst *JavascriptTracer) CaptureExit(output []byte, gasUsed uint64, err error) error {
+	if jst.err != nil || !jst.hasExit {
+		return nil
+	}
+	result := map[string]interface{}{
+		"output":  output,
+		"gasUsed": gasUsed,
+	}
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	if _, err := jst.callSafely("exit", result); err != nil {
+		jst.err = wrapError("exit", err)
+	}
+	return nil
+}
+
 // CaptureEnd is called after the call finishes
 func (jst *JavascriptTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
-	//TODO! @Arachnid please figure out of there's anything we can use this method for
 	return nil
 }
 
@@ -24,8 +24,11 @@ import (
 	"time"
 
 	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/state"
 	"github.com/apolo-technologies/zerium/core/vm"
+	"github.com/apolo-technologies/zerium/core/vm/runtime"
 	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/zrmdb"
 )
 
 type account struct{}
@@ -110,6 +113,44 @@ func TestOpcodes(t *testing.T) {
 	}
 }
 
+func TestEnterExit(t *testing.T) {
+	tracer, err := NewJavascriptTracer(`{
+		events: [],
+		step: function(log) { if (log.op.toString() == "CALL") this.events.push("CALL " + log.depth); },
+		enter: function(frame) { this.events.push("enter " + frame.type); },
+		exit: function(res) { this.events.push("exit " + (res.error || "ok")); },
+		result: function() { return this.events; }
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, _ := zrmdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	// 0x0a calls 0x0b, which calls 0x0c hitting an invalid opcode before returning
+	call := func(to byte) []byte {
+		return []byte{
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), to, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+		}
+	}
+	statedb.SetCode(common.HexToAddress("0x0a"), append(call(0x0b), byte(vm.STOP)))
+	statedb.SetCode(common.HexToAddress("0x0b"), append(call(0x0c), byte(vm.STOP)))
+	statedb.SetCode(common.HexToAddress("0x0c"), []byte{0xfe})
+
+	if _, _, err := runtime.Call(common.HexToAddress("0x0a"), nil, &runtime.Config{State: statedb, EVMConfig: vm.Config{Debug: true, Tracer: tracer}}); err != nil {
+		t.Fatal(err)
+	}
+	ret, err := tracer.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"CALL 1", "enter CALL", "CALL 2", "enter CALL", "exit invalid opcode 0xfe", "exit ok"}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("Expected return value to be %#v, got %#v", expected, ret)
+	}
+}
+
 func TestHalt(t *testing.T) {
 	timeout := errors.New("stahp")
 	tracer, err := NewJavascriptTracer("{step: function() { while(1); }, result: function() { return null; }}")
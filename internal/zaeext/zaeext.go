@@ -142,8 +142,8 @@ zae._extend({
 		new zae._extend.Method({
 			name: 'startRPC',
 			call: 'admin_startRPC',
-			params: 4,
-			inputFormatter: [null, null, null, null]
+			params: 5,
+			inputFormatter: [null, null, null, null, null]
 		}),
 		new zae._extend.Method({
 			name: 'stopRPC',
@@ -159,6 +159,10 @@ zae._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new zae._extend.Method({
+			name: 'checkpoint',
+			call: 'admin_checkpoint'
+		}),
 	],
 	properties: [
 		new zae._extend.Property({
@@ -338,6 +342,17 @@ zae._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new zae._extend.Method({
+			name: 'traceCall',
+			call: 'debug_traceCall',
+			params: 4,
+			inputFormatter: [null, zae._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
+		new zae._extend.Method({
+			name: 'getTransactionRevertReason',
+			call: 'debug_getTransactionRevertReason',
+			params: 1
+		}),
 		new zae._extend.Method({
 			name: 'preimage',
 			call: 'debug_preimage',
@@ -387,6 +402,35 @@ zae._extend({
 			params: 1,
 			inputFormatter: [zae._extend.formatters.inputTransactionFormatter]
 		}),
+		new zae._extend.Method({
+			name: 'sendPrivateTransaction',
+			call: 'zrm_sendPrivateTransaction',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new zae._extend.Method({
+			name: 'feeHistory',
+			call: 'zrm_feeHistory',
+			params: 3,
+			inputFormatter: [null, zae._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new zae._extend.Method({
+			name: 'gasPriceTiers',
+			call: 'zrm_gasPriceTiers',
+			params: 0
+		}),
+		new zae._extend.Method({
+			name: 'callBundle',
+			call: 'zrm_callBundle',
+			params: 3,
+			inputFormatter: [null, zae._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new zae._extend.Method({
+			name: 'getProof',
+			call: 'zrm_getProof',
+			params: 3,
+			inputFormatter: [zae._extend.formatters.inputAddressFormatter, null, zae._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new zae._extend.Method({
 			name: 'getRawTransaction',
 			call: 'zrm_getRawTransactionByHash',
@@ -443,6 +487,17 @@ zae._extend({
 			call: 'miner_setExtra',
 			params: 1
 		}),
+		new zae._extend.Method({
+			name: 'setStrategy',
+			call: 'miner_setStrategy',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new zae._extend.Method({
+			name: 'getStrategy',
+			call: 'miner_getStrategy',
+			params: 0
+		}),
 		new zae._extend.Method({
 			name: 'setGasPrice',
 			call: 'miner_setGasPrice',
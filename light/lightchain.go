@@ -40,6 +40,10 @@ var (
 	blockCacheLimit = 256
 )
 
+// checkpointTimeout is the time limit for retrieving the header a light chain is
+// anchored at from the trusted checkpoint's CHT.
+const checkpointTimeout = time.Second * 5
+
 // LightChain represents a canonical chain that by default only handles block
 // headers, downloading block bodies and receipts on demand through an ODR
 // interface. It only does header validation during chain insertion.
@@ -95,7 +99,9 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 	if bc.genesisBlock == nil {
 		return nil, core.ErrNoGenesis
 	}
-	if cp, ok := trustedCheckpoints[bc.genesisBlock.Hash()]; ok {
+	if cp := config.Checkpoint; cp != nil {
+		bc.addTrustedCheckpoint(cp)
+	} else if cp, ok := params.TrustedCheckpoints[bc.genesisBlock.Hash()]; ok {
 		bc.addTrustedCheckpoint(cp)
 	}
 
@@ -114,19 +120,19 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 }
 
 // addTrustedCheckpoint adds a trusted checkpoint to the blockchain
-func (self *LightChain) addTrustedCheckpoint(cp trustedCheckpoint) {
+func (self *LightChain) addTrustedCheckpoint(cp *params.TrustedCheckpoint) {
 	if self.odr.ChtIndexer() != nil {
-		StoreChtRoot(self.chainDb, cp.sectionIdx, cp.sectionHead, cp.chtRoot)
-		self.odr.ChtIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		StoreChtRoot(self.chainDb, cp.SectionIndex, cp.SectionHead, cp.CHTRoot)
+		self.odr.ChtIndexer().AddKnownSectionHead(cp.SectionIndex, cp.SectionHead)
 	}
 	if self.odr.BloomTrieIndexer() != nil {
-		StoreBloomTrieRoot(self.chainDb, cp.sectionIdx, cp.sectionHead, cp.bloomTrieRoot)
-		self.odr.BloomTrieIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		StoreBloomTrieRoot(self.chainDb, cp.SectionIndex, cp.SectionHead, cp.BloomRoot)
+		self.odr.BloomTrieIndexer().AddKnownSectionHead(cp.SectionIndex, cp.SectionHead)
 	}
 	if self.odr.BloomIndexer() != nil {
-		self.odr.BloomIndexer().AddKnownSectionHead(cp.sectionIdx, cp.sectionHead)
+		self.odr.BloomIndexer().AddKnownSectionHead(cp.SectionIndex, cp.SectionHead)
 	}
-	log.Info("Added trusted checkpoint", "chain name", cp.name)
+	log.Info("Added trusted checkpoint", "name", cp.Name, "section", cp.SectionIndex, "head", cp.SectionHead)
 }
 
 func (self *LightChain) getProcInterrupt() bool {
@@ -478,6 +484,37 @@ func (self *LightChain) SyncCht(ctx context.Context) bool {
 	return false
 }
 
+// SyncCheckpoint anchors the chain at the given trusted checkpoint if the local
+// head is behind it, retrieving the section head along with its total difficulty
+// from the checkpoint's CHT. Clique chains are anchored at the last epoch header
+// of the checkpointed sections instead, as its signer list is needed to verify
+// the headers after it. The header the chain continues from is returned, or nil
+// if the local chain is already past the checkpoint.
+func (self *LightChain) SyncCheckpoint(cp *params.TrustedCheckpoint) (*types.Header, error) {
+	number := cp.HeadNumber()
+	if clique := self.hc.Config().Clique; clique != nil {
+		number -= number % clique.Epoch
+	}
+	if self.CurrentHeader().Number.Uint64() >= number {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+	defer cancel()
+
+	header, err := GetHeaderByNumber(ctx, self.odr, number)
+	if err != nil {
+		return nil, err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.hc.CurrentHeader().Number.Uint64() < number {
+		self.hc.SetCurrentHeader(header)
+		log.Info("Anchored chain at trusted checkpoint", "number", header.Number, "hash", header.Hash())
+	}
+	return header, nil
+}
+
 // LockChain locks the chain mutex for reading so that multiple canonical hashes can be
 // retrieved while it is guaranteed that they belong to the same version of the chain
 func (self *LightChain) LockChain() {
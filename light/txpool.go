@@ -52,6 +52,7 @@ type TxPool struct {
 	signer       types.Signer
 	quit         chan bool
 	txFeed       event.Feed
+	dropFeed     event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan core.ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -327,6 +328,13 @@ func (pool *TxPool) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Subscri
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeTxDropEvent registers a subscription of core.TxDropEvent and
+// starts sending event to the given channel. The light pool retains its
+// transactions until they are mined, so no drop events are posted yet.
+func (pool *TxPool) SubscribeTxDropEvent(ch chan<- core.TxDropEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
 // Stats returns the number of currently pending (locally created) transactions
 func (pool *TxPool) Stats() (pending int) {
 	pool.mu.RLock()
@@ -336,6 +344,19 @@ func (pool *TxPool) Stats() (pending int) {
 	return
 }
 
+// Usage returns the memory usage of the pending transactions, namely the number
+// of data slots they would take up in a full node's pool and their total size.
+func (pool *TxPool) Usage() (slots int, size common.StorageSize) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	for _, tx := range pool.pending {
+		slots += core.TxSlots(tx)
+		size += tx.Size()
+	}
+	return slots, size
+}
+
 // validateTx checks whether a transaction is valid according to the consensus rules.
 func (pool *TxPool) validateTx(ctx context.Context, tx *types.Transaction) error {
 	// Validate sender
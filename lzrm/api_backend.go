@@ -18,6 +18,7 @@ package lzrm
 
 import (
 	"context"
+	"errors"
 	"math/big"
 
 	"github.com/apolo-technologies/zerium/accounts"
@@ -33,10 +34,15 @@ import (
 	"github.com/apolo-technologies/zerium/zrmdb"
 	"github.com/apolo-technologies/zerium/event"
 	"github.com/apolo-technologies/zerium/light"
+	"github.com/apolo-technologies/zerium/p2p/discover"
 	"github.com/apolo-technologies/zerium/params"
 	"github.com/apolo-technologies/zerium/rpc"
 )
 
+// errPrivateTxUnsupported is returned when submitting a private transaction, as
+// light clients have no miner to include it and must relay it to servers.
+var errPrivateTxUnsupported = errors.New("private transactions not supported by light clients")
+
 type LesApiBackend struct {
 	zrm *LightZerium
 	gpo *gasprice.Oracle
@@ -101,6 +107,10 @@ func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction)
 	return b.zrm.txPool.Add(ctx, signedTx)
 }
 
+func (b *LesApiBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction, peers []discover.NodeID) (int, error) {
+	return 0, errPrivateTxUnsupported
+}
+
 func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.zrm.txPool.RemoveTx(txHash)
 }
@@ -121,6 +131,10 @@ func (b *LesApiBackend) Stats() (pending int, queued int) {
 	return b.zrm.txPool.Stats(), 0
 }
 
+func (b *LesApiBackend) TxPoolUsage() (slots int, size common.StorageSize) {
+	return b.zrm.txPool.Usage()
+}
+
 func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
 	return b.zrm.txPool.Content()
 }
@@ -129,6 +143,10 @@ func (b *LesApiBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Sub
 	return b.zrm.txPool.SubscribeTxPreEvent(ch)
 }
 
+func (b *LesApiBackend) SubscribeTxDropEvent(ch chan<- core.TxDropEvent) event.Subscription {
+	return b.zrm.txPool.SubscribeTxDropEvent(ch)
+}
+
 func (b *LesApiBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.zrm.blockchain.SubscribeChainEvent(ch)
 }
@@ -161,6 +179,18 @@ func (b *LesApiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *LesApiBackend) SuggestPriceTiers(ctx context.Context) (*big.Int, *big.Int, *big.Int, error) {
+	tiers, err := b.gpo.SuggestTiers(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tiers.Slow, tiers.Standard, tiers.Fast, nil
+}
+
+func (b *LesApiBackend) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	return b.gpo.FeeHistory(ctx, blocks, lastBlock, rewardPercentiles)
+}
+
 func (b *LesApiBackend) ChainDb() zrmdb.Database {
 	return b.zrm.chainDb
 }
@@ -86,6 +86,9 @@ func New(ctx *node.ServiceContext, config *zrm.Config) (*LightZerium, error) {
 	if _, isCompat := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !isCompat {
 		return nil, genesisErr
 	}
+	if config.Checkpoint != nil {
+		chainConfig.Checkpoint = config.Checkpoint
+	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
 	peers := newPeerSet()
@@ -170,6 +173,21 @@ func (s *LightDummyAPI) Mining() bool {
 	return false
 }
 
+// PrivateLightAdminAPI is the collection of light client related APIs exposed
+// over the private admin endpoint.
+type PrivateLightAdminAPI struct {
+	lzrm *LightZerium
+}
+
+// Checkpoint returns the trusted checkpoint of the latest section known to the
+// light client, either embedded in the chain config or processed since.
+func (api *PrivateLightAdminAPI) Checkpoint() (*params.TrustedCheckpoint, error) {
+	if cp := latestCheckpoint(api.lzrm.chainDb, api.lzrm.chtIndexer, api.lzrm.bloomTrieIndexer, 1); cp != nil {
+		return cp, nil
+	}
+	return nil, fmt.Errorf("no checkpoint available")
+}
+
 // APIs returns the collection of RPC services the zerium package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *LightZerium) APIs() []rpc.API {
@@ -194,6 +212,10 @@ func (s *LightZerium) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   s.netRPCService,
 			Public:    true,
+		}, {
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   &PrivateLightAdminAPI{s},
 		},
 	}...)
 }
@@ -205,7 +205,7 @@ func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, protoco
 	}
 
 	if lightSync {
-		manager.downloader = downloader.New(downloader.LightSync, chainDb, manager.eventMux, nil, blockchain, removePeer)
+		manager.downloader = downloader.New(downloader.LightSync, chainConfig.Checkpoint, chainDb, manager.eventMux, nil, blockchain, removePeer)
 		manager.peers.notify((*downloaderPeerNotify)(manager))
 		manager.fetcher = newLightFetcher(manager)
 	}
@@ -559,8 +559,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 		// Gather state data until the fetch or network limits is reached
 		var (
-			bytes int
-			data  [][]byte
+			bytes   int
+			data    [][]byte
+			statedb = pm.stateDatabase()
 		)
 		reqCnt := len(req.Reqs)
 		if reject(uint64(reqCnt), MaxCodeFetch) {
@@ -569,7 +570,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		for _, req := range req.Reqs {
 			// Retrieve the requested state entry, stopping if enough was found
 			if header := core.GetHeader(pm.chainDb, req.BHash, core.GetBlockNumber(pm.chainDb, req.BHash)); header != nil {
-				if trie, _ := trie.New(header.Root, pm.chainDb); trie != nil {
+				if trie, _ := trie.New(header.Root, statedb); trie != nil {
 					sdata := trie.Get(req.AccKey)
 					var acc state.Account
 					if err := rlp.DecodeBytes(sdata, &acc); err == nil {
@@ -683,8 +684,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 		// Gather state data until the fetch or network limits is reached
 		var (
-			bytes  int
-			proofs proofsData
+			bytes   int
+			proofs  proofsData
+			statedb = pm.stateDatabase()
 		)
 		reqCnt := len(req.Reqs)
 		if reject(uint64(reqCnt), MaxProofsFetch) {
@@ -696,13 +698,13 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			// Retrieve the requested state entry, stopping if enough was found
 			if header := core.GetHeader(pm.chainDb, req.BHash, core.GetBlockNumber(pm.chainDb, req.BHash)); header != nil {
-				if tr, _ := trie.New(header.Root, pm.chainDb); tr != nil {
+				if tr, _ := trie.New(header.Root, statedb); tr != nil {
 					if len(req.AccKey) > 0 {
 						sdata := tr.Get(req.AccKey)
 						tr = nil
 						var acc state.Account
 						if err := rlp.DecodeBytes(sdata, &acc); err == nil {
-							tr, _ = trie.New(acc.Root, pm.chainDb)
+							tr, _ = trie.New(acc.Root, statedb)
 						}
 					}
 					if tr != nil {
@@ -733,6 +735,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			lastBHash  common.Hash
 			lastAccKey []byte
 			tr, str    *trie.Trie
+			statedb    = pm.stateDatabase()
 		)
 		reqCnt := len(req.Reqs)
 		if reject(uint64(reqCnt), MaxProofsFetch) {
@@ -747,7 +750,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			if tr == nil || req.BHash != lastBHash {
 				if header := core.GetHeader(pm.chainDb, req.BHash, core.GetBlockNumber(pm.chainDb, req.BHash)); header != nil {
-					tr, _ = trie.New(header.Root, pm.chainDb)
+					tr, _ = trie.New(header.Root, statedb)
 				} else {
 					tr = nil
 				}
@@ -761,7 +764,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 						str = nil
 						var acc state.Account
 						if err := rlp.DecodeBytes(sdata, &acc); err == nil {
-							str, _ = trie.New(acc.Root, pm.chainDb)
+							str, _ = trie.New(acc.Root, statedb)
 						}
 						lastAccKey = common.CopyBytes(req.AccKey)
 					}
@@ -1080,6 +1083,20 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	return nil
 }
 
+// stateDatabase returns the database the state tries of the served chain are
+// read from. Full chains cache recent tries in memory before flushing them to
+// disk, so they are served from the trie node cache if there is one.
+func (pm *ProtocolManager) stateDatabase() trie.Database {
+	if bc, ok := pm.blockchain.(interface {
+		StateCache() state.Database
+	}); ok {
+		if triedb := bc.StateCache().TrieDB(); triedb != nil {
+			return triedb
+		}
+	}
+	return pm.chainDb
+}
+
 // getHelperTrie returns the post-processed trie root for the given trie ID and section index
 func (pm *ProtocolManager) getHelperTrie(id uint, idx uint64) (common.Hash, string) {
 	switch id {
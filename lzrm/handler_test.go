@@ -412,7 +412,85 @@ func testGetProofs(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that contract code and merkle proofs of the chain head can be retrieved
+// from a pruning server, which only caches recent tries in memory.
+func TestGetStatePrunedLes1(t *testing.T) { testGetStatePruned(t, 1) }
+
+func TestGetStatePrunedLes2(t *testing.T) { testGetStatePruned(t, 2) }
+
+func testGetStatePruned(t *testing.T, protocol int) {
+	// Assemble the test environment, generating the chain in a separate database
+	// so its state only reaches the server through block import
+	testCacheConfig = &core.CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute}
+	defer func() { testCacheConfig = nil }()
+
+	db, _ := zrmdb.NewMemDatabase()
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil, nil, db)
+	bc := pm.blockchain.(*core.BlockChain)
+	peer, _ := newTestPeer(t, "peer", protocol, pm, true)
+	defer peer.close()
+
+	gendb, _ := zrmdb.NewMemDatabase()
+	gspec := core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
+	}
+	chain, _ := core.GenerateChain(gspec.Config, gspec.MustCommit(gendb), gendb, 4, testChainGen)
+	if _, err := bc.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	header := bc.CurrentHeader()
+	if ok, _ := db.Has(header.Root.Bytes()); ok {
+		t.Fatalf("head state flushed to disk")
+	}
+	// Request the code of the test contract at the head
+	codereqs := []*CodeReq{{BHash: header.Hash(), AccKey: crypto.Keccak256(testContractAddr[:])}}
+	cost := peer.GetRequestCost(GetCodeMsg, len(codereqs))
+	sendRequest(peer.app, GetCodeMsg, 42, cost, codereqs)
+	if err := expectResponse(peer.app, CodeMsg, 42, testBufLimit, [][]byte{testContractCodeDeployed}); err != nil {
+		t.Fatalf("codes mismatch: %v", err)
+	}
+	// Request the proof of the test bank account at the head
+	trie, _ := trie.New(header.Root, bc.StateCache().TrieDB())
+	proofreqs := []ProofReq{{BHash: header.Hash(), Key: crypto.Keccak256(testBankAddress[:])}}
+	switch protocol {
+	case 1:
+		var proof light.NodeList
+		trie.Prove(crypto.Keccak256(testBankAddress[:]), 0, &proof)
+
+		cost := peer.GetRequestCost(GetProofsV1Msg, len(proofreqs))
+		sendRequest(peer.app, GetProofsV1Msg, 43, cost, proofreqs)
+		if err := expectResponse(peer.app, ProofsV1Msg, 43, testBufLimit, [][]rlp.RawValue{proof}); err != nil {
+			t.Errorf("proofs mismatch: %v", err)
+		}
+	case 2:
+		proof := light.NewNodeSet()
+		trie.Prove(crypto.Keccak256(testBankAddress[:]), 0, proof)
+
+		cost := peer.GetRequestCost(GetProofsV2Msg, len(proofreqs))
+		sendRequest(peer.app, GetProofsV2Msg, 43, cost, proofreqs)
+		msg, err := peer.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("Message read error: %v", err)
+		}
+		var resp struct {
+			ReqID, BV uint64
+			Data      light.NodeList
+		}
+		if err := msg.Decode(&resp); err != nil {
+			t.Fatalf("reply decode error: %v", err)
+		}
+		if msg.Code != ProofsV2Msg || resp.ReqID != 43 {
+			t.Fatalf("reply mismatch: code %d, id %d", msg.Code, resp.ReqID)
+		}
+		testCheckProof(t, proof, resp.Data)
+	}
+}
+
 func TestTransactionStatusLes2(t *testing.T) {
+	testCacheConfig = &core.CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute}
+	defer func() { testCacheConfig = nil }()
+
 	db, _ := zrmdb.NewMemDatabase()
 	pm := newTestProtocolManagerMust(t, false, 0, nil, nil, nil, db)
 	chain := pm.blockchain.(*core.BlockChain)
@@ -127,6 +127,11 @@ func testRCL() RequestCostList {
 	return cl
 }
 
+// testCacheConfig is the trie caching configuration of the blockchains created
+// by the test protocol managers. It's nil, making them archive nodes, unless a
+// test needs a pruning one.
+var testCacheConfig *core.CacheConfig
+
 // newTestProtocolManager creates a new protocol manager for testing purposes,
 // with the given number of blocks already known, and potential notification
 // channels for different events.
@@ -148,7 +153,7 @@ func newTestProtocolManager(lightSync bool, blocks int, generator func(int, *cor
 	if lightSync {
 		chain, _ = light.NewLightChain(odr, gspec.Config, engine)
 	} else {
-		blockchain, _ := core.NewBlockChain(db, gspec.Config, engine, vm.Config{})
+		blockchain, _ := core.NewBlockChain(db, testCacheConfig, gspec.Config, engine, vm.Config{})
 		gchain, _ := core.GenerateChain(gspec.Config, genesis, db, blocks, generator)
 		if _, err := blockchain.InsertChain(gchain); err != nil {
 			panic(err)
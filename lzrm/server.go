@@ -34,6 +34,7 @@ import (
 	"github.com/apolo-technologies/zerium/log"
 	"github.com/apolo-technologies/zerium/p2p"
 	"github.com/apolo-technologies/zerium/p2p/discv5"
+	"github.com/apolo-technologies/zerium/params"
 	"github.com/apolo-technologies/zerium/rlp"
 )
 
@@ -123,6 +124,13 @@ func (s *LesServer) Start(srvr *p2p.Server) {
 	s.protocolManager.blockLoop()
 }
 
+// Checkpoint returns the trusted checkpoint of the latest section processed by
+// both the CHT and the BloomTrie indexers, or nil if there's none yet.
+func (s *LesServer) Checkpoint() *params.TrustedCheckpoint {
+	// indexer still uses LES/1 4k section size for backwards server compatibility
+	return latestCheckpoint(s.protocolManager.chainDb, s.chtIndexer, s.bloomTrieIndexer, light.ChtFrequency/light.ChtV1Frequency)
+}
+
 func (s *LesServer) SetBloomBitsIndexer(bloomIndexer *core.ChainIndexer) {
 	bloomIndexer.AddChildIndexer(s.bloomTrieIndexer)
 }
@@ -378,3 +386,30 @@ func (pm *ProtocolManager) blockLoop() {
 		}
 	}()
 }
+
+// latestCheckpoint assembles the trusted checkpoint of the latest section
+// processed by both the CHT and the BloomTrie indexers, or nil if there's none
+// yet. The chtRatio is the number of CHT indexer sections making up a single
+// checkpoint section.
+func latestCheckpoint(db zrmdb.Database, chtIndexer, bloomTrieIndexer *core.ChainIndexer, chtRatio uint64) *params.TrustedCheckpoint {
+	chtSections, _, _ := chtIndexer.Sections()
+	bloomTrieSections, _, _ := bloomTrieIndexer.Sections()
+
+	sections := chtSections / chtRatio
+	if bloomTrieSections < sections {
+		sections = bloomTrieSections
+	}
+	if sections == 0 {
+		return nil
+	}
+	idx := sections - 1
+	chtIdx := (idx+1)*chtRatio - 1
+	head := chtIndexer.SectionHead(chtIdx)
+
+	return &params.TrustedCheckpoint{
+		SectionIndex: idx,
+		SectionHead:  head,
+		CHTRoot:      light.GetChtRoot(db, chtIdx, head),
+		BloomRoot:    light.GetBloomTrieRoot(db, idx, head),
+	}
+}
@@ -163,6 +163,18 @@ func (self *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetStrategy sets the transaction ordering strategy used when building new
+// blocks, taking effect from the next block onwards.
+func (self *Miner) SetStrategy(strategy TxOrderingStrategy) {
+	self.worker.setStrategy(strategy)
+	log.Info("Updated transaction ordering strategy", "strategy", strategy.Name())
+}
+
+// Strategy returns the transaction ordering strategy used when building blocks.
+func (self *Miner) Strategy() TxOrderingStrategy {
+	return self.worker.getStrategy()
+}
+
 // Pending returns the currently pending block and associated state.
 func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 	return self.worker.pending()
@@ -0,0 +1,279 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/types"
+)
+
+const (
+	// PriceGreedyStrategy orders transactions by decreasing gas price.
+	PriceGreedyStrategy = "greedy"
+
+	// LocalFirstStrategy includes the transactions of local accounts before any
+	// remote ones, ordering both groups by decreasing gas price.
+	LocalFirstStrategy = "local"
+
+	// FIFOStrategy orders transactions by their arrival into the pool.
+	FIFOStrategy = "fifo"
+)
+
+// TxPoolInfo is the transaction pool metadata the ordering strategies may rely
+// on besides the pending transactions themselves.
+type TxPoolInfo interface {
+	// Locals retrieves the accounts considered local by the pool.
+	Locals() []common.Address
+
+	// Arrival returns the time a transaction entered the pool.
+	Arrival(hash common.Hash) time.Time
+}
+
+// OrderedTransactions is a set of transactions that can be iterated in the order
+// they should be included into a block, honouring the nonces of each account.
+type OrderedTransactions interface {
+	// Peek returns the next transaction to include, or nil if done.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one from the same
+	// account.
+	Shift()
+
+	// Pop removes the current transaction, discarding all subsequent ones from
+	// the same account.
+	Pop()
+}
+
+// TxOrderingStrategy decides which of the pending transactions the miner should
+// include into a new block, and in which order.
+type TxOrderingStrategy interface {
+	// Name returns a short description of the strategy.
+	Name() string
+
+	// Order creates the set of transactions to include from the pending ones,
+	// grouped by account and sorted by nonce. The input map is reowned.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions, pool TxPoolInfo) OrderedTransactions
+}
+
+// NewStrategy creates a built-in ordering strategy by name, filtering senders by
+// the allow and deny lists if any are given. An empty name selects the default
+// price-greedy strategy.
+func NewStrategy(name string, allow, deny []common.Address) (TxOrderingStrategy, error) {
+	var strategy TxOrderingStrategy
+	switch name {
+	case "", PriceGreedyStrategy:
+		strategy = priceGreedy{}
+	case LocalFirstStrategy:
+		strategy = localFirst{}
+	case FIFOStrategy:
+		strategy = fifo{}
+	default:
+		return nil, fmt.Errorf("unknown ordering strategy %q (want one of %s)", name, strings.Join(Strategies(), ", "))
+	}
+	if len(allow) > 0 || len(deny) > 0 {
+		strategy = NewSenderFilter(strategy, allow, deny)
+	}
+	return strategy, nil
+}
+
+// Strategies returns the names of the built-in ordering strategies.
+func Strategies() []string {
+	return []string{PriceGreedyStrategy, LocalFirstStrategy, FIFOStrategy}
+}
+
+// priceGreedy is the profit-maximising strategy, including the best paying
+// transactions first.
+type priceGreedy struct{}
+
+func (priceGreedy) Name() string { return PriceGreedyStrategy }
+
+func (priceGreedy) Order(signer types.Signer, pending map[common.Address]types.Transactions, pool TxPoolInfo) OrderedTransactions {
+	return types.NewTransactionsByPriceAndNonce(signer, pending)
+}
+
+// localFirst includes all the executable transactions of local accounts before
+// any remote ones, regardless of their pricing.
+type localFirst struct{}
+
+func (localFirst) Name() string { return LocalFirstStrategy }
+
+func (localFirst) Order(signer types.Signer, pending map[common.Address]types.Transactions, pool TxPoolInfo) OrderedTransactions {
+	locals := make(map[common.Address]types.Transactions)
+	for _, account := range pool.Locals() {
+		if txs := pending[account]; len(txs) > 0 {
+			locals[account] = txs
+			delete(pending, account)
+		}
+	}
+	return &chainedTransactions{sets: []OrderedTransactions{
+		types.NewTransactionsByPriceAndNonce(signer, locals),
+		types.NewTransactionsByPriceAndNonce(signer, pending),
+	}}
+}
+
+// fifo includes transactions in the order they arrived into the pool.
+type fifo struct{}
+
+func (fifo) Name() string { return FIFOStrategy }
+
+func (fifo) Order(signer types.Signer, pending map[common.Address]types.Transactions, pool TxPoolInfo) OrderedTransactions {
+	arrivals := make(map[common.Hash]time.Time)
+	for _, txs := range pending {
+		for _, tx := range txs {
+			arrivals[tx.Hash()] = pool.Arrival(tx.Hash())
+		}
+	}
+	return newTransactionsByNonce(signer, pending, func(a, b *types.Transaction) bool {
+		return arrivals[a.Hash()].Before(arrivals[b.Hash()])
+	})
+}
+
+// senderFilter is a strategy wrapper excluding the transactions of certain
+// senders from the blocks built by another strategy.
+type senderFilter struct {
+	strategy TxOrderingStrategy
+	allow    map[common.Address]struct{}
+	deny     map[common.Address]struct{}
+}
+
+// NewSenderFilter wraps an ordering strategy, only including the transactions of
+// senders on the allow list (if non-empty) and not on the deny list.
+func NewSenderFilter(strategy TxOrderingStrategy, allow, deny []common.Address) TxOrderingStrategy {
+	filter := &senderFilter{
+		strategy: strategy,
+		allow:    make(map[common.Address]struct{}),
+		deny:     make(map[common.Address]struct{}),
+	}
+	for _, account := range allow {
+		filter.allow[account] = struct{}{}
+	}
+	for _, account := range deny {
+		filter.deny[account] = struct{}{}
+	}
+	return filter
+}
+
+func (f *senderFilter) Name() string {
+	return fmt.Sprintf("%s (allow %d, deny %d)", f.strategy.Name(), len(f.allow), len(f.deny))
+}
+
+func (f *senderFilter) Order(signer types.Signer, pending map[common.Address]types.Transactions, pool TxPoolInfo) OrderedTransactions {
+	for account := range pending {
+		if _, ok := f.deny[account]; ok {
+			delete(pending, account)
+			continue
+		}
+		if _, ok := f.allow[account]; len(f.allow) > 0 && !ok {
+			delete(pending, account)
+		}
+	}
+	return f.strategy.Order(signer, pending, pool)
+}
+
+// chainedTransactions iterates over multiple transaction sets one after the
+// other, moving to the next set once the current one is exhausted.
+type chainedTransactions struct {
+	sets []OrderedTransactions
+}
+
+func (c *chainedTransactions) Peek() *types.Transaction {
+	for len(c.sets) > 0 {
+		if tx := c.sets[0].Peek(); tx != nil {
+			return tx
+		}
+		c.sets = c.sets[1:]
+	}
+	return nil
+}
+
+func (c *chainedTransactions) Shift() { c.sets[0].Shift() }
+func (c *chainedTransactions) Pop()   { c.sets[0].Pop() }
+
+// txHeads is a heap of the next transaction of each account, sorted by an
+// arbitrary ordering function.
+type txHeads struct {
+	txs  types.Transactions
+	less func(a, b *types.Transaction) bool
+}
+
+func (h txHeads) Len() int            { return len(h.txs) }
+func (h txHeads) Less(i, j int) bool  { return h.less(h.txs[i], h.txs[j]) }
+func (h txHeads) Swap(i, j int)       { h.txs[i], h.txs[j] = h.txs[j], h.txs[i] }
+func (h *txHeads) Push(x interface{}) { h.txs = append(h.txs, x.(*types.Transaction)) }
+
+func (h *txHeads) Pop() interface{} {
+	old := h.txs
+	n := len(old)
+	x := old[n-1]
+	h.txs = old[0 : n-1]
+	return x
+}
+
+// transactionsByNonce is a nonce-honouring set of transactions, returning the
+// next transactions of each account in the order defined by a custom function.
+type transactionsByNonce struct {
+	txs    map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads  *txHeads                              // Next transaction for each unique account
+	signer types.Signer                          // Signer for the set of transactions
+}
+
+// newTransactionsByNonce creates a transaction set ordered by the given function,
+// reowning the input map.
+func newTransactionsByNonce(signer types.Signer, txs map[common.Address]types.Transactions, less func(a, b *types.Transaction) bool) *transactionsByNonce {
+	heads := &txHeads{txs: make(types.Transactions, 0, len(txs)), less: less}
+	for acc, accTxs := range txs {
+		if len(accTxs) == 0 {
+			delete(txs, acc)
+			continue
+		}
+		heads.txs = append(heads.txs, accTxs[0])
+		txs[acc] = accTxs[1:]
+	}
+	heap.Init(heads)
+
+	return &transactionsByNonce{
+		txs:    txs,
+		heads:  heads,
+		signer: signer,
+	}
+}
+
+func (t *transactionsByNonce) Peek() *types.Transaction {
+	if t.heads.Len() == 0 {
+		return nil
+	}
+	return t.heads.txs[0]
+}
+
+func (t *transactionsByNonce) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads.txs[0])
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads.txs[0], t.txs[acc] = txs[0], txs[1:]
+		heap.Fix(t.heads, 0)
+	} else {
+		heap.Pop(t.heads)
+	}
+}
+
+func (t *transactionsByNonce) Pop() {
+	heap.Pop(t.heads)
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/crypto"
+)
+
+// testPoolInfo is a mock transaction pool metadata source.
+type testPoolInfo struct {
+	locals   []common.Address
+	arrivals map[common.Hash]time.Time
+}
+
+func (p *testPoolInfo) Locals() []common.Address           { return p.locals }
+func (p *testPoolInfo) Arrival(hash common.Hash) time.Time { return p.arrivals[hash] }
+
+// orderingFixture is a set of pending transactions from three accounts, with
+// prices and arrival times deliberately ordered differently.
+type orderingFixture struct {
+	signer  types.Signer
+	keys    []*ecdsa.PrivateKey
+	addrs   []common.Address
+	pending map[common.Address]types.Transactions
+	info    *testPoolInfo
+}
+
+// newOrderingFixture creates two transactions for each of three accounts, the
+// first account paying the least but arriving first, and the last paying the
+// most but arriving last.
+func newOrderingFixture() *orderingFixture {
+	f := &orderingFixture{
+		signer:  types.HomesteadSigner{},
+		pending: make(map[common.Address]types.Transactions),
+		info:    &testPoolInfo{arrivals: make(map[common.Hash]time.Time)},
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+
+		f.keys = append(f.keys, key)
+		f.addrs = append(f.addrs, addr)
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, new(big.Int), big.NewInt(21000), big.NewInt(int64(i+1)), nil), f.signer, key)
+			f.pending[addr] = append(f.pending[addr], tx)
+			f.info.arrivals[tx.Hash()] = start.Add(time.Duration(2*i+int(nonce)) * time.Second)
+		}
+	}
+	return f
+}
+
+// senders iterates over an ordered transaction set, returning the index of the
+// sender of each transaction.
+func (f *orderingFixture) senders(txs OrderedTransactions) []int {
+	var order []int
+	for tx := txs.Peek(); tx != nil; tx = txs.Peek() {
+		from, _ := types.Sender(f.signer, tx)
+		for i, addr := range f.addrs {
+			if addr == from {
+				order = append(order, i)
+			}
+		}
+		txs.Shift()
+	}
+	return order
+}
+
+func TestOrderingStrategies(t *testing.T) {
+	tests := []struct {
+		name   string
+		allow  []int
+		deny   []int
+		locals []int
+		want   []int
+	}{
+		{name: PriceGreedyStrategy, want: []int{2, 2, 1, 1, 0, 0}},
+		{name: LocalFirstStrategy, locals: []int{0}, want: []int{0, 0, 2, 2, 1, 1}},
+		{name: FIFOStrategy, want: []int{0, 0, 1, 1, 2, 2}},
+		{name: PriceGreedyStrategy, deny: []int{2}, want: []int{1, 1, 0, 0}},
+		{name: FIFOStrategy, allow: []int{0, 2}, deny: []int{0}, want: []int{2, 2}},
+	}
+	for i, tt := range tests {
+		f := newOrderingFixture()
+
+		var allow, deny []common.Address
+		for _, idx := range tt.allow {
+			allow = append(allow, f.addrs[idx])
+		}
+		for _, idx := range tt.deny {
+			deny = append(deny, f.addrs[idx])
+		}
+		for _, idx := range tt.locals {
+			f.info.locals = append(f.info.locals, f.addrs[idx])
+		}
+		strategy, err := NewStrategy(tt.name, allow, deny)
+		if err != nil {
+			t.Fatalf("test %d: failed to create strategy: %v", i, err)
+		}
+		have := f.senders(strategy.Order(f.signer, f.pending, f.info))
+		if len(have) != len(tt.want) {
+			t.Errorf("test %d (%s): order mismatch: have %v, want %v", i, strategy.Name(), have, tt.want)
+			continue
+		}
+		for j := range have {
+			if have[j] != tt.want[j] {
+				t.Errorf("test %d (%s): order mismatch: have %v, want %v", i, strategy.Name(), have, tt.want)
+				break
+			}
+		}
+	}
+	if _, err := NewStrategy("unknown", nil, nil); err == nil {
+		t.Errorf("unknown strategy created")
+	}
+}
+
+// Tests that popping a transaction of the local-first strategy discards the rest
+// of the account, without affecting the remote transactions.
+func TestLocalFirstPop(t *testing.T) {
+	f := newOrderingFixture()
+	f.info.locals = []common.Address{f.addrs[0]}
+
+	txs := localFirst{}.Order(f.signer, f.pending, f.info)
+	txs.Pop()
+
+	have, want := f.senders(txs), []int{2, 2, 1, 1}
+	if fmt.Sprint(have) != fmt.Sprint(want) {
+		t.Fatalf("order mismatch after pop: have %v, want %v", have, want)
+	}
+}
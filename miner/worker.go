@@ -113,6 +113,7 @@ type worker struct {
 
 	coinbase common.Address
 	extra    []byte
+	strategy TxOrderingStrategy
 
 	currentMu sync.Mutex
 	current   *Work
@@ -142,6 +143,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		proc:           zrm.BlockChain().Validator(),
 		possibleUncles: make(map[common.Hash]*types.Block),
 		coinbase:       coinbase,
+		strategy:       priceGreedy{},
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(zrm.BlockChain(), miningLogAtDepth),
 	}
@@ -170,6 +172,18 @@ func (self *worker) setExtra(extra []byte) {
 	self.extra = extra
 }
 
+func (self *worker) setStrategy(strategy TxOrderingStrategy) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.strategy = strategy
+}
+
+func (self *worker) getStrategy() TxOrderingStrategy {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.strategy
+}
+
 func (self *worker) pending() (*types.Block, *state.StateDB) {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
@@ -262,10 +276,14 @@ func (self *worker) update() {
 		case ev := <-self.txCh:
 			// Apply transaction to the pending state if we're not mining
 			if atomic.LoadInt32(&self.mining) == 0 {
+				self.mu.Lock()
+				strategy := self.strategy
+				self.mu.Unlock()
+
 				self.currentMu.Lock()
 				acc, _ := types.Sender(self.current.signer, ev.Tx)
 				txs := map[common.Address]types.Transactions{acc: {ev.Tx}}
-				txset := types.NewTransactionsByPriceAndNonce(self.current.signer, txs)
+				txset := strategy.Order(self.current.signer, txs, self.zrm.TxPool())
 
 				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase)
 				self.currentMu.Unlock()
@@ -454,7 +472,7 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
+	txs := self.strategy.Order(self.current.signer, pending, self.zrm.TxPool())
 	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
 
 	// compute uncles for the new block.
@@ -507,7 +525,7 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
+func (env *Work) commitTransactions(mux *event.TypeMux, txs OrderedTransactions, bc *core.BlockChain, coinbase common.Address) {
 	gp := new(core.GasPool).AddGas(env.header.GasLimit)
 
 	var coalescedLogs []*types.Log
@@ -18,6 +18,9 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -33,6 +36,7 @@ import (
 	"github.com/apolo-technologies/zerium/log"
 	"github.com/apolo-technologies/zerium/p2p"
 	"github.com/apolo-technologies/zerium/p2p/discover"
+	"github.com/apolo-technologies/zerium/rpc"
 )
 
 const (
@@ -41,6 +45,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirJWTSecret       = "jwtsecret"          // Path within the datadir to the RPC authentication secret
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -66,6 +71,11 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DatabaseBackend is the key/value engine used for the persistent databases
+	// opened within the data directory. If empty, the default LevelDB backend is
+	// used. Ephemeral nodes always use memory databases.
+	DatabaseBackend string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -105,11 +115,28 @@ type Config struct {
 	// useless for custom HTTP clients.
 	HTTPCors []string `toml:",omitempty"`
 
+	// HTTPVirtualHosts is the list of virtual hostnames which are allowed on incoming
+	// requests. This is by default {'localhost'}. Using this prevents attacks like
+	// DNS rebinding, which bypasses SOP by simply masquerading as being within the same
+	// origin. These attacks do not utilize CORS, since they are not cross-domain.
+	// By explicitly checking the Host-header, the server will not allow requests
+	// made against the server with a malicious host domain.
+	// Requests using ip address directly are not affected
+	HTTPVirtualHosts []string `toml:",omitempty"`
+
 	// HTTPModules is a list of API modules to expose via the HTTP RPC interface.
 	// If the module list is empty, all RPC API endpoints designated public will be
 	// exposed.
 	HTTPModules []string `toml:",omitempty"`
 
+	// HTTPTimeouts allows for customization of the timeout values used by the HTTP RPC
+	// interface.
+	HTTPTimeouts rpc.HTTPTimeouts
+
+	// HTTPBodyLimit is the maximum size in bytes of the request bodies accepted by
+	// the HTTP RPC interface. Zero uses the default limit.
+	HTTPBodyLimit int64 `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string `toml:",omitempty"`
@@ -135,6 +162,22 @@ type Config struct {
 	// *WARNING* Only set this if the node is running in a trusted network, exposing
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
+
+	// RPCLimits are the rate and concurrency limits imposed on the calls served by
+	// the HTTP and websocket RPC interfaces, which enforce them jointly.
+	RPCLimits rpc.LimiterConfig `toml:",omitempty"`
+
+	// RPCAccessLog logs every call served by the HTTP and websocket RPC interfaces.
+	RPCAccessLog bool `toml:",omitempty"`
+
+	// JWTAuth requires all HTTP and websocket RPC requests to carry an HS256 JWT
+	// bearer token, the claims of which may restrict the callable APIs.
+	JWTAuth bool `toml:",omitempty"`
+
+	// JWTSecret is the path of the file holding the hex encoded secret the bearer
+	// tokens are signed with. If empty, the secret within the data directory is
+	// used, generating one if missing.
+	JWTSecret string `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -318,6 +361,42 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// JWTAuthSecret retrieves the secret authenticating the RPC bearer tokens from
+// the configured file, falling back to the one found in the data folder. If no
+// secret can be found, a new one is generated and persisted.
+func (c *Config) JWTAuthSecret() ([]byte, error) {
+	path := c.JWTSecret
+	if path == "" {
+		if c.DataDir == "" {
+			return nil, errors.New("JWT secret file required for ephemeral nodes")
+		}
+		path = c.resolvePath(datadirJWTSecret)
+	}
+	if data, err := ioutil.ReadFile(path); err == nil {
+		secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT secret %s: %v", path, err)
+		}
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("JWT secret %s too short: have %d bytes, want at least 32", path, len(secret))
+		}
+		return secret, nil
+	}
+	// No persistent secret found, generate and store a new one.
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, []byte(hex.EncodeToString(secret)), 0600); err != nil {
+		return nil, err
+	}
+	log.Info("Generated JWT secret", "path", path)
+	return secret, nil
+}
+
 // StaticNodes returns a list of node enode URLs configured as static nodes.
 func (c *Config) StaticNodes() []*discover.Node {
 	return c.parsePersistentNodes(c.resolvePath(datadirStaticNodes))
@@ -24,6 +24,7 @@ import (
 
 	"github.com/apolo-technologies/zerium/p2p"
 	"github.com/apolo-technologies/zerium/p2p/nat"
+	"github.com/apolo-technologies/zerium/rpc"
 )
 
 const (
@@ -35,11 +36,13 @@ const (
 
 // DefaultConfig contains reasonable default settings.
 var DefaultConfig = Config{
-	DataDir:     DefaultDataDir(),
-	HTTPPort:    DefaultHTTPPort,
-	HTTPModules: []string{"net", "zae"},
-	WSPort:      DefaultWSPort,
-	WSModules:   []string{"net", "zae"},
+	DataDir:          DefaultDataDir(),
+	HTTPPort:         DefaultHTTPPort,
+	HTTPModules:      []string{"net", "zae"},
+	HTTPVirtualHosts: []string{"localhost"},
+	HTTPTimeouts:     rpc.DefaultHTTPTimeouts,
+	WSPort:           DefaultWSPort,
+	WSModules:        []string{"net", "zae"},
 	P2P: p2p.Config{
 		ListenAddr:      ":32310",
 		DiscoveryV5Addr: ":32311",
@@ -31,6 +31,7 @@ import (
 	"github.com/apolo-technologies/zerium/event"
 	"github.com/apolo-technologies/zerium/internal/debug"
 	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/metrics"
 	"github.com/apolo-technologies/zerium/p2p"
 	"github.com/apolo-technologies/zerium/rpc"
 	"github.com/prometheus/prometheus/util/flock"
@@ -67,6 +68,8 @@ type Node struct {
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	rpcLimiter *rpc.Limiter // Rate and concurrency limits shared by the HTTP and websocket handlers (nil = unlimited)
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 }
@@ -248,6 +251,11 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
 	}
+	// Create the limits shared by the remotely reachable endpoints
+	n.rpcLimiter = nil
+	if limits := n.config.RPCLimits; limits.Client.Rate > 0 || len(limits.Methods) > 0 || len(limits.Namespaces) > 0 || limits.MaxConcurrent > 0 {
+		n.rpcLimiter = rpc.NewLimiter(limits)
+	}
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
@@ -256,7 +264,7 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 		n.stopInProc()
 		return err
 	}
-	if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, n.config.HTTPCors); err != nil {
+	if err := n.startHTTP(n.httpEndpoint, apis, n.config.HTTPModules, n.config.HTTPCors, n.config.HTTPVirtualHosts, n.config.HTTPTimeouts); err != nil {
 		n.stopIPC()
 		n.stopInProc()
 		return err
@@ -358,7 +366,7 @@ func (n *Node) stopIPC() {
 }
 
 // startHTTP initializes and starts the HTTP RPC endpoint.
-func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string) error {
+func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string, vhosts []string, timeouts rpc.HTTPTimeouts) error {
 	// Short circuit if the HTTP endpoint isn't being exposed
 	if endpoint == "" {
 		return nil
@@ -378,6 +386,13 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 			log.Debug(fmt.Sprintf("HTTP registered %T under '%s'", api.Service, api.Namespace))
 		}
 	}
+	if err := n.enableRPCAuth(handler); err != nil {
+		return err
+	}
+	n.enableRPCLimits(handler)
+	n.enableRPCMiddlewares(handler)
+	handler.SetHTTPBodyLimit(n.config.HTTPBodyLimit)
+
 	// All APIs registered, start the HTTP listener
 	var (
 		listener net.Listener
@@ -386,7 +401,7 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, handler).Serve(listener)
+	go rpc.NewHTTPServer(cors, vhosts, timeouts, handler).Serve(listener)
 	log.Info(fmt.Sprintf("HTTP endpoint opened: http://%s", endpoint))
 
 	// All listeners booted successfully
@@ -397,6 +412,40 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	return nil
 }
 
+// enableRPCAuth requires JWT authentication on the given HTTP or websocket RPC
+// handler if configured.
+func (n *Node) enableRPCAuth(handler *rpc.Server) error {
+	if !n.config.JWTAuth {
+		return nil
+	}
+	secret, err := n.config.JWTAuthSecret()
+	if err != nil {
+		return err
+	}
+	handler.EnableJWTAuth(secret)
+	return nil
+}
+
+// enableRPCLimits imposes the configured rate and concurrency limits on the given
+// HTTP or websocket RPC handler. The limits are shared by all handlers, so they
+// apply to the calls served over both transports jointly.
+func (n *Node) enableRPCLimits(handler *rpc.Server) {
+	if n.rpcLimiter != nil {
+		handler.EnableRateLimiting(n.rpcLimiter)
+	}
+}
+
+// enableRPCMiddlewares installs the configured call interceptors on the given
+// HTTP or websocket RPC handler.
+func (n *Node) enableRPCMiddlewares(handler *rpc.Server) {
+	if n.config.RPCAccessLog {
+		handler.Use(rpc.AccessLogMiddleware(log.Root()))
+	}
+	if metrics.Enabled {
+		handler.Use(rpc.LatencyMiddleware())
+	}
+}
+
 // stopHTTP terminates the HTTP RPC endpoint.
 func (n *Node) stopHTTP() {
 	if n.httpListener != nil {
@@ -432,6 +481,12 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 			log.Debug(fmt.Sprintf("WebSocket registered %T under '%s'", api.Service, api.Namespace))
 		}
 	}
+	if err := n.enableRPCAuth(handler); err != nil {
+		return err
+	}
+	n.enableRPCLimits(handler)
+	n.enableRPCMiddlewares(handler)
+
 	// All APIs registered, start the HTTP listener
 	var (
 		listener net.Listener
@@ -639,7 +694,7 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (zrmdb.Database, er
 	if n.config.DataDir == "" {
 		return zrmdb.NewMemDatabase()
 	}
-	return zrmdb.NewLDBDatabase(n.config.resolvePath(name), cache, handles)
+	return zrmdb.Open(n.config.DatabaseBackend, n.config.resolvePath(name), cache, handles)
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
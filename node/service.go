@@ -43,7 +43,7 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (zr
 	if ctx.config.DataDir == "" {
 		return zrmdb.NewMemDatabase()
 	}
-	db, err := zrmdb.NewLDBDatabase(ctx.config.resolvePath(name), cache, handles)
+	db, err := zrmdb.Open(ctx.config.DatabaseBackend, ctx.config.resolvePath(name), cache, handles)
 	if err != nil {
 		return nil, err
 	}
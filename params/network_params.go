@@ -23,4 +23,8 @@ const (
 	// BloomBitsBlocks is the number of blocks a single bloom bit section vector
 	// contains.
 	BloomBitsBlocks uint64 = 4096
+
+	// CheckpointFrequency is the block frequency of the trusted checkpoints, the
+	// size of a single CHT and BloomTrie section.
+	CheckpointFrequency = 32768
 )
@@ -0,0 +1,135 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// authClockDrift is the maximum difference tolerated between the issuance time
+// of a bearer token and the local clock.
+const authClockDrift = time.Minute
+
+var (
+	errMissingToken  = errors.New("missing bearer token")
+	errInvalidToken  = errors.New("invalid bearer token")
+	errMissingExpiry = errors.New("token has no expiry")
+	errIssuanceDrift = errors.New("token issuance time too far from local time")
+)
+
+// AuthClaims are the claims of the JWT bearer tokens authenticating RPC clients.
+// Besides the standard claims, of which the expiry is mandatory, a token can
+// restrict the RPC calls its bearer may make. A token without restrictions may
+// call any method.
+type AuthClaims struct {
+	Modules []string `json:"modules,omitempty"` // RPC namespaces the bearer may call (e.g. "zrm")
+	Methods []string `json:"methods,omitempty"` // RPC methods the bearer may call (e.g. "zrm_blockNumber")
+
+	jwt.StandardClaims
+}
+
+// Valid implements jwt.Claims, requiring an expiry and, if the issuance time is
+// set, bounding its difference to the local clock on top of the standard checks.
+func (c *AuthClaims) Valid() error {
+	if c.ExpiresAt == 0 {
+		return errMissingExpiry
+	}
+	if c.IssuedAt != 0 {
+		drift := jwt.TimeFunc().Sub(time.Unix(c.IssuedAt, 0))
+		if drift > authClockDrift || drift < -authClockDrift {
+			return errIssuanceDrift
+		}
+		// The standard checks reject any issuance time in the future
+		claims := c.StandardClaims
+		claims.IssuedAt = 0
+		return claims.Valid()
+	}
+	return c.StandardClaims.Valid()
+}
+
+// allows returns whether the claims permit calling the given method of the
+// given RPC namespace.
+func (c *AuthClaims) allows(namespace, method string) bool {
+	if len(c.Modules) == 0 && len(c.Methods) == 0 {
+		return true
+	}
+	for _, module := range c.Modules {
+		if module == namespace {
+			return true
+		}
+	}
+	for _, allowed := range c.Methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAuthToken creates an HS256 signed JWT bearer token carrying the given
+// claims, usable to authenticate against servers sharing the same secret.
+func NewAuthToken(secret []byte, claims *AuthClaims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// authClaimsKey is the context key of the claims of authenticated requests.
+type authClaimsKey struct{}
+
+// authClaimsFromContext retrieves the claims of the bearer token the request
+// was authenticated with, if any.
+func authClaimsFromContext(ctx context.Context) (*AuthClaims, bool) {
+	claims, ok := ctx.Value(authClaimsKey{}).(*AuthClaims)
+	return claims, ok
+}
+
+// EnableJWTAuth requires all HTTP and websocket requests to the server to carry
+// a valid HS256 JWT bearer token signed with the given secret, restricting the
+// calls they may make to the ones permitted by the token's claims.
+func (s *Server) EnableJWTAuth(secret []byte) {
+	s.jwtSecret = secret
+}
+
+// authenticate verifies the bearer token of an HTTP request, returning a context
+// carrying its claims. If authentication is disabled, the context is returned
+// unmodified.
+func (s *Server) authenticate(ctx context.Context, r *http.Request) (context.Context, error) {
+	if s.jwtSecret == nil {
+		return ctx, nil
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, errMissingToken
+	}
+	claims := new(AuthClaims)
+	token, err := jwt.ParseWithClaims(strings.TrimPrefix(auth, "Bearer "), claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return s.jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errInvalidToken
+	}
+	return context.WithValue(ctx, authClaimsKey{}, claims), nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestJWTAuthHTTP(t *testing.T) { testJWTAuth(t, "http") }
+func TestJWTAuthWS(t *testing.T)   { testJWTAuth(t, "ws") }
+
+// Tests that servers requiring authentication reject requests without valid
+// bearer tokens, and restrict the calls to the ones permitted by the claims.
+func testJWTAuth(t *testing.T, transport string) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	server := newTestServer("service", new(Service))
+	server.EnableJWTAuth(secret)
+	defer server.Stop()
+
+	var hs *httptest.Server
+	if transport == "ws" {
+		hs = httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	} else {
+		hs = httptest.NewServer(server)
+	}
+	defer hs.Close()
+
+	dial := func(opts ...ClientOption) (*Client, error) {
+		if transport == "ws" {
+			return DialWebsocket(context.Background(), "ws://"+hs.Listener.Addr().String(), "", opts...)
+		}
+		return DialHTTP("http://"+hs.Listener.Addr().String(), opts...)
+	}
+	call := func(method string, opts ...ClientOption) error {
+		client, err := dial(opts...)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		var result Result
+		return client.Call(&result, method, "hello", 10, &Args{"world"})
+	}
+	token := func(key []byte, claims *AuthClaims) string {
+		token, err := NewAuthToken(key, claims)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+		return token
+	}
+	valid := jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix(), IssuedAt: time.Now().Unix()}
+
+	// Unauthenticated and wrongly signed or expired requests must be rejected
+	if err := call("service_echo"); err == nil {
+		t.Errorf("unauthenticated call succeeded")
+	}
+	if err := call("service_echo", WithBearerToken(token([]byte("wrong secret"), &AuthClaims{StandardClaims: valid}))); err == nil {
+		t.Errorf("call with wrongly signed token succeeded")
+	}
+	expired := &AuthClaims{StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(-time.Minute).Unix()}}
+	if err := call("service_echo", WithBearerToken(token(secret, expired))); err == nil {
+		t.Errorf("call with expired token succeeded")
+	}
+	if err := call("service_echo", WithBearerToken(token(secret, new(AuthClaims)))); err == nil {
+		t.Errorf("call with token without expiry succeeded")
+	}
+	// Tokens issued too far from the local time must be rejected, small clock
+	// differences tolerated
+	for _, drift := range []time.Duration{-2 * authClockDrift, 2 * authClockDrift} {
+		drifted := &AuthClaims{StandardClaims: valid}
+		drifted.IssuedAt = time.Now().Add(drift).Unix()
+		if err := call("service_echo", WithBearerToken(token(secret, drifted))); err == nil {
+			t.Errorf("call with token issued %v from now succeeded", drift)
+		}
+	}
+	skewed := &AuthClaims{StandardClaims: valid}
+	skewed.IssuedAt = time.Now().Add(authClockDrift / 2).Unix()
+	if err := call("service_echo", WithBearerToken(token(secret, skewed))); err != nil {
+		t.Errorf("call with token issued slightly in the future failed: %v", err)
+	}
+	// Unrestricted tokens may call anything, restricted ones only the permitted methods
+	if err := call("service_echo", WithBearerToken(token(secret, &AuthClaims{StandardClaims: valid}))); err != nil {
+		t.Errorf("call with unrestricted token failed: %v", err)
+	}
+	if err := call("service_echo", WithBearerToken(token(secret, &AuthClaims{Modules: []string{"service"}, StandardClaims: valid}))); err != nil {
+		t.Errorf("call with module permitting token failed: %v", err)
+	}
+	if err := call("service_echo", WithBearerToken(token(secret, &AuthClaims{Methods: []string{"service_echo"}, StandardClaims: valid}))); err != nil {
+		t.Errorf("call with method permitting token failed: %v", err)
+	}
+	restricted := token(secret, &AuthClaims{Modules: []string{"other"}, Methods: []string{"service_echoWithCtx"}, StandardClaims: valid})
+	if err := call("service_echo", WithBearerToken(restricted)); err == nil {
+		t.Errorf("call not permitted by token succeeded")
+	} else if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != (&unauthorizedError{}).ErrorCode() {
+		t.Errorf("unpermitted call error mismatch: have %v, want code %d", err, (&unauthorizedError{}).ErrorCode())
+	}
+	// Subscriptions may only be cancelled by tokens permitting them
+	unsubscribe := func(token string) error {
+		client, err := dial(WithBearerToken(token))
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		var result bool
+		return client.Call(&result, "service_unsubscribe", "0x1")
+	}
+	if err := unsubscribe(restricted); err == nil {
+		t.Errorf("unsubscribe not permitted by token succeeded")
+	} else if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != (&unauthorizedError{}).ErrorCode() {
+		t.Errorf("unpermitted unsubscribe error mismatch: have %v, want code %d", err, (&unauthorizedError{}).ErrorCode())
+	}
+	permitted := token(secret, &AuthClaims{Methods: []string{"service_subscribe"}, StandardClaims: valid})
+	if err := unsubscribe(permitted); err != nil {
+		if rpcErr, ok := err.(Error); ok && rpcErr.ErrorCode() == (&unauthorizedError{}).ErrorCode() {
+			t.Errorf("unsubscribe permitted by token rejected: %v", err)
+		}
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
@@ -47,8 +48,16 @@ const (
 	defaultDialTimeout   = 10 * time.Second // used when dialing if the context has no deadline
 	defaultWriteTimeout  = 10 * time.Second // used for calls if the context has no deadline
 	subscribeTimeout     = 5 * time.Second  // overall timeout zrm_subscribe, rpc_modules calls
+
+	// Reconnection backoff of resilient clients
+	defaultMinReconnectBackoff = 100 * time.Millisecond
+	defaultMaxReconnectBackoff = time.Minute
 )
 
+// errClientReconnected is returned to requests in flight on a connection that
+// was replaced by a new one.
+var errClientReconnected = errors.New("client reconnected")
+
 const (
 	// Subscriptions are removed when the subscriber cannot keep up.
 	//
@@ -109,6 +118,7 @@ type Client struct {
 	idCounter   uint32
 	connectFunc func(ctx context.Context) (net.Conn, error)
 	isHTTP      bool
+	backoff     *backoffConfig // reconnection backoff of resilient clients (nil = not resilient)
 
 	// writeConn is only safe to access outside dispatch, with the
 	// write lock held. The write lock is taken by sending on
@@ -125,13 +135,15 @@ type Client struct {
 	sendDone    chan error                     // signals write completion, releases write lock
 	respWait    map[string]*requestOp          // active requests
 	subs        map[string]*ClientSubscription // active subscriptions
+	lostSubs    []*ClientSubscription          // subscriptions of resilient clients awaiting resubscription
 }
 
 type requestOp struct {
-	ids  []json.RawMessage
-	err  error
-	resp chan *jsonrpcMessage // receives up to len(ids) responses
-	sub  *ClientSubscription  // only set for EthSubscribe requests
+	ids   []json.RawMessage
+	err   error
+	resp  chan *jsonrpcMessage // receives up to len(ids) responses
+	sub   *ClientSubscription  // only set for EthSubscribe requests
+	resub bool                 // set if sub is being re-established after a reconnect
 }
 
 func (op *requestOp) wait(ctx context.Context) (*jsonrpcMessage, error) {
@@ -178,7 +190,78 @@ func DialContext(ctx context.Context, rawurl string) (*Client, error) {
 	}
 }
 
+// ClientOption configures the transport of a client created by DialHTTP or
+// DialWebsocket.
+type ClientOption func(*clientConfig)
+
+// clientConfig is the transport configuration assembled from client options.
+type clientConfig struct {
+	header  http.Header    // Headers to send with every HTTP request or the websocket handshake
+	backoff *backoffConfig // Reconnection backoff of resilient websocket clients
+}
+
+// backoffConfig is the exponential backoff of the reconnection attempts of
+// resilient clients.
+type backoffConfig struct {
+	min time.Duration // Delay after the first failed attempt
+	max time.Duration // Maximum delay between attempts
+}
+
+// newClientConfig assembles the transport configuration from client options.
+func newClientConfig(opts []ClientOption) *clientConfig {
+	cfg := &clientConfig{header: make(http.Header)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithHeader sets an HTTP header to send with every HTTP request, or with the
+// handshake of websocket connections.
+func WithHeader(key, value string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.header.Set(key, value)
+	}
+}
+
+// WithReconnect makes a websocket client resilient to connection loss. When the
+// connection drops, the client keeps redialing the server, doubling the delay
+// between failed attempts from minBackoff up to maxBackoff. Once reconnected, all
+// active subscriptions are re-issued with their original arguments, and signal
+// the notifications they might have missed on their Gaps channel. Non-positive
+// backoffs are replaced by defaults.
+//
+// Calls in flight when the connection drops still fail. HTTP clients have no
+// connection to lose, so DialHTTP rejects this option.
+func WithReconnect(minBackoff, maxBackoff time.Duration) ClientOption {
+	if minBackoff <= 0 {
+		minBackoff = defaultMinReconnectBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxReconnectBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+	return func(cfg *clientConfig) {
+		cfg.backoff = &backoffConfig{min: minBackoff, max: maxBackoff}
+	}
+}
+
+// WithBearerToken authenticates the client with the given bearer token, such as
+// one created by NewAuthToken.
+func WithBearerToken(token string) ClientOption {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
 func newClient(initctx context.Context, connectFunc func(context.Context) (net.Conn, error)) (*Client, error) {
+	return newResilientClient(initctx, connectFunc, nil)
+}
+
+// newResilientClient creates a client that reconnects with the given backoff if
+// the connection drops, re-establishing its subscriptions. A nil backoff creates
+// a plain client.
+func newResilientClient(initctx context.Context, connectFunc func(context.Context) (net.Conn, error), backoff *backoffConfig) (*Client, error) {
 	conn, err := connectFunc(initctx)
 	if err != nil {
 		return nil, err
@@ -188,6 +271,7 @@ func newClient(initctx context.Context, connectFunc func(context.Context) (net.C
 	c := &Client{
 		writeConn:   conn,
 		isHTTP:      isHTTP,
+		backoff:     backoff,
 		connectFunc: connectFunc,
 		close:       make(chan struct{}),
 		didQuit:     make(chan struct{}),
@@ -391,7 +475,7 @@ func (c *Client) Subscribe(ctx context.Context, namespace string, channel interf
 	op := &requestOp{
 		ids:  []json.RawMessage{msg.ID},
 		resp: make(chan *jsonrpcMessage),
-		sub:  newClientSubscription(c, namespace, chanVal),
+		sub:  newClientSubscription(c, namespace, chanVal, args),
 	}
 
 	// Send the subscription request.
@@ -468,6 +552,96 @@ func (c *Client) reconnect(ctx context.Context) error {
 	}
 }
 
+// redial re-establishes the dropped connection of a resilient client, retrying
+// with exponential backoff until it succeeds or the client is closed. Nothing is
+// done if the connection was already replaced by a call in the meantime.
+func (c *Client) redial(dead net.Conn) {
+	delay := c.backoff.min
+	for {
+		// Take the write lock, which guards the connection
+		select {
+		case c.requestOp <- new(requestOp):
+		case <-c.didQuit:
+			return
+		}
+		var err error
+		if c.writeConn == nil || c.writeConn == dead {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultDialTimeout)
+			err = c.reconnect(ctx)
+			cancel()
+		}
+		c.sendDone <- nil
+
+		if err == nil || err == ErrClientQuit {
+			return
+		}
+		log.Debug("Failed to reconnect RPC client", "err", err, "retry", delay)
+		select {
+		case <-time.After(delay):
+		case <-c.didQuit:
+			return
+		}
+		if delay *= 2; delay > c.backoff.max {
+			delay = c.backoff.max
+		}
+	}
+}
+
+// resubscribe re-issues the given subscriptions of a resilient client over a new
+// connection. Subscriptions failing due to connection loss are retried on the next
+// reconnect, whereas the ones the server refuses are ended with its error.
+func (c *Client) resubscribe(subs []*ClientSubscription) {
+	for i, sub := range subs {
+		select {
+		case <-sub.quit:
+			continue // unsubscribed in the meantime
+		default:
+		}
+		msg, err := c.newMessage(sub.namespace+subscribeMethodSuffix, sub.args...)
+		if err != nil {
+			sub.quitWithError(err, false)
+			continue
+		}
+		op := &requestOp{
+			ids:   []json.RawMessage{msg.ID},
+			resp:  make(chan *jsonrpcMessage),
+			sub:   sub,
+			resub: true,
+		}
+		// The outcome is handled by dispatch, only wait for it to keep the order
+		err = c.send(context.Background(), op, msg)
+		if err == ErrClientQuit {
+			// Nothing will re-establish the remaining subscriptions either
+			for _, sub := range subs[i:] {
+				sub.quitWithError(err, false)
+			}
+			return
+		}
+		if err == nil {
+			op.wait(context.Background())
+		}
+	}
+}
+
+// suspendSubscriptions moves the active subscriptions of a resilient client, and
+// the ones being re-established, aside until the connection is replaced. The
+// pending resubscription requests are released without ending their subscriptions.
+func (c *Client) suspendSubscriptions() {
+	for id, sub := range c.subs {
+		delete(c.subs, id)
+		c.lostSubs = append(c.lostSubs, sub)
+	}
+	for id, op := range c.respWait {
+		if op.resub {
+			c.lostSubs = append(c.lostSubs, op.sub)
+
+			delete(c.respWait, id)
+			op.err = errClientReconnected
+			close(op.resp)
+		}
+	}
+}
+
 // dispatch is the main loop of the client.
 // It sends read messages to waiting calls to Call and BatchCall
 // and subscription notifications to registered subscriptions.
@@ -483,6 +657,10 @@ func (c *Client) dispatch(conn net.Conn) {
 	defer close(c.didQuit)
 	defer func() {
 		c.closeRequestOps(ErrClientQuit)
+		for _, sub := range c.lostSubs {
+			sub.quitWithError(ErrClientQuit, false)
+		}
+		c.lostSubs = nil
 		conn.Close()
 		if reading {
 			// Empty read channels until read is dead.
@@ -525,6 +703,10 @@ func (c *Client) dispatch(conn net.Conn) {
 
 		case err := <-c.readErr:
 			log.Debug(fmt.Sprintf("<-readErr: %v", err))
+			if c.backoff != nil {
+				c.suspendSubscriptions()
+				go c.redial(conn)
+			}
 			c.closeRequestOps(err)
 			conn.Close()
 			reading = false
@@ -535,11 +717,23 @@ func (c *Client) dispatch(conn net.Conn) {
 				// Wait for the previous read loop to exit. This is a rare case.
 				conn.Close()
 				<-c.readErr
+
+				// Nothing in flight on the old connection will be answered anymore
+				if c.backoff != nil {
+					c.suspendSubscriptions()
+					c.closeRequestOps(errClientReconnected)
+				}
 			}
 			go c.read(newconn)
 			reading = true
 			conn = newconn
 
+			// Re-establish the subscriptions lost with the previous connection
+			if len(c.lostSubs) > 0 {
+				go c.resubscribe(c.lostSubs)
+				c.lostSubs = nil
+			}
+
 		// Send path.
 		case op := <-requestOpLock:
 			// Stop listening for further send ops until the current one is done.
@@ -551,12 +745,20 @@ func (c *Client) dispatch(conn net.Conn) {
 
 		case err := <-c.sendDone:
 			if err != nil {
+				// Resubscriptions not yet suspended by a read failure are retried
+				// once the connection is replaced.
+				retry := lastOp.resub && c.respWait[string(lastOp.ids[0])] == lastOp
+
 				// Remove response handlers for the last send. We remove those here
 				// because the error is already handled in Call or BatchCall. When the
 				// read loop goes down, it will signal all other current operations.
 				for _, id := range lastOp.ids {
 					delete(c.respWait, string(id))
 				}
+				if retry {
+					c.lostSubs = append(c.lostSubs, lastOp.sub)
+					go c.redial(conn)
+				}
 			}
 			// Listen for send ops again.
 			requestOpLock = c.requestOp
@@ -565,7 +767,8 @@ func (c *Client) dispatch(conn net.Conn) {
 	}
 }
 
-// closeRequestOps unblocks pending send ops and active subscriptions.
+// closeRequestOps unblocks pending send ops and active subscriptions, ending
+// the subscriptions being re-established too.
 func (c *Client) closeRequestOps(err error) {
 	didClose := make(map[*requestOp]bool)
 
@@ -577,6 +780,10 @@ func (c *Client) closeRequestOps(err error) {
 			op.err = err
 			close(op.resp)
 			didClose[op] = true
+
+			if op.resub {
+				op.sub.quitWithError(err, false)
+			}
 		}
 	}
 	for id, sub := range c.subs {
@@ -621,11 +828,44 @@ func (c *Client) handleResponse(msg *jsonrpcMessage) {
 	defer close(op.resp)
 	if msg.Error != nil {
 		op.err = msg.Error
+		if op.resub {
+			op.sub.quitWithError(msg.Error, false)
+		}
 		return
 	}
-	if op.err = json.Unmarshal(msg.Result, &op.sub.subid); op.err == nil {
+	if op.resub {
+		c.handleResubscribe(op.sub, msg)
+		return
+	}
+	var subid string
+	if op.err = json.Unmarshal(msg.Result, &subid); op.err == nil {
+		op.sub.setID(subid)
 		go op.sub.start()
-		c.subs[op.sub.subid] = op.sub
+		c.subs[subid] = op.sub
+	}
+}
+
+// handleResubscribe registers a subscription re-established after a reconnect
+// under its new id, signaling the gap in its notifications.
+func (c *Client) handleResubscribe(sub *ClientSubscription, msg *jsonrpcMessage) {
+	var subid string
+	if err := json.Unmarshal(msg.Result, &subid); err != nil {
+		sub.quitWithError(err, false)
+		return
+	}
+	sub.setID(subid)
+
+	select {
+	case <-sub.quit:
+		// Unsubscribed while being re-established, drop it on the server too
+		go sub.requestUnsubscribe()
+		return
+	default:
+	}
+	c.subs[subid] = sub
+	select {
+	case sub.gaps <- struct{}{}:
+	default:
 	}
 }
 
@@ -668,8 +908,11 @@ type ClientSubscription struct {
 	etype     reflect.Type
 	channel   reflect.Value
 	namespace string
+	args      []interface{} // arguments to re-issue the subscription with after a reconnect
 	subid     string
+	idLock    sync.Mutex // protects subid, which changes when resubscribing
 	in        chan json.RawMessage
+	gaps      chan struct{}
 
 	quitOnce sync.Once     // ensures quit is closed once
 	quit     chan struct{} // quit is closed when the subscription exits
@@ -677,15 +920,17 @@ type ClientSubscription struct {
 	err      chan error
 }
 
-func newClientSubscription(c *Client, namespace string, channel reflect.Value) *ClientSubscription {
+func newClientSubscription(c *Client, namespace string, channel reflect.Value, args []interface{}) *ClientSubscription {
 	sub := &ClientSubscription{
 		client:    c,
 		namespace: namespace,
+		args:      args,
 		etype:     channel.Type().Elem(),
 		channel:   channel,
 		quit:      make(chan struct{}),
 		err:       make(chan error, 1),
 		in:        make(chan json.RawMessage),
+		gaps:      make(chan struct{}, 1),
 	}
 	return sub
 }
@@ -702,6 +947,15 @@ func (sub *ClientSubscription) Err() <-chan error {
 	return sub.err
 }
 
+// Gaps returns a channel receiving a value whenever the subscription of a client
+// created with WithReconnect was re-established after a connection loss. The
+// notifications sent by the server while disconnected are lost, so consumers
+// should backfill them (e.g. fetch the headers following the last one received
+// on newHeads). Consecutive gaps not yet received are coalesced.
+func (sub *ClientSubscription) Gaps() <-chan struct{} {
+	return sub.gaps
+}
+
 // Unsubscribe unsubscribes the notification and closes the error channel.
 // It can safely be called more than once.
 func (sub *ClientSubscription) Unsubscribe() {
@@ -787,5 +1041,21 @@ func (sub *ClientSubscription) unmarshal(result json.RawMessage) (interface{}, e
 
 func (sub *ClientSubscription) requestUnsubscribe() error {
 	var result interface{}
-	return sub.client.Call(&result, sub.namespace+unsubscribeMethodSuffix, sub.subid)
+	return sub.client.Call(&result, sub.namespace+unsubscribeMethodSuffix, sub.id())
+}
+
+// id returns the current server side id of the subscription.
+func (sub *ClientSubscription) id() string {
+	sub.idLock.Lock()
+	defer sub.idLock.Unlock()
+
+	return sub.subid
+}
+
+// setID updates the server side id of the subscription after resubscribing.
+func (sub *ClientSubscription) setID(subid string) {
+	sub.idLock.Lock()
+	defer sub.idLock.Unlock()
+
+	sub.subid = subid
 }
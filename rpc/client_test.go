@@ -49,6 +49,48 @@ func TestClientRequest(t *testing.T) {
 	}
 }
 
+// DataErrorService is a service failing all calls with a custom error code and
+// additional error data.
+type DataErrorService struct{}
+
+type testDataError struct{}
+
+func (testDataError) Error() string          { return "custom error" }
+func (testDataError) ErrorCode() int         { return 444 }
+func (testDataError) ErrorData() interface{} { return "custom data" }
+
+type testCodeError struct{}
+
+func (testCodeError) Error() string  { return "code error" }
+func (testCodeError) ErrorCode() int { return 555 }
+
+func (s *DataErrorService) Fail() error     { return testDataError{} }
+func (s *DataErrorService) FailCode() error { return testCodeError{} }
+
+// Tests that the code and data of callback errors are relayed to the client.
+func TestClientErrorData(t *testing.T) {
+	server := newTestServer("service", new(DataErrorService))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	err := client.Call(nil, "service_fail")
+	if err == nil {
+		t.Fatal("no error returned")
+	}
+	if err, ok := err.(Error); !ok || err.ErrorCode() != 444 || err.Error() != "custom error" {
+		t.Errorf("error mismatch: have %v, want code 444 and message %q", err, "custom error")
+	}
+	if err, ok := err.(DataError); !ok || err.ErrorData() != "custom data" {
+		t.Errorf("error data mismatch: have %v, want %q", err, "custom data")
+	}
+	// Errors without data are reported with the generic callback error code
+	err = client.Call(nil, "service_failCode")
+	if err, ok := err.(Error); !ok || err.ErrorCode() != -32000 || err.Error() != "code error" {
+		t.Errorf("error mismatch: have %v, want code -32000 and message %q", err, "code error")
+	}
+}
+
 func TestClientBatchRequest(t *testing.T) {
 	server := newTestServer("service", new(Service))
 	defer server.Stop()
@@ -485,6 +527,224 @@ func TestClientReconnect(t *testing.T) {
 	}
 }
 
+// TickerTestService notifies its subscribers of increasing numbers, recording
+// the arguments of the subscriptions it serves.
+type TickerTestService struct {
+	starts chan int
+}
+
+func (s *TickerTestService) Ticks(ctx context.Context, start int) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	s.starts <- start
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		for i := start; ; i++ {
+			if err := notifier.Notify(sub.ID, i); err != nil {
+				return
+			}
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// switchHandler is an HTTP handler forwarding to a replaceable handler.
+type switchHandler struct {
+	handler http.Handler
+	lock    sync.Mutex
+}
+
+func (h *switchHandler) set(handler http.Handler) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.handler = handler
+}
+
+func (h *switchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.lock.Lock()
+	handler := h.handler
+	h.lock.Unlock()
+
+	handler.ServeHTTP(w, r)
+}
+
+// Tests that resilient websocket clients redial dropped connections, re-issue
+// their subscriptions with the original arguments and signal the gap.
+func TestClientReconnectSubscription(t *testing.T) {
+	service := &TickerTestService{starts: make(chan int, 10)}
+
+	s1 := newTestServer("zrm", service)
+	handler := &switchHandler{handler: s1.WebsocketHandler([]string{"*"})}
+	hs := httptest.NewServer(handler)
+	defer hs.Close()
+
+	client, err := DialWebsocket(context.Background(), "ws://"+hs.Listener.Addr().String(), "", WithReconnect(10*time.Millisecond, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal("can't dial", err)
+	}
+	defer client.Close()
+
+	nc := make(chan int, 100)
+	sub, err := client.EthSubscribe(context.Background(), nc, "ticks", 42)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	defer sub.Unsubscribe()
+
+	if start := <-service.starts; start != 42 {
+		t.Fatalf("subscription argument mismatch: have %d, want %d", start, 42)
+	}
+	if val := <-nc; val != 42 {
+		t.Fatalf("first notification mismatch: have %d, want %d", val, 42)
+	}
+	// Drop the connection and refuse a few reconnection attempts
+	handler.set(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	s1.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	// Bring the server back and ensure the subscription is resumed
+	s2 := newTestServer("zrm", service)
+	defer s2.Stop()
+	handler.set(s2.WebsocketHandler([]string{"*"}))
+
+	select {
+	case <-sub.Gaps():
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("subscription not resumed")
+	}
+	if start := <-service.starts; start != 42 {
+		t.Fatalf("resubscription argument mismatch: have %d, want %d", start, 42)
+	}
+	// Drain the notifications received before the drop and wait for new ones
+	for len(nc) > 0 {
+		<-nc
+	}
+	select {
+	case <-nc:
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("no notification after resubscription")
+	}
+	// Plain calls should be served by the new connection as well
+	if err := client.Call(nil, "rpc_modules"); err != nil {
+		t.Fatalf("call after reconnect failed: %v", err)
+	}
+}
+
+// Tests that subscriptions the server refuses to re-establish after a reconnect
+// are ended with the server's error.
+func TestClientReconnectSubscriptionRefused(t *testing.T) {
+	service := &TickerTestService{starts: make(chan int, 10)}
+
+	s1 := newTestServer("zrm", service)
+	handler := &switchHandler{handler: s1.WebsocketHandler([]string{"*"})}
+	hs := httptest.NewServer(handler)
+	defer hs.Close()
+
+	client, err := DialWebsocket(context.Background(), "ws://"+hs.Listener.Addr().String(), "", WithReconnect(10*time.Millisecond, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal("can't dial", err)
+	}
+	defer client.Close()
+
+	nc := make(chan int, 100)
+	sub, err := client.EthSubscribe(context.Background(), nc, "ticks", 1)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	// Restart the server without the subscribed service
+	s2 := newTestServer("other", new(Service))
+	defer s2.Stop()
+	handler.set(s2.WebsocketHandler([]string{"*"}))
+	s1.Stop()
+
+	select {
+	case err := <-sub.Err():
+		if err == nil {
+			t.Fatalf("refused subscription ended without error")
+		}
+	case <-sub.Gaps():
+		t.Fatalf("refused subscription resumed")
+	case <-time.After(5 * time.Second):
+		t.Fatalf("refused subscription not ended")
+	}
+}
+
+// HoldTestService accepts subscriptions without ever answering them, until the
+// connection is closed.
+type HoldTestService struct {
+	arrived chan int
+}
+
+func (s *HoldTestService) Ticks(ctx context.Context, start int) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	s.arrived <- start
+	<-notifier.Closed()
+	return nil, ErrNotificationsUnsupported
+}
+
+// Tests that closing a resilient client while its subscriptions are being
+// re-established ends all of them, the one in flight and the ones queued.
+func TestClientCloseDuringResubscribe(t *testing.T) {
+	s1 := newTestServer("zrm", &TickerTestService{starts: make(chan int, 10)})
+	handler := &switchHandler{handler: s1.WebsocketHandler([]string{"*"})}
+	hs := httptest.NewServer(handler)
+	defer hs.Close()
+
+	client, err := DialWebsocket(context.Background(), "ws://"+hs.Listener.Addr().String(), "", WithReconnect(10*time.Millisecond, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal("can't dial", err)
+	}
+	var subs []*ClientSubscription
+	for i := 0; i < 2; i++ {
+		sub, err := client.EthSubscribe(context.Background(), make(chan int, 100), "ticks", i)
+		if err != nil {
+			t.Fatal("can't subscribe:", err)
+		}
+		subs = append(subs, sub)
+	}
+	// Drop the connection and hold the first resubscription on the new one
+	service := &HoldTestService{arrived: make(chan int, 10)}
+	s2 := newTestServer("zrm", service)
+	defer s2.Stop()
+	handler.set(s2.WebsocketHandler([]string{"*"}))
+	s1.Stop()
+
+	select {
+	case <-service.arrived:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("subscriptions not re-established")
+	}
+	client.Close()
+
+	for i, sub := range subs {
+		select {
+		case <-sub.Err():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("subscription %d not ended by close", i)
+		}
+	}
+}
+
 func newTestServer(serviceName string, service interface{}) *Server {
 	server := NewServer()
 	if err := server.RegisterName(serviceName, service); err != nil {
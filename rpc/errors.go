@@ -58,6 +58,24 @@ func (e *callbackError) ErrorCode() int { return -32000 }
 
 func (e *callbackError) Error() string { return e.message }
 
+// request isn't permitted by the claims of the bearer token
+type unauthorizedError struct{ method string }
+
+func (e *unauthorizedError) ErrorCode() int { return -32001 }
+
+func (e *unauthorizedError) Error() string {
+	return fmt.Sprintf("The method %s is not permitted by the bearer token", e.method)
+}
+
+// request exceeds one of the rate or concurrency limits of the server
+type limitExceededError struct{ method, limit string }
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string {
+	return fmt.Sprintf("The method %s exceeds the %s limit", e.method, e.limit)
+}
+
 // issued when a request is received after the server is issued to stop.
 type shutdownError struct{}
 
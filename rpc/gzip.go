@@ -0,0 +1,129 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// gzPool caches the gzip writers compressing HTTP responses to avoid allocating
+// the sizable compression state for every single request.
+var gzPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(ioutil.Discard)
+	},
+}
+
+// gzipResponseWriter is an http.ResponseWriter compressing everything written
+// into it before passing it on to the wrapped writer. The compression is only
+// set up once the first bytes of the body are written, so empty responses are
+// sent as they are.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+
+	gz      *gzip.Writer // Compressor of the body, nil until the first write
+	status  int          // Status code requested by the handler, sent with the header
+	written bool         // Whether the header was already sent
+}
+
+// WriteHeader records the status code, deferring the header until it's known
+// whether the response has a body to compress.
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write compresses b into the wrapped response writer, sending the header along
+// with the gzip encoding before the first non-empty write.
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if !w.written {
+		// Drop any preset content length, which would refer to the uncompressed
+		// response, before sending the header
+		w.Header().Set("content-encoding", "gzip")
+		w.Header().Del("content-length")
+		w.writeHeader()
+
+		w.gz = gzPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	if w.gz == nil {
+		// The header was flushed out before the body, send it uncompressed
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Flush sends any buffered compressed data to the client, implementing
+// http.Flusher if the wrapped writer does.
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	} else if !w.written {
+		w.writeHeader()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeHeader sends the header with the recorded status code.
+func (w *gzipResponseWriter) writeHeader() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.written = true
+}
+
+// close terminates the compressed stream and returns the compressor to the
+// pool, or sends the header of empty responses.
+func (w *gzipResponseWriter) close() {
+	if w.gz == nil {
+		if !w.written {
+			w.writeHeader()
+		}
+		return
+	}
+	w.gz.Close()
+	gzPool.Put(w.gz)
+	w.gz = nil
+}
+
+// newGzipHandler wraps an HTTP handler, compressing its responses if the client
+// signals support for gzip encoding.
+func newGzipHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("accept-encoding"), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("vary", "accept-encoding")
+
+		wrapped := &gzipResponseWriter{ResponseWriter: w}
+		defer wrapped.close()
+
+		next.ServeHTTP(wrapped, r)
+	})
+}
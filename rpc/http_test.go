@@ -0,0 +1,252 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestHTTPServer starts an HTTP RPC server around the test service.
+func newTestHTTPServer(vhosts []string) (*Server, *httptest.Server) {
+	server := newTestServer("service", new(Service))
+	return server, httptest.NewServer(NewHTTPServer(nil, vhosts, DefaultHTTPTimeouts, server).Handler)
+}
+
+// postTestRequest sends a raw JSON-RPC request to the given HTTP endpoint.
+func postTestRequest(t *testing.T, url string, host string, body string, header http.Header) *http.Response {
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("content-type", "application/json")
+	if host != "" {
+		req.Host = host
+	}
+	resp, err := new(http.Transport).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	return resp
+}
+
+const testHTTPRequest = `{"jsonrpc":"2.0","id":1,"method":"service_echo","params":["hello",10,{"S":"world"}]}`
+
+// Tests that requests with hostnames outside of the virtual host allowlist are
+// rejected, while direct IP requests are always served.
+func TestHTTPVirtualHosts(t *testing.T) {
+	server, hs := newTestHTTPServer([]string{"localhost", "Node.Example"})
+	defer server.Stop()
+	defer hs.Close()
+
+	tests := []struct {
+		host   string
+		status int
+	}{
+		{"", http.StatusOK},                             // IP address of the test listener
+		{"localhost:8545", http.StatusOK},               // allowlisted with port
+		{"node.example", http.StatusOK},                 // allowlisted, case insensitive
+		{"127.0.0.1:8545", http.StatusOK},               // direct IP request
+		{"[::1]:8545", http.StatusOK},                   // direct IPv6 request
+		{"attacker.example", http.StatusForbidden},      // DNS rebinding attempt
+		{"attacker.example:8545", http.StatusForbidden}, // DNS rebinding attempt with port
+	}
+	for i, tt := range tests {
+		resp := postTestRequest(t, hs.URL, tt.host, testHTTPRequest, nil)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("test %d (host %q): status mismatch: have %d, want %d", i, tt.host, resp.StatusCode, tt.status)
+		}
+	}
+	// Wildcards should permit all hostnames
+	wserver, whs := newTestHTTPServer([]string{"*"})
+	defer wserver.Stop()
+	defer whs.Close()
+
+	resp := postTestRequest(t, whs.URL, "attacker.example", testHTTPRequest, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("wildcard vhost: status mismatch: have %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+// Tests that request bodies above the configured limit are rejected, regardless
+// of whether their length is announced upfront.
+func TestHTTPBodyLimit(t *testing.T) {
+	server, hs := newTestHTTPServer([]string{"*"})
+	defer server.Stop()
+	defer hs.Close()
+
+	// Requests within the default limit should be served
+	resp := postTestRequest(t, hs.URL, "", testHTTPRequest, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("default limit: status mismatch: have %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	// Lower the limit and ensure the same request is rejected
+	server.SetHTTPBodyLimit(int64(len(testHTTPRequest) - 1))
+
+	resp = postTestRequest(t, hs.URL, "", testHTTPRequest, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("lowered limit: status mismatch: have %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+	// Requests hiding their length must not be able to get around the limit
+	req, _ := http.NewRequest("POST", hs.URL, ioutil.NopCloser(strings.NewReader(testHTTPRequest)))
+	req.Header.Set("content-type", "application/json")
+	req.ContentLength = -1
+
+	if resp, err := new(http.Transport).RoundTrip(req); err == nil {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if strings.Contains(string(body), `"result"`) {
+			t.Errorf("chunked request above limit served: %s", body)
+		}
+	}
+	// Restoring the default limit should serve the request again
+	server.SetHTTPBodyLimit(0)
+
+	resp = postTestRequest(t, hs.URL, "", testHTTPRequest, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("restored limit: status mismatch: have %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+// Tests that responses are gzip compressed only for clients accepting it.
+func TestHTTPGzip(t *testing.T) {
+	server, hs := newTestHTTPServer([]string{"*"})
+	defer server.Stop()
+	defer hs.Close()
+
+	// Plain requests should get plain responses
+	resp := postTestRequest(t, hs.URL, "", testHTTPRequest, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if enc := resp.Header.Get("content-encoding"); enc != "" {
+		t.Errorf("plain response encoding mismatch: have %q, want none", enc)
+	}
+	if !strings.Contains(string(body), `"result"`) {
+		t.Errorf("plain response missing result: %s", body)
+	}
+	// Requests accepting gzip should get compressed responses
+	resp = postTestRequest(t, hs.URL, "", testHTTPRequest, http.Header{"Accept-Encoding": {"gzip"}})
+	defer resp.Body.Close()
+
+	if enc := resp.Header.Get("content-encoding"); enc != "gzip" {
+		t.Fatalf("compressed response encoding mismatch: have %q, want %q", enc, "gzip")
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to open compressed response: %v", err)
+	}
+	if unzipped, err := ioutil.ReadAll(gz); err != nil {
+		t.Fatalf("failed to decompress response: %v", err)
+	} else if string(unzipped) != string(body) {
+		t.Errorf("decompressed response mismatch: have %s, want %s", unzipped, body)
+	}
+}
+
+// Tests that the gzip handler leaves empty responses uncompressed, and passes
+// flushes of streamed responses through to the client.
+func TestHTTPGzipLazy(t *testing.T) {
+	header := http.Header{"Accept-Encoding": {"gzip"}}
+
+	// Empty responses should keep their status without an encoding
+	empty := newGzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header = header
+
+	rec := httptest.NewRecorder()
+	empty.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("empty response status mismatch: have %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if enc := rec.Header().Get("content-encoding"); enc != "" {
+		t.Errorf("empty response encoding mismatch: have %q, want none", enc)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("empty response has a body: %x", rec.Body.Bytes())
+	}
+	// Streamed responses should be decompressible up to every flush
+	flushed := make(chan struct{})
+	resume := make(chan struct{})
+	streamed := newGzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-length", "11")
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+
+		close(flushed)
+		<-resume
+		w.Write([]byte(" world"))
+	}))
+	rec = httptest.NewRecorder()
+	go func() {
+		<-flushed
+		defer close(resume)
+
+		if !rec.Flushed {
+			t.Errorf("flush not passed through")
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			t.Errorf("failed to open flushed response: %v", err)
+			return
+		}
+		part := make([]byte, 5)
+		if _, err := io.ReadFull(gz, part); err != nil || string(part) != "hello" {
+			t.Errorf("flushed response mismatch: have %q (%v), want %q", part, err, "hello")
+		}
+	}()
+	req = httptest.NewRequest("POST", "/", nil)
+	req.Header = header
+	streamed.ServeHTTP(rec, req)
+
+	if enc := rec.Header().Get("content-encoding"); enc != "gzip" {
+		t.Fatalf("streamed response encoding mismatch: have %q, want %q", enc, "gzip")
+	}
+	if length := rec.Header().Get("content-length"); length != "" {
+		t.Errorf("uncompressed content length kept: %s", length)
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("failed to open streamed response: %v", err)
+	}
+	if unzipped, err := ioutil.ReadAll(gz); err != nil || string(unzipped) != "hello world" {
+		t.Errorf("streamed response mismatch: have %q (%v), want %q", unzipped, err, "hello world")
+	}
+}
+
+// Tests that HTTP clients refuse to be made resilient to connection loss, as
+// they have no connection to lose.
+func TestHTTPRejectReconnect(t *testing.T) {
+	if _, err := DialHTTP("http://127.0.0.1:8545", WithReconnect(0, 0)); err != errReconnectUnsupported {
+		t.Fatalf("error mismatch: have %v, want %v", err, errReconnectUnsupported)
+	}
+}
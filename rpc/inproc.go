@@ -26,7 +26,8 @@ func DialInProc(handler *Server) *Client {
 	initctx := context.Background()
 	c, _ := newClient(initctx, func(context.Context) (net.Conn, error) {
 		p1, p2 := net.Pipe()
-		go handler.ServeCodec(NewJSONCodec(p1), OptionMethodInvocation|OptionSubscriptions)
+		ctx := withConnInfo(initctx, ConnInfo{Transport: "inproc"})
+		go handler.serveCodec(ctx, NewJSONCodec(p1), OptionMethodInvocation|OptionSubscriptions)
 		return p2, nil
 	})
 	return c
@@ -38,7 +38,8 @@ func (srv *Server) ServeListener(l net.Listener) error {
 			return err
 		}
 		log.Trace(fmt.Sprint("accepted conn", conn.RemoteAddr()))
-		go srv.ServeCodec(NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions)
+		ctx := withConnInfo(context.Background(), ConnInfo{Transport: "ipc", RemoteAddr: conn.RemoteAddr().String()})
+		go srv.serveCodec(ctx, NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions)
 	}
 }
 
@@ -0,0 +1,121 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/metrics"
+)
+
+var (
+	rpcSuccessMeter = metrics.NewMeter("rpc/calls/success")
+	rpcFailureMeter = metrics.NewMeter("rpc/calls/failure")
+)
+
+// CallHandler executes an RPC call of the given method (e.g. "zrm_getBalance")
+// with the given parameters, returning its result.
+type CallHandler func(ctx context.Context, method string, params []interface{}) (interface{}, error)
+
+// Middleware intercepts the RPC calls served, including subscriptions and the
+// elements of batch requests. It may inspect or replace the context, parameters
+// and result of a call, or reject it by returning an error instead of invoking
+// next. Calls to subscriptions are made under the "<namespace>_subscribe" method,
+// with the name of the subscription as the first parameter.
+type Middleware func(ctx context.Context, method string, params []interface{}, next CallHandler) (interface{}, error)
+
+// Use appends a middleware to the chain intercepting all calls served. The first
+// middleware added is the outermost one. It must be called before the server
+// starts serving requests.
+func (s *Server) Use(middleware Middleware) {
+	s.middlewares = append(s.middlewares, middleware)
+}
+
+// intercept runs a call through the middleware chain of the server, ending with
+// the given handler executing it.
+func (s *Server) intercept(ctx context.Context, method string, params []interface{}, handler CallHandler) (interface{}, error) {
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		middleware, next := s.middlewares[i], handler
+		handler = func(ctx context.Context, method string, params []interface{}) (interface{}, error) {
+			return middleware(ctx, method, params, next)
+		}
+	}
+	return handler(ctx, method, params)
+}
+
+// ConnInfo is the metadata of the connection an RPC call was made over.
+type ConnInfo struct {
+	Transport  string      // Transport of the connection ("http", "ws", "ipc" or "inproc")
+	RemoteAddr string      // Address of the remote endpoint, if known
+	Header     http.Header // Headers of the HTTP request or websocket handshake (nil for other transports)
+}
+
+// connInfoKey is the context key of the metadata of the connection requests
+// were received over.
+type connInfoKey struct{}
+
+// withConnInfo returns a copy of the context carrying the given connection
+// metadata.
+func withConnInfo(ctx context.Context, info ConnInfo) context.Context {
+	return context.WithValue(ctx, connInfoKey{}, info)
+}
+
+// ConnInfoFromContext retrieves the metadata of the connection the RPC call was
+// made over. Calls served through ServeCodec and ServeSingleRequest carry none.
+func ConnInfoFromContext(ctx context.Context) ConnInfo {
+	info, _ := ctx.Value(connInfoKey{}).(ConnInfo)
+	return info
+}
+
+// AccessLogMiddleware creates a middleware logging every call served, along with
+// the connection it was made over, its duration and its failure if any.
+func AccessLogMiddleware(logger log.Logger) Middleware {
+	return func(ctx context.Context, method string, params []interface{}, next CallHandler) (interface{}, error) {
+		start := time.Now()
+		result, err := next(ctx, method, params)
+
+		info := ConnInfoFromContext(ctx)
+		ctxs := []interface{}{"method", method, "transport", info.Transport, "remote", info.RemoteAddr, "elapsed", time.Since(start)}
+		if err != nil {
+			logger.Info("Failed RPC call", append(ctxs, "err", err)...)
+		} else {
+			logger.Info("Served RPC call", ctxs...)
+		}
+		return result, err
+	}
+}
+
+// LatencyMiddleware creates a middleware timing the calls served, exporting the
+// latencies of every method and the success and failure rates of all calls via
+// the metrics system.
+func LatencyMiddleware() Middleware {
+	return func(ctx context.Context, method string, params []interface{}, next CallHandler) (interface{}, error) {
+		start := time.Now()
+		result, err := next(ctx, method, params)
+
+		metrics.NewTimer("rpc/duration/" + method).UpdateSince(start)
+		if err != nil {
+			rpcFailureMeter.Mark(1)
+		} else {
+			rpcSuccessMeter.Mark(1)
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apolo-technologies/zerium/log"
+)
+
+// interceptedCall is a call seen by a middleware.
+type interceptedCall struct {
+	method string
+	params []interface{}
+}
+
+// callRecorder is a middleware recording the calls it intercepts.
+type callRecorder struct {
+	calls []interceptedCall
+	lock  sync.Mutex
+}
+
+func (r *callRecorder) intercept(ctx context.Context, method string, params []interface{}, next CallHandler) (interface{}, error) {
+	r.lock.Lock()
+	r.calls = append(r.calls, interceptedCall{method, params})
+	r.lock.Unlock()
+
+	return next(ctx, method, params)
+}
+
+func (r *callRecorder) recorded() []interceptedCall {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]interceptedCall{}, r.calls...)
+}
+
+// Tests that the middlewares intercept calls, batch elements, subscriptions and
+// unsubscriptions alike, in the order they were added.
+func TestMiddlewareChain(t *testing.T) {
+	server := newTestServer("zrm", new(NotificationTestService))
+	defer server.Stop()
+
+	var (
+		order    []string
+		recorder = new(callRecorder)
+	)
+	server.Use(func(ctx context.Context, method string, params []interface{}, next CallHandler) (interface{}, error) {
+		order = append(order, "outer")
+		return next(ctx, method, params)
+	})
+	server.Use(func(ctx context.Context, method string, params []interface{}, next CallHandler) (interface{}, error) {
+		order = append(order, "inner")
+		if info := ConnInfoFromContext(ctx); info.Transport != "inproc" {
+			t.Errorf("%s: transport mismatch: have %q, want %q", method, info.Transport, "inproc")
+		}
+		return next(ctx, method, params)
+	})
+	server.Use(recorder.intercept)
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	// Execute a plain call, a batch and a subscription
+	var result int
+	if err := client.Call(&result, "zrm_echo", 7); err != nil || result != 7 {
+		t.Fatalf("call failed: result %d, err %v", result, err)
+	}
+	if !reflect.DeepEqual(order, []string{"outer", "inner"}) {
+		t.Errorf("middleware order mismatch: have %v, want %v", order, []string{"outer", "inner"})
+	}
+	batch := []BatchElem{
+		{Method: "zrm_echo", Args: []interface{}{1}, Result: new(int)},
+		{Method: "zrm_echo", Args: []interface{}{2}, Result: new(int)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatalf("batch call failed: %v", err)
+	}
+	nc := make(chan int)
+	sub, err := client.EthSubscribe(context.Background(), nc, "someSubscription", 2, 0)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	sub.Unsubscribe()
+
+	// Wait for the unsubscription to be served and check the intercepted calls
+	want := []interceptedCall{
+		{"zrm_echo", []interface{}{7}},
+		{"zrm_echo", []interface{}{1}},
+		{"zrm_echo", []interface{}{2}},
+		{"zrm_subscribe", []interface{}{"someSubscription", 2, 0}},
+		{"zrm_unsubscribe", []interface{}{string(sub.subid)}},
+	}
+	for start := time.Now(); len(recorder.recorded()) < len(want) && time.Since(start) < time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if have := recorder.recorded(); !reflect.DeepEqual(have, want) {
+		t.Errorf("intercepted calls mismatch:\nhave %v\nwant %v", have, want)
+	}
+}
+
+// Tests that middlewares can reject calls and rewrite their parameters.
+func TestMiddlewareIntercept(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	denied := errors.New("method denied")
+	server.Use(func(ctx context.Context, method string, params []interface{}, next CallHandler) (interface{}, error) {
+		switch method {
+		case "service_noArgsRets":
+			return nil, denied
+		case "service_echo":
+			params[0] = "rewritten"
+		}
+		return next(ctx, method, params)
+	})
+	client := DialInProc(server)
+	defer client.Close()
+
+	if err := client.Call(nil, "service_noArgsRets"); err == nil || err.Error() != denied.Error() {
+		t.Errorf("denied call error mismatch: have %v, want %v", err, denied)
+	}
+	var result Result
+	if err := client.Call(&result, "service_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Fatalf("rewritten call failed: %v", err)
+	}
+	if result.String != "rewritten" {
+		t.Errorf("rewritten parameter mismatch: have %q, want %q", result.String, "rewritten")
+	}
+}
+
+// Tests that the metadata of HTTP connections is available to the middlewares.
+func TestMiddlewareConnInfoHTTP(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	infos := make(chan ConnInfo, 1)
+	server.Use(func(ctx context.Context, method string, params []interface{}, next CallHandler) (interface{}, error) {
+		infos <- ConnInfoFromContext(ctx)
+		return next(ctx, method, params)
+	})
+	hs := httptest.NewServer(server)
+	defer hs.Close()
+
+	client, err := DialHTTP(hs.URL, WithHeader("X-Request-Source", "test"))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Call(nil, "service_noArgsRets"); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	info := <-infos
+	if info.Transport != "http" {
+		t.Errorf("transport mismatch: have %q, want %q", info.Transport, "http")
+	}
+	if info.RemoteAddr == "" {
+		t.Errorf("remote address missing")
+	}
+	if source := info.Header.Get("X-Request-Source"); source != "test" {
+		t.Errorf("header mismatch: have %q, want %q", source, "test")
+	}
+}
+
+// Tests that the access log middleware logs successful and failed calls.
+func TestAccessLogMiddleware(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+
+	var records []*log.Record
+	logger := log.New()
+	logger.SetHandler(log.FuncHandler(func(r *log.Record) error {
+		records = append(records, r)
+		return nil
+	}))
+	server.Use(AccessLogMiddleware(logger))
+	server.Use(LatencyMiddleware())
+	server.Use(func(ctx context.Context, method string, params []interface{}, next CallHandler) (interface{}, error) {
+		if method == "service_echo" {
+			return nil, errors.New("method denied")
+		}
+		return next(ctx, method, params)
+	})
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	client.Call(nil, "service_noArgsRets")
+	client.Call(nil, "service_echo", "hello", 10, &Args{"world"})
+
+	if len(records) != 2 {
+		t.Fatalf("logged record count mismatch: have %d, want 2", len(records))
+	}
+	if records[0].Msg != "Served RPC call" || records[0].Ctx[1] != "service_noArgsRets" {
+		t.Errorf("successful call record mismatch: %s %v", records[0].Msg, records[0].Ctx)
+	}
+	if records[1].Msg != "Failed RPC call" || records[1].Ctx[1] != "service_echo" {
+		t.Errorf("failed call record mismatch: %s %v", records[1].Msg, records[1].Ctx)
+	}
+}
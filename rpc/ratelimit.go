@@ -0,0 +1,228 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/apolo-technologies/zerium/metrics"
+	"github.com/hashicorp/golang-lru"
+)
+
+// maxClientBuckets is the number of client rate limiting buckets tracked, above
+// which the buckets of the least recently seen clients are dropped.
+const maxClientBuckets = 4096
+
+var (
+	limiterAllowedMeter     = metrics.NewMeter("rpc/limiter/allowed")
+	limiterClientMeter      = metrics.NewMeter("rpc/limiter/rejected/client")
+	limiterMethodMeter      = metrics.NewMeter("rpc/limiter/rejected/method")
+	limiterNamespaceMeter   = metrics.NewMeter("rpc/limiter/rejected/namespace")
+	limiterConcurrencyMeter = metrics.NewMeter("rpc/limiter/rejected/concurrency")
+	limiterInflightCounter  = metrics.NewCounter("rpc/limiter/inflight")
+)
+
+// RateLimit is the configuration of a token bucket, refilled with Rate tokens
+// per second up to a capacity of Burst tokens. Every call takes a single token.
+type RateLimit struct {
+	Rate  float64 // Number of calls permitted per second on average (0 = unlimited)
+	Burst int     // Number of calls permitted at once (minimum 1)
+}
+
+// LimiterConfig is the set of limits the server imposes on the calls it serves.
+// Method and namespace limits are shared by all clients, whereas the client limit
+// is imposed on every remote IP address individually. Calls made over transports
+// without a remote address (IPC, in-process) are exempt from the client limit.
+type LimiterConfig struct {
+	Client        RateLimit            // Limit of each remote IP address
+	Methods       map[string]RateLimit // Limits of individual methods (e.g. "zrm_getLogs")
+	Namespaces    map[string]RateLimit // Limits of API namespaces (e.g. "debug")
+	MaxConcurrent int                  // Maximum number of calls executing at once (0 = unlimited)
+}
+
+// tokenBucket is a lazily refilled token bucket.
+type tokenBucket struct {
+	rate   float64   // Tokens added per second
+	burst  float64   // Capacity of the bucket
+	tokens float64   // Tokens available at the last refill
+	last   time.Time // Time of the last refill
+}
+
+// newTokenBucket creates a full token bucket of the given limit, or nil if the
+// limit is unlimited.
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst, last: now}
+}
+
+// refill adds the tokens accumulated since the last refill, returning whether
+// the bucket has a token to take.
+func (b *tokenBucket) refill(now time.Time) bool {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	return b.tokens >= 1
+}
+
+// Limiter enforces a set of rate and concurrency limits. A single limiter may be
+// shared by multiple servers, making them enforce the limits jointly.
+type Limiter struct {
+	config LimiterConfig
+
+	clients    *lru.Cache              // Buckets of the most recently seen remote IP addresses
+	methods    map[string]*tokenBucket // Buckets of the limited methods
+	namespaces map[string]*tokenBucket // Buckets of the limited namespaces
+	lock       sync.Mutex              // Protects the buckets
+
+	slots chan struct{} // Semaphore capping the concurrent calls (nil = unlimited)
+}
+
+// NewLimiter creates a limiter enforcing the given configuration.
+func NewLimiter(config LimiterConfig) *Limiter {
+	now := time.Now()
+
+	clients, _ := lru.New(maxClientBuckets)
+	l := &Limiter{
+		config:     config,
+		clients:    clients,
+		methods:    make(map[string]*tokenBucket),
+		namespaces: make(map[string]*tokenBucket),
+	}
+	for method, limit := range config.Methods {
+		if bucket := newTokenBucket(limit, now); bucket != nil {
+			l.methods[method] = bucket
+		}
+	}
+	for namespace, limit := range config.Namespaces {
+		if bucket := newTokenBucket(limit, now); bucket != nil {
+			l.namespaces[namespace] = bucket
+		}
+	}
+	if config.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, config.MaxConcurrent)
+	}
+	return l
+}
+
+// acquire charges a call of the given method, made by the given client, against
+// the limits. If the call is permitted, the returned function must be invoked
+// once it finished executing; otherwise the exceeded limit is returned.
+//
+// The concurrency cap is checked before any tokens are taken, so calls rejected
+// for it don't eat into the rate limits.
+func (l *Limiter) acquire(client, namespace, method string) (func(), Error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			limiterConcurrencyMeter.Mark(1)
+			return nil, &limitExceededError{method, "concurrent call"}
+		}
+	}
+	if err := l.take(client, namespace, method); err != nil {
+		if l.slots != nil {
+			<-l.slots
+		}
+		return nil, err
+	}
+	limiterAllowedMeter.Mark(1)
+	limiterInflightCounter.Inc(1)
+
+	return func() {
+		limiterInflightCounter.Dec(1)
+		if l.slots != nil {
+			<-l.slots
+		}
+	}, nil
+}
+
+// take removes a token from all the buckets the call is subject to. Tokens are
+// only taken if all buckets can spare one, so rejected calls don't eat into the
+// quotas of the limits they did not exceed.
+func (l *Limiter) take(client, namespace, method string) Error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+
+	var buckets []*tokenBucket
+	if client != "" {
+		var bucket *tokenBucket
+		if cached, ok := l.clients.Get(client); ok {
+			bucket = cached.(*tokenBucket)
+		} else if bucket = newTokenBucket(l.config.Client, now); bucket != nil {
+			l.clients.Add(client, bucket)
+		}
+		if bucket != nil {
+			if !bucket.refill(now) {
+				limiterClientMeter.Mark(1)
+				return &limitExceededError{method, "client rate"}
+			}
+			buckets = append(buckets, bucket)
+		}
+	}
+	if bucket := l.methods[method]; bucket != nil {
+		if !bucket.refill(now) {
+			limiterMethodMeter.Mark(1)
+			return &limitExceededError{method, "method rate"}
+		}
+		buckets = append(buckets, bucket)
+	}
+	if bucket := l.namespaces[namespace]; bucket != nil {
+		if !bucket.refill(now) {
+			limiterNamespaceMeter.Mark(1)
+			return &limitExceededError{method, "namespace rate"}
+		}
+		buckets = append(buckets, bucket)
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	return nil
+}
+
+// EnableRateLimiting imposes the limits of the given limiter on all calls served,
+// rejecting the ones exceeding them. Calls within batches are charged individually.
+// It must be called before the server starts serving requests.
+func (s *Server) EnableRateLimiting(limiter *Limiter) {
+	s.limiter = limiter
+}
+
+// clientIP returns the remote IP address of HTTP and websocket connections, or
+// an empty string for other transports.
+func clientIP(info ConnInfo) string {
+	if info.Transport != "http" && info.Transport != "ws" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(info.RemoteAddr)
+	if err != nil {
+		return info.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,201 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Tests that every client IP is charged against its own bucket, which refills
+// over time, and that clients without an IP are exempt from the client limit.
+func TestLimiterClientRate(t *testing.T) {
+	l := NewLimiter(LimiterConfig{Client: RateLimit{Rate: 0.001, Burst: 2}})
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.acquire("10.0.0.1", "service", "service_echo"); err != nil {
+			t.Fatalf("call %d within burst rejected: %v", i, err)
+		}
+	}
+	if _, err := l.acquire("10.0.0.1", "service", "service_echo"); err == nil {
+		t.Fatalf("call above burst permitted")
+	} else if err.ErrorCode() != -32005 {
+		t.Fatalf("error code mismatch: have %d, want %d", err.ErrorCode(), -32005)
+	}
+	if _, err := l.acquire("10.0.0.2", "service", "service_echo"); err != nil {
+		t.Fatalf("call of other client rejected: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := l.acquire("", "service", "service_echo"); err != nil {
+			t.Fatalf("call %d without client IP rejected: %v", i, err)
+		}
+	}
+	// Rewind the bucket of the throttled client to simulate the passing of time
+	bucket, _ := l.clients.Get("10.0.0.1")
+	bucket.(*tokenBucket).last = bucket.(*tokenBucket).last.Add(-1000 * time.Second)
+	if _, err := l.acquire("10.0.0.1", "service", "service_echo"); err != nil {
+		t.Fatalf("call after refill rejected: %v", err)
+	}
+}
+
+// Tests that the number of tracked client buckets is capped, dropping the ones
+// of the least recently seen clients.
+func TestLimiterClientCap(t *testing.T) {
+	l := NewLimiter(LimiterConfig{Client: RateLimit{Rate: 0.001, Burst: 1}})
+
+	for i := 0; i <= maxClientBuckets; i++ {
+		client := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		if _, err := l.acquire(client, "service", "service_echo"); err != nil {
+			t.Fatalf("call of client %s rejected: %v", client, err)
+		}
+	}
+	if n := l.clients.Len(); n != maxClientBuckets {
+		t.Fatalf("tracked client count mismatch: have %d, want %d", n, maxClientBuckets)
+	}
+	if l.clients.Contains("10.0.0.0") {
+		t.Errorf("least recently seen client not dropped")
+	}
+	if !l.clients.Contains(fmt.Sprintf("10.0.%d.%d", maxClientBuckets/256, maxClientBuckets%256)) {
+		t.Errorf("most recently seen client dropped")
+	}
+}
+
+// Tests that method and namespace limits are shared by all clients, and that
+// calls rejected by one limit do not eat into the quota of the others.
+func TestLimiterSharedRates(t *testing.T) {
+	l := NewLimiter(LimiterConfig{
+		Client:     RateLimit{Rate: 0.001, Burst: 2},
+		Methods:    map[string]RateLimit{"zrm_getLogs": {Rate: 0.001, Burst: 1}},
+		Namespaces: map[string]RateLimit{"debug": {Rate: 0.001, Burst: 1}},
+	})
+	if _, err := l.acquire("10.0.0.1", "zrm", "zrm_getLogs"); err != nil {
+		t.Fatalf("first method call rejected: %v", err)
+	}
+	if _, err := l.acquire("10.0.0.2", "zrm", "zrm_getLogs"); err == nil {
+		t.Fatalf("method call above shared limit permitted")
+	}
+	if _, err := l.acquire("10.0.0.2", "zrm", "zrm_blockNumber"); err != nil {
+		t.Fatalf("unlimited method call rejected: %v", err)
+	}
+	if _, err := l.acquire("10.0.0.3", "debug", "debug_traceTransaction"); err != nil {
+		t.Fatalf("first namespace call rejected: %v", err)
+	}
+	if _, err := l.acquire("10.0.0.3", "debug", "debug_traceBlock"); err == nil {
+		t.Fatalf("namespace call above shared limit permitted")
+	}
+	// The rejected namespace call must not have been charged to the client
+	if _, err := l.acquire("10.0.0.3", "zrm", "zrm_blockNumber"); err != nil {
+		t.Fatalf("client charged for rejected call: %v", err)
+	}
+}
+
+// Tests that the number of concurrently executing calls is capped.
+func TestLimiterConcurrency(t *testing.T) {
+	l := NewLimiter(LimiterConfig{MaxConcurrent: 2})
+
+	release1, err := l.acquire("10.0.0.1", "service", "service_sleep")
+	if err != nil {
+		t.Fatalf("first call rejected: %v", err)
+	}
+	release2, err := l.acquire("10.0.0.2", "service", "service_sleep")
+	if err != nil {
+		t.Fatalf("second call rejected: %v", err)
+	}
+	if _, err := l.acquire("10.0.0.3", "service", "service_sleep"); err == nil {
+		t.Fatalf("call above concurrency cap permitted")
+	}
+	release1()
+	if _, err := l.acquire("10.0.0.3", "service", "service_sleep"); err != nil {
+		t.Fatalf("call after release rejected: %v", err)
+	}
+	release2()
+}
+
+// Tests that calls rejected for exceeding the concurrency cap are not charged
+// against the rate limits.
+func TestLimiterConcurrencyNotCharged(t *testing.T) {
+	l := NewLimiter(LimiterConfig{Client: RateLimit{Rate: 0.001, Burst: 2}, MaxConcurrent: 1})
+
+	release, err := l.acquire("10.0.0.1", "service", "service_sleep")
+	if err != nil {
+		t.Fatalf("first call rejected: %v", err)
+	}
+	if _, err := l.acquire("10.0.0.1", "service", "service_sleep"); err == nil {
+		t.Fatalf("call above concurrency cap permitted")
+	}
+	release()
+	if _, err := l.acquire("10.0.0.1", "service", "service_sleep"); err != nil {
+		t.Fatalf("client charged for call rejected by concurrency cap: %v", err)
+	}
+}
+
+// Tests that the elements of batch requests are charged against the limits
+// individually.
+func TestServerRateLimitBatch(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	server.EnableRateLimiting(NewLimiter(LimiterConfig{
+		Methods: map[string]RateLimit{"service_echo": {Rate: 0.001, Burst: 2}},
+	}))
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := make([]BatchElem, 3)
+	for i := range batch {
+		batch[i] = BatchElem{Method: "service_echo", Args: []interface{}{"hello", i, &Args{"world"}}, Result: new(Result)}
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatalf("batch call failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if batch[i].Error != nil {
+			t.Errorf("batch element %d within limit rejected: %v", i, batch[i].Error)
+		}
+	}
+	if err, ok := batch[2].Error.(Error); !ok || err.ErrorCode() != -32005 {
+		t.Errorf("batch element above limit error mismatch: have %v, want code %d", batch[2].Error, -32005)
+	}
+	// Unlimited methods are still permitted
+	if err := client.Call(nil, "service_noArgsRets"); err != nil {
+		t.Errorf("unlimited method rejected: %v", err)
+	}
+}
+
+// Tests that servers sharing a limiter enforce its limits jointly.
+func TestServerRateLimitShared(t *testing.T) {
+	limiter := NewLimiter(LimiterConfig{
+		Methods: map[string]RateLimit{"service_echo": {Rate: 0.001, Burst: 1}},
+	})
+	servers := []*Server{newTestServer("service", new(Service)), newTestServer("service", new(Service))}
+	for _, server := range servers {
+		server.EnableRateLimiting(limiter)
+		defer server.Stop()
+	}
+	first, second := DialInProc(servers[0]), DialInProc(servers[1])
+	defer first.Close()
+	defer second.Close()
+
+	if err := first.Call(new(Result), "service_echo", "hello", 1, &Args{"world"}); err != nil {
+		t.Fatalf("call within limit rejected: %v", err)
+	}
+	err := second.Call(new(Result), "service_echo", "hello", 2, &Args{"world"})
+	if err, ok := err.(Error); !ok || err.ErrorCode() != -32005 {
+		t.Fatalf("call above shared limit error mismatch: have %v, want code %d", err, -32005)
+	}
+}
@@ -45,9 +45,10 @@ const (
 // NewServer will create a new server instance with no registered handlers.
 func NewServer() *Server {
 	server := &Server{
-		services: make(serviceRegistry),
-		codecs:   set.New(),
-		run:      1,
+		services:      make(serviceRegistry),
+		codecs:        set.New(),
+		run:           1,
+		httpBodyLimit: maxHTTPRequestContentLength,
 	}
 
 	// register a default service which will provide meta information about the RPC service such as the services and
@@ -120,12 +121,13 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 }
 
 // serveRequest will reads requests from the codec, calls the RPC callback and
-// writes the response to the given codec.
+// writes the response to the given codec. The given context is the parent of
+// the context of all the callbacks served.
 //
 // If singleShot is true it will process a single request, otherwise it will handle
 // requests until the codec returns an error when reading a request (in most cases
 // an EOF). It executes requests in parallel when singleShot is false.
-func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecOption) error {
+func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot bool, options CodecOption) error {
 	var pend sync.WaitGroup
 
 	defer func() {
@@ -140,7 +142,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 		s.codecsMu.Unlock()
 	}()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// if the codec supports notification include a notifier that callbacks can use
@@ -214,15 +216,21 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 // response back using the given codec. It will block until the codec is closed or the server is
 // stopped. In either case the codec is closed.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
+	s.serveCodec(context.Background(), codec, options)
+}
+
+// serveCodec is ServeCodec, with the given context being the parent of the context
+// of all the callbacks served.
+func (s *Server) serveCodec(ctx context.Context, codec ServerCodec, options CodecOption) {
 	defer codec.Close()
-	s.serveRequest(codec, false, options)
+	s.serveRequest(ctx, codec, false, options)
 }
 
 // ServeSingleRequest reads and processes a single RPC request from the given codec. It will not
 // close the codec unless a non-recoverable error has occurred. Note, this method will return after
 // a single request has been processed!
 func (s *Server) ServeSingleRequest(codec ServerCodec, options CodecOption) {
-	s.serveRequest(codec, true, options)
+	s.serveRequest(context.Background(), codec, true, options)
 }
 
 // Stop will stop reading new requests, wait for stopPendingRequestTimeout to allow pending requests to finish,
@@ -240,10 +248,10 @@ func (s *Server) Stop() {
 }
 
 // createSubscription will call the subscription callback and returns the subscription id or error.
-func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *serverRequest) (ID, error) {
+func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *serverRequest, params []reflect.Value) (ID, error) {
 	// subscription have as first argument the context following optional arguments
 	args := []reflect.Value{req.callb.rcvr, reflect.ValueOf(ctx)}
-	args = append(args, req.args...)
+	args = append(args, params...)
 	reply := req.callb.method.Func.Call(args)
 
 	if !reply[1].IsNil() { // subscription creation failed
@@ -253,33 +261,78 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 	return reply[0].Interface().(*Subscription).ID, nil
 }
 
-// handle executes a request and returns the response from the callback.
+// handle executes a request through the middleware chain of the server and
+// returns the response from the callback.
 func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
 	if req.err != nil {
 		return codec.CreateErrorResponse(&req.id, req.err), nil
 	}
+	method, params := req.name(), req.params()
+
+	// the subscription is activated after the sub id was successfully sent to the client
+	var activate func()
+	result, err := s.intercept(ctx, method, params, func(ctx context.Context, _ string, params []interface{}) (interface{}, error) {
+		result, callback, err := s.call(ctx, codec, req, method, params)
+		activate = callback
+		return result, err
+	})
+	if err != nil {
+		return createCallbackErrorResponse(codec, &req.id, err), nil
+	}
+	return codec.CreateResponse(req.id, result), activate
+}
 
+// call executes a request with the given parameters, after ensuring it doesn't
+// violate the restrictions of the server. For subscriptions, it also returns the
+// function activating the subscription.
+func (s *Server) call(ctx context.Context, codec ServerCodec, req *serverRequest, method string, params []interface{}) (interface{}, func(), error) {
+	// ensure the bearer token of authenticated connections permits the call, the
+	// subscriptions it permits may always be cancelled
+	if claims, ok := authClaimsFromContext(ctx); ok {
+		allowed := claims.allows(req.svcname, method)
+		if !allowed && req.isUnsubscribe {
+			allowed = claims.allows(req.svcname, req.svcname+subscribeMethodSuffix)
+		}
+		if !allowed {
+			return nil, nil, &unauthorizedError{method}
+		}
+	}
 	if req.isUnsubscribe { // cancel subscription, first param must be the subscription id
-		if len(req.args) >= 1 && req.args[0].Kind() == reflect.String {
-			notifier, supported := NotifierFromContext(ctx)
-			if !supported { // interface doesn't support subscriptions (e.g. http)
-				return codec.CreateErrorResponse(&req.id, &callbackError{ErrNotificationsUnsupported.Error()}), nil
-			}
-
-			subid := ID(req.args[0].String())
-			if err := notifier.unsubscribe(subid); err != nil {
-				return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+		if len(params) >= 1 {
+			if subid, ok := params[0].(string); ok {
+				notifier, supported := NotifierFromContext(ctx)
+				if !supported { // interface doesn't support subscriptions (e.g. http)
+					return nil, nil, &callbackError{ErrNotificationsUnsupported.Error()}
+				}
+				if err := notifier.unsubscribe(ID(subid)); err != nil {
+					return nil, nil, &callbackError{err.Error()}
+				}
+				return true, nil, nil
 			}
+		}
+		return nil, nil, &invalidParamsError{"Expected subscription id as first argument"}
+	}
 
-			return codec.CreateResponse(req.id, true), nil
+	// charge the call against the rate and concurrency limits of the server
+	if s.limiter != nil {
+		release, err := s.limiter.acquire(clientIP(ConnInfoFromContext(ctx)), req.svcname, method)
+		if err != nil {
+			return nil, nil, err
 		}
-		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
+		defer release()
 	}
 
 	if req.callb.isSubscribe {
-		subid, err := s.createSubscription(ctx, codec, req)
+		if len(params) > 0 { // first one is the subscription name which isn't an actual argument
+			params = params[1:]
+		}
+		args, err := req.arguments(params)
 		if err != nil {
-			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+			return nil, nil, err
+		}
+		subid, subErr := s.createSubscription(ctx, codec, req, args)
+		if subErr != nil {
+			return nil, nil, &callbackError{subErr.Error()}
 		}
 
 		// active the subscription after the sub id was successfully sent to the client
@@ -288,39 +341,40 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 			notifier.activate(subid, req.svcname)
 		}
 
-		return codec.CreateResponse(req.id, subid), activateSub
+		return subid, activateSub, nil
 	}
 
 	// regular RPC call, prepare arguments
-	if len(req.args) != len(req.callb.argTypes) {
-		rpcErr := &invalidParamsError{fmt.Sprintf("%s%s%s expects %d parameters, got %d",
-			req.svcname, serviceMethodSeparator, req.callb.method.Name,
-			len(req.callb.argTypes), len(req.args))}
-		return codec.CreateErrorResponse(&req.id, rpcErr), nil
+	args, err := req.arguments(params)
+	if err != nil {
+		return nil, nil, err
 	}
-
 	arguments := []reflect.Value{req.callb.rcvr}
 	if req.callb.hasCtx {
 		arguments = append(arguments, reflect.ValueOf(ctx))
 	}
-	if len(req.args) > 0 {
-		arguments = append(arguments, req.args...)
+	if len(args) > 0 {
+		arguments = append(arguments, args...)
 	}
 
 	// execute RPC method and return result
 	reply := req.callb.method.Func.Call(arguments)
 	if len(reply) == 0 {
-		return codec.CreateResponse(req.id, nil), nil
+		return nil, nil, nil
 	}
 
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
-			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+			// only errors carrying data (e.g. reverts) retain their own code,
+			// all other failures are reported as generic callback errors
+			err := reply[req.callb.errPos].Interface().(error)
+			if _, ok := err.(DataError); !ok {
+				err = &callbackError{err.Error()}
+			}
+			return nil, nil, err
 		}
 	}
-	return codec.CreateResponse(req.id, reply[0].Interface()), nil
+	return reply[0].Interface(), nil, nil
 }
 
 // exec executes the given request and writes the result back using the codec.
@@ -393,7 +447,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if r.isPubSub && strings.HasSuffix(r.method, unsubscribeMethodSuffix) {
-			requests[i] = &serverRequest{id: r.id, isUnsubscribe: true}
+			requests[i] = &serverRequest{id: r.id, svcname: strings.TrimSuffix(r.method, unsubscribeMethodSuffix), isUnsubscribe: true}
 			argTypes := []reflect.Type{reflect.TypeOf("")} // expect subscription id as first arg
 			if args, err := codec.ParseRequestArguments(argTypes, r.params); err == nil {
 				requests[i].args = args
@@ -443,3 +497,67 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 	return requests, batch, nil
 }
+
+// name returns the fully qualified name the request was made under, e.g.
+// "zrm_getBalance" for calls and "zrm_subscribe" for subscriptions.
+func (req *serverRequest) name() string {
+	switch {
+	case req.isUnsubscribe:
+		return req.svcname + unsubscribeMethodSuffix
+	case req.callb.isSubscribe:
+		return req.svcname + subscribeMethodSuffix
+	default:
+		return req.svcname + serviceMethodSeparator + formatName(req.callb.method.Name)
+	}
+}
+
+// params returns the decoded parameters of the request. The parameters of
+// subscriptions are preceded by the name of the subscription, as on the wire.
+func (req *serverRequest) params() []interface{} {
+	var params []interface{}
+	if !req.isUnsubscribe && req.callb.isSubscribe {
+		params = append(params, formatName(req.callb.method.Name))
+	}
+	for _, arg := range req.args {
+		params = append(params, arg.Interface())
+	}
+	return params
+}
+
+// arguments converts the given parameters into the arguments of the callback
+// of the request, ensuring they match the callback's signature.
+func (req *serverRequest) arguments(params []interface{}) ([]reflect.Value, Error) {
+	if len(params) != len(req.callb.argTypes) {
+		return nil, &invalidParamsError{fmt.Sprintf("%s%s%s expects %d parameters, got %d",
+			req.svcname, serviceMethodSeparator, req.callb.method.Name,
+			len(req.callb.argTypes), len(params))}
+	}
+	args := make([]reflect.Value, len(params))
+	for i, param := range params {
+		if param == nil {
+			args[i] = reflect.Zero(req.callb.argTypes[i])
+			continue
+		}
+		args[i] = reflect.ValueOf(param)
+		if !args[i].Type().AssignableTo(req.callb.argTypes[i]) {
+			return nil, &invalidParamsError{fmt.Sprintf("invalid argument %d: %v is not assignable to %v",
+				i, args[i].Type(), req.callb.argTypes[i])}
+		}
+	}
+	return args, nil
+}
+
+// createCallbackErrorResponse assembles the error response of a failed method
+// call, retaining the code and data of errors that provide them. Errors returned
+// by the callbacks themselves only reach here with their own code if they carry
+// data too, see call.
+func createCallbackErrorResponse(codec ServerCodec, id interface{}, err error) interface{} {
+	rpcErr, ok := err.(Error)
+	if !ok {
+		rpcErr = &callbackError{err.Error()}
+	}
+	if dataErr, ok := err.(DataError); ok {
+		return codec.CreateErrorResponseWithInfo(id, rpcErr, dataErr.ErrorData())
+	}
+	return codec.CreateErrorResponse(id, rpcErr)
+}
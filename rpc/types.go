@@ -23,6 +23,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/apolo-technologies/zerium/common"
 	"github.com/apolo-technologies/zerium/common/hexutil"
 	"gopkg.in/fatih/set.v0"
 )
@@ -74,6 +75,12 @@ type Server struct {
 	run      int32
 	codecsMu sync.Mutex
 	codecs   *set.Set
+
+	jwtSecret     []byte   // Secret authenticating HTTP and websocket requests (nil = no authentication)
+	httpBodyLimit int64    // Maximum size of HTTP request bodies, accessed atomically
+	limiter       *Limiter // Rate and concurrency limits of the calls (nil = unlimited)
+
+	middlewares []Middleware // Chain intercepting all calls served, outermost first
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -92,6 +99,13 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError is an error carrying additional information that is returned to the
+// caller in the data field of the error response.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
@@ -163,3 +177,36 @@ func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 func (bn BlockNumber) Int64() int64 {
 	return (int64)(bn)
 }
+
+// BlockNumberOrHash selects a block either by its number (or one of the block
+// tags supported by BlockNumber) or by its hash. Exactly one of the fields is
+// set after unmarshalling.
+type BlockNumberOrHash struct {
+	BlockNumber *BlockNumber
+	BlockHash   *common.Hash
+}
+
+// UnmarshalJSON parses the given JSON fragment into a BlockNumberOrHash. A 32
+// byte hex string is taken as a block hash, anything else is parsed as a
+// BlockNumber.
+func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
+	input := strings.TrimSpace(string(data))
+	if len(input) >= 2 && input[0] == '"' && input[len(input)-1] == '"' {
+		input = input[1 : len(input)-1]
+	}
+	if len(input) == 2+2*common.HashLength {
+		hash, err := hexutil.Decode(input)
+		if err != nil {
+			return err
+		}
+		h := common.BytesToHash(hash)
+		*bnh = BlockNumberOrHash{BlockHash: &h}
+		return nil
+	}
+	var number BlockNumber
+	if err := number.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*bnh = BlockNumberOrHash{BlockNumber: &number}
+	return nil
+}
@@ -37,10 +37,26 @@ import (
 // allowedOrigins should be a comma-separated list of allowed origin URLs.
 // To allow connections with any origin, pass "*".
 func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
+	validateOrigin := wsHandshakeValidator(allowedOrigins)
 	return websocket.Server{
-		Handshake: wsHandshakeValidator(allowedOrigins),
+		Handshake: func(cfg *websocket.Config, req *http.Request) error {
+			if err := validateOrigin(cfg, req); err != nil {
+				return err
+			}
+			_, err := srv.authenticate(context.Background(), req)
+			return err
+		},
 		Handler: func(conn *websocket.Conn) {
-			srv.ServeCodec(NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions)
+			req := conn.Request()
+			ctx := withConnInfo(context.Background(), ConnInfo{Transport: "ws", RemoteAddr: req.RemoteAddr, Header: req.Header})
+
+			// The handshake already authenticated the request, only retrieve the claims
+			ctx, err := srv.authenticate(ctx, req)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			srv.serveCodec(ctx, NewJSONCodec(conn), OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
@@ -94,8 +110,9 @@ func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http
 // that is listening on the given endpoint.
 //
 // The context is used for the initial connection establishment. It does not
-// affect subsequent interactions with the client.
-func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error) {
+// affect subsequent interactions with the client. The options can be used to set
+// additional handshake headers, e.g. for authentication.
+func DialWebsocket(ctx context.Context, endpoint, origin string, opts ...ClientOption) (*Client, error) {
 	if origin == "" {
 		var err error
 		if origin, err = os.Hostname(); err != nil {
@@ -111,10 +128,12 @@ func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error
 	if err != nil {
 		return nil, err
 	}
+	cfg := newClientConfig(opts)
+	config.Header = cfg.header
 
-	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
+	return newResilientClient(ctx, func(ctx context.Context) (net.Conn, error) {
 		return wsDialContext(ctx, config)
-	})
+	}, cfg.backoff)
 }
 
 func wsDialContext(ctx context.Context, config *websocket.Config) (*websocket.Conn, error) {
@@ -0,0 +1,349 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// LeafReferencer is called for every leaf value of a node inserted into the node
+// database, returning the roots of any other tries the leaf references (e.g. the
+// storage trie of an account). Those tries are kept alive as long as the leaf is.
+type LeafReferencer func(leaf []byte) []common.Hash
+
+// NodeDatabase is an intermediate write layer between the trie data structures
+// and the disk database. Committed trie nodes are accumulated in memory along
+// with reference counts tracking which cached nodes refer to which others, so
+// that the nodes of unreferenced tries can be garbage collected before ever
+// reaching the disk. Only explicit commits flush nodes to the disk database.
+//
+// NodeDatabase implements Database so tries can be opened on top of it directly.
+type NodeDatabase struct {
+	diskdb zrmdb.Database // Persistent storage for matured trie nodes
+	onleaf LeafReferencer // Resolver for tries referenced from leaf values
+
+	nodes     map[common.Hash]*cachedNode // Data and references relationships of trie nodes
+	preimages map[string][]byte           // Secure trie key preimages, keyed by database key
+
+	nodesSize     common.StorageSize // Storage size of the nodes cache
+	preimagesSize common.StorageSize // Storage size of the preimages cache
+
+	gcnodes uint64             // Nodes garbage collected since last commit
+	gcsize  common.StorageSize // Data storage garbage collected since last commit
+	gctime  time.Duration      // Time spent on garbage collection since last commit
+
+	lock sync.RWMutex
+}
+
+// cachedNode is a trie node cached in memory along with its references.
+type cachedNode struct {
+	blob     []byte              // Encoded node data
+	parents  int                 // Number of live cached nodes (or roots) referencing this one
+	children map[common.Hash]int // Cached nodes referenced by this one
+}
+
+// NewNodeDatabase creates a new trie node database on top of the given disk
+// database. The optional leaf referencer links leaves to other tries.
+func NewNodeDatabase(diskdb zrmdb.Database, onleaf LeafReferencer) *NodeDatabase {
+	return &NodeDatabase{
+		diskdb: diskdb,
+		onleaf: onleaf,
+		nodes: map[common.Hash]*cachedNode{
+			{}: {children: make(map[common.Hash]int)},
+		},
+		preimages: make(map[string][]byte),
+	}
+}
+
+// DiskDB retrieves the persistent storage backing the node database.
+func (db *NodeDatabase) DiskDB() zrmdb.Database {
+	return db.diskdb
+}
+
+// Put implements DatabaseWriter, caching a trie node or a secure trie key
+// preimage. Nodes are keyed by their hash, everything else is a preimage.
+func (db *NodeDatabase) Put(key, value []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if len(key) != common.HashLength {
+		if _, ok := db.preimages[string(key)]; !ok {
+			db.preimages[string(key)] = common.CopyBytes(value)
+			db.preimagesSize += common.StorageSize(len(key) + len(value))
+		}
+		return nil
+	}
+	db.insert(common.BytesToHash(key), value)
+	return nil
+}
+
+// insert caches a trie node, referencing all the cached nodes and tries it
+// links to. The caller must hold the write lock.
+func (db *NodeDatabase) insert(hash common.Hash, blob []byte) {
+	// If the node's already cached, its references are already tracked
+	if _, ok := db.nodes[hash]; ok {
+		return
+	}
+	entry := &cachedNode{
+		blob:     common.CopyBytes(blob),
+		children: make(map[common.Hash]int),
+	}
+	// Reference the children that are still cached. Children already on disk
+	// are never garbage collected, so they need no tracking.
+	if n, err := decodeNode(hash[:], entry.blob, 0); err == nil {
+		db.forChildren(n, func(child common.Hash) {
+			if node, ok := db.nodes[child]; ok {
+				node.parents++
+				entry.children[child]++
+			}
+		})
+	}
+	db.nodes[hash] = entry
+	db.nodesSize += common.StorageSize(common.HashLength + len(blob))
+}
+
+// forChildren iterates over the hash references of a decoded node, including
+// the tries referenced from its leaves.
+func (db *NodeDatabase) forChildren(n node, onChild func(common.Hash)) {
+	switch n := n.(type) {
+	case *shortNode:
+		db.forChildren(n.Val, onChild)
+	case *fullNode:
+		for _, child := range n.Children {
+			if child != nil {
+				db.forChildren(child, onChild)
+			}
+		}
+	case hashNode:
+		onChild(common.BytesToHash(n))
+	case valueNode:
+		if db.onleaf != nil {
+			for _, root := range db.onleaf(n) {
+				onChild(root)
+			}
+		}
+	}
+}
+
+// Get implements DatabaseReader, retrieving a trie node or preimage from the
+// memory cache, or from the disk database if not cached.
+func (db *NodeDatabase) Get(key []byte) ([]byte, error) {
+	db.lock.RLock()
+	if len(key) == common.HashLength {
+		if node, ok := db.nodes[common.BytesToHash(key)]; ok && node.blob != nil {
+			db.lock.RUnlock()
+			return node.blob, nil
+		}
+	} else if preimage, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return preimage, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Get(key)
+}
+
+// Has implements DatabaseReader, checking whether a trie node or preimage is
+// either cached or stored on disk.
+func (db *NodeDatabase) Has(key []byte) (bool, error) {
+	db.lock.RLock()
+	if len(key) == common.HashLength {
+		if node, ok := db.nodes[common.BytesToHash(key)]; ok && node.blob != nil {
+			db.lock.RUnlock()
+			return true, nil
+		}
+	} else if _, ok := db.preimages[string(key)]; ok {
+		db.lock.RUnlock()
+		return true, nil
+	}
+	db.lock.RUnlock()
+
+	return db.diskdb.Has(key)
+}
+
+// Reference adds a new reference from a parent node to a child node. A zero
+// parent hash references the child as a live root, keeping it and everything
+// it links to in memory until dereferenced.
+func (db *NodeDatabase) Reference(child common.Hash, parent common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	node, ok := db.nodes[child]
+	if !ok {
+		return
+	}
+	owner, ok := db.nodes[parent]
+	if !ok {
+		return
+	}
+	// Non-root references are implied by the node contents, only count roots
+	if _, ok := owner.children[child]; ok && parent != (common.Hash{}) {
+		return
+	}
+	node.parents++
+	owner.children[child]++
+}
+
+// Dereference removes a live root reference, garbage collecting every cached
+// node that becomes unreferenced as a result.
+func (db *NodeDatabase) Dereference(root common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	nodes, storage, start := len(db.nodes), db.nodesSize, time.Now()
+	db.dereference(root, common.Hash{})
+
+	db.gcnodes += uint64(nodes - len(db.nodes))
+	db.gcsize += storage - db.nodesSize
+	db.gctime += time.Since(start)
+
+	log.Debug("Dereferenced trie from memory database", "nodes", nodes-len(db.nodes), "size", storage-db.nodesSize, "time", time.Since(start),
+		"gcnodes", db.gcnodes, "gcsize", db.gcsize, "gctime", db.gctime, "livenodes", len(db.nodes), "livesize", db.nodesSize)
+}
+
+// dereference drops a reference from parent to child, deleting the child and
+// recursively its own children if it's no longer referenced.
+func (db *NodeDatabase) dereference(child common.Hash, parent common.Hash) {
+	if owner, ok := db.nodes[parent]; ok {
+		if owner.children[child]--; owner.children[child] <= 0 {
+			delete(owner.children, child)
+		}
+	}
+	node, ok := db.nodes[child]
+	if !ok {
+		return
+	}
+	if node.parents > 0 {
+		node.parents--
+	}
+	if node.parents == 0 {
+		for hash := range node.children {
+			db.dereference(hash, child)
+		}
+		delete(db.nodes, child)
+		db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
+	}
+}
+
+// Commit writes the trie with the given root, along with all cached preimages,
+// to the disk database and drops the written nodes from the memory cache.
+func (db *NodeDatabase) Commit(root common.Hash) error {
+	// Writing to disk may take a while, only block concurrent readers
+	db.lock.RLock()
+
+	start := time.Now()
+	batch := db.diskdb.NewBatch()
+
+	for key, preimage := range db.preimages {
+		if err := batch.Put([]byte(key), preimage); err != nil {
+			db.lock.RUnlock()
+			return err
+		}
+		if batch.ValueSize() > zrmdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				db.lock.RUnlock()
+				return err
+			}
+			batch = db.diskdb.NewBatch()
+		}
+	}
+	nodes, storage := len(db.nodes), db.nodesSize
+	if err := db.commit(root, &batch); err != nil {
+		db.lock.RUnlock()
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		db.lock.RUnlock()
+		return err
+	}
+	db.lock.RUnlock()
+
+	// Everything is on disk, drop the written data from memory
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.preimages = make(map[string][]byte)
+	db.preimagesSize = 0
+	db.uncache(root)
+
+	log.Debug("Persisted trie from memory database", "nodes", nodes-len(db.nodes), "size", storage-db.nodesSize, "time", time.Since(start),
+		"gcnodes", db.gcnodes, "gcsize", db.gcsize, "gctime", db.gctime, "livenodes", len(db.nodes), "livesize", db.nodesSize)
+
+	db.gcnodes, db.gcsize, db.gctime = 0, 0, 0
+	return nil
+}
+
+// commit is the private locked version of Commit, writing a node and all its
+// cached children into the batch.
+func (db *NodeDatabase) commit(hash common.Hash, batch *zrmdb.Batch) error {
+	node, ok := db.nodes[hash]
+	if !ok || node.blob == nil {
+		return nil
+	}
+	for child := range node.children {
+		if err := db.commit(child, batch); err != nil {
+			return err
+		}
+	}
+	if err := (*batch).Put(hash[:], node.blob); err != nil {
+		return err
+	}
+	if (*batch).ValueSize() >= zrmdb.IdealBatchSize {
+		if err := (*batch).Write(); err != nil {
+			return err
+		}
+		*batch = db.diskdb.NewBatch()
+	}
+	return nil
+}
+
+// uncache is the post-processing step of a commit operation, removing the
+// written nodes from the memory cache. The caller must hold the write lock.
+func (db *NodeDatabase) uncache(hash common.Hash) {
+	node, ok := db.nodes[hash]
+	if !ok || node.blob == nil {
+		return
+	}
+	delete(db.nodes, hash)
+	db.nodesSize -= common.StorageSize(common.HashLength + len(node.blob))
+
+	for child := range node.children {
+		db.uncache(child)
+	}
+}
+
+// Size returns the current storage size of the memory cache in front of the
+// persistent database layer.
+func (db *NodeDatabase) Size() common.StorageSize {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.nodesSize + db.preimagesSize
+}
+
+// Nodes returns the number of trie nodes cached in memory.
+func (db *NodeDatabase) Nodes() int {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return len(db.nodes) - 1 // Don't count the live roots metadata
+}
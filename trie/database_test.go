@@ -0,0 +1,88 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// Tests that dereferencing a trie root garbage collects only the nodes that are
+// not shared with other live tries, and that committing flushes a trie to disk.
+func TestNodeDatabaseGC(t *testing.T) {
+	diskdb, _ := zrmdb.NewMemDatabase()
+	triedb := NewNodeDatabase(diskdb, nil)
+
+	// Create two tries sharing most of their nodes
+	trie, _ := New(common.Hash{}, triedb)
+	for i := 0; i < 100; i++ {
+		trie.Update([]byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("val-%03d", i)))
+	}
+	first, err := trie.CommitTo(triedb)
+	if err != nil {
+		t.Fatalf("failed to commit first trie: %v", err)
+	}
+	triedb.Reference(first, common.Hash{})
+	nodes := triedb.Nodes()
+
+	trie.Update([]byte("key-000"), []byte("changed"))
+	second, err := trie.CommitTo(triedb)
+	if err != nil {
+		t.Fatalf("failed to commit second trie: %v", err)
+	}
+	triedb.Reference(second, common.Hash{})
+	if triedb.Nodes() <= nodes {
+		t.Fatalf("second trie added no nodes: have %d, had %d", triedb.Nodes(), nodes)
+	}
+	// Drop the first trie and ensure the second one is intact
+	triedb.Dereference(first)
+	if ok, _ := triedb.Has(first[:]); ok {
+		t.Errorf("dereferenced root still present")
+	}
+	if triedb.Nodes() > nodes {
+		t.Errorf("unshared nodes not collected: have %d, want at most %d", triedb.Nodes(), nodes)
+	}
+	checkGCTrie(t, triedb, second)
+
+	// Flush the second trie and ensure it's fully on disk
+	if err := triedb.Commit(second); err != nil {
+		t.Fatalf("failed to commit to disk: %v", err)
+	}
+	if n := triedb.Nodes(); n != 0 {
+		t.Errorf("cached nodes mismatch after commit: have %d, want 0", n)
+	}
+	checkGCTrie(t, diskdb, second)
+}
+
+func checkGCTrie(t *testing.T, db Database, root common.Hash) {
+	trie, err := New(root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", root, err)
+	}
+	if val := trie.Get([]byte("key-000")); string(val) != "changed" {
+		t.Errorf("updated value mismatch: have %q, want %q", val, "changed")
+	}
+	for i := 1; i < 100; i++ {
+		key, want := fmt.Sprintf("key-%03d", i), fmt.Sprintf("val-%03d", i)
+		if val := trie.Get([]byte(key)); string(val) != want {
+			t.Errorf("value mismatch for %s: have %q, want %q", key, val, want)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -67,6 +68,31 @@ func TestOneElementProof(t *testing.T) {
 	}
 }
 
+func TestSecureProof(t *testing.T) {
+	db, _ := zrmdb.NewMemDatabase()
+	trie, _ := NewSecure(common.Hash{}, db, 0)
+	vals := make(map[string]*kv)
+	for i := byte(0); i < 100; i++ {
+		value := &kv{common.LeftPadBytes([]byte{i}, 32), []byte{i}, false}
+		trie.Update(value.k, value.v)
+		vals[string(value.k)] = value
+	}
+	root := trie.Hash()
+	for _, kv := range vals {
+		proofs, _ := zrmdb.NewMemDatabase()
+		if trie.Prove(kv.k, 0, proofs) != nil {
+			t.Fatalf("missing key %x while constructing proof", kv.k)
+		}
+		val, err, _ := VerifyProof(root, crypto.Keccak256(kv.k), proofs)
+		if err != nil {
+			t.Fatalf("VerifyProof error for key %x: %v\nraw proof: %v", kv.k, err, proofs)
+		}
+		if !bytes.Equal(val, kv.v) {
+			t.Fatalf("VerifyProof returned wrong value for key %x: got %x, want %x", kv.k, val, kv.v)
+		}
+	}
+}
+
 func TestVerifyBadProof(t *testing.T) {
 	trie, vals := randomTrie(800)
 	root := trie.Hash()
@@ -89,6 +115,145 @@ func TestVerifyBadProof(t *testing.T) {
 }
 
 // mutateByte changes one byte in b.
+// sortedEntries returns the entries of a test trie sorted by key.
+func sortedEntries(vals map[string]*kv) []*kv {
+	entries := make([]*kv, 0, len(vals))
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].k, entries[j].k) < 0 })
+	return entries
+}
+
+// proveRange creates the edge proofs for a range of trie entries.
+func proveRange(t *testing.T, trie *Trie, first, last []byte) *zrmdb.MemDatabase {
+	proof, _ := zrmdb.NewMemDatabase()
+	if err := trie.Prove(first, 0, proof); err != nil {
+		t.Fatalf("failed to prove the first node %x: %v", first, err)
+	}
+	if err := trie.Prove(last, 0, proof); err != nil {
+		t.Fatalf("failed to prove the last node %x: %v", last, err)
+	}
+	return proof
+}
+
+// Tests that random sub-ranges of a trie can be proven with their edge proofs,
+// including ranges starting at non-existent keys.
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 100; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + mrand.Intn(len(entries)-start)
+
+		var keys, values [][]byte
+		for _, entry := range entries[start : end+1] {
+			keys = append(keys, entry.k)
+			values = append(values, entry.v)
+		}
+		// Request the range either from the first key or from right before it
+		first := keys[0]
+		if i%2 == 0 && start > 0 {
+			first = common.CopyBytes(first)
+			for j := len(first) - 1; j >= 0; j-- {
+				if first[j] > 0 {
+					first[j]--
+					break
+				}
+				first[j] = 0xff
+			}
+			if bytes.Compare(first, entries[start-1].k) <= 0 {
+				first = keys[0]
+			}
+		}
+		proof := proveRange(t, trie, first, keys[len(keys)-1])
+		more, err := VerifyRangeProof(trie.Hash(), first, keys[len(keys)-1], keys, values, proof)
+		if err != nil {
+			t.Fatalf("case %d (%d->%d): failed to verify range: %v", i, start, end, err)
+		}
+		if more != (end != len(entries)-1) {
+			t.Fatalf("case %d (%d->%d): more elements mismatch: have %v", i, start, end, more)
+		}
+	}
+}
+
+// Tests that a range containing the entire trie can be verified without proofs,
+// and that an empty range after the last leaf proves the end of the trie.
+func TestRangeProofEdges(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	entries := sortedEntries(vals)
+
+	var keys, values [][]byte
+	for _, entry := range entries {
+		keys = append(keys, entry.k)
+		values = append(values, entry.v)
+	}
+	if _, err := VerifyRangeProof(trie.Hash(), nil, nil, keys, values, nil); err != nil {
+		t.Fatalf("failed to verify whole trie: %v", err)
+	}
+	if _, err := VerifyRangeProof(trie.Hash(), nil, nil, keys[1:], values[1:], nil); err == nil {
+		t.Fatalf("partial trie verified as whole")
+	}
+	// Prove there is nothing after the last element
+	last := entries[len(entries)-1].k
+	after := common.CopyBytes(last)
+	after[len(after)-1]++
+
+	proof, _ := zrmdb.NewMemDatabase()
+	trie.Prove(after, 0, proof)
+	if _, err := VerifyRangeProof(trie.Hash(), after, nil, nil, nil, proof); err != nil {
+		t.Fatalf("failed to verify empty tail range: %v", err)
+	}
+	// Ensure an empty range can't hide existing elements
+	first := entries[len(entries)-10].k
+
+	proof, _ = zrmdb.NewMemDatabase()
+	trie.Prove(first, 0, proof)
+	if _, err := VerifyRangeProof(trie.Hash(), first, nil, nil, nil, proof); err == nil {
+		t.Fatalf("non-empty range verified as empty")
+	}
+}
+
+// Tests that tampered ranges are rejected.
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 100; i++ {
+		start := mrand.Intn(len(entries) - 3)
+		end := start + 2 + mrand.Intn(len(entries)-start-2)
+
+		var keys, values [][]byte
+		for _, entry := range entries[start : end+1] {
+			keys = append(keys, entry.k)
+			values = append(values, entry.v)
+		}
+		proof := proveRange(t, trie, keys[0], keys[len(keys)-1])
+
+		first, last := keys[0], keys[len(keys)-1]
+		switch i % 3 {
+		case 0:
+			// Modify a random value
+			index := mrand.Intn(len(values))
+			values[index] = append(common.CopyBytes(values[index]), 0x01)
+		case 1:
+			// Drop an inner element of the range
+			index := 1 + mrand.Intn(len(keys)-2)
+			keys = append(keys[:index:index], keys[index+1:]...)
+			values = append(values[:index:index], values[index+1:]...)
+		case 2:
+			// Add a fake element after the proven last key
+			keys = append(keys[:len(keys):len(keys)], common.CopyBytes(last))
+			keys[len(keys)-1][len(last)-1]++
+			values = append(values[:len(values):len(values)], []byte{0x01})
+		}
+		if _, err := VerifyRangeProof(trie.Hash(), first, last, keys, values, proof); err == nil {
+			t.Fatalf("case %d (%d->%d): tampered range verified", i, start, end)
+		}
+	}
+}
+
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {
 		new := byte(mrand.Intn(255))
@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -28,6 +30,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/apolo-technologies/zerium/accounts/abi"
 	"github.com/apolo-technologies/zerium/common"
 	"github.com/apolo-technologies/zerium/common/hexutil"
 	"github.com/apolo-technologies/zerium/core"
@@ -184,6 +187,30 @@ func (api *PrivateMinerAPI) SetExtra(extra string) (bool, error) {
 	return true, nil
 }
 
+// SetStrategy sets the transaction ordering strategy used by the miner, only
+// including the transactions of senders on the allow list (if any) and not on
+// the deny list.
+func (api *PrivateMinerAPI) SetStrategy(name string, allow *[]common.Address, deny *[]common.Address) (bool, error) {
+	var allowed, denied []common.Address
+	if allow != nil {
+		allowed = *allow
+	}
+	if deny != nil {
+		denied = *deny
+	}
+	strategy, err := miner.NewStrategy(name, allowed, denied)
+	if err != nil {
+		return false, err
+	}
+	api.e.Miner().SetStrategy(strategy)
+	return true, nil
+}
+
+// GetStrategy returns the name of the transaction ordering strategy used by the miner.
+func (api *PrivateMinerAPI) GetStrategy() string {
+	return api.e.Miner().Strategy().Name()
+}
+
 // SetGasPrice sets the minimum accepted gas price for the miner.
 func (api *PrivateMinerAPI) SetGasPrice(gasPrice hexutil.Big) bool {
 	api.e.lock.Lock()
@@ -217,6 +244,21 @@ func NewPrivateAdminAPI(zrm *Zerium) *PrivateAdminAPI {
 	return &PrivateAdminAPI{zrm: zrm}
 }
 
+// Checkpoint returns the trusted checkpoint of the latest section indexed by the
+// light server, falling back to the configured one if the node doesn't serve
+// light clients.
+func (api *PrivateAdminAPI) Checkpoint() (*params.TrustedCheckpoint, error) {
+	if api.zrm.lesServer != nil {
+		if cp := api.zrm.lesServer.Checkpoint(); cp != nil {
+			return cp, nil
+		}
+	}
+	if cp := api.zrm.chainConfig.Checkpoint; cp != nil {
+		return cp, nil
+	}
+	return nil, errors.New("no checkpoint available")
+}
+
 // ExportChain exports the current blockchain into a local file.
 func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	// Make sure we can create the file to export into
@@ -359,8 +401,9 @@ type BlockTraceResult struct {
 // TraceArgs holds extra parameters to trace functions
 type TraceArgs struct {
 	*vm.LogConfig
-	Tracer  *string
-	Timeout *string
+	Tracer       *string
+	TracerConfig json.RawMessage `json:"tracerConfig"`
+	Timeout      *string
 }
 
 // TraceBlock processes the given block'api RLP but does not import the block in to
@@ -393,17 +436,7 @@ func (api *PrivateDebugAPI) TraceBlockFromFile(file string, config *vm.LogConfig
 // TraceBlockByNumber processes the block by canonical block number.
 func (api *PrivateDebugAPI) TraceBlockByNumber(blockNr rpc.BlockNumber, config *vm.LogConfig) BlockTraceResult {
 	// Fetch the block that we aim to reprocess
-	var block *types.Block
-	switch blockNr {
-	case rpc.PendingBlockNumber:
-		// Pending block is only known by the miner
-		block = api.zrm.miner.PendingBlock()
-	case rpc.LatestBlockNumber:
-		block = api.zrm.blockchain.CurrentBlock()
-	default:
-		block = api.zrm.blockchain.GetBlockByNumber(uint64(blockNr))
-	}
-
+	block := api.blockByNumber(blockNr)
 	if block == nil {
 		return BlockTraceResult{Error: fmt.Sprintf("block #%d not found", blockNr)}
 	}
@@ -416,6 +449,20 @@ func (api *PrivateDebugAPI) TraceBlockByNumber(blockNr rpc.BlockNumber, config *
 	}
 }
 
+// blockByNumber retrieves a block by number, resolving the pending and latest
+// block numbers.
+func (api *PrivateDebugAPI) blockByNumber(blockNr rpc.BlockNumber) *types.Block {
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		// Pending block is only known by the miner
+		return api.zrm.miner.PendingBlock()
+	case rpc.LatestBlockNumber:
+		return api.zrm.blockchain.CurrentBlock()
+	default:
+		return api.zrm.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+}
+
 // TraceBlockByHash processes the block by hash.
 func (api *PrivateDebugAPI) TraceBlockByHash(hash common.Hash, config *vm.LogConfig) BlockTraceResult {
 	// Fetch the block that we aim to reprocess
@@ -483,33 +530,11 @@ func (t *timeoutError) Error() string {
 // TraceTransaction returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.Hash, config *TraceArgs) (interface{}, error) {
-	var tracer vm.Tracer
-	if config != nil && config.Tracer != nil {
-		timeout := defaultTraceTimeout
-		if config.Timeout != nil {
-			var err error
-			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
-				return nil, err
-			}
-		}
-
-		var err error
-		if tracer, err = zaeapi.NewJavascriptTracer(*config.Tracer); err != nil {
-			return nil, err
-		}
-
-		// Handle timeouts and RPC cancellations
-		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
-		go func() {
-			<-deadlineCtx.Done()
-			tracer.(*zaeapi.JavascriptTracer).Stop(&timeoutError{})
-		}()
-		defer cancel()
-	} else if config == nil {
-		tracer = vm.NewStructLogger(nil)
-	} else {
-		tracer = vm.NewStructLogger(config.LogConfig)
+	tracer, cancel, err := newTracer(ctx, config)
+	if err != nil {
+		return nil, err
 	}
+	defer cancel()
 
 	// Retrieve the tx from the chain and the containing block
 	tx, blockHash, _, txIndex := core.GetTransaction(api.zrm.ChainDb(), txHash)
@@ -520,13 +545,115 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 	if err != nil {
 		return nil, err
 	}
+	return api.traceTx(msg, context, statedb, tracer)
+}
 
-	// Run the transaction with tracing enabled.
-	vmenv := vm.NewEVM(context, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
-	ret, gas, failed, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
+// traceTx runs the given message on top of the given state with tracing
+// enabled and returns the result of the tracer.
+func (api *PrivateDebugAPI) traceTx(msg core.Message, context vm.Context, statedb *state.StateDB, tracer vm.Tracer) (interface{}, error) {
+	var db vm.StateDB = statedb
+	if tracer, ok := tracer.(zaeapi.StateTracer); ok {
+		db = tracer.HookStateDB(db)
+	}
+	vmenv := vm.NewEVM(context, db, api.config, vm.Config{Debug: true, Tracer: tracer})
+	ret, gas, failed, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %v", err)
 	}
+	return traceResult(tracer, ret, gas, failed)
+}
+
+// TraceCall executes the given call on top of the state of the requested block
+// and returns the structured logs created during the execution of EVM, in the
+// same format as TraceTransaction. The block may be given by number or hash.
+// The optional overrides are applied to the state before the call is executed.
+func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args zaeapi.CallArgs, block rpc.BlockNumberOrHash, config *TraceArgs, overrides *zaeapi.StateOverride) (interface{}, error) {
+	tracer, cancel, err := newTracer(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var diff zaeapi.StateOverride
+	if overrides != nil {
+		diff = *overrides
+	}
+	vmCfg := vm.Config{Debug: true, Tracer: tracer}
+
+	var (
+		ret    []byte
+		gas    *big.Int
+		failed bool
+	)
+	if block.BlockHash != nil {
+		header := api.zrm.BlockChain().GetHeaderByHash(*block.BlockHash)
+		if header == nil {
+			return nil, fmt.Errorf("block %x not found", *block.BlockHash)
+		}
+		statedb, stateErr := api.zrm.BlockChain().StateAt(header.Root)
+		if stateErr != nil {
+			return nil, stateErr
+		}
+		ret, gas, failed, err = zaeapi.DoCallAt(ctx, api.zrm.ApiBackend, args, statedb, header, diff, vmCfg)
+	} else {
+		blockNr := rpc.LatestBlockNumber
+		if block.BlockNumber != nil {
+			blockNr = *block.BlockNumber
+		}
+		ret, gas, failed, err = zaeapi.DoCall(ctx, api.zrm.ApiBackend, args, blockNr, diff, vmCfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	return traceResult(tracer, ret, gas, failed)
+}
+
+// newTracer creates the tracer requested by the trace arguments, falling back
+// to a struct logger if no custom tracer was specified. The returned cancel
+// function must be called once tracing is done.
+func newTracer(ctx context.Context, config *TraceArgs) (vm.Tracer, context.CancelFunc, error) {
+	if config == nil {
+		return vm.NewStructLogger(nil), func() {}, nil
+	}
+	if config.Tracer == nil {
+		return vm.NewStructLogger(config.LogConfig), func() {}, nil
+	}
+	// Native tracers are selected by name and need no timeout handling
+	switch *config.Tracer {
+	case "callTracer":
+		return zaeapi.NewCallTracer(), func() {}, nil
+	case "prestateTracer":
+		var cfg zaeapi.PrestateTracerConfig
+		if len(config.TracerConfig) > 0 {
+			if err := json.Unmarshal(config.TracerConfig, &cfg); err != nil {
+				return nil, nil, fmt.Errorf("invalid tracer config: %v", err)
+			}
+		}
+		return zaeapi.NewPrestateTracer(cfg), func() {}, nil
+	}
+	timeout := defaultTraceTimeout
+	if config.Timeout != nil {
+		var err error
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, nil, err
+		}
+	}
+	tracer, err := zaeapi.NewJavascriptTracer(*config.Tracer)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Handle timeouts and RPC cancellations
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	go func() {
+		<-deadlineCtx.Done()
+		tracer.Stop(&timeoutError{})
+	}()
+	return tracer, cancel, nil
+}
+
+// traceResult assembles the result of a traced execution from the tracer it
+// was run with.
+func traceResult(tracer vm.Tracer, ret []byte, gas *big.Int, failed bool) (interface{}, error) {
 	switch tracer := tracer.(type) {
 	case *vm.StructLogger:
 		return &zaeapi.ExecutionResult{
@@ -537,11 +664,80 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.
 		}, nil
 	case *zaeapi.JavascriptTracer:
 		return tracer.GetResult()
+	case *zaeapi.CallTracer:
+		return tracer.GetResult()
+	case *zaeapi.PrestateTracer:
+		return tracer.GetResult()
 	default:
 		panic(fmt.Sprintf("bad tracer type %T", tracer))
 	}
 }
 
+// TxRevertReason is the revert payload of a failed transaction, along with its
+// decoded reason if the payload is a Solidity Error(string).
+type TxRevertReason struct {
+	Revert hexutil.Bytes `json:"revert"`
+	Reason string        `json:"reason,omitempty"`
+}
+
+// GetTransactionRevertReason replays a mined transaction that failed, returning
+// the payload and reason it was reverted with.
+func (api *PrivateDebugAPI) GetTransactionRevertReason(ctx context.Context, txHash common.Hash) (*TxRevertReason, error) {
+	tx, blockHash, _, txIndex := core.GetTransaction(api.zrm.ChainDb(), txHash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", txHash)
+	}
+	block := api.zrm.BlockChain().GetBlockByHash(blockHash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	return replayRevert(api.config, api.zrm.BlockChain(), block, int(txIndex))
+}
+
+// replayRevert re-executes the transactions of a block on top of its parent state
+// up to the given index, and returns the revert payload of the last one.
+func replayRevert(config *params.ChainConfig, bc *core.BlockChain, block *types.Block, txIndex int) (*TxRevertReason, error) {
+	txs := block.Transactions()
+	if txIndex < 0 || txIndex >= len(txs) {
+		return nil, fmt.Errorf("tx index %d out of range for block %x", txIndex, block.Hash())
+	}
+	parent := bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := bc.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		header  = block.Header()
+		gp      = new(core.GasPool).AddGas(header.GasLimit)
+		usedGas = new(big.Int)
+	)
+	for i, tx := range txs[:txIndex] {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		if _, _, _, err := core.ApplyTransactionWithResult(config, bc, nil, gp, statedb, header, tx, usedGas, vm.Config{}); err != nil {
+			return nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+		}
+	}
+	tx := txs[txIndex]
+	statedb.Prepare(tx.Hash(), block.Hash(), txIndex)
+
+	receipt, ret, _, err := core.ApplyTransactionWithResult(config, bc, nil, gp, statedb, header, tx, usedGas, vm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+	}
+	if receipt.Status != types.ReceiptStatusFailed {
+		return nil, fmt.Errorf("transaction %x did not fail", tx.Hash())
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("transaction %x failed without reverting", tx.Hash())
+	}
+	result := &TxRevertReason{Revert: ret}
+	result.Reason, _ = abi.UnpackRevert(ret)
+	return result, nil
+}
+
 // computeTxEnv returns the execution environment of a certain transaction.
 func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.Message, vm.Context, *state.StateDB, error) {
 	// Create the parent state.
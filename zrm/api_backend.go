@@ -32,6 +32,7 @@ import (
 	"github.com/apolo-technologies/zerium/zrm/gasprice"
 	"github.com/apolo-technologies/zerium/zrmdb"
 	"github.com/apolo-technologies/zerium/event"
+	"github.com/apolo-technologies/zerium/p2p/discover"
 	"github.com/apolo-technologies/zerium/params"
 	"github.com/apolo-technologies/zerium/rpc"
 )
@@ -140,6 +141,21 @@ func (b *zaeapiBackend) SendTx(ctx context.Context, signedTx *types.Transaction)
 	return b.zrm.txPool.AddLocal(signedTx)
 }
 
+func (b *zaeapiBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction, peers []discover.NodeID) (int, error) {
+	// Refuse the transaction upfront if it's meant for trusted peers none of which
+	// can be reached, instead of silently keeping it to the local miner only
+	if len(peers) > 0 && len(b.zrm.protocolManager.trustedPeers(peers)) == 0 {
+		return 0, errNoPrivateTxPeers
+	}
+	if err := b.zrm.txPool.AddPrivate(signedTx); err != nil {
+		return 0, err
+	}
+	if len(peers) == 0 {
+		return 0, nil
+	}
+	return b.zrm.protocolManager.ForwardPrivateTx(signedTx, peers)
+}
+
 func (b *zaeapiBackend) GetPoolTransactions() (types.Transactions, error) {
 	pending, err := b.zrm.txPool.Pending()
 	if err != nil {
@@ -164,6 +180,10 @@ func (b *zaeapiBackend) Stats() (pending int, queued int) {
 	return b.zrm.txPool.Stats()
 }
 
+func (b *zaeapiBackend) TxPoolUsage() (slots int, size common.StorageSize) {
+	return b.zrm.txPool.Usage()
+}
+
 func (b *zaeapiBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
 	return b.zrm.TxPool().Content()
 }
@@ -172,6 +192,10 @@ func (b *zaeapiBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Sub
 	return b.zrm.TxPool().SubscribeTxPreEvent(ch)
 }
 
+func (b *zaeapiBackend) SubscribeTxDropEvent(ch chan<- core.TxDropEvent) event.Subscription {
+	return b.zrm.TxPool().SubscribeTxDropEvent(ch)
+}
+
 func (b *zaeapiBackend) Downloader() *downloader.Downloader {
 	return b.zrm.Downloader()
 }
@@ -184,6 +208,18 @@ func (b *zaeapiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *zaeapiBackend) SuggestPriceTiers(ctx context.Context) (*big.Int, *big.Int, *big.Int, error) {
+	tiers, err := b.gpo.SuggestTiers(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tiers.Slow, tiers.Standard, tiers.Fast, nil
+}
+
+func (b *zaeapiBackend) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	return b.gpo.FeeHistory(ctx, blocks, lastBlock, rewardPercentiles)
+}
+
 func (b *zaeapiBackend) ChainDb() zrmdb.Database {
 	return b.zrm.ChainDb()
 }
@@ -0,0 +1,186 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/apolo-technologies/zerium/accounts/abi"
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/core"
+	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/core/vm"
+	"github.com/apolo-technologies/zerium/internal/zaeapi"
+	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/rlp"
+	"github.com/apolo-technologies/zerium/rpc"
+)
+
+// errEmptyBundle is returned if a bundle without any transactions is requested
+// to be simulated.
+var errEmptyBundle = errors.New("bundle contains no transactions")
+
+// PublicBundleAPI provides an API to simulate the combined effect of ordered
+// transaction bundles, without submitting them to the transaction pool.
+type PublicBundleAPI struct {
+	e *Zerium
+}
+
+// NewPublicBundleAPI creates a new bundle simulation API for full nodes.
+func NewPublicBundleAPI(e *Zerium) *PublicBundleAPI {
+	return &PublicBundleAPI{e}
+}
+
+// BundleTxResult is the outcome of executing a single transaction of a bundle.
+type BundleTxResult struct {
+	TxHash          common.Hash     `json:"txHash"`
+	From            common.Address  `json:"from"`
+	To              *common.Address `json:"to"`
+	ContractAddress *common.Address `json:"contractAddress,omitempty"`
+	GasUsed         *hexutil.Big    `json:"gasUsed"`
+	GasPrice        *hexutil.Big    `json:"gasPrice"`
+	CoinbaseDiff    *hexutil.Big    `json:"coinbaseDiff"`
+	Status          hexutil.Uint    `json:"status"`
+	Revert          hexutil.Bytes   `json:"revert,omitempty"`
+	RevertReason    string          `json:"revertReason,omitempty"`
+	Logs            []*types.Log    `json:"logs"`
+}
+
+// BundleResult is the combined outcome of executing a bundle of transactions.
+type BundleResult struct {
+	Results          []*BundleTxResult `json:"results"`
+	GasUsed          *hexutil.Big      `json:"gasUsed"`
+	CoinbaseDiff     *hexutil.Big      `json:"coinbaseDiff"`
+	StateBlockNumber *hexutil.Big      `json:"stateBlockNumber"`
+	StateRoot        common.Hash       `json:"stateRoot"`
+}
+
+// CallBundle executes the given signed transactions sequentially on top of the
+// state of the requested block, after applying any state overrides, and returns
+// the result of each along with their combined effect. The transactions are
+// executed in the context of a new child block mined by the local zeriumbase,
+// and neither the chain nor the transaction pool are modified.
+func (api *PublicBundleAPI) CallBundle(ctx context.Context, rawTxs []hexutil.Bytes, blockNr rpc.BlockNumber, overrides *zaeapi.StateOverride) (*BundleResult, error) {
+	if len(rawTxs) == 0 {
+		return nil, errEmptyBundle
+	}
+	txs := make([]*types.Transaction, len(rawTxs))
+	for i, raw := range rawTxs {
+		txs[i] = new(types.Transaction)
+		if err := rlp.DecodeBytes(raw, txs[i]); err != nil {
+			return nil, fmt.Errorf("tx %d: %v", i, err)
+		}
+	}
+	statedb, parent, err := api.e.ApiBackend.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	statedb = statedb.Copy()
+	if overrides != nil {
+		overrides.Apply(statedb)
+	}
+	coinbase, err := api.e.Zeriumbase()
+	if err != nil {
+		coinbase = parent.Coinbase
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   coinbase,
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   new(big.Int).Set(parent.GasLimit),
+		GasUsed:    new(big.Int),
+		Time:       new(big.Int).Add(parent.Time, common.Big1),
+		Difficulty: new(big.Int).Set(parent.Difficulty),
+	}
+	return callBundle(ctx, api.e.chainConfig, api.e.blockchain, statedb, header, txs)
+}
+
+// callBundle applies the given transactions on top of the state in the context
+// of the given header, collecting their individual and combined results.
+func callBundle(ctx context.Context, config *params.ChainConfig, bc *core.BlockChain, statedb *state.StateDB, header *types.Header, txs []*types.Transaction) (*BundleResult, error) {
+	var (
+		signer  = types.MakeSigner(config, header.Number)
+		gp      = new(core.GasPool).AddGas(header.GasLimit)
+		usedGas = new(big.Int)
+		initial = statedb.GetBalance(header.Coinbase)
+		results = make([]*BundleTxResult, 0, len(txs))
+	)
+	// Setup context so the whole bundle's execution times out like a call does
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	for i, tx := range txs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d (%x): %v", i, tx.Hash(), err)
+		}
+		from := msg.From()
+		before := statedb.GetBalance(header.Coinbase)
+
+		// Cancel the EVM when the context is done, even if it has finished
+		evm := vm.NewEVM(core.NewEVMContext(msg, header, bc, &header.Coinbase), statedb, config, vm.Config{})
+		go func() {
+			<-ctx.Done()
+			evm.Cancel()
+		}()
+		statedb.Prepare(tx.Hash(), common.Hash{}, i)
+		receipt, ret, gas, err := core.ApplyTransactionInEVM(evm, msg, gp, statedb, header, tx, usedGas)
+		if err == nil {
+			err = ctx.Err() // an aborted EVM returns without error
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tx %d (%x): %v", i, tx.Hash(), err)
+		}
+		result := &BundleTxResult{
+			TxHash:       tx.Hash(),
+			From:         from,
+			To:           tx.To(),
+			GasUsed:      (*hexutil.Big)(gas),
+			GasPrice:     (*hexutil.Big)(tx.GasPrice()),
+			CoinbaseDiff: (*hexutil.Big)(new(big.Int).Sub(statedb.GetBalance(header.Coinbase), before)),
+			Status:       hexutil.Uint(receipt.Status),
+			Logs:         receipt.Logs,
+		}
+		if tx.To() == nil {
+			result.ContractAddress = &receipt.ContractAddress
+		}
+		if receipt.Status == types.ReceiptStatusFailed && len(ret) > 0 {
+			result.Revert = ret
+			result.RevertReason, _ = abi.UnpackRevert(ret)
+		}
+		if result.Logs == nil {
+			result.Logs = []*types.Log{}
+		}
+		results = append(results, result)
+	}
+	return &BundleResult{
+		Results:          results,
+		GasUsed:          (*hexutil.Big)(usedGas),
+		CoinbaseDiff:     (*hexutil.Big)(new(big.Int).Sub(statedb.GetBalance(header.Coinbase), initial)),
+		StateBlockNumber: (*hexutil.Big)(new(big.Int).Sub(header.Number, common.Big1)),
+		StateRoot:        statedb.IntermediateRoot(config.IsEIP158(header.Number)),
+	}, nil
+}
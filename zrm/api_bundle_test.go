@@ -0,0 +1,105 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrm
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/consensus/abthash"
+	"github.com/apolo-technologies/zerium/core"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/core/vm"
+	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// revertCode is a contract reverting every call with Error("nope").
+var revertCode = common.Hex2Bytes("6064600c60003960646000fd" +
+	"08c379a0" +
+	"0000000000000000000000000000000000000000000000000000000000000020" +
+	"0000000000000000000000000000000000000000000000000000000000000004" +
+	"6e6f706500000000000000000000000000000000000000000000000000000000")
+
+// Tests that bundles are executed sequentially on top of the requested state,
+// reporting the per transaction and combined outcomes.
+func TestCallBundle(t *testing.T) {
+	var (
+		db, _    = zrmdb.NewMemDatabase()
+		coinbase = common.Address{0xc0}
+		reverter = common.Address{0xdd}
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000000)},
+				reverter: {Balance: new(big.Int), Code: revertCode},
+			},
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = core.NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
+		signer        = types.MakeSigner(gspec.Config, common.Big1)
+	)
+	defer blockchain.Stop()
+
+	statedb, err := blockchain.State()
+	if err != nil {
+		t.Fatalf("failed to retrieve head state: %v", err)
+	}
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Coinbase:   coinbase,
+		Number:     common.Big1,
+		GasLimit:   genesis.GasLimit(),
+		GasUsed:    new(big.Int),
+		Time:       common.Big1,
+		Difficulty: genesis.Difficulty(),
+	}
+	// Pay the coinbase directly, then call into the reverting contract
+	payment, _ := types.SignTx(types.NewTransaction(0, coinbase, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil), signer, testBankKey)
+	call, _ := types.SignTx(types.NewTransaction(1, reverter, new(big.Int), big.NewInt(100000), new(big.Int), nil), signer, testBankKey)
+
+	result, err := callBundle(context.Background(), gspec.Config, blockchain, statedb, header, []*types.Transaction{payment, call})
+	if err != nil {
+		t.Fatalf("failed to call bundle: %v", err)
+	}
+	if len(result.Results) != 2 {
+		t.Fatalf("result count mismatch: have %d, want %d", len(result.Results), 2)
+	}
+	if res := result.Results[0]; res.From != testBank || res.Status != hexutil.Uint(types.ReceiptStatusSuccessful) || res.CoinbaseDiff.ToInt().Cmp(big.NewInt(1000+21000)) != 0 {
+		t.Errorf("payment result mismatch: from %x, status %d, coinbase diff %v", res.From, res.Status, res.CoinbaseDiff.ToInt())
+	}
+	if res := result.Results[1]; res.Status != hexutil.Uint(types.ReceiptStatusFailed) || res.RevertReason != "nope" || res.CoinbaseDiff.ToInt().Sign() != 0 {
+		t.Errorf("call result mismatch: status %d, revert reason %q, coinbase diff %v", res.Status, res.RevertReason, res.CoinbaseDiff.ToInt())
+	}
+	if diff := result.CoinbaseDiff.ToInt(); diff.Cmp(big.NewInt(1000+21000)) != 0 {
+		t.Errorf("bundle coinbase diff mismatch: have %v, want %v", diff, 1000+21000)
+	}
+	if gas := result.GasUsed.ToInt(); gas.Cmp(new(big.Int).Add(result.Results[0].GasUsed.ToInt(), result.Results[1].GasUsed.ToInt())) != 0 {
+		t.Errorf("bundle gas used mismatch: have %v", gas)
+	}
+	if result.StateRoot == genesis.Root() {
+		t.Errorf("bundle state root unchanged")
+	}
+	// Ensure an invalid transaction aborts the whole bundle
+	statedb, _ = blockchain.State()
+	if _, err := callBundle(context.Background(), gspec.Config, blockchain, statedb, header, []*types.Transaction{call}); err == nil {
+		t.Errorf("bundle with nonce gap executed")
+	}
+}
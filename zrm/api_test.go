@@ -17,12 +17,28 @@
 package zrm
 
 import (
+	"bytes"
+	"context"
+	"math/big"
 	"reflect"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/apolo-technologies/zerium"
+	"github.com/apolo-technologies/zerium/accounts"
 	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/consensus/abthash"
+	"github.com/apolo-technologies/zerium/core"
 	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/core/vm"
+	"github.com/apolo-technologies/zerium/event"
+	"github.com/apolo-technologies/zerium/internal/zaeapi"
+	"github.com/apolo-technologies/zerium/miner"
+	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/rpc"
+	"github.com/apolo-technologies/zerium/zrmclient"
 	"github.com/apolo-technologies/zerium/zrmdb"
 )
 
@@ -86,3 +102,129 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that failed transactions can be replayed to recover their revert reason.
+func TestReplayRevert(t *testing.T) {
+	var (
+		db, _    = zrmdb.NewMemDatabase()
+		reverter = common.Address{0xdd}
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000000)},
+				reverter: {Balance: new(big.Int), Code: revertCode},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.MakeSigner(gspec.Config, common.Big1)
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, db, 1, func(i int, gen *core.BlockGen) {
+		transfer, _ := types.SignTx(types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil), signer, testBankKey)
+		call, _ := types.SignTx(types.NewTransaction(1, reverter, new(big.Int), big.NewInt(100000), big.NewInt(1), nil), signer, testBankKey)
+		gen.AddTx(transfer)
+		gen.AddTx(call)
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	result, err := replayRevert(gspec.Config, blockchain, blocks[0], 1)
+	if err != nil {
+		t.Fatalf("failed to replay reverted transaction: %v", err)
+	}
+	if result.Reason != "nope" || len(result.Revert) != 100 {
+		t.Errorf("revert mismatch: reason %q, payload %x", result.Reason, result.Revert)
+	}
+	if _, err := replayRevert(gspec.Config, blockchain, blocks[0], 0); err == nil {
+		t.Errorf("successful transaction reported as reverted")
+	}
+	if _, err := replayRevert(gspec.Config, blockchain, blocks[0], 2); err == nil {
+		t.Errorf("out of range transaction replayed")
+	}
+}
+
+// Tests that calls and gas estimations reverting with a malformed Error(string)
+// payload are reported as reverts carrying the raw payload, without a reason.
+func TestMalformedRevert(t *testing.T) {
+	// Revert with an Error(string) payload whose string offset is 2^63
+	payload := common.Hex2Bytes("08c379a0" +
+		"0000000000000000000000000000000000000000000000008000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000004")
+	code := append(common.Hex2Bytes("6044600c60003960446000fd"), payload...)
+
+	var (
+		db, _    = zrmdb.NewMemDatabase()
+		reverter = common.Address{0xdd}
+		engine   = abthash.NewFaker()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000000)},
+				reverter: {Balance: new(big.Int), Code: code},
+			},
+		}
+		_             = gspec.MustCommit(db)
+		blockchain, _ = core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	)
+	defer blockchain.Stop()
+
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+
+	zrm := &Zerium{
+		chainConfig:    gspec.Config,
+		chainDb:        db,
+		blockchain:     blockchain,
+		txPool:         core.NewTxPool(poolConfig, gspec.Config, blockchain),
+		accountManager: accounts.NewManager(),
+		eventMux:       new(event.TypeMux),
+		engine:         engine,
+	}
+	defer zrm.txPool.Stop()
+	zrm.miner = miner.New(zrm, gspec.Config, zrm.eventMux, engine)
+	defer zrm.miner.Stop()
+	zrm.ApiBackend = &zaeapiBackend{zrm, nil}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("zrm", zaeapi.NewPublicBlockChainAPI(zrm.ApiBackend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	defer server.Stop()
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// The raw responses must be code 3 errors with the payload as data
+	args := map[string]interface{}{"from": testBank, "to": reverter, "gas": hexutil.Uint64(100000)}
+	for _, method := range []string{"zrm_call", "zrm_estimateGas"} {
+		var (
+			result interface{}
+			err    error
+		)
+		if method == "zrm_call" {
+			err = client.Call(&result, method, args, "latest")
+		} else {
+			err = client.Call(&result, method, args)
+		}
+		if err == nil {
+			t.Fatalf("%s: no error returned", method)
+		}
+		if rpcErr, ok := err.(rpc.Error); !ok || rpcErr.ErrorCode() != 3 {
+			t.Errorf("%s: error code mismatch: have %v, want 3", method, err)
+		}
+		if dataErr, ok := err.(rpc.DataError); !ok || dataErr.ErrorData() != hexutil.Encode(payload) {
+			t.Errorf("%s: error data mismatch: have %v, want %x", method, err, payload)
+		}
+		if err.Error() != "execution reverted" {
+			t.Errorf("%s: error message mismatch: have %q, want %q", method, err.Error(), "execution reverted")
+		}
+	}
+	// The client must report them as reverts without a reason
+	msg := zerium.CallMsg{From: testBank, To: &reverter, Gas: big.NewInt(100000)}
+	if _, err := zrmclient.NewClient(client).CallContract(context.Background(), msg, nil); err == nil {
+		t.Errorf("client call: no error returned")
+	} else if revert, ok := err.(*zrmclient.RevertError); !ok || revert.Reason != "" || !bytes.Equal(revert.Data, payload) {
+		t.Errorf("client call: error mismatch: have %v, want revert without reason", err)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrm
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/core"
+	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/core/vm"
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/rpc"
+)
+
+const (
+	// traceChainStateReopen is the number of blocks after which chain tracing drops
+	// the reused state database and reopens it from disk, releasing the memory
+	// accumulated by cached state objects.
+	traceChainStateReopen = 128
+
+	// traceChainMaxBlocks is the maximum number of blocks traced by a single chain
+	// tracing subscription.
+	traceChainMaxBlocks = 4096
+
+	// traceChainMaxThreads is the maximum number of blocks traced concurrently by
+	// a single chain tracing subscription.
+	traceChainMaxThreads = 16
+
+	// traceChainActivation is the time chain tracing holds back its results after
+	// creating the subscription. The RPC server drops the notifications sent before
+	// it activated the subscription, which it does after replying with its ID.
+	traceChainActivation = 250 * time.Millisecond
+)
+
+// txTraceResult is the result of a single transaction trace.
+type txTraceResult struct {
+	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
+	Error  string      `json:"error,omitempty"`  // Trace failure produced by the tracer
+}
+
+// blockTraceResult represents the results of tracing a single block when an
+// entire chain is being traced.
+type blockTraceResult struct {
+	Block  hexutil.Uint64   `json:"block"`  // Block number corresponding to this trace
+	Hash   common.Hash      `json:"hash"`   // Block hash corresponding to this trace
+	Traces []*txTraceResult `json:"traces"` // Trace results produced by the task
+}
+
+// blockTraceTask represents a single block trace task when an entire chain is
+// being traced.
+type blockTraceTask struct {
+	statedb *state.StateDB    // Intermediate state prepped for tracing
+	block   *types.Block      // Block to trace the transactions from
+	results *blockTraceResult // Trace results produced by the task
+	done    chan struct{}     // Closed when the task has been traced
+}
+
+// TraceChain returns the structured logs created during the execution of EVM
+// for all transactions in the blocks between start and end (inclusive). The
+// blocks are traced concurrently and the results are streamed, in order, as
+// notifications of the returned subscription.
+func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceArgs) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	// Fetch the block interval that we want to trace
+	from := api.blockByNumber(start)
+	if from == nil {
+		return nil, fmt.Errorf("start block #%d not found", start)
+	}
+	to := api.blockByNumber(end)
+	if to == nil {
+		return nil, fmt.Errorf("end block #%d not found", end)
+	}
+	if from.NumberU64() > to.NumberU64() {
+		return nil, fmt.Errorf("end block #%d needs to come after start block #%d", to.NumberU64(), from.NumberU64())
+	}
+	if blocks := to.NumberU64() - from.NumberU64() + 1; blocks > traceChainMaxBlocks {
+		return nil, fmt.Errorf("block range of %d exceeds the limit of %d blocks", blocks, traceChainMaxBlocks)
+	}
+	// Open the state the first block is executed on top of. The genesis block has
+	// no parent, nor any transactions, so its own state is used instead.
+	blockchain := api.zrm.BlockChain()
+
+	root := from.Root()
+	if from.NumberU64() > 0 {
+		parent := blockchain.GetBlock(from.ParentHash(), from.NumberU64()-1)
+		if parent == nil {
+			return nil, fmt.Errorf("parent block %x not found", from.ParentHash())
+		}
+		root = parent.Root()
+	}
+	statedb, err := blockchain.StateAt(root)
+	if err != nil {
+		return nil, err
+	}
+	sub := notifier.CreateSubscription()
+	go api.traceChain(notifier, sub, statedb, from, to, config)
+
+	return sub, nil
+}
+
+// traceChain re-executes the blocks between start and end, handing each to a
+// pool of tracing workers along with a copy of its parent state, and streams
+// the trace results in block order until done or the subscription ends.
+func (api *PrivateDebugAPI) traceChain(notifier *rpc.Notifier, sub *rpc.Subscription, statedb *state.StateDB, start, end *types.Block, config *TraceArgs) {
+	var (
+		blockchain = api.zrm.BlockChain()
+		blocks     = int(end.NumberU64()-start.NumberU64()) + 1
+		threads    = runtime.NumCPU()
+		activated  = time.After(traceChainActivation)
+	)
+	if threads > traceChainMaxThreads {
+		threads = traceChainMaxThreads
+	}
+	if threads > blocks {
+		threads = blocks
+	}
+	var (
+		tasks   = make(chan *blockTraceTask, threads)
+		results = make(chan *blockTraceTask, threads)
+		quit    = make(chan struct{})
+		pend    sync.WaitGroup
+	)
+	defer close(quit)
+
+	// Start the tracing workers, each tracing the transactions of a block on its
+	// own copy of the block's parent state
+	for i := 0; i < threads; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+			for task := range tasks {
+				api.traceBlockTask(task, config)
+				close(task.done)
+			}
+		}()
+	}
+	// Feed the blocks to the workers, advancing a single shared state through
+	// the chain without tracing
+	go func() {
+		defer func() {
+			close(tasks)
+			pend.Wait()
+			close(results)
+		}()
+		for number := start.NumberU64(); number <= end.NumberU64(); number++ {
+			block := blockchain.GetBlockByNumber(number)
+			if block == nil {
+				log.Warn("Chain tracing aborted, block missing", "number", number)
+				return
+			}
+			task := &blockTraceTask{
+				statedb: statedb.Copy(),
+				block:   block,
+				results: &blockTraceResult{Block: hexutil.Uint64(number), Hash: block.Hash()},
+				done:    make(chan struct{}),
+			}
+			// Queue the results first to preserve the block order
+			select {
+			case results <- task:
+			case <-quit:
+				return
+			}
+			select {
+			case tasks <- task:
+			case <-quit:
+				return
+			}
+			if number == end.NumberU64() {
+				break
+			}
+			// Generate the next state without tracing, validating and finalising it
+			// like the block tracer does, and periodically reopening it to bound the
+			// memory used by cached state objects. The genesis state is already the
+			// one its child is executed on top of.
+			if number == 0 {
+				continue
+			}
+			receipts, _, usedGas, err := blockchain.Processor().Process(block, statedb, vm.Config{})
+			if err != nil {
+				log.Warn("Chain tracing aborted, block failed", "number", number, "hash", block.Hash(), "err", err)
+				return
+			}
+			if err := blockchain.Validator().ValidateState(block, blockchain.GetBlock(block.ParentHash(), number-1), statedb, receipts, usedGas); err != nil {
+				log.Warn("Chain tracing aborted, block state invalid", "number", number, "hash", block.Hash(), "err", err)
+				return
+			}
+			if (number-start.NumberU64()+1)%traceChainStateReopen == 0 {
+				if statedb, err = blockchain.StateAt(block.Root()); err != nil {
+					log.Warn("Chain tracing aborted, state unavailable", "number", number, "hash", block.Hash(), "err", err)
+					return
+				}
+			}
+		}
+	}()
+	// Stream the results in block order as they finish, once the subscription
+	// had the time to be activated
+	select {
+	case <-activated:
+	case <-sub.Err():
+		return
+	case <-notifier.Closed():
+		return
+	}
+	for task := range results {
+		select {
+		case <-task.done:
+		case <-sub.Err():
+			return
+		case <-notifier.Closed():
+			return
+		}
+		if err := notifier.Notify(sub.ID, task.results); err != nil {
+			log.Warn("Chain tracing aborted, notification failed", "err", err)
+			return
+		}
+	}
+}
+
+// traceBlockTask traces all the transactions of a block task on top of the
+// task's state, collecting the results in the task.
+func (api *PrivateDebugAPI) traceBlockTask(task *blockTraceTask, config *TraceArgs) {
+	var (
+		blockchain = api.zrm.BlockChain()
+		signer     = types.MakeSigner(api.config, task.block.Number())
+		txs        = task.block.Transactions()
+	)
+	task.results.Traces = make([]*txTraceResult, 0, len(txs))
+	for i, tx := range txs {
+		msg, _ := tx.AsMessage(signer)
+		vmctx := core.NewEVMContext(msg, task.block.Header(), blockchain, nil)
+
+		task.statedb.Prepare(tx.Hash(), task.block.Hash(), i)
+		res, err := api.traceChainTx(msg, vmctx, task.statedb, config)
+		if err != nil {
+			task.results.Traces = append(task.results.Traces, &txTraceResult{Error: err.Error()})
+			log.Warn("Tracing failed", "hash", tx.Hash(), "block", task.block.NumberU64(), "err", err)
+			break
+		}
+		task.statedb.Finalise(api.config.IsEIP158(task.block.Number()))
+		task.results.Traces = append(task.results.Traces, &txTraceResult{Result: res})
+	}
+}
+
+// traceChainTx traces a single transaction during chain tracing. The tracer
+// timeouts are not tied to the subscription request, which returns as soon as
+// tracing starts.
+func (api *PrivateDebugAPI) traceChainTx(msg core.Message, vmctx vm.Context, statedb *state.StateDB, config *TraceArgs) (interface{}, error) {
+	tracer, cancel, err := newTracer(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	return api.traceTx(msg, vmctx, statedb, tracer)
+}
@@ -0,0 +1,244 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrm
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/consensus/abthash"
+	"github.com/apolo-technologies/zerium/core"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/core/vm"
+	"github.com/apolo-technologies/zerium/internal/zaeapi"
+	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/rpc"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// traceProbe is a contract that loads the balance of the account at 0x01 and
+// its own storage slot zero onto the stack, then stops.
+var (
+	traceProbe     = common.Address{0xbb}
+	traceProbeCode = common.Hex2Bytes("730100000000000000000000000000000000000000" + "31" + "600054" + "00")
+)
+
+// newTraceChainClient creates a chain of the given length with a value transfer
+// in every block, and an in-process client to a debug API serving it. Block i
+// sends 1000 wei to the account whose address is i-1.
+func newTraceChainClient(t *testing.T, blocks int) (*core.BlockChain, *rpc.Client) {
+	var (
+		db, _ = zrmdb.NewMemDatabase()
+		gspec = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank:   {Balance: big.NewInt(1000000000)},
+				traceProbe: {Balance: new(big.Int), Code: traceProbeCode, Storage: map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(0x2a))}},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.MakeSigner(gspec.Config, common.Big1)
+	)
+	chain, _ := core.GenerateChain(gspec.Config, genesis, db, blocks, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(testBank), common.Address{byte(i)}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil), signer, testBankKey)
+		gen.AddTx(tx)
+	})
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, abthash.NewFaker(), vm.Config{})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	zrm := &Zerium{chainConfig: gspec.Config, blockchain: blockchain, chainDb: db}
+	zrm.ApiBackend = &zaeapiBackend{zrm, nil}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("debug", NewPrivateDebugAPI(gspec.Config, zrm)); err != nil {
+		t.Fatalf("failed to register debug API: %v", err)
+	}
+	return blockchain, rpc.DialInProc(server)
+}
+
+// Tests that chain tracing streams the traces of all the requested blocks in
+// order, including when starting from the genesis block.
+func TestTraceChain(t *testing.T) {
+	blockchain, client := newTraceChainClient(t, 12)
+	defer blockchain.Stop()
+	defer client.Close()
+
+	tests := []struct {
+		start, end uint64
+	}{
+		{0, 0},
+		{0, 12},
+		{1, 5},
+		{7, 12},
+		{12, 12},
+	}
+	for _, tt := range tests {
+		results := make(chan *blockTraceResult)
+		sub, err := client.Subscribe(context.Background(), "debug", results, "traceChain", hexutil.Uint64(tt.start), hexutil.Uint64(tt.end))
+		if err != nil {
+			t.Fatalf("range [%d, %d]: failed to subscribe: %v", tt.start, tt.end, err)
+		}
+		for number := tt.start; number <= tt.end; number++ {
+			select {
+			case res := <-results:
+				if uint64(res.Block) != number || res.Hash != blockchain.GetBlockByNumber(number).Hash() {
+					t.Fatalf("range [%d, %d]: block mismatch: have #%d [%x], want #%d", tt.start, tt.end, res.Block, res.Hash, number)
+				}
+				txs := len(blockchain.GetBlockByNumber(number).Transactions())
+				if len(res.Traces) != txs {
+					t.Fatalf("range [%d, %d]: block #%d trace count mismatch: have %d, want %d", tt.start, tt.end, number, len(res.Traces), txs)
+				}
+				for i, trace := range res.Traces {
+					if trace.Error != "" || trace.Result == nil {
+						t.Fatalf("range [%d, %d]: block #%d tx %d trace failed: %v", tt.start, tt.end, number, i, trace.Error)
+					}
+				}
+			case err := <-sub.Err():
+				t.Fatalf("range [%d, %d]: subscription failed: %v", tt.start, tt.end, err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("range [%d, %d]: block #%d trace timeout", tt.start, tt.end, number)
+			}
+		}
+		sub.Unsubscribe()
+	}
+	// Invalid ranges must be rejected upfront
+	results := make(chan *blockTraceResult)
+	if _, err := client.Subscribe(context.Background(), "debug", results, "traceChain", hexutil.Uint64(5), hexutil.Uint64(4)); err == nil {
+		t.Errorf("reversed range accepted")
+	}
+	if _, err := client.Subscribe(context.Background(), "debug", results, "traceChain", hexutil.Uint64(5), hexutil.Uint64(13)); err == nil {
+		t.Errorf("range beyond the head accepted")
+	}
+}
+
+// Tests that chain tracing carries on across the state reopened from disk
+// periodically, tracing every block on top of the right state.
+func TestTraceChainStateReopen(t *testing.T) {
+	blocks := traceChainStateReopen + 5
+
+	blockchain, client := newTraceChainClient(t, blocks)
+	defer blockchain.Stop()
+	defer client.Close()
+
+	results := make(chan *blockTraceResult)
+	sub, err := client.Subscribe(context.Background(), "debug", results, "traceChain", hexutil.Uint64(1), hexutil.Uint64(blocks))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for number := uint64(1); number <= uint64(blocks); number++ {
+		select {
+		case res := <-results:
+			if uint64(res.Block) != number {
+				t.Fatalf("block mismatch: have #%d, want #%d", res.Block, number)
+			}
+			for i, trace := range res.Traces {
+				if trace.Error != "" || trace.Result == nil {
+					t.Fatalf("block #%d tx %d trace failed: %v", number, i, trace.Error)
+				}
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("block #%d trace timeout", number)
+		}
+	}
+}
+
+// Tests that calls are traced on top of the state of the requested block, given
+// by number or by hash, and that state overrides are applied before the call.
+func TestTraceCall(t *testing.T) {
+	blockchain, client := newTraceChainClient(t, 3)
+	defer blockchain.Stop()
+	defer client.Close()
+
+	word := func(n int64) string {
+		return common.BigToHash(big.NewInt(n)).Hex()[2:]
+	}
+	var (
+		args    = zaeapi.CallArgs{From: testBank, To: &traceProbe, Gas: hexutil.Big(*big.NewInt(100000))}
+		balance = hexutil.Big(*big.NewInt(0x99))
+		code    = hexutil.Bytes(common.Hex2Bytes("60050000"))
+	)
+	tests := []struct {
+		block     interface{}
+		overrides *zaeapi.StateOverride
+		ops       []string
+		stack     []string // stack at the final STOP
+	}{
+		// Account 0x01 is only funded in block 2
+		{hexutil.Uint64(1), nil, []string{"PUSH20", "BALANCE", "PUSH1", "SLOAD", "STOP"}, []string{word(0), word(0x2a)}},
+		{hexutil.Uint64(2), nil, []string{"PUSH20", "BALANCE", "PUSH1", "SLOAD", "STOP"}, []string{word(1000), word(0x2a)}},
+		{blockchain.GetBlockByNumber(1).Hash(), nil, []string{"PUSH20", "BALANCE", "PUSH1", "SLOAD", "STOP"}, []string{word(0), word(0x2a)}},
+		{blockchain.GetBlockByNumber(2).Hash(), nil, []string{"PUSH20", "BALANCE", "PUSH1", "SLOAD", "STOP"}, []string{word(1000), word(0x2a)}},
+		// Balance and storage overrides are visible to the call
+		{
+			hexutil.Uint64(1),
+			&zaeapi.StateOverride{
+				common.Address{0x01}: {Balance: &balance},
+				traceProbe:           {Storage: map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(7))}},
+			},
+			[]string{"PUSH20", "BALANCE", "PUSH1", "SLOAD", "STOP"}, []string{word(0x99), word(7)},
+		},
+		{
+			blockchain.GetBlockByNumber(2).Hash(),
+			&zaeapi.StateOverride{common.Address{0x01}: {Balance: &balance}},
+			[]string{"PUSH20", "BALANCE", "PUSH1", "SLOAD", "STOP"}, []string{word(0x99), word(0x2a)},
+		},
+		// Code overrides replace the executed contract
+		{hexutil.Uint64(2), &zaeapi.StateOverride{traceProbe: {Code: &code}}, []string{"PUSH1", "STOP"}, []string{word(5)}},
+		{blockchain.GetBlockByNumber(2).Hash(), &zaeapi.StateOverride{traceProbe: {Code: &code}}, []string{"PUSH1", "STOP"}, []string{word(5)}},
+	}
+	for i, tt := range tests {
+		var result struct {
+			Failed     bool
+			StructLogs []struct {
+				Op    string
+				Stack []string
+			}
+		}
+		if err := client.Call(&result, "debug_traceCall", args, tt.block, nil, tt.overrides); err != nil {
+			t.Fatalf("test %d: failed to trace call: %v", i, err)
+		}
+		if result.Failed {
+			t.Fatalf("test %d: call failed", i)
+		}
+		if len(result.StructLogs) != len(tt.ops) {
+			t.Fatalf("test %d: struct log count mismatch: have %d, want %d", i, len(result.StructLogs), len(tt.ops))
+		}
+		for j, log := range result.StructLogs {
+			if log.Op != tt.ops[j] {
+				t.Errorf("test %d: op %d mismatch: have %s, want %s", i, j, log.Op, tt.ops[j])
+			}
+		}
+		if stack := result.StructLogs[len(result.StructLogs)-1].Stack; !reflect.DeepEqual(stack, tt.stack) {
+			t.Errorf("test %d: final stack mismatch: have %v, want %v", i, stack, tt.stack)
+		}
+	}
+	// Unknown blocks must be rejected
+	var result interface{}
+	if err := client.Call(&result, "debug_traceCall", args, common.Hash{0xff}, nil, nil); err == nil {
+		t.Errorf("unknown block hash accepted")
+	}
+}
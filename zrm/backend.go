@@ -55,6 +55,7 @@ type LesServer interface {
 	Stop()
 	Protocols() []p2p.Protocol
 	SetBloomBitsIndexer(bbIndexer *core.ChainIndexer)
+	Checkpoint() *params.TrustedCheckpoint
 }
 
 // Zerium implements the Zerium full node service.
@@ -108,15 +109,25 @@ func New(ctx *node.ServiceContext, config *Config) (*Zerium, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
+	strategy, err := miner.NewStrategy(config.MinerStrategy, config.MinerAllow, config.MinerDeny)
+	if err != nil {
+		return nil, err
+	}
 	chainDb, err := CreateDB(ctx, config, "chaindata")
 	if err != nil {
 		return nil, err
 	}
+	if chainDb, err = attachFreezer(ctx, config, chainDb); err != nil {
+		return nil, err
+	}
 	stopDbUpgrade := upgradeDeduplicateData(chainDb)
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
+	if config.Checkpoint != nil {
+		chainConfig.Checkpoint = config.Checkpoint
+	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
 	zrm := &Zerium{
@@ -145,8 +156,11 @@ func New(ctx *node.ServiceContext, config *Config) (*Zerium, error) {
 		core.WriteBlockChainVersion(chainDb, core.BlockChainVersion)
 	}
 
-	vmConfig := vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-	zrm.blockchain, err = core.NewBlockChain(chainDb, zrm.chainConfig, zrm.engine, vmConfig)
+	var (
+		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+	)
+	zrm.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, zrm.chainConfig, zrm.engine, vmConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -168,6 +182,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Zerium, error) {
 	}
 	zrm.miner = miner.New(zrm, zrm.chainConfig, zrm.EventMux(), zrm.engine)
 	zrm.miner.SetExtra(makeExtraData(config.ExtraData))
+	zrm.miner.SetStrategy(strategy)
 
 	zrm.ApiBackend = &zaeapiBackend{zrm, nil}
 	gpoParams := config.GPO
@@ -208,6 +223,24 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (zrmdb.Data
 	return db, nil
 }
 
+// attachFreezer pairs a persistent chain database with the ancient store that
+// old blocks are migrated into. Ephemeral databases are returned as is.
+func attachFreezer(ctx *node.ServiceContext, config *Config, db zrmdb.Database) (zrmdb.Database, error) {
+	if _, ok := db.(*zrmdb.MemDatabase); ok {
+		return db, nil
+	}
+	freezer := config.DatabaseFreezer
+	if freezer != "" {
+		freezer = ctx.ResolvePath(freezer)
+	}
+	adb, err := zrmdb.NewDatabaseWithFreezer(db, freezer)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return adb, nil
+}
+
 // CreateConsensusEngine creates the required type of consensus engine instance for an Zerium service
 func CreateConsensusEngine(ctx *node.ServiceContext, config *Config, chainConfig *params.ChainConfig, db zrmdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
@@ -253,6 +286,11 @@ func (s *Zerium) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicMinerAPI(s),
 			Public:    true,
+		}, {
+			Namespace: "zrm",
+			Version:   "1.0",
+			Service:   NewPublicBundleAPI(s),
+			Public:    true,
 		}, {
 			Namespace: "zrm",
 			Version:   "1.0",
@@ -22,6 +22,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/apolo-technologies/zerium/common"
 	"github.com/apolo-technologies/zerium/common/hexutil"
@@ -42,12 +43,16 @@ var DefaultConfig = Config{
 	NetworkId:            1,
 	LightPeers:           20,
 	DatabaseCache:        128,
+	TrieCache:            256,
+	TrieTimeout:          5 * time.Minute,
 	GasPrice:             big.NewInt(18 * params.Shannon),
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
-		Blocks:     10,
-		Percentile: 50,
+		Blocks:         10,
+		Percentile:     50,
+		SlowPercentile: 20,
+		FastPercentile: 80,
 	},
 }
 
@@ -76,6 +81,9 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 
+	// Trusted checkpoint to start syncing from, overriding the chain config one
+	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
@@ -84,6 +92,10 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string `toml:",omitempty"`
+	TrieCache          int
+	TrieTimeout        time.Duration
+	NoPruning          bool
 
 	// Mining-related options
 	Zeriumbase    common.Address `toml:",omitempty"`
@@ -91,6 +103,11 @@ type Config struct {
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
 
+	// Block building options (ordering strategy and sender allow/deny lists)
+	MinerStrategy string           `toml:",omitempty"`
+	MinerAllow    []common.Address `toml:",omitempty"`
+	MinerDeny     []common.Address `toml:",omitempty"`
+
 	// Abthash options
 	AbthashCacheDir       string
 	AbthashCachesInMem    int
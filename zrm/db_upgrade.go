@@ -50,7 +50,7 @@ func upgradeDeduplicateData(db zrmdb.Database) func() error {
 
 	go func() {
 		// Create an iterator to read the entire database and covert old lookup entires
-		it := db.(*zrmdb.LDBDatabase).NewIterator()
+		it := db.NewIteratorWithPrefix(nil)
 		defer func() {
 			if it != nil {
 				it.Release()
@@ -99,9 +99,9 @@ func upgradeDeduplicateData(db zrmdb.Database) func() error {
 			// avoid too high memory consumption.
 			converted++
 			if converted%100000 == 0 {
+				next := common.CopyBytes(key)
 				it.Release()
-				it = db.(*zrmdb.LDBDatabase).NewIterator()
-				it.Seek(key)
+				it = db.NewIteratorWithRange(next, nil)
 
 				log.Info("Deduplicating database entries", "deduped", converted)
 			}
@@ -80,6 +80,7 @@ var (
 	errPeersUnavailable        = errors.New("no peers available or all tried for download")
 	errInvalidAncestor         = errors.New("retrieved ancestor is invalid")
 	errInvalidChain            = errors.New("retrieved hash chain is invalid")
+	errCheckpointMismatch      = errors.New("retrieved chain doesn't match trusted checkpoint")
 	errInvalidBlock            = errors.New("retrieved block is invalid")
 	errInvalidBody             = errors.New("retrieved block body is invalid")
 	errInvalidReceipt          = errors.New("retrieved receipt is invalid")
@@ -105,6 +106,8 @@ type Downloader struct {
 	fsPivotLock  *types.Header // Pivot header on critical section entry (cannot change between retries)
 	fsPivotFails uint32        // Number of subsequent fast sync failures in the critical section
 
+	checkpoint *params.TrustedCheckpoint // Trusted checkpoint below which headers are imported unverified (nil = none)
+
 	rttEstimate   uint64 // Round trip time to target for download requests
 	rttConfidence uint64 // Confidence in the estimated RTT (unit: millionths to allow atomic ops)
 
@@ -138,6 +141,8 @@ type Downloader struct {
 	trackStateReq  chan *stateReq
 	stateCh        chan dataPack // [zrm/63] Channel receiving inbound node state data
 
+	snap *snapSyncer // Flat state range retriever of snap sync
+
 	// Cancellation and termination
 	cancelPeer string        // Identifier of the peer currently being used as the master (cancel on drop)
 	cancelCh   chan struct{} // Channel to cancel mid-flight syncs
@@ -174,6 +179,16 @@ type LightChain interface {
 	Rollback([]common.Hash)
 }
 
+// CheckpointChain is a LightChain which can be anchored at a trusted checkpoint
+// without the headers before it, allowing light syncs to skip them.
+type CheckpointChain interface {
+	LightChain
+
+	// SyncCheckpoint anchors the local chain at the trusted checkpoint if it's
+	// behind it, returning the header to continue from (nil if already past it).
+	SyncCheckpoint(cp *params.TrustedCheckpoint) (*types.Header, error)
+}
+
 // BlockChain encapsulates functions required to sync a (full or fast) blockchain.
 type BlockChain interface {
 	LightChain
@@ -201,13 +216,14 @@ type BlockChain interface {
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
-func New(mode SyncMode, stateDb zrmdb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
+func New(mode SyncMode, checkpoint *params.TrustedCheckpoint, stateDb zrmdb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
 	if lightchain == nil {
 		lightchain = chain
 	}
 
 	dl := &Downloader{
 		mode:           mode,
+		checkpoint:     checkpoint,
 		stateDB:        stateDb,
 		mux:            mux,
 		queue:          newQueue(),
@@ -227,6 +243,7 @@ func New(mode SyncMode, stateDb zrmdb.Database, mux *event.TypeMux, chain BlockC
 		stateCh:        make(chan dataPack),
 		stateSyncStart: make(chan *stateSync),
 		trackStateReq:  make(chan *stateReq),
+		snap:           newSnapSyncer(stateDb, dropPeer),
 	}
 	go dl.qosTuner()
 	go dl.stateFetcher()
@@ -249,7 +266,7 @@ func (d *Downloader) Progress() zerium.SyncProgress {
 	switch d.mode {
 	case FullSync:
 		current = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case FastSync, SnapSync:
 		current = d.blockchain.CurrentFastBlock().NumberU64()
 	case LightSync:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
@@ -322,7 +339,7 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
-		errInvalidAncestor, errInvalidChain:
+		errInvalidAncestor, errInvalidChain, errCheckpointMismatch:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
 		d.dropPeer(id)
 
@@ -386,7 +403,7 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 
 	// Set the requested sync mode, unless it's forbidden
 	d.mode = mode
-	if d.mode == FastSync && atomic.LoadUint32(&d.fsPivotFails) >= fsCriticalTrials {
+	if (d.mode == FastSync || d.mode == SnapSync) && atomic.LoadUint32(&d.fsPivotFails) >= fsCriticalTrials {
 		d.mode = FullSync
 	}
 	// Retrieve the origin peer and initiate the downloading process
@@ -429,6 +446,28 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	if err != nil {
 		return err
 	}
+	// If the sync crosses the trusted checkpoint, ensure the peer is on the
+	// checkpointed chain, importing the headers below it without verification
+	trusted := uint64(0)
+	if cp := d.checkpoint; cp != nil && d.mode != FullSync && origin < cp.HeadNumber() && height >= cp.HeadNumber() {
+		if err := d.fetchCheckpoint(p, cp); err != nil {
+			return err
+		}
+		trusted = cp.HeadNumber()
+
+		// Light syncs don't need the headers below the checkpoint at all, so if the
+		// local chain can be anchored at it, start retrieving headers from there
+		if chain, ok := d.lightchain.(CheckpointChain); ok && d.mode == LightSync {
+			anchor, err := chain.SyncCheckpoint(cp)
+			if err != nil {
+				return err
+			}
+			if anchor != nil {
+				log.Debug("Anchored light chain at trusted checkpoint", "number", anchor.Number, "hash", anchor.Hash())
+				origin, trusted = anchor.Number.Uint64(), 0
+			}
+		}
+	}
 	d.syncStatsLock.Lock()
 	if d.syncStatsChainHeight <= origin || d.syncStatsChainOrigin > origin {
 		d.syncStatsChainOrigin = origin
@@ -441,7 +480,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	switch d.mode {
 	case LightSync:
 		pivot = height
-	case FastSync:
+	case FastSync, SnapSync:
 		// Calculate the new fast/slow sync pivot point
 		if d.fsPivotLock == nil {
 			pivotOffset, err := rand.Int(rand.Reader, big.NewInt(int64(fsPivotInterval)))
@@ -474,15 +513,15 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		func() error { return d.fetchHeaders(p, origin+1) }, // Headers are always retrieved
 		func() error { return d.fetchBodies(origin + 1) },   // Bodies are retrieved during normal and fast sync
 		func() error { return d.fetchReceipts(origin + 1) }, // Receipts are retrieved during fast sync
-		func() error { return d.processHeaders(origin+1, td) },
+		func() error { return d.processHeaders(origin+1, trusted, td) },
 	}
-	if d.mode == FastSync {
+	if d.mode == FastSync || d.mode == SnapSync {
 		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest) })
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
 	}
 	err = d.spawnSync(fetchers)
-	if err != nil && d.mode == FastSync && d.fsPivotLock != nil {
+	if err != nil && (d.mode == FastSync || d.mode == SnapSync) && d.fsPivotLock != nil {
 		// If sync failed in the critical section, bump the fail counter.
 		atomic.AddUint32(&d.fsPivotFails, 1)
 	}
@@ -593,6 +632,51 @@ func (d *Downloader) fetchHeight(p *peerConnection) (*types.Header, error) {
 	}
 }
 
+// fetchCheckpoint retrieves the header of the remote peer at the trusted
+// checkpoint, ensuring the peer's chain contains the checkpointed section.
+func (d *Downloader) fetchCheckpoint(p *peerConnection, cp *params.TrustedCheckpoint) error {
+	p.log.Debug("Retrieving remote checkpoint header", "number", cp.HeadNumber())
+
+	go p.peer.RequestHeadersByNumber(cp.HeadNumber(), 1, 0, false)
+
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return errCancelBlockFetch
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			// Make sure the peer actually gave the checkpointed header
+			headers := packet.(*headerPack).headers
+			if len(headers) != 1 {
+				p.log.Debug("Multiple headers for single request", "headers", len(headers))
+				return errBadPeer
+			}
+			header := headers[0]
+			if header.Number.Uint64() != cp.HeadNumber() || header.Hash() != cp.SectionHead {
+				p.log.Warn("Remote checkpoint mismatch", "number", header.Number, "hash", header.Hash(), "want", cp.SectionHead)
+				return errCheckpointMismatch
+			}
+			p.log.Debug("Remote checkpoint header verified", "number", header.Number, "hash", header.Hash())
+			return nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for checkpoint header timed out", "elapsed", ttl)
+			return errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
 // findAncestor tries to locate the common ancestor link of the local chain and
 // a remote peers blockchain. In the general case when our node was in sync and
 // on the correct chain, checking the top N links should already get us a match.
@@ -605,7 +689,7 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 	p.log.Debug("Looking for common ancestor", "local", ceil, "remote", height)
 	if d.mode == FullSync {
 		ceil = d.blockchain.CurrentBlock().NumberU64()
-	} else if d.mode == FastSync {
+	} else if d.mode == FastSync || d.mode == SnapSync {
 		ceil = d.blockchain.CurrentFastBlock().NumberU64()
 	}
 	if ceil >= MaxForkAncestry {
@@ -1143,18 +1227,21 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 // processHeaders takes batches of retrieved headers from an input channel and
 // keeps processing and scheduling them into the header chain and downloader's
 // queue until the stream ends or a failure occurs.
-func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
+func (d *Downloader) processHeaders(origin uint64, trusted uint64, td *big.Int) error {
 	// Calculate the pivoting point for switching from fast to slow sync
 	pivot := d.queue.FastSyncPivot()
 
-	// Keep a count of uncertain headers to roll back
+	// Keep a count of uncertain headers to roll back, along with the ones imported
+	// without verification until the trusted checkpoint is reached
 	rollback := []*types.Header{}
+	unverified := []common.Hash{}
 	defer func() {
-		if len(rollback) > 0 {
+		if len(rollback) > 0 || len(unverified) > 0 {
 			// Flatten the headers and roll them back
-			hashes := make([]common.Hash, len(rollback))
-			for i, header := range rollback {
-				hashes[i] = header.Hash()
+			hashes := make([]common.Hash, 0, len(unverified)+len(rollback))
+			hashes = append(hashes, unverified...)
+			for _, header := range rollback {
+				hashes = append(hashes, header.Hash())
 			}
 			lastHeader, lastFastBlock, lastBlock := d.lightchain.CurrentHeader().Number, common.Big0, common.Big0
 			if d.mode != LightSync {
@@ -1173,7 +1260,7 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 				"block", fmt.Sprintf("%d->%d", lastBlock, curBlock))
 
 			// If we're already past the pivot point, this could be an attack, thread carefully
-			if rollback[len(rollback)-1].Number.Uint64() > pivot {
+			if len(rollback) > 0 && rollback[len(rollback)-1].Number.Uint64() > pivot {
 				// If we didn't ever fail, lock in the pivot header (must! not! change!)
 				if atomic.LoadUint32(&d.fsPivotFails) == 0 {
 					for _, header := range rollback {
@@ -1229,11 +1316,16 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 				// This check cannot be executed "as is" for full imports, since blocks may still be
 				// queued for processing when the header download completes. However, as long as the
 				// peer gave us something useful, we're already happy/progressed (above check).
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode != FullSync {
 					if td.Cmp(d.lightchain.GetTdByHash(d.lightchain.CurrentHeader().Hash())) > 0 {
 						return errStallingPeer
 					}
 				}
+				// If the trusted checkpoint was promised but never reached, the headers
+				// imported without verification cannot be kept
+				if len(unverified) > 0 {
+					return errStallingPeer
+				}
 				// Disable any rollback and return
 				rollback = nil
 				return nil
@@ -1253,10 +1345,37 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 				if limit > len(headers) {
 					limit = len(headers)
 				}
+				// Split the chunk at the trusted checkpoint, as the headers below it are
+				// imported without verification
+				if first := headers[0].Number.Uint64(); first <= trusted && trusted < first+uint64(limit)-1 {
+					limit = int(trusted-first) + 1
+				}
 				chunk := headers[:limit]
 
 				// In case of header only syncing, validate the chunk immediately
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode != FullSync && chunk[len(chunk)-1].Number.Uint64() <= trusted {
+					// Headers below the trusted checkpoint are only checked for ancestry,
+					// the checkpoint hash anchoring them once reached
+					if n, err := d.lightchain.InsertHeaderChain(chunk, 0); err != nil {
+						// If some headers were inserted, add them too to the rollback list
+						for _, header := range chunk[:n] {
+							unverified = append(unverified, header.Hash())
+						}
+						log.Debug("Invalid header encountered", "number", chunk[n].Number, "hash", chunk[n].Hash(), "err", err)
+						return errInvalidChain
+					}
+					for _, header := range chunk {
+						unverified = append(unverified, header.Hash())
+					}
+					if last := chunk[len(chunk)-1]; last.Number.Uint64() == trusted {
+						if last.Hash() != d.checkpoint.SectionHead {
+							log.Warn("Chain doesn't match trusted checkpoint", "number", last.Number, "hash", last.Hash(), "want", d.checkpoint.SectionHead)
+							return errCheckpointMismatch
+						}
+						log.Info("Imported headers up to trusted checkpoint", "number", last.Number, "hash", last.Hash())
+						unverified = nil
+					}
+				} else if d.mode != FullSync {
 					// Collect the yet unknown headers to mark them as uncertain
 					unknown := make([]*types.Header, 0, len(headers))
 					for _, header := range chunk {
@@ -1284,14 +1403,14 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 					}
 				}
 				// If we're fast syncing and just pulled in the pivot, make sure it's the one locked in
-				if d.mode == FastSync && d.fsPivotLock != nil && chunk[0].Number.Uint64() <= pivot && chunk[len(chunk)-1].Number.Uint64() >= pivot {
+				if (d.mode == FastSync || d.mode == SnapSync) && d.fsPivotLock != nil && chunk[0].Number.Uint64() <= pivot && chunk[len(chunk)-1].Number.Uint64() >= pivot {
 					if pivot := chunk[int(pivot-chunk[0].Number.Uint64())]; pivot.Hash() != d.fsPivotLock.Hash() {
 						log.Warn("Pivot doesn't match locked in one", "remoteNumber", pivot.Number, "remoteHash", pivot.Hash(), "localNumber", d.fsPivotLock.Number, "localHash", d.fsPivotLock.Hash())
 						return errInvalidChain
 					}
 				}
 				// Unless we're doing light chains, schedule the headers for associated content retrieval
-				if d.mode == FullSync || d.mode == FastSync {
+				if d.mode != LightSync {
 					// If we've reached the allowed number of pending headers, stall a bit
 					for d.queue.PendingBlocks() >= maxQueuedHeaders || d.queue.PendingReceipts() >= maxQueuedHeaders {
 						select {
@@ -96,7 +96,7 @@ func newTester() *downloadTester {
 	tester.stateDb, _ = zrmdb.NewMemDatabase()
 	tester.stateDb.Put(genesis.Root().Bytes(), []byte{0x00})
 
-	tester.downloader = New(FullSync, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
+	tester.downloader = New(FullSync, nil, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
 
 	return tester
 }
@@ -335,6 +335,23 @@ func (dl *downloadTester) InsertHeaderChain(headers []*types.Header, checkFreq i
 	return len(headers), nil
 }
 
+// SyncCheckpoint anchors the simulated chain at the trusted checkpoint, looking
+// up its header and total difficulty from the peers in place of a CHT.
+func (dl *downloadTester) SyncCheckpoint(cp *params.TrustedCheckpoint) (*types.Header, error) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	for id, headers := range dl.peerHeaders {
+		if header := headers[cp.SectionHead]; header != nil {
+			dl.ownHashes = append(dl.ownHashes, header.Hash())
+			dl.ownHeaders[header.Hash()] = header
+			dl.ownChainTd[header.Hash()] = dl.peerChainTds[id][header.Hash()]
+			return header, nil
+		}
+	}
+	return nil, errors.New("unknown checkpoint")
+}
+
 // InsertChain injects a new batch of blocks into the simulated chain.
 func (dl *downloadTester) InsertChain(blocks types.Blocks) (int, error) {
 	dl.lock.Lock()
@@ -1820,3 +1837,45 @@ func testFastCriticalRestarts(t *testing.T, protocol int, progress bool) {
 	// completed using a single mode of operation, whereas fast-then-slow can result
 	// in arbitrary intermediate state that's not cleanly verifiable.
 }
+
+// Tests that syncing across a trusted checkpoint is only done with peers having
+// the checkpointed header on their chain, and that the headers below it are
+// imported from those peers, or skipped altogether by light syncs.
+func TestCheckpointEnforcement64Fast(t *testing.T)  { testCheckpointEnforcement(t, 64, FastSync) }
+func TestCheckpointEnforcement64Light(t *testing.T) { testCheckpointEnforcement(t, 64, LightSync) }
+
+func testCheckpointEnforcement(t *testing.T, protocol int, mode SyncMode) {
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a block chain crossing the first checkpoint section
+	targetBlocks := params.CheckpointFrequency + fsMinFullBlocks
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+
+	// Attempt to sync with a checkpoint not on the peer's chain and ensure it fails
+	tester.downloader.checkpoint = &params.TrustedCheckpoint{SectionHead: common.Hash{0x01}}
+	if err := tester.sync("peer", nil, mode); err != errCheckpointMismatch {
+		t.Fatalf("checkpoint mismatch error: have %v, want %v", err, errCheckpointMismatch)
+	}
+	if head := tester.CurrentHeader().Number.Uint64(); head != 0 {
+		t.Fatalf("headers imported past mismatching checkpoint: head %d", head)
+	}
+	// Sync with the correct checkpoint and ensure the chain is retrieved, light
+	// syncs only from the checkpoint onward
+	tester.downloader.checkpoint = &params.TrustedCheckpoint{SectionHead: hashes[len(hashes)-params.CheckpointFrequency]}
+	if err := tester.sync("peer", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if mode != LightSync {
+		assertOwnChain(t, tester, targetBlocks+1)
+		return
+	}
+	if head := tester.CurrentHeader().Number.Uint64(); head != uint64(targetBlocks) {
+		t.Fatalf("synchronised head mismatch: have %d, want %d", head, targetBlocks)
+	}
+	// Genesis, the checkpoint and the headers after it
+	if have, want := len(tester.ownHeaders), 2+targetBlocks-int(tester.downloader.checkpoint.HeadNumber()); have != want {
+		t.Fatalf("synchronised headers mismatch: have %d, want %d", have, want)
+	}
+}
@@ -0,0 +1,770 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/crypto"
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/rlp"
+	"github.com/apolo-technologies/zerium/trie"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+var (
+	SnapAccountChunks = 16         // Number of chunks to split the account hash space into for concurrent retrieval
+	SnapResponseLimit = 512 * 1024 // Soft size limit of the range responses requested from peers
+	SnapStorageFetch  = 128        // Amount of accounts to request storage ranges for per request
+	SnapCodeFetch     = 64         // Amount of contract bytecodes to request per request
+)
+
+var (
+	emptyRoot = types.EmptyRootHash       // Root hash of an empty storage trie
+	emptyCode = crypto.Keccak256Hash(nil) // Code hash of accounts without contract code
+
+	errUnrequestedSnap = errors.New("unrequested snap response")
+)
+
+// SnapPeer encapsulates the methods required to retrieve flat state ranges from
+// a remote peer speaking the snap protocol. The responses are delivered back via
+// the downloader's DeliverAccountRange, DeliverStorageRanges and DeliverByteCodes
+// methods, tagged with the request id.
+type SnapPeer interface {
+	// RequestAccountRange requests the accounts of the given state root starting
+	// at origin, until limit or the soft response size is reached.
+	RequestAccountRange(id uint64, root common.Hash, origin, limit common.Hash, bytes uint64) error
+
+	// RequestStorageRanges requests the storage slots of the given accounts. The
+	// origin applies to the first account and the limit to the last one only.
+	RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit common.Hash, bytes uint64) error
+
+	// RequestByteCodes requests contract bytecodes by their hash.
+	RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error
+}
+
+// accountTask is a chunk of the account hash space still to be retrieved.
+type accountTask struct {
+	next     common.Hash // Next account hash to retrieve
+	last     common.Hash // Last account hash belonging to this chunk
+	assigned bool        // Whether a retrieval is in flight
+}
+
+// storageTask is a storage trie still to be retrieved. Small tries are retrieved
+// in batches, large ones chunk by chunk, accumulating the leaves in trie.
+type storageTask struct {
+	account   common.Hash // Hash of an account owning the storage trie
+	root      common.Hash // Root hash of the storage trie
+	stateRoot common.Hash // State root the account was retrieved from
+	next      common.Hash // Next slot hash to retrieve of a chunked trie
+	trie      *trie.Trie  // Partially retrieved trie of a chunked retrieval
+	assigned  bool        // Whether a retrieval is in flight
+}
+
+// snapRequest is a single range retrieval request sent to a snap peer, along
+// with its response once delivered.
+type snapRequest struct {
+	id     uint64
+	peer   string
+	root   common.Hash   // State root the request was made against
+	timer  *time.Timer   // Timer to fail the request if the peer stalls
+	cancel chan struct{} // Closed when the sync cycle of the request terminates
+
+	account *accountTask   // Account chunk being retrieved
+	storage []*storageTask // Storage tries being retrieved
+	codes   []common.Hash  // Contract bytecodes being retrieved
+
+	hashes    []common.Hash   // Delivered account hashes
+	accounts  [][]byte        // Delivered account bodies
+	slotKeys  [][]common.Hash // Delivered storage slot hashes per account
+	slots     [][][]byte      // Delivered storage slot values per account
+	byteCodes [][]byte        // Delivered contract bytecodes
+	proof     [][]byte        // Boundary proof nodes of the last range
+	failed    bool            // Whether the request timed out or the peer dropped
+}
+
+// snapSyncer fills the state of a root hash from flat account and storage ranges
+// retrieved from snap peers, each range verified against the root via boundary
+// Merkle proofs. The progress survives root changes: ranges already retrieved
+// for an older root are kept and the inconsistencies are healed afterwards by
+// the regular trie node sync.
+type snapSyncer struct {
+	db   zrmdb.Database // Database to write the retrieved state into
+	drop peerDropFn     // Drops a peer for misbehaving
+
+	peers   map[string]SnapPeer     // Snap peers to retrieve state ranges from
+	reqs    map[uint64]*snapRequest // Requests currently in flight
+	nextID  uint64                  // Id of the next request to send
+	lock    sync.Mutex              // Protects the peer set and the in-flight requests
+	update  chan struct{}           // Notification channel for peer set changes
+	deliver chan *snapRequest       // Channel of delivered and failed requests
+
+	root         common.Hash                     // State root currently being retrieved
+	accountTasks []*accountTask                  // Account chunks still to be retrieved
+	storageTasks []*storageTask                  // Storage tries still to be retrieved
+	storageRoots map[common.Hash]struct{}        // Storage roots already scheduled
+	codeTasks    map[common.Hash]bool            // Bytecodes still to be retrieved (and whether in flight)
+	busy         map[string]struct{}             // Peers with a request in flight
+	stateless    map[string]map[common.Hash]bool // Roots known to be unavailable from each peer
+	accountTrie  *trie.Trie                      // Account trie assembled from the ranges
+	batch        zrmdb.Batch                     // Write batch of the assembled state
+	done         bool                            // Whether all ranges were retrieved
+
+	accounts, slots, codes uint64             // Retrieval statistics
+	bytes                  common.StorageSize // Data retrieved since the last commit
+}
+
+// newSnapSyncer creates a range based state retriever writing into db.
+func newSnapSyncer(db zrmdb.Database, drop peerDropFn) *snapSyncer {
+	return &snapSyncer{
+		db:           db,
+		drop:         drop,
+		peers:        make(map[string]SnapPeer),
+		reqs:         make(map[uint64]*snapRequest),
+		update:       make(chan struct{}, 1),
+		deliver:      make(chan *snapRequest),
+		storageRoots: make(map[common.Hash]struct{}),
+		codeTasks:    make(map[common.Hash]bool),
+		busy:         make(map[string]struct{}),
+		stateless:    make(map[string]map[common.Hash]bool),
+	}
+}
+
+// register adds a snap peer to retrieve state ranges from.
+func (s *snapSyncer) register(id string, peer SnapPeer) error {
+	s.lock.Lock()
+	if _, ok := s.peers[id]; ok {
+		s.lock.Unlock()
+		return errAlreadyRegistered
+	}
+	s.peers[id] = peer
+	s.lock.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// unregister removes a snap peer, failing all its in-flight requests.
+func (s *snapSyncer) unregister(id string) error {
+	s.lock.Lock()
+	if _, ok := s.peers[id]; !ok {
+		s.lock.Unlock()
+		return errNotRegistered
+	}
+	delete(s.peers, id)
+
+	var failed []*snapRequest
+	for reqID, req := range s.reqs {
+		if req.peer == id {
+			req.timer.Stop()
+			req.failed = true
+			delete(s.reqs, reqID)
+			failed = append(failed, req)
+		}
+	}
+	s.lock.Unlock()
+
+	for _, req := range failed {
+		s.push(req)
+	}
+	s.notify()
+	return nil
+}
+
+// notify wakes the sync loop up to reconsider the task assignments.
+func (s *snapSyncer) notify() {
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+}
+
+// push hands a finished request over to the sync loop it belongs to.
+func (s *snapSyncer) push(req *snapRequest) {
+	select {
+	case s.deliver <- req:
+	case <-req.cancel:
+	}
+}
+
+// fulfil looks up an in-flight request of the given peer and fills it with the
+// delivered response, handing it over to the sync loop for processing.
+func (s *snapSyncer) fulfil(peer string, id uint64, fill func(req *snapRequest) error) error {
+	s.lock.Lock()
+	req := s.reqs[id]
+	if req == nil || req.peer != peer {
+		s.lock.Unlock()
+		return errUnrequestedSnap
+	}
+	if err := fill(req); err != nil {
+		s.lock.Unlock()
+		return err
+	}
+	req.timer.Stop()
+	delete(s.reqs, id)
+	s.lock.Unlock()
+
+	s.push(req)
+	return nil
+}
+
+// timeout fails a request if it's still in flight.
+func (s *snapSyncer) timeout(id uint64) {
+	s.lock.Lock()
+	req := s.reqs[id]
+	if req == nil {
+		s.lock.Unlock()
+		return
+	}
+	req.failed = true
+	delete(s.reqs, id)
+	s.lock.Unlock()
+
+	s.push(req)
+}
+
+// sync retrieves the state ranges of root until all of them are retrieved, the
+// sync is canceled or none of the peers are able to serve the remaining ones.
+// In the latter case the sync returns without an error, leaving the rest of the
+// state to the trie node healing.
+func (s *snapSyncer) sync(root common.Hash, ttl time.Duration, cancel chan struct{}) error {
+	if s.done {
+		return nil
+	}
+	if s.accountTasks == nil {
+		s.accountTrie, _ = trie.New(common.Hash{}, s.db)
+		s.accountTasks = splitAccountSpace(SnapAccountChunks)
+		s.batch = s.db.NewBatch()
+	}
+	if root != s.root {
+		s.root = root
+		s.stateless = make(map[string]map[common.Hash]bool)
+	}
+	log.Debug("Starting snap state sync", "root", root, "chunks", len(s.accountTasks), "storage", len(s.storageTasks), "codes", len(s.codeTasks))
+
+	quit := make(chan struct{})
+	defer s.reset(quit)
+
+	for {
+		if err := s.commit(false); err != nil {
+			return err
+		}
+		if len(s.accountTasks) == 0 && len(s.storageTasks) == 0 && len(s.codeTasks) == 0 {
+			s.done = true
+			log.Info("Snap state sync completed", "accounts", s.accounts, "slots", s.slots, "codes", s.codes)
+			return s.commit(true)
+		}
+		if !s.assign(ttl, quit) {
+			log.Info("Snap state sync stalled, healing the rest", "accounts", s.accounts, "slots", s.slots, "codes", s.codes)
+			return s.commit(true)
+		}
+		select {
+		case <-s.update:
+			// Peer set changed, reassign the tasks
+
+		case <-cancel:
+			return errCancelStateFetch
+
+		case req := <-s.deliver:
+			s.process(req)
+		}
+	}
+}
+
+// reset terminates the sync cycle of quit, dropping all its in-flight requests
+// and rescheduling their tasks.
+func (s *snapSyncer) reset(quit chan struct{}) {
+	close(quit)
+
+	s.lock.Lock()
+	for id, req := range s.reqs {
+		req.timer.Stop()
+		delete(s.reqs, id)
+	}
+	s.lock.Unlock()
+
+	for _, task := range s.accountTasks {
+		task.assigned = false
+	}
+	for _, task := range s.storageTasks {
+		task.assigned = false
+	}
+	for hash := range s.codeTasks {
+		s.codeTasks[hash] = false
+	}
+	s.busy = make(map[string]struct{})
+}
+
+// commit writes the assembled state to the database if enough accumulated, or
+// if forced.
+func (s *snapSyncer) commit(force bool) error {
+	if !force && s.bytes < zrmdb.IdealBatchSize {
+		return nil
+	}
+	if _, err := s.accountTrie.CommitTo(s.batch); err != nil {
+		return err
+	}
+	for _, task := range s.storageTasks {
+		if task.trie != nil {
+			if _, err := task.trie.CommitTo(s.batch); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.batch.Write(); err != nil {
+		return fmt.Errorf("DB write error: %v", err)
+	}
+	s.batch.Reset()
+	log.Info("Imported new state ranges", "accounts", s.accounts, "slots", s.slots, "codes", s.codes, "size", s.bytes, "chunks", len(s.accountTasks), "storage", len(s.storageTasks))
+	s.bytes = 0
+	return nil
+}
+
+// assign sends new retrieval requests to all idle peers, prioritising bytecodes
+// and storage over accounts to keep the pending task sets small. It returns
+// false if there are no requests in flight after assignment, meaning that none
+// of the peers can serve the remaining tasks.
+func (s *snapSyncer) assign(ttl time.Duration, quit chan struct{}) bool {
+	s.lock.Lock()
+	var (
+		peers = make(map[string]SnapPeer)
+		reqs  []*snapRequest
+	)
+	for id, peer := range s.peers {
+		if _, ok := s.busy[id]; ok {
+			continue
+		}
+		req := &snapRequest{id: s.nextID, peer: id, cancel: quit}
+		if !s.fillCodes(req) && !s.fillStorage(req) && !s.fillAccounts(req) {
+			continue
+		}
+		s.nextID++
+		s.reqs[req.id] = req
+		s.busy[id] = struct{}{}
+
+		reqID := req.id
+		req.timer = time.AfterFunc(ttl, func() { s.timeout(reqID) })
+		peers[req.peer], reqs = peer, append(reqs, req)
+	}
+	s.lock.Unlock()
+
+	for _, req := range reqs {
+		var err error
+		switch peer := peers[req.peer]; {
+		case req.codes != nil:
+			err = peer.RequestByteCodes(req.id, req.codes, uint64(SnapResponseLimit))
+		case req.storage != nil:
+			accounts := make([]common.Hash, len(req.storage))
+			for i, task := range req.storage {
+				accounts[i] = task.account
+			}
+			limit := common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+			err = peer.RequestStorageRanges(req.id, req.root, accounts, req.storage[0].next, limit, uint64(SnapResponseLimit))
+		default:
+			err = peer.RequestAccountRange(req.id, req.root, req.account.next, req.account.last, uint64(SnapResponseLimit))
+		}
+		if err != nil {
+			log.Debug("Failed to request state ranges", "peer", req.peer, "err", err)
+
+			s.lock.Lock()
+			if _, ok := s.reqs[req.id]; ok {
+				req.timer.Stop()
+				delete(s.reqs, req.id)
+			}
+			s.lock.Unlock()
+			s.revert(req, true)
+		}
+	}
+	// Peers stay busy until their responses are processed, even if delivered
+	return len(s.busy) > 0
+}
+
+// unavailable returns whether the peer is known not to serve the given root.
+func (s *snapSyncer) unavailable(peer string, root common.Hash) bool {
+	return s.stateless[peer][root]
+}
+
+// fillCodes assigns a batch of pending bytecode retrievals to the request.
+func (s *snapSyncer) fillCodes(req *snapRequest) bool {
+	if s.unavailable(req.peer, common.Hash{}) {
+		return false
+	}
+	for hash, assigned := range s.codeTasks {
+		if len(req.codes) >= SnapCodeFetch {
+			break
+		}
+		if !assigned {
+			s.codeTasks[hash] = true
+			req.codes = append(req.codes, hash)
+		}
+	}
+	return req.codes != nil
+}
+
+// fillStorage assigns either a single chunked storage trie or a batch of storage
+// tries of the same state root to the request.
+func (s *snapSyncer) fillStorage(req *snapRequest) bool {
+	for _, task := range s.storageTasks {
+		if task.assigned || s.unavailable(req.peer, task.stateRoot) {
+			continue
+		}
+		chunked := task.trie != nil || task.next != (common.Hash{})
+		if req.storage == nil {
+			req.root = task.stateRoot
+		} else if chunked || task.stateRoot != req.root {
+			continue
+		}
+		task.assigned = true
+		req.storage = append(req.storage, task)
+
+		if chunked || len(req.storage) >= SnapStorageFetch {
+			break
+		}
+	}
+	return req.storage != nil
+}
+
+// fillAccounts assigns the next pending account chunk to the request.
+func (s *snapSyncer) fillAccounts(req *snapRequest) bool {
+	if s.unavailable(req.peer, s.root) {
+		return false
+	}
+	for _, task := range s.accountTasks {
+		if !task.assigned {
+			task.assigned = true
+			req.root, req.account = s.root, task
+			return true
+		}
+	}
+	return false
+}
+
+// revert reschedules the tasks of a request, optionally marking the peer as not
+// being able to serve the root of the request.
+func (s *snapSyncer) revert(req *snapRequest, stateless bool) {
+	delete(s.busy, req.peer)
+	if req.account != nil {
+		req.account.assigned = false
+	}
+	for _, task := range req.storage {
+		task.assigned = false
+	}
+	for _, hash := range req.codes {
+		if _, ok := s.codeTasks[hash]; ok {
+			s.codeTasks[hash] = false
+		}
+	}
+	if stateless {
+		if s.stateless[req.peer] == nil {
+			s.stateless[req.peer] = make(map[common.Hash]bool)
+		}
+		s.stateless[req.peer][req.root] = true
+	}
+}
+
+// process integrates a delivered or failed request into the sync.
+func (s *snapSyncer) process(req *snapRequest) {
+	var err error
+	switch {
+	case req.failed:
+		s.revert(req, true)
+		return
+	case req.codes != nil:
+		err = s.processCodes(req)
+	case req.storage != nil:
+		err = s.processStorage(req)
+	default:
+		err = s.processAccounts(req)
+	}
+	if err != nil {
+		log.Warn("Invalid state range delivered, dropping peer", "peer", req.peer, "err", err)
+		s.revert(req, true)
+		s.drop(req.peer)
+	}
+}
+
+// proofDB collects the delivered proof nodes into a database keyed by their
+// hashes, or returns nil if there are none.
+func proofDB(proof [][]byte) trie.DatabaseReader {
+	if len(proof) == 0 {
+		return nil
+	}
+	db, _ := zrmdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// processAccounts verifies a delivered account range and inserts the accounts
+// into the account trie, scheduling the retrieval of their storage and code.
+func (s *snapSyncer) processAccounts(req *snapRequest) error {
+	task := req.account
+	if len(req.hashes) == 0 && len(req.proof) == 0 {
+		s.revert(req, true)
+		return nil
+	}
+	keys := make([][]byte, len(req.hashes))
+	for i := range req.hashes {
+		keys[i] = req.hashes[i][:]
+	}
+	var last []byte
+	if len(keys) > 0 {
+		last = keys[len(keys)-1]
+	}
+	more, err := trie.VerifyRangeProof(req.root, task.next[:], last, keys, req.accounts, proofDB(req.proof))
+	if err != nil {
+		return err
+	}
+	accounts := make([]state.Account, len(req.accounts))
+	for i, blob := range req.accounts {
+		if err := rlp.DecodeBytes(blob, &accounts[i]); err != nil {
+			return fmt.Errorf("invalid account %x: %v", req.hashes[i], err)
+		}
+	}
+	s.revert(req, false)
+
+	// Insert the accounts belonging to the chunk, anything beyond is only proven
+	for i, hash := range req.hashes {
+		if bytes.Compare(hash[:], task.last[:]) > 0 {
+			more = false
+			break
+		}
+		if err := s.accountTrie.TryUpdate(hash[:], req.accounts[i]); err != nil {
+			return err
+		}
+		s.accounts++
+		s.bytes += common.StorageSize(common.HashLength + len(req.accounts[i]))
+
+		if root := accounts[i].Root; root != emptyRoot {
+			if _, ok := s.storageRoots[root]; !ok {
+				if has, _ := s.db.Has(root[:]); !has {
+					s.storageRoots[root] = struct{}{}
+					s.storageTasks = append(s.storageTasks, &storageTask{account: hash, root: root, stateRoot: req.root})
+				}
+			}
+		}
+		if code := common.BytesToHash(accounts[i].CodeHash); code != emptyCode {
+			if _, ok := s.codeTasks[code]; !ok {
+				if has, _ := s.db.Has(code[:]); !has {
+					s.codeTasks[code] = false
+				}
+			}
+		}
+	}
+	// Advance the chunk, removing it if completed
+	if more {
+		if next, ok := incHash(req.hashes[len(req.hashes)-1]); ok && bytes.Compare(next[:], task.last[:]) <= 0 {
+			task.next = next
+			return nil
+		}
+	}
+	for i, t := range s.accountTasks {
+		if t == task {
+			s.accountTasks = append(s.accountTasks[:i], s.accountTasks[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// processStorage verifies the delivered storage ranges, writing out the storage
+// tries completed by them.
+func (s *snapSyncer) processStorage(req *snapRequest) error {
+	if len(req.slotKeys) == 0 && len(req.proof) == 0 {
+		s.revert(req, true)
+		return nil
+	}
+	if len(req.slotKeys) > len(req.storage) || len(req.slotKeys) != len(req.slots) {
+		return fmt.Errorf("storage range count mismatch: have %d/%d, want %d", len(req.slotKeys), len(req.slots), len(req.storage))
+	}
+	s.revert(req, false)
+
+	completed := make(map[*storageTask]bool)
+	for i, hashes := range req.slotKeys {
+		task := req.storage[i]
+
+		keys := make([][]byte, len(hashes))
+		for j := range hashes {
+			keys[j] = hashes[j][:]
+		}
+		// All but the last range must contain the entire trie, the last one may be
+		// a proven chunk
+		var proof trie.DatabaseReader
+		if i == len(req.slotKeys)-1 {
+			proof = proofDB(req.proof)
+		}
+		if proof == nil && task.next != (common.Hash{}) {
+			return fmt.Errorf("unproven storage chunk of %x", task.account)
+		}
+		var last []byte
+		if len(keys) > 0 {
+			last = keys[len(keys)-1]
+		}
+		more, err := trie.VerifyRangeProof(task.root, task.next[:], last, keys, req.slots[i], proof)
+		if err != nil {
+			return fmt.Errorf("invalid storage range of %x: %v", task.account, err)
+		}
+		if task.trie == nil {
+			task.trie, _ = trie.New(common.Hash{}, s.db)
+		}
+		for j, key := range keys {
+			if err := task.trie.TryUpdate(key, req.slots[i][j]); err != nil {
+				return err
+			}
+			s.bytes += common.StorageSize(len(key) + len(req.slots[i][j]))
+		}
+		s.slots += uint64(len(keys))
+
+		if more {
+			next, _ := incHash(hashes[len(hashes)-1])
+			task.next = next
+			continue
+		}
+		if _, err := task.trie.CommitTo(s.batch); err != nil {
+			return err
+		}
+		completed[task] = true
+	}
+	// Drop the completed storage tries from the pending set
+	pending := s.storageTasks[:0]
+	for _, task := range s.storageTasks {
+		if completed[task] {
+			delete(s.storageRoots, task.root)
+			continue
+		}
+		pending = append(pending, task)
+	}
+	s.storageTasks = pending
+	return nil
+}
+
+// processCodes verifies and writes out the delivered contract bytecodes.
+func (s *snapSyncer) processCodes(req *snapRequest) error {
+	if len(req.byteCodes) == 0 {
+		s.revert(req, true)
+		return nil
+	}
+	requested := make(map[common.Hash]bool, len(req.codes))
+	for _, hash := range req.codes {
+		requested[hash] = true
+	}
+	for _, code := range req.byteCodes {
+		hash := crypto.Keccak256Hash(code)
+		if !requested[hash] {
+			return fmt.Errorf("unrequested bytecode %x", hash)
+		}
+		if err := s.batch.Put(hash[:], code); err != nil {
+			return err
+		}
+		delete(s.codeTasks, hash)
+		s.codes++
+		s.bytes += common.StorageSize(len(code))
+	}
+	s.revert(req, false)
+	return nil
+}
+
+// splitAccountSpace splits the account hash space into n consecutive chunks.
+func splitAccountSpace(n int) []*accountTask {
+	var (
+		tasks []*accountTask
+		next  common.Hash
+		step  = new(big.Int).Div(new(big.Int).Lsh(common.Big1, 256), big.NewInt(int64(n)))
+	)
+	for i := 0; i < n; i++ {
+		last := common.BigToHash(new(big.Int).Sub(new(big.Int).Add(next.Big(), step), common.Big1))
+		if i == n-1 {
+			last = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+		}
+		tasks = append(tasks, &accountTask{next: next, last: last})
+		next = common.BigToHash(new(big.Int).Add(last.Big(), common.Big1))
+	}
+	return tasks
+}
+
+// incHash returns the hash following h, or false if h is the last one.
+func incHash(h common.Hash) (common.Hash, bool) {
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i]++
+		if h[i] != 0 {
+			return h, true
+		}
+	}
+	return h, false
+}
+
+// RegisterSnapPeer injects a new snap protocol peer into the set of sources to
+// retrieve state ranges from during snap sync.
+func (d *Downloader) RegisterSnapPeer(id string, peer SnapPeer) error {
+	log.Trace("Registering snap sync peer", "peer", id)
+	return d.snap.register(id, peer)
+}
+
+// UnregisterSnapPeer removes a snap protocol peer, rescheduling any of its
+// pending range retrievals.
+func (d *Downloader) UnregisterSnapPeer(id string) error {
+	log.Trace("Unregistering snap sync peer", "peer", id)
+	return d.snap.unregister(id)
+}
+
+// DeliverAccountRange injects a range of accounts and its boundary proof received
+// from a remote snap peer.
+func (d *Downloader) DeliverAccountRange(id string, reqID uint64, hashes []common.Hash, accounts [][]byte, proof [][]byte) error {
+	return d.snap.fulfil(id, reqID, func(req *snapRequest) error {
+		if req.account == nil {
+			return errUnrequestedSnap
+		}
+		if len(hashes) != len(accounts) {
+			return fmt.Errorf("account range mismatch: %d hashes, %d accounts", len(hashes), len(accounts))
+		}
+		req.hashes, req.accounts, req.proof = hashes, accounts, proof
+		return nil
+	})
+}
+
+// DeliverStorageRanges injects the storage ranges of a set of accounts, along
+// with the boundary proof of the last range, received from a remote snap peer.
+func (d *Downloader) DeliverStorageRanges(id string, reqID uint64, hashes [][]common.Hash, slots [][][]byte, proof [][]byte) error {
+	return d.snap.fulfil(id, reqID, func(req *snapRequest) error {
+		if req.storage == nil {
+			return errUnrequestedSnap
+		}
+		req.slotKeys, req.slots, req.proof = hashes, slots, proof
+		return nil
+	})
+}
+
+// DeliverByteCodes injects a batch of contract bytecodes received from a remote
+// snap peer.
+func (d *Downloader) DeliverByteCodes(id string, reqID uint64, codes [][]byte) error {
+	return d.snap.fulfil(id, reqID, func(req *snapRequest) error {
+		if req.codes == nil {
+			return errUnrequestedSnap
+		}
+		req.byteCodes = codes
+		return nil
+	})
+}
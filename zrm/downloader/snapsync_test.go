@@ -0,0 +1,45 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"testing"
+
+	"github.com/apolo-technologies/zerium/common"
+)
+
+// Tests that the account hash space is split into consecutive chunks, covering
+// it entirely without overlaps.
+func TestSplitAccountSpace(t *testing.T) {
+	for _, n := range []int{1, 3, 16} {
+		tasks := splitAccountSpace(n)
+		if len(tasks) != n {
+			t.Fatalf("%d chunks: chunk count mismatch: have %d", n, len(tasks))
+		}
+		if tasks[0].next != (common.Hash{}) {
+			t.Errorf("%d chunks: first chunk starts at %x", n, tasks[0].next)
+		}
+		for i := 1; i < n; i++ {
+			if next, ok := incHash(tasks[i-1].last); !ok || next != tasks[i].next {
+				t.Errorf("%d chunks: gap between chunk %d ending at %x and chunk %d starting at %x", n, i-1, tasks[i-1].last, i, tasks[i].next)
+			}
+		}
+		if _, ok := incHash(tasks[n-1].last); ok {
+			t.Errorf("%d chunks: last chunk ends at %x", n, tasks[n-1].last)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/rpc"
+)
+
+const (
+	// feeCacheLimit is the number of processed blocks to keep fee data for.
+	feeCacheLimit = 2048
+
+	// maxFeeHistory is the maximum number of blocks a fee history can span.
+	maxFeeHistory = 1024
+)
+
+var (
+	errInvalidPercentile = errors.New("invalid reward percentile")
+	errRequestBeyondHead = errors.New("request beyond head block")
+)
+
+// txGasAndPrice is the gas used and the gas price paid by a single transaction.
+type txGasAndPrice struct {
+	gasUsed uint64
+	price   *big.Int
+}
+
+// blockFees is the processed fee data of a single block.
+type blockFees struct {
+	gasUsedRatio float64
+	gasUsed      uint64          // Total gas used by all the transactions
+	txs          []txGasAndPrice // Transactions sorted by increasing gas price
+}
+
+// percentile returns the gas price at the given percentile of the transactions,
+// weighted by the gas they used. Empty blocks report a zero price. The returned
+// price is a copy, the fee data is shared through the cache.
+func (f *blockFees) percentile(p float64) *big.Int {
+	return new(big.Int).Set(weightedPercentile(f.txs, f.gasUsed, p))
+}
+
+// weightedPercentile returns the price at the given percentile of a set of price
+// sorted transactions, each one weighted by the gas it used.
+func weightedPercentile(txs []txGasAndPrice, total uint64, p float64) *big.Int {
+	if len(txs) == 0 {
+		return new(big.Int)
+	}
+	var (
+		threshold = uint64(float64(total) * p / 100)
+		sum       uint64
+	)
+	for _, tx := range txs {
+		sum += tx.gasUsed
+		if sum >= threshold {
+			return tx.price
+		}
+	}
+	return txs[len(txs)-1].price
+}
+
+// blockFeesResult is the fee data of a block fetched in the background.
+type blockFeesResult struct {
+	fees *blockFees
+	err  error
+}
+
+// fetchBlockFees retrieves the fee data of a block by number and sends it to the
+// result channel.
+func (gpo *Oracle) fetchBlockFees(ctx context.Context, number uint64, ch chan<- blockFeesResult) {
+	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(number))
+	if block == nil {
+		if err == nil {
+			err = fmt.Errorf("block #%d not found", number)
+		}
+		ch <- blockFeesResult{nil, err}
+		return
+	}
+	fees, err := gpo.blockFees(ctx, block)
+	ch <- blockFeesResult{fees, err}
+}
+
+// blockFees retrieves the fee data of a block, processing it from the block's
+// transactions and receipts if not yet cached.
+func (gpo *Oracle) blockFees(ctx context.Context, block *types.Block) (*blockFees, error) {
+	if fees, ok := gpo.feeCache.Get(block.Hash()); ok {
+		return fees.(*blockFees), nil
+	}
+	fees := new(blockFees)
+	if limit := block.GasLimit(); limit.Sign() > 0 {
+		fees.gasUsedRatio, _ = new(big.Rat).SetFrac(block.GasUsed(), limit).Float64()
+	}
+	if txs := block.Transactions(); len(txs) > 0 {
+		receipts, err := gpo.backend.GetReceipts(ctx, block.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if len(receipts) != len(txs) {
+			return nil, fmt.Errorf("receipt count mismatch for block %x: have %d, want %d", block.Hash(), len(receipts), len(txs))
+		}
+		fees.txs = make([]txGasAndPrice, len(txs))
+		for i, tx := range txs {
+			fees.txs[i] = txGasAndPrice{gasUsed: receipts[i].GasUsed.Uint64(), price: tx.GasPrice()}
+			fees.gasUsed += fees.txs[i].gasUsed
+		}
+		sort.Slice(fees.txs, func(i, j int) bool {
+			return fees.txs[i].price.Cmp(fees.txs[j].price) < 0
+		})
+	}
+	gpo.feeCache.Add(block.Hash(), fees)
+	return fees, nil
+}
+
+// FeeHistory returns the gas usage ratio and the gas used weighted gas price
+// percentiles of up to maxFeeHistory consecutive blocks, ending with lastBlock.
+// The pending block is not tracked, requesting it returns the history up to the
+// latest one. Beside the per block data, the number of the oldest block in the
+// range is returned.
+func (gpo *Oracle) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	if blocks < 1 {
+		return new(big.Int), nil, nil, nil
+	}
+	if blocks > maxFeeHistory {
+		blocks = maxFeeHistory
+	}
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return nil, nil, nil, fmt.Errorf("%v: %f", errInvalidPercentile, p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return nil, nil, nil, fmt.Errorf("%v: #%d:%f > #%d:%f", errInvalidPercentile, i-1, rewardPercentiles[i-1], i, p)
+		}
+	}
+	// Resolve the range of blocks to process
+	head, err := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil {
+		return nil, nil, nil, err
+	}
+	last := head.Number.Uint64()
+	if lastBlock >= 0 {
+		if uint64(lastBlock) > last {
+			return nil, nil, nil, fmt.Errorf("%v: requested %d, head %d", errRequestBeyondHead, lastBlock, last)
+		}
+		last = uint64(lastBlock)
+	}
+	if uint64(blocks) > last+1 {
+		blocks = int(last + 1)
+	}
+	oldest := last + 1 - uint64(blocks)
+
+	// Gather the fee data of each block in the range
+	var (
+		reward       [][]*big.Int
+		gasUsedRatio = make([]float64, blocks)
+	)
+	if len(rewardPercentiles) > 0 {
+		reward = make([][]*big.Int, blocks)
+	}
+	for i := 0; i < blocks; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, nil, err
+		}
+		number := oldest + uint64(i)
+		block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(number))
+		if block == nil {
+			if err == nil {
+				err = fmt.Errorf("block #%d not found", number)
+			}
+			return nil, nil, nil, err
+		}
+		fees, err := gpo.blockFees(ctx, block)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		gasUsedRatio[i] = fees.gasUsedRatio
+		if reward != nil {
+			reward[i] = make([]*big.Int, len(rewardPercentiles))
+			for j, p := range rewardPercentiles {
+				reward[i][j] = fees.percentile(p)
+			}
+		}
+	}
+	return new(big.Int).SetUint64(oldest), reward, gasUsedRatio, nil
+}
+
+// PriceTiers is a set of gas price suggestions for different inclusion urgencies.
+type PriceTiers struct {
+	Slow     *big.Int
+	Standard *big.Int
+	Fast     *big.Int
+}
+
+// SuggestTiers returns the slow, standard and fast gas price suggestions, being
+// the configured percentiles of the transactions of recent non-empty blocks, each
+// weighted by the gas it used.
+func (gpo *Oracle) SuggestTiers(ctx context.Context) (*PriceTiers, error) {
+	head, err := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil {
+		return nil, err
+	}
+	headHash := head.Hash()
+
+	gpo.tiersLock.Lock()
+	defer gpo.tiersLock.Unlock()
+
+	if headHash == gpo.tiersHead && gpo.lastTiers != nil {
+		return gpo.lastTiers, nil
+	}
+	// Gather the transactions of the most recent non-empty blocks, fetching them
+	// concurrently and replacing every empty block with an older one
+	var (
+		txs   []txGasAndPrice
+		total uint64
+
+		ch     = make(chan blockFeesResult, gpo.maxBlocks)
+		number = head.Number.Uint64()
+		sent   int
+		exp    int
+	)
+	for sent < gpo.checkBlocks && number > 0 {
+		go gpo.fetchBlockFees(ctx, number, ch)
+		sent++
+		exp++
+		number--
+	}
+	for exp > 0 {
+		res := <-ch
+		if res.err != nil {
+			return nil, res.err
+		}
+		exp--
+		if len(res.fees.txs) > 0 {
+			txs = append(txs, res.fees.txs...)
+			total += res.fees.gasUsed
+			continue
+		}
+		if number > 0 && sent < gpo.maxBlocks {
+			go gpo.fetchBlockFees(ctx, number, ch)
+			sent++
+			exp++
+			number--
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].price.Cmp(txs[j].price) < 0
+	})
+	gpo.cacheLock.RLock()
+	lastPrice := gpo.lastPrice
+	gpo.cacheLock.RUnlock()
+
+	suggest := func(percentile int) *big.Int {
+		price := lastPrice
+		if price == nil {
+			price = new(big.Int)
+		}
+		if len(txs) > 0 {
+			price = weightedPercentile(txs, total, float64(percentile))
+		}
+		if price.Cmp(maxPrice) > 0 {
+			price = maxPrice
+		}
+		return new(big.Int).Set(price)
+	}
+	tiers := &PriceTiers{
+		Slow:     suggest(gpo.slowPercentile),
+		Standard: suggest(gpo.percentile),
+		Fast:     suggest(gpo.fastPercentile),
+	}
+	gpo.tiersHead, gpo.lastTiers = headHash, tiers
+	return tiers, nil
+}
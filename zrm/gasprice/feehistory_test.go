@@ -0,0 +1,199 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/rpc"
+)
+
+// testBackend is a mock chain of blocks with fixed transaction sets.
+type testBackend struct {
+	blocks   []*types.Block
+	receipts map[common.Hash]types.Receipts
+	fetches  int        // Number of receipt retrievals
+	lock     sync.Mutex // Protects the retrieval counter from concurrent fetches
+}
+
+// newTestBackend creates a chain with a block for each of the given transaction
+// sets, each transaction being described by its gas price and the gas it used.
+func newTestBackend(blocks ...[][2]int64) *testBackend {
+	b := &testBackend{receipts: make(map[common.Hash]types.Receipts)}
+	for number := 0; number <= len(blocks); number++ {
+		var (
+			txs      types.Transactions
+			receipts types.Receipts
+			gasUsed  = new(big.Int)
+		)
+		if number > 0 {
+			for i, tx := range blocks[number-1] {
+				price, gas := big.NewInt(tx[0]), big.NewInt(tx[1])
+				txs = append(txs, types.NewTransaction(uint64(i), common.Address{}, new(big.Int), gas, price, nil))
+
+				gasUsed.Add(gasUsed, gas)
+				receipt := types.NewReceipt(nil, false, new(big.Int).Set(gasUsed))
+				receipt.GasUsed = gas
+				receipts = append(receipts, receipt)
+			}
+		}
+		header := &types.Header{
+			Number:   big.NewInt(int64(number)),
+			GasLimit: big.NewInt(168000),
+			GasUsed:  gasUsed,
+			Extra:    []byte(fmt.Sprintf("block %d", number)),
+		}
+		block := types.NewBlock(header, txs, nil, receipts)
+		b.blocks = append(b.blocks, block)
+		b.receipts[block.Hash()] = receipts
+	}
+	return b
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	block, err := b.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number < 0 {
+		return b.blocks[len(b.blocks)-1], nil
+	}
+	if int(number) >= len(b.blocks) {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return b.blocks[number], nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	b.lock.Lock()
+	b.fetches++
+	b.lock.Unlock()
+
+	return b.receipts[hash], nil
+}
+
+// Tests that fee histories report the gas used ratio and the gas weighted price
+// percentiles of the requested blocks.
+func TestFeeHistory(t *testing.T) {
+	backend := newTestBackend(
+		[][2]int64{{3, 63000}, {1, 21000}},
+		nil,
+		[][2]int64{{2, 21000}},
+	)
+	oracle := NewOracle(backend, Config{Blocks: 2, Default: big.NewInt(5)})
+
+	oldest, reward, ratios, err := oracle.FeeHistory(context.Background(), 3, rpc.LatestBlockNumber, []float64{0, 25, 50, 100})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	if oldest.Uint64() != 1 {
+		t.Errorf("oldest block mismatch: have %d, want %d", oldest, 1)
+	}
+	if want := []float64{0.5, 0, 0.125}; fmt.Sprint(ratios) != fmt.Sprint(want) {
+		t.Errorf("gas used ratio mismatch: have %v, want %v", ratios, want)
+	}
+	if want := [][]int64{{1, 1, 3, 3}, {0, 0, 0, 0}, {2, 2, 2, 2}}; fmt.Sprint(reward) != fmt.Sprint(want) {
+		t.Errorf("reward mismatch: have %v, want %v", reward, want)
+	}
+	// Ensure processed blocks are served from the cache, unaffected by callers
+	// modifying the returned prices
+	reward[0][0].SetInt64(100)
+
+	fetches := backend.fetches
+	_, cached, _, err := oracle.FeeHistory(context.Background(), 2, 1, []float64{0})
+	if err != nil {
+		t.Fatalf("failed to retrieve cached fee history: %v", err)
+	}
+	if want := [][]int64{{0}, {1}}; fmt.Sprint(cached) != fmt.Sprint(want) {
+		t.Errorf("cached reward mismatch: have %v, want %v", cached, want)
+	}
+	if backend.fetches != fetches {
+		t.Errorf("receipts refetched for cached blocks: have %d fetches, want %d", backend.fetches, fetches)
+	}
+	// Ensure invalid requests are rejected
+	if _, _, _, err := oracle.FeeHistory(context.Background(), 1, 4, nil); err == nil {
+		t.Errorf("fee history beyond head returned")
+	}
+	if _, _, _, err := oracle.FeeHistory(context.Background(), 1, rpc.LatestBlockNumber, []float64{50, 10}); err == nil {
+		t.Errorf("fee history with unordered percentiles returned")
+	}
+	if _, _, _, err := oracle.FeeHistory(context.Background(), 1, rpc.LatestBlockNumber, []float64{101}); err == nil {
+		t.Errorf("fee history with out of range percentile returned")
+	}
+}
+
+// Tests that price tiers are derived from the gas weighted prices of the recent
+// non-empty blocks, falling back to the default price if there are none.
+func TestSuggestTiers(t *testing.T) {
+	config := Config{Blocks: 2, Percentile: 30, SlowPercentile: 20, FastPercentile: 80, Default: big.NewInt(5)}
+
+	oracle := NewOracle(newTestBackend(
+		[][2]int64{{3, 63000}, {1, 21000}},
+		nil,
+		[][2]int64{{2, 21000}},
+	), config)
+	tiers, err := oracle.SuggestTiers(context.Background())
+	if err != nil {
+		t.Fatalf("failed to suggest price tiers: %v", err)
+	}
+	if tiers.Slow.Int64() != 1 || tiers.Standard.Int64() != 2 || tiers.Fast.Int64() != 3 {
+		t.Errorf("tiers mismatch: have %v/%v/%v, want 1/2/3", tiers.Slow, tiers.Standard, tiers.Fast)
+	}
+	oracle = NewOracle(newTestBackend(nil), config)
+	if tiers, err = oracle.SuggestTiers(context.Background()); err != nil {
+		t.Fatalf("failed to suggest price tiers: %v", err)
+	}
+	if tiers.Slow.Int64() != 5 || tiers.Standard.Int64() != 5 || tiers.Fast.Int64() != 5 {
+		t.Errorf("default tiers mismatch: have %v/%v/%v, want 5/5/5", tiers.Slow, tiers.Standard, tiers.Fast)
+	}
+}
+
+// Tests that the suggested gas price is the gas weighted standard tier, and that
+// unset slow and fast percentiles fall back to their defaults.
+func TestSuggestPrice(t *testing.T) {
+	config := Config{Blocks: 2, Percentile: 30, Default: big.NewInt(5)}
+
+	oracle := NewOracle(newTestBackend(
+		[][2]int64{{3, 63000}, {1, 21000}},
+		nil,
+		[][2]int64{{2, 21000}},
+	), config)
+	price, err := oracle.SuggestPrice(context.Background())
+	if err != nil {
+		t.Fatalf("failed to suggest price: %v", err)
+	}
+	if price.Int64() != 2 {
+		t.Errorf("price mismatch: have %v, want 2", price)
+	}
+	tiers, err := oracle.SuggestTiers(context.Background())
+	if err != nil {
+		t.Fatalf("failed to suggest price tiers: %v", err)
+	}
+	if tiers.Slow.Int64() != 1 || tiers.Standard.Int64() != 2 || tiers.Fast.Int64() != 3 {
+		t.Errorf("tiers mismatch: have %v/%v/%v, want 1/2/3", tiers.Slow, tiers.Standard, tiers.Fast)
+	}
+}
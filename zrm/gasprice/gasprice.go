@@ -19,156 +19,119 @@ package gasprice
 import (
 	"context"
 	"math/big"
-	"sort"
 	"sync"
 
 	"github.com/apolo-technologies/zerium/common"
-	"github.com/apolo-technologies/zerium/internal/zaeapi"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/log"
 	"github.com/apolo-technologies/zerium/params"
 	"github.com/apolo-technologies/zerium/rpc"
+	"github.com/hashicorp/golang-lru"
 )
 
 var maxPrice = big.NewInt(500 * params.Shannon)
 
+const (
+	defaultSlowPercentile = 20 // Slow tier percentile if left unset
+	defaultFastPercentile = 80 // Fast tier percentile if left unset
+)
+
 type Config struct {
-	Blocks     int
-	Percentile int
-	Default    *big.Int `toml:",omitempty"`
+	Blocks         int
+	Percentile     int
+	SlowPercentile int      `toml:",omitempty"`
+	FastPercentile int      `toml:",omitempty"`
+	Default        *big.Int `toml:",omitempty"`
+}
+
+// OracleBackend is the chain access the oracle needs, provided by both full and
+// light clients.
+type OracleBackend interface {
+	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
+	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
+	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 }
 
 // Oracle recommends gas prices based on the content of recent
 // blocks. Suitable for both light and full clients.
 type Oracle struct {
-	backend   zaeapi.Backend
+	backend   OracleBackend
 	lastHead  common.Hash
 	lastPrice *big.Int
+	lastTiers *PriceTiers
+	tiersHead common.Hash
 	cacheLock sync.RWMutex
-	fetchLock sync.Mutex
+	tiersLock sync.Mutex
+
+	feeCache *lru.Cache // Processed fee data of recent blocks, keyed by hash
 
-	checkBlocks, maxEmpty, maxBlocks int
-	percentile                       int
+	checkBlocks, maxBlocks         int
+	percentile                     int
+	slowPercentile, fastPercentile int
 }
 
 // NewOracle returns a new oracle.
-func NewOracle(backend zaeapi.Backend, params Config) *Oracle {
+func NewOracle(backend OracleBackend, params Config) *Oracle {
 	blocks := params.Blocks
 	if blocks < 1 {
 		blocks = 1
 	}
-	percent := params.Percentile
-	if percent < 0 {
-		percent = 0
+	slow, fast := params.SlowPercentile, params.FastPercentile
+	if slow <= 0 {
+		slow = defaultSlowPercentile
+		log.Debug("Sanitizing unset gas price oracle slow percentile", "provided", params.SlowPercentile, "updated", slow)
 	}
-	if percent > 100 {
-		percent = 100
+	if fast <= 0 {
+		fast = defaultFastPercentile
+		log.Debug("Sanitizing unset gas price oracle fast percentile", "provided", params.FastPercentile, "updated", fast)
 	}
+	feeCache, _ := lru.New(feeCacheLimit)
 	return &Oracle{
-		backend:     backend,
-		lastPrice:   params.Default,
-		checkBlocks: blocks,
-		maxEmpty:    blocks / 2,
-		maxBlocks:   blocks * 5,
-		percentile:  percent,
+		backend:        backend,
+		lastPrice:      params.Default,
+		feeCache:       feeCache,
+		checkBlocks:    blocks,
+		maxBlocks:      blocks * 5,
+		percentile:     clampPercentile(params.Percentile),
+		slowPercentile: clampPercentile(slow),
+		fastPercentile: clampPercentile(fast),
 	}
 }
 
-// SuggestPrice returns the recommended gas price.
+// clampPercentile limits a configured percentile into the [0, 100] range.
+func clampPercentile(percent int) int {
+	if percent < 0 {
+		return 0
+	}
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+// SuggestPrice returns the recommended gas price, being the standard tier of the
+// gas weighted price suggestions.
 func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	gpo.cacheLock.RLock()
 	lastHead := gpo.lastHead
 	lastPrice := gpo.lastPrice
 	gpo.cacheLock.RUnlock()
 
-	head, _ := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
-	headHash := head.Hash()
-	if headHash == lastHead {
-		return lastPrice, nil
+	head, err := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil {
+		return lastPrice, err
 	}
-
-	gpo.fetchLock.Lock()
-	defer gpo.fetchLock.Unlock()
-
-	// try checking the cache again, maybe the last fetch fetched what we need
-	gpo.cacheLock.RLock()
-	lastHead = gpo.lastHead
-	lastPrice = gpo.lastPrice
-	gpo.cacheLock.RUnlock()
+	headHash := head.Hash()
 	if headHash == lastHead {
 		return lastPrice, nil
 	}
-
-	blockNum := head.Number.Uint64()
-	ch := make(chan getBlockPricesResult, gpo.checkBlocks)
-	sent := 0
-	exp := 0
-	var txPrices []*big.Int
-	for sent < gpo.checkBlocks && blockNum > 0 {
-		go gpo.getBlockPrices(ctx, blockNum, ch)
-		sent++
-		exp++
-		blockNum--
+	tiers, err := gpo.SuggestTiers(ctx)
+	if err != nil {
+		return lastPrice, err
 	}
-	maxEmpty := gpo.maxEmpty
-	for exp > 0 {
-		res := <-ch
-		if res.err != nil {
-			return lastPrice, res.err
-		}
-		exp--
-		if len(res.prices) > 0 {
-			txPrices = append(txPrices, res.prices...)
-			continue
-		}
-		if maxEmpty > 0 {
-			maxEmpty--
-			continue
-		}
-		if blockNum > 0 && sent < gpo.maxBlocks {
-			go gpo.getBlockPrices(ctx, blockNum, ch)
-			sent++
-			exp++
-			blockNum--
-		}
-	}
-	price := lastPrice
-	if len(txPrices) > 0 {
-		sort.Sort(bigIntArray(txPrices))
-		price = txPrices[(len(txPrices)-1)*gpo.percentile/100]
-	}
-	if price.Cmp(maxPrice) > 0 {
-		price = new(big.Int).Set(maxPrice)
-	}
-
 	gpo.cacheLock.Lock()
 	gpo.lastHead = headHash
-	gpo.lastPrice = price
+	gpo.lastPrice = tiers.Standard
 	gpo.cacheLock.Unlock()
-	return price, nil
+	return tiers.Standard, nil
 }
-
-type getBlockPricesResult struct {
-	prices []*big.Int
-	err    error
-}
-
-// getLowestPrice calculates the lowest transaction gas price in a given block
-// and sends it to the result channel. If the block is empty, price is nil.
-func (gpo *Oracle) getBlockPrices(ctx context.Context, blockNum uint64, ch chan getBlockPricesResult) {
-	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
-	if block == nil {
-		ch <- getBlockPricesResult{nil, err}
-		return
-	}
-	txs := block.Transactions()
-	prices := make([]*big.Int, len(txs))
-	for i, tx := range txs {
-		prices[i] = tx.GasPrice()
-	}
-	ch <- getBlockPricesResult{prices, nil}
-}
-
-type bigIntArray []*big.Int
-
-func (s bigIntArray) Len() int           { return len(s) }
-func (s bigIntArray) Less(i, j int) bool { return s[i].Cmp(s[j]) < 0 }
-func (s bigIntArray) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
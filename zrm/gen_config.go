@@ -4,10 +4,12 @@ package zrm
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/apolo-technologies/zerium/common"
 	"github.com/apolo-technologies/zerium/common/hexutil"
 	"github.com/apolo-technologies/zerium/core"
+	"github.com/apolo-technologies/zerium/params"
 	"github.com/apolo-technologies/zerium/zrm/downloader"
 	"github.com/apolo-technologies/zerium/zrm/gasprice"
 )
@@ -17,16 +19,24 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		MaxPeers                int  `toml:"-"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		Checkpoint              *params.TrustedCheckpoint `toml:",omitempty"`
+		LightServ               int                       `toml:",omitempty"`
+		LightPeers              int                       `toml:",omitempty"`
+		MaxPeers                int                       `toml:"-"`
+		SkipBcVersionCheck      bool                      `toml:"-"`
+		DatabaseHandles         int                       `toml:"-"`
 		DatabaseCache           int
+		DatabaseFreezer         string `toml:",omitempty"`
+		TrieCache               int
+		TrieTimeout             time.Duration
+		NoPruning               bool
 		Zeriumbase               common.Address `toml:",omitempty"`
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerStrategy           string           `toml:",omitempty"`
+		MinerAllow              []common.Address `toml:",omitempty"`
+		MinerDeny               []common.Address `toml:",omitempty"`
 		AbthashCacheDir          string
 		AbthashCachesInMem       int
 		AbthashCachesOnDisk      int
@@ -45,15 +55,23 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
+	enc.Checkpoint = c.Checkpoint
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.TrieCache = c.TrieCache
+	enc.TrieTimeout = c.TrieTimeout
+	enc.NoPruning = c.NoPruning
 	enc.Zeriumbase = c.Zeriumbase
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.MinerStrategy = c.MinerStrategy
+	enc.MinerAllow = c.MinerAllow
+	enc.MinerDeny = c.MinerDeny
 	enc.AbthashCacheDir = c.AbthashCacheDir
 	enc.AbthashCachesInMem = c.AbthashCachesInMem
 	enc.AbthashCachesOnDisk = c.AbthashCachesOnDisk
@@ -75,16 +93,24 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		MaxPeers                *int  `toml:"-"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		Checkpoint              *params.TrustedCheckpoint `toml:",omitempty"`
+		LightServ               *int                      `toml:",omitempty"`
+		LightPeers              *int                      `toml:",omitempty"`
+		MaxPeers                *int                      `toml:"-"`
+		SkipBcVersionCheck      *bool                     `toml:"-"`
+		DatabaseHandles         *int                      `toml:"-"`
 		DatabaseCache           *int
+		DatabaseFreezer         *string `toml:",omitempty"`
+		TrieCache               *int
+		TrieTimeout             *time.Duration
+		NoPruning               *bool
 		Zeriumbase               *common.Address `toml:",omitempty"`
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes   `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerStrategy           *string          `toml:",omitempty"`
+		MinerAllow              []common.Address `toml:",omitempty"`
+		MinerDeny               []common.Address `toml:",omitempty"`
 		AbthashCacheDir          *string
 		AbthashCachesInMem       *int
 		AbthashCachesOnDisk      *int
@@ -112,6 +138,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.Checkpoint != nil {
+		c.Checkpoint = dec.Checkpoint
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -127,6 +156,18 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.DatabaseFreezer != nil {
+		c.DatabaseFreezer = *dec.DatabaseFreezer
+	}
+	if dec.TrieCache != nil {
+		c.TrieCache = *dec.TrieCache
+	}
+	if dec.TrieTimeout != nil {
+		c.TrieTimeout = *dec.TrieTimeout
+	}
+	if dec.NoPruning != nil {
+		c.NoPruning = *dec.NoPruning
+	}
 	if dec.Zeriumbase != nil {
 		c.Zeriumbase = *dec.Zeriumbase
 	}
@@ -139,6 +180,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.MinerStrategy != nil {
+		c.MinerStrategy = *dec.MinerStrategy
+	}
+	if dec.MinerAllow != nil {
+		c.MinerAllow = dec.MinerAllow
+	}
+	if dec.MinerDeny != nil {
+		c.MinerDeny = dec.MinerDeny
+	}
 	if dec.AbthashCacheDir != nil {
 		c.AbthashCacheDir = *dec.AbthashCacheDir
 	}
@@ -40,6 +40,7 @@ import (
 	"github.com/apolo-technologies/zerium/p2p/discover"
 	"github.com/apolo-technologies/zerium/params"
 	"github.com/apolo-technologies/zerium/rlp"
+	"github.com/apolo-technologies/zerium/trie"
 )
 
 const (
@@ -59,6 +60,10 @@ var (
 // not compatible (low protocol version restrictions and high requirements).
 var errIncompatibleConfig = errors.New("incompatible configuration")
 
+// errNoPrivateTxPeers is returned if none of the trusted peers a private
+// transaction is meant for are connected.
+var errNoPrivateTxPeers = errors.New("no trusted peer connected")
+
 func errResp(code errCode, format string, v ...interface{}) error {
 	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
 }
@@ -67,6 +72,7 @@ type ProtocolManager struct {
 	networkId uint64
 
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
+	snapSync  uint32 // Flag whether the fast sync state is retrieved as snap ranges
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
 	txpool      txPool
@@ -115,18 +121,21 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		quitSync:    make(chan struct{}),
 	}
 	// Figure out whether to allow fast sync or not
-	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
+	if (mode == downloader.FastSync || mode == downloader.SnapSync) && blockchain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = downloader.FullSync
 	}
-	if mode == downloader.FastSync {
+	if mode == downloader.FastSync || mode == downloader.SnapSync {
 		manager.fastSync = uint32(1)
 	}
+	if mode == downloader.SnapSync {
+		manager.snapSync = uint32(1)
+	}
 	// Initiate a sub-protocol for every implemented version we can handle
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		// Skip protocol version if incompatible with the mode of operation
-		if mode == downloader.FastSync && version < eth63 {
+		if (mode == downloader.FastSync || mode == downloader.SnapSync) && version < eth63 {
 			continue
 		}
 		// Compatible; initialise the sub-protocol
@@ -160,8 +169,26 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	if len(manager.SubProtocols) == 0 {
 		return nil, errIncompatibleConfig
 	}
+	// Serve the state ranges of snap sync to anyone, and use them if snap syncing
+	for i, version := range SnapProtocolVersions {
+		manager.SubProtocols = append(manager.SubProtocols, p2p.Protocol{
+			Name:    SnapProtocolName,
+			Version: version,
+			Length:  SnapProtocolLengths[i],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				select {
+				case <-manager.quitSync:
+					return p2p.DiscQuitting
+				default:
+				}
+				manager.wg.Add(1)
+				defer manager.wg.Done()
+				return manager.handleSnap(newSnapPeer(p, rw))
+			},
+		})
+	}
 	// Construct the different synchronisation mechanisms
-	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(mode, config.Checkpoint, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -520,9 +547,10 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 		// Gather state data until the fetch or network limits is reached
 		var (
-			hash  common.Hash
-			bytes int
-			data  [][]byte
+			hash    common.Hash
+			bytes   int
+			data    [][]byte
+			statedb = pm.stateDatabase()
 		)
 		for bytes < softResponseLimit && len(data) < downloader.MaxStateFetch {
 			// Retrieve the hash of the next state entry
@@ -532,7 +560,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				return errResp(ErrDecode, "msg %v: %v", msg, err)
 			}
 			// Retrieve the requested state entry, stopping if enough was found
-			if entry, err := pm.chaindb.Get(hash.Bytes()); err == nil {
+			if entry, err := statedb.Get(hash.Bytes()); err == nil {
 				data = append(data, entry)
 				bytes += len(entry)
 			}
@@ -674,6 +702,16 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	return nil
 }
 
+// stateDatabase returns the database node data requests are served from. Recent
+// state tries are cached in memory before they are flushed to disk, so they are
+// read through the trie node cache of the chain if there is one.
+func (pm *ProtocolManager) stateDatabase() trie.DatabaseReader {
+	if triedb := pm.blockchain.StateCache().TrieDB(); triedb != nil {
+		return triedb
+	}
+	return pm.chaindb
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
@@ -719,6 +757,36 @@ func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction)
 	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(peers))
 }
 
+// ForwardPrivateTx sends a private transaction to the given trusted peers only,
+// skipping any that are not connected. It returns the number of peers reached,
+// or an error if none of them could be.
+func (pm *ProtocolManager) ForwardPrivateTx(tx *types.Transaction, ids []discover.NodeID) (int, error) {
+	peers := pm.trustedPeers(ids)
+	if len(peers) == 0 {
+		return 0, errNoPrivateTxPeers
+	}
+	for _, peer := range peers {
+		peer.SendTransactions(types.Transactions{tx})
+	}
+	log.Trace("Forwarded private transaction", "hash", tx.Hash(), "recipients", len(peers))
+	return len(peers), nil
+}
+
+// trustedPeers retrieves the connected peers among the given node ids.
+func (pm *ProtocolManager) trustedPeers(ids []discover.NodeID) []*peer {
+	var peers []*peer
+	for _, id := range ids {
+		// Peers are tracked by a prefix of their ids only, match the full one
+		peer := pm.peers.Peer(fmt.Sprintf("%x", id[:8]))
+		if peer == nil || peer.ID() != id {
+			log.Debug("Skipping disconnected private transaction peer", "peer", id)
+			continue
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
 // Mined broadcast loop
 func (self *ProtocolManager) minedBroadcastLoop() {
 	// automatically stops if unsubscribe
@@ -735,6 +803,10 @@ func (self *ProtocolManager) txBroadcastLoop() {
 	for {
 		select {
 		case event := <-self.txCh:
+			// Private transactions are never gossiped to the network
+			if event.Private {
+				continue
+			}
 			self.BroadcastTx(event.Tx.Hash(), event.Tx)
 
 		// Err() channel will be closed when unsubscribing.
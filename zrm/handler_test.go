@@ -391,6 +391,61 @@ func testGetNodeData(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that the state of the chain head can be retrieved from a pruning node,
+// which only caches recent tries in memory.
+func TestGetNodeDataPruned63(t *testing.T) { testGetNodeDataPruned(t, 63) }
+
+func testGetNodeDataPruned(t *testing.T, protocol int) {
+	testCacheConfig = &core.CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute}
+	defer func() { testCacheConfig = nil }()
+
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	peer, _ := newTestPeer("peer", protocol, pm, true)
+	defer peer.close()
+
+	// Generate the chain in a separate database, so its state only reaches the
+	// node through block import
+	var (
+		gendb, _ = zrmdb.NewMemDatabase()
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000)}},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.HomesteadSigner{}
+	)
+	chain, _ := core.GenerateChain(gspec.Config, genesis, gendb, 4, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{byte(i)}, big.NewInt(1000), bigTxGas, nil, nil), signer, testBankKey)
+		block.AddTx(tx)
+	})
+	if _, err := pm.blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	root := pm.blockchain.CurrentBlock().Root()
+	if ok, _ := pm.chaindb.Has(root.Bytes()); ok {
+		t.Fatalf("head state flushed to disk")
+	}
+	// Request the root of the head state and verify the response
+	p2p.Send(peer.app, GetNodeDataMsg, []common.Hash{root})
+	msg, err := peer.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read node data response: %v", err)
+	}
+	if msg.Code != NodeDataMsg {
+		t.Fatalf("response packet code mismatch: have %x, want %x", msg.Code, NodeDataMsg)
+	}
+	var data [][]byte
+	if err := msg.Decode(&data); err != nil {
+		t.Fatalf("failed to decode response node data: %v", err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("node data count mismatch: have %d, want 1", len(data))
+	}
+	if hash := crypto.Keccak256Hash(data[0]); hash != root {
+		t.Errorf("data hash mismatch: have %x, want %x", hash, root)
+	}
+}
+
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetReceipt63(t *testing.T) { testGetReceipt(t, 63) }
 
@@ -474,7 +529,7 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 		config        = &params.ChainConfig{DAOForkBlock: big.NewInt(1), DAOForkSupport: localForked}
 		gspec         = &core.Genesis{Config: config}
 		genesis       = gspec.MustCommit(db)
-		blockchain, _ = core.NewBlockChain(db, config, pow, vm.Config{})
+		blockchain, _ = core.NewBlockChain(db, nil, config, pow, vm.Config{})
 	)
 	pm, err := NewProtocolManager(config, downloader.FullSync, DefaultConfig.NetworkId, evmux, new(testTxPool), pow, blockchain, db)
 	if err != nil {
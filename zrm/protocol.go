@@ -103,6 +103,10 @@ type txPool interface {
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.Transactions, error)
 
+	// IsPrivate should return whether the transaction with the given hash
+	// must not be announced to the network.
+	IsPrivate(hash common.Hash) bool
+
 	// SubscribeTxPreEvent should return an event subscription of
 	// TxPreEvent and send events to the given channel.
 	SubscribeTxPreEvent(chan<- core.TxPreEvent) event.Subscription
@@ -23,10 +23,12 @@ import (
 	"time"
 
 	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core"
 	"github.com/apolo-technologies/zerium/core/types"
 	"github.com/apolo-technologies/zerium/crypto"
 	"github.com/apolo-technologies/zerium/zrm/downloader"
 	"github.com/apolo-technologies/zerium/p2p"
+	"github.com/apolo-technologies/zerium/p2p/discover"
 	"github.com/apolo-technologies/zerium/rlp"
 )
 
@@ -174,6 +176,153 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that private transactions are not relayed to newly connected peers.
+func TestSendPrivateTransactions62(t *testing.T) { testSendPrivateTransactions(t, 62) }
+func TestSendPrivateTransactions63(t *testing.T) { testSendPrivateTransactions(t, 63) }
+
+func testSendPrivateTransactions(t *testing.T, protocol int) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	// Fill the pool with transactions, marking every other one private
+	pool := pm.txpool.(*testTxPool)
+	pool.private = make(map[common.Hash]bool)
+
+	var public []*types.Transaction
+	for nonce := 0; nonce < 32; nonce++ {
+		tx := newTestTransaction(testAccount, uint64(nonce), 0)
+		if nonce%2 == 0 {
+			pool.private[tx.Hash()] = true
+		} else {
+			public = append(public, tx)
+		}
+		pool.AddRemotes([]*types.Transaction{tx})
+	}
+	// Connect a peer and ensure it only receives the public transactions
+	p, _ := newTestPeer("peer", protocol, pm, true)
+	defer p.close()
+
+	seen := make(map[common.Hash]bool)
+	for len(seen) < len(public) && !t.Failed() {
+		var txs []*types.Transaction
+		msg, err := p.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		} else if msg.Code != TxMsg {
+			t.Fatalf("got code %d, want TxMsg", msg.Code)
+		}
+		if err := msg.Decode(&txs); err != nil {
+			t.Fatalf("failed to decode transactions: %v", err)
+		}
+		for _, tx := range txs {
+			if pool.private[tx.Hash()] {
+				t.Errorf("private transaction relayed: %x", tx.Hash())
+			}
+			seen[tx.Hash()] = true
+		}
+	}
+}
+
+// Tests that private transactions entering the pool are not gossiped to the
+// connected peers, while public ones are.
+func TestBroadcastPrivateTransactions62(t *testing.T) { testBroadcastPrivateTransactions(t, 62) }
+func TestBroadcastPrivateTransactions63(t *testing.T) { testBroadcastPrivateTransactions(t, 63) }
+
+func testBroadcastPrivateTransactions(t *testing.T, protocol int) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	// Connect a peer and wait until it's registered for broadcasts
+	p, _ := newTestPeer("peer", protocol, pm, true)
+	defer p.close()
+
+	for pm.peers.Len() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Announce a batch of private transactions followed by a public one
+	pool := pm.txpool.(*testTxPool)
+	for nonce := 0; nonce < 8; nonce++ {
+		pool.txFeed.Send(core.TxPreEvent{Tx: newTestTransaction(testAccount, uint64(nonce), 0), Private: true})
+	}
+	public := newTestTransaction(testAccount, 8, 0)
+	pool.txFeed.Send(core.TxPreEvent{Tx: public})
+
+	// Ensure the first transactions the peer receives are the public ones
+	var txs []*types.Transaction
+	msg, err := p.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	} else if msg.Code != TxMsg {
+		t.Fatalf("got code %d, want TxMsg", msg.Code)
+	}
+	if err := msg.Decode(&txs); err != nil {
+		t.Fatalf("failed to decode transactions: %v", err)
+	}
+	for _, tx := range txs {
+		if tx.Hash() != public.Hash() {
+			t.Errorf("private transaction broadcast: %x", tx.Hash())
+		}
+	}
+	if len(txs) != 1 {
+		t.Fatalf("broadcast transaction count mismatch: have %d, want %d", len(txs), 1)
+	}
+}
+
+// Tests that private transactions are only forwarded to connected peers whose
+// full node id matches a trusted one, and that forwarding to nobody fails.
+func TestForwardPrivateTransactions62(t *testing.T) { testForwardPrivateTransactions(t, 62) }
+func TestForwardPrivateTransactions63(t *testing.T) { testForwardPrivateTransactions(t, 63) }
+
+func testForwardPrivateTransactions(t *testing.T, protocol int) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	// Connect a peer and wait until it's registered
+	p, _ := newTestPeer("peer", protocol, pm, true)
+	defer p.close()
+
+	for pm.peers.Len() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	tx := newTestTransaction(testAccount, 0, 0)
+
+	// A node id only sharing the peer's id prefix must not be matched
+	impostor := p.peer.ID()
+	impostor[len(impostor)-1]++
+
+	for i, ids := range [][]discover.NodeID{nil, {impostor}} {
+		if n, err := pm.ForwardPrivateTx(tx, ids); err != errNoPrivateTxPeers {
+			t.Errorf("test %d: forwarding result mismatch: have %d/%v, want %v", i, n, err, errNoPrivateTxPeers)
+		}
+	}
+	// Forward to the peer itself and ensure it's the only one reached, the message
+	// pipe blocking until the transaction is read
+	errc := make(chan error, 1)
+	go func() {
+		n, err := pm.ForwardPrivateTx(tx, []discover.NodeID{impostor, p.peer.ID()})
+		if err == nil && n != 1 {
+			err = fmt.Errorf("forwarded to %d peers, want %d", n, 1)
+		}
+		errc <- err
+	}()
+	var txs []*types.Transaction
+	msg, err := p.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	} else if msg.Code != TxMsg {
+		t.Fatalf("got code %d, want TxMsg", msg.Code)
+	}
+	if err := msg.Decode(&txs); err != nil {
+		t.Fatalf("failed to decode transactions: %v", err)
+	}
+	if len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+		t.Fatalf("forwarded transactions mismatch: have %v, want %x", txs, tx.Hash())
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("failed to forward transaction: %v", err)
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
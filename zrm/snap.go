@@ -0,0 +1,430 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrm
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/apolo-technologies/zerium/p2p"
+	"github.com/apolo-technologies/zerium/rlp"
+	"github.com/apolo-technologies/zerium/trie"
+)
+
+// Constants to match up snap protocol versions and messages
+const (
+	snap1 = 1
+)
+
+// Official short name of the snap protocol used during capability negotiation.
+var SnapProtocolName = "snap"
+
+// Supported versions of the snap protocol (first is primary).
+var SnapProtocolVersions = []uint{snap1}
+
+// Number of implemented message corresponding to different snap protocol versions.
+var SnapProtocolLengths = []uint64{6}
+
+// snap protocol message codes
+const (
+	GetAccountRangeMsg  = 0x00
+	AccountRangeMsg     = 0x01
+	GetStorageRangesMsg = 0x02
+	StorageRangesMsg    = 0x03
+	GetByteCodesMsg     = 0x04
+	ByteCodesMsg        = 0x05
+)
+
+// maxCodeFetch is the maximum number of contract bytecodes to serve per request.
+const maxCodeFetch = 1024
+
+// maxStorageFetch is the maximum number of storage ranges to serve per request.
+const maxStorageFetch = 1024
+
+// getAccountRangeData represents an account range query.
+type getAccountRangeData struct {
+	ID     uint64      // Request id to match up the response with
+	Root   common.Hash // State root to retrieve the accounts of
+	Origin common.Hash // First account hash of the range
+	Limit  common.Hash // Last account hash of the range
+	Bytes  uint64      // Soft limit of the response size
+}
+
+// accountData is a single account of an account range, keyed by its hash.
+type accountData struct {
+	Hash common.Hash  // Hash of the account address
+	Body rlp.RawValue // Account body as stored in the state trie
+}
+
+// accountRangeData is the network packet for account range responses.
+type accountRangeData struct {
+	ID       uint64
+	Accounts []*accountData
+	Proof    [][]byte // Merkle proof nodes of the range boundaries
+}
+
+// getStorageRangesData represents a storage range query of a set of accounts.
+type getStorageRangesData struct {
+	ID       uint64
+	Root     common.Hash   // State root the accounts belong to
+	Accounts []common.Hash // Hashes of the accounts to retrieve the storage of
+	Origin   common.Hash   // First slot hash of the first account's range
+	Limit    common.Hash   // Last slot hash of the last account's range
+	Bytes    uint64
+}
+
+// storageData is a single storage slot of a storage range, keyed by its hash.
+type storageData struct {
+	Hash common.Hash // Hash of the storage slot key
+	Body []byte      // Slot value as stored in the storage trie
+}
+
+// storageRangesData is the network packet for storage range responses.
+type storageRangesData struct {
+	ID    uint64
+	Slots [][]*storageData
+	Proof [][]byte // Merkle proof nodes of the last range's boundaries
+}
+
+// getByteCodesData represents a contract bytecode query.
+type getByteCodesData struct {
+	ID     uint64
+	Hashes []common.Hash
+	Bytes  uint64
+}
+
+// byteCodesData is the network packet for contract bytecode responses.
+type byteCodesData struct {
+	ID    uint64
+	Codes [][]byte
+}
+
+// snapPeer is a remote peer speaking the snap protocol.
+type snapPeer struct {
+	*p2p.Peer
+
+	id string
+	rw p2p.MsgReadWriter
+}
+
+func newSnapPeer(p *p2p.Peer, rw p2p.MsgReadWriter) *snapPeer {
+	id := p.ID()
+
+	return &snapPeer{
+		Peer: p,
+		rw:   rw,
+		id:   fmt.Sprintf("%x", id[:8]),
+	}
+}
+
+// RequestAccountRange fetches a range of accounts of a state root.
+func (p *snapPeer) RequestAccountRange(id uint64, root common.Hash, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching account range", "root", root, "origin", origin, "limit", limit)
+	return p2p.Send(p.rw, GetAccountRangeMsg, &getAccountRangeData{ID: id, Root: root, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+// RequestStorageRanges fetches the storage ranges of a batch of accounts.
+func (p *snapPeer) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching storage ranges", "root", root, "accounts", len(accounts), "origin", origin)
+	return p2p.Send(p.rw, GetStorageRangesMsg, &getStorageRangesData{ID: id, Root: root, Accounts: accounts, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+// RequestByteCodes fetches a batch of contract bytecodes by hash.
+func (p *snapPeer) RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching batch of bytecodes", "count", len(hashes))
+	return p2p.Send(p.rw, GetByteCodesMsg, &getByteCodesData{ID: id, Hashes: hashes, Bytes: bytes})
+}
+
+// handleSnap is the callback invoked to manage the life cycle of a snap peer.
+// When this function terminates, the peer is disconnected.
+func (pm *ProtocolManager) handleSnap(p *snapPeer) error {
+	p.Log().Debug("Snap peer connected", "name", p.Name())
+
+	if err := pm.downloader.RegisterSnapPeer(p.id, p); err != nil {
+		return err
+	}
+	defer pm.downloader.UnregisterSnapPeer(p.id)
+
+	for {
+		if err := pm.handleSnapMsg(p); err != nil {
+			p.Log().Debug("Snap message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleSnapMsg is invoked whenever an inbound snap message is received from a
+// remote peer. The remote connection is torn down upon returning any error.
+func (pm *ProtocolManager) handleSnapMsg(p *snapPeer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case GetAccountRangeMsg:
+		var query getAccountRangeData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		accounts, proof := pm.serveAccountRange(&query)
+		return p2p.Send(p.rw, AccountRangeMsg, &accountRangeData{ID: query.ID, Accounts: accounts, Proof: proof})
+
+	case AccountRangeMsg:
+		var res accountRangeData
+		if err := msg.Decode(&res); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		hashes := make([]common.Hash, len(res.Accounts))
+		accounts := make([][]byte, len(res.Accounts))
+		for i, account := range res.Accounts {
+			hashes[i], accounts[i] = account.Hash, account.Body
+		}
+		if err := pm.downloader.DeliverAccountRange(p.id, res.ID, hashes, accounts, res.Proof); err != nil {
+			log.Debug("Failed to deliver account range", "err", err)
+		}
+
+	case GetStorageRangesMsg:
+		var query getStorageRangesData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		slots, proof := pm.serveStorageRanges(&query)
+		return p2p.Send(p.rw, StorageRangesMsg, &storageRangesData{ID: query.ID, Slots: slots, Proof: proof})
+
+	case StorageRangesMsg:
+		var res storageRangesData
+		if err := msg.Decode(&res); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		hashes := make([][]common.Hash, len(res.Slots))
+		slots := make([][][]byte, len(res.Slots))
+		for i, set := range res.Slots {
+			hashes[i] = make([]common.Hash, len(set))
+			slots[i] = make([][]byte, len(set))
+			for j, slot := range set {
+				hashes[i][j], slots[i][j] = slot.Hash, slot.Body
+			}
+		}
+		if err := pm.downloader.DeliverStorageRanges(p.id, res.ID, hashes, slots, res.Proof); err != nil {
+			log.Debug("Failed to deliver storage ranges", "err", err)
+		}
+
+	case GetByteCodesMsg:
+		var query getByteCodesData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return p2p.Send(p.rw, ByteCodesMsg, &byteCodesData{ID: query.ID, Codes: pm.serveByteCodes(&query)})
+
+	case ByteCodesMsg:
+		var res byteCodesData
+		if err := msg.Decode(&res); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if err := pm.downloader.DeliverByteCodes(p.id, res.ID, res.Codes); err != nil {
+			log.Debug("Failed to deliver bytecodes", "err", err)
+		}
+
+	default:
+		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+	return nil
+}
+
+// responseLimit caps the requested response size to the soft response limit.
+func responseLimit(bytes uint64) uint64 {
+	if bytes > softResponseLimit {
+		return softResponseLimit
+	}
+	return bytes
+}
+
+// proofSet collects the nodes of Merkle proofs, deduplicating the shared ones.
+type proofSet map[common.Hash][]byte
+
+// Put implements trie.DatabaseWriter.
+func (set proofSet) Put(key []byte, value []byte) error {
+	set[common.BytesToHash(key)] = common.CopyBytes(value)
+	return nil
+}
+
+// nodes returns the collected proof nodes.
+func (set proofSet) nodes() [][]byte {
+	nodes := make([][]byte, 0, len(set))
+	for _, node := range set {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// proveRange creates the boundary proof of a range of trie leaves starting at
+// origin and ending with last (nil for empty ranges).
+func proveRange(t *trie.Trie, origin common.Hash, last []byte) ([][]byte, error) {
+	proof := make(proofSet)
+	if err := t.Prove(origin[:], 0, proof); err != nil {
+		return nil, err
+	}
+	if last != nil {
+		if err := t.Prove(last, 0, proof); err != nil {
+			return nil, err
+		}
+	}
+	return proof.nodes(), nil
+}
+
+// serveAccountRange gathers the accounts of a state root from the requested
+// origin until the limit or the size cap is reached, along with the proof of the
+// range boundaries. If the state is unavailable, an empty response is returned.
+func (pm *ProtocolManager) serveAccountRange(query *getAccountRangeData) ([]*accountData, [][]byte) {
+	tr, err := trie.New(query.Root, pm.blockchain.StateCache().TrieDB())
+	if err != nil {
+		return nil, nil
+	}
+	var (
+		accounts []*accountData
+		size     uint64
+		limit    = responseLimit(query.Bytes)
+	)
+	it := trie.NewIterator(tr.NodeIterator(query.Origin[:]))
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		accounts = append(accounts, &accountData{Hash: hash, Body: common.CopyBytes(it.Value)})
+
+		size += uint64(common.HashLength + len(it.Value))
+		if bytes.Compare(hash[:], query.Limit[:]) >= 0 || size >= limit {
+			break
+		}
+	}
+	if it.Err != nil {
+		return nil, nil
+	}
+	var last []byte
+	if len(accounts) > 0 {
+		last = accounts[len(accounts)-1].Hash[:]
+	}
+	proof, err := proveRange(tr, query.Origin, last)
+	if err != nil {
+		return nil, nil
+	}
+	return accounts, proof
+}
+
+// serveStorageRanges gathers the storage slots of the requested accounts until
+// the size cap or the range count cap is reached. Only the last range may be a chunk of a storage trie,
+// in which case it is accompanied by the proof of its boundaries.
+func (pm *ProtocolManager) serveStorageRanges(query *getStorageRangesData) ([][]*storageData, [][]byte) {
+	triedb := pm.blockchain.StateCache().TrieDB()
+
+	accTrie, err := trie.New(query.Root, triedb)
+	if err != nil {
+		return nil, nil
+	}
+	var (
+		slots [][]*storageData
+		proof [][]byte
+		size  uint64
+		limit = responseLimit(query.Bytes)
+	)
+	for i, hash := range query.Accounts {
+		if size >= limit || len(slots) >= maxStorageFetch {
+			break
+		}
+		blob, err := accTrie.TryGet(hash[:])
+		if err != nil || blob == nil {
+			break
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			break
+		}
+		stTrie, err := trie.New(account.Root, triedb)
+		if err != nil {
+			break
+		}
+		// The origin applies to the first account and the limit to the last one
+		var origin common.Hash
+		if i == 0 {
+			origin = query.Origin
+		}
+		last := common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+		if i == len(query.Accounts)-1 {
+			last = query.Limit
+		}
+		var (
+			set     []*storageData
+			partial bool
+		)
+		it := trie.NewIterator(stTrie.NodeIterator(origin[:]))
+		for it.Next() {
+			slot := common.BytesToHash(it.Key)
+			set = append(set, &storageData{Hash: slot, Body: common.CopyBytes(it.Value)})
+
+			size += uint64(common.HashLength + len(it.Value))
+			if bytes.Compare(slot[:], last[:]) >= 0 || size >= limit {
+				partial = true
+				break
+			}
+		}
+		if it.Err != nil {
+			break
+		}
+		// Every range counts towards the size cap, empty storage tries included
+		slots = append(slots, set)
+		size += common.HashLength
+
+		// Chunks of a storage trie need a proof and terminate the response
+		if origin != (common.Hash{}) || partial {
+			var last []byte
+			if len(set) > 0 {
+				last = set[len(set)-1].Hash[:]
+			}
+			if proof, err = proveRange(stTrie, origin, last); err != nil {
+				return nil, nil
+			}
+			break
+		}
+	}
+	return slots, proof
+}
+
+// serveByteCodes gathers the requested contract bytecodes until the size cap is
+// reached, skipping the unknown ones.
+func (pm *ProtocolManager) serveByteCodes(query *getByteCodesData) [][]byte {
+	var (
+		codes [][]byte
+		size  uint64
+		limit = responseLimit(query.Bytes)
+	)
+	for _, hash := range query.Hashes {
+		if size >= limit || len(codes) >= maxCodeFetch {
+			break
+		}
+		if code, err := pm.chaindb.Get(hash[:]); err == nil {
+			codes = append(codes, code)
+			size += uint64(len(code))
+		}
+	}
+	return codes
+}
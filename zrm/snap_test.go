@@ -0,0 +1,344 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrm
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/consensus/abthash"
+	"github.com/apolo-technologies/zerium/core"
+	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/core/vm"
+	"github.com/apolo-technologies/zerium/crypto"
+	"github.com/apolo-technologies/zerium/event"
+	"github.com/apolo-technologies/zerium/p2p"
+	"github.com/apolo-technologies/zerium/p2p/discover"
+	"github.com/apolo-technologies/zerium/params"
+	"github.com/apolo-technologies/zerium/rlp"
+	"github.com/apolo-technologies/zerium/trie"
+	"github.com/apolo-technologies/zerium/zrm/downloader"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// snapTestAlloc creates a genesis state of many accounts, every tenth having
+// contract code and a few storage slots, and a single one with a large storage.
+func snapTestAlloc() core.GenesisAlloc {
+	alloc := make(core.GenesisAlloc)
+	for i := 1; i <= 400; i++ {
+		account := core.GenesisAccount{Balance: big.NewInt(int64(i))}
+		if i%10 == 0 {
+			account.Code = []byte{0x60, byte(i), 0x00}
+			account.Storage = make(map[common.Hash]common.Hash)
+			for j := 1; j <= i/10; j++ {
+				account.Storage[common.BigToHash(big.NewInt(int64(j)))] = common.BigToHash(big.NewInt(int64(i * j)))
+			}
+		}
+		alloc[common.BigToAddress(big.NewInt(int64(i)))] = account
+	}
+	large := core.GenesisAccount{Balance: big.NewInt(1), Storage: make(map[common.Hash]common.Hash)}
+	for j := 1; j <= 1000; j++ {
+		large.Storage[common.BigToHash(big.NewInt(int64(j)))] = common.BigToHash(big.NewInt(int64(j)))
+	}
+	alloc[common.BigToAddress(big.NewInt(1000))] = large
+
+	return alloc
+}
+
+// newSnapTestManager creates an archive protocol manager with the given genesis
+// state and number of blocks on top.
+func newSnapTestManager(t *testing.T, mode downloader.SyncMode, alloc core.GenesisAlloc, blocks int) *ProtocolManager {
+	var (
+		engine = abthash.NewFaker()
+		db, _  = zrmdb.NewMemDatabase()
+		gspec  = &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	)
+	genesis := gspec.MustCommit(db)
+	blockchain, _ := core.NewBlockChain(db, &core.CacheConfig{Disabled: true}, gspec.Config, engine, vm.Config{})
+
+	chain, _ := core.GenerateChain(gspec.Config, genesis, db, blocks, nil)
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	pm, err := NewProtocolManager(gspec.Config, mode, DefaultConfig.NetworkId, new(event.TypeMux), &testTxPool{}, engine, blockchain, db)
+	if err != nil {
+		t.Fatalf("failed to create protocol manager: %v", err)
+	}
+	pm.Start(1000)
+	return pm
+}
+
+// snapTestPeer is a snap connection to a protocol manager, sending queries and
+// reading the responses directly.
+type snapTestPeer struct {
+	net p2p.MsgReadWriter
+	app *p2p.MsgPipeRW
+}
+
+func newSnapTestPeer(pm *ProtocolManager) *snapTestPeer {
+	app, net := p2p.MsgPipe()
+	go pm.handleSnap(newSnapPeer(p2p.NewPeer(discover.NodeID{}, "test", nil), app))
+
+	return &snapTestPeer{net: net, app: app}
+}
+
+// query sends a request to the remote peer and decodes its response.
+func (p *snapTestPeer) query(t *testing.T, code uint64, req interface{}, res interface{}) {
+	if err := p2p.Send(p.net, code, req); err != nil {
+		t.Fatalf("failed to send query: %v", err)
+	}
+	msg, err := p.net.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	defer msg.Discard()
+
+	if msg.Code != code+1 {
+		t.Fatalf("response code mismatch: have %d, want %d", msg.Code, code+1)
+	}
+	if err := msg.Decode(res); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+}
+
+// verifyRange checks the boundary proof of a served range of trie leaves.
+func verifyRange(root common.Hash, origin common.Hash, keys []common.Hash, values [][]byte, proof [][]byte) (bool, error) {
+	var proofDb trie.DatabaseReader
+	if len(proof) > 0 {
+		db, _ := zrmdb.NewMemDatabase()
+		for _, node := range proof {
+			db.Put(crypto.Keccak256(node), node)
+		}
+		proofDb = db
+	}
+	leaves := make([][]byte, len(keys))
+	for i := range keys {
+		leaves[i] = keys[i][:]
+	}
+	var last []byte
+	if len(leaves) > 0 {
+		last = leaves[len(leaves)-1]
+	}
+	return trie.VerifyRangeProof(root, origin[:], last, leaves, values, proofDb)
+}
+
+// Tests that account and storage ranges are served along with valid boundary
+// proofs, and that bytecodes are served by hash.
+func TestSnapServeRanges(t *testing.T) {
+	alloc := snapTestAlloc()
+	pm := newSnapTestManager(t, downloader.FullSync, alloc, 0)
+	defer pm.Stop()
+
+	peer := newSnapTestPeer(pm)
+	defer peer.app.Close()
+
+	root := pm.blockchain.CurrentBlock().Root()
+	limit := common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+	// Page through the entire account trie, verifying each range
+	var (
+		origin   common.Hash
+		accounts int
+		large    state.Account
+		largeKey = crypto.Keccak256Hash(common.BigToAddress(big.NewInt(1000)).Bytes())
+	)
+	for pages := 0; ; pages++ {
+		var res accountRangeData
+		peer.query(t, GetAccountRangeMsg, &getAccountRangeData{ID: uint64(pages), Root: root, Origin: origin, Limit: limit, Bytes: 2048}, &res)
+		if res.ID != uint64(pages) {
+			t.Fatalf("page %d: request id mismatch: have %d", pages, res.ID)
+		}
+		keys := make([]common.Hash, len(res.Accounts))
+		values := make([][]byte, len(res.Accounts))
+		for i, account := range res.Accounts {
+			keys[i], values[i] = account.Hash, account.Body
+			if account.Hash == largeKey {
+				rlp.DecodeBytes(account.Body, &large)
+			}
+		}
+		more, err := verifyRange(root, origin, keys, values, res.Proof)
+		if err != nil {
+			t.Fatalf("page %d: invalid account range: %v", pages, err)
+		}
+		accounts += len(keys)
+		if !more {
+			if pages == 0 {
+				t.Fatalf("account trie served in a single page")
+			}
+			break
+		}
+		origin = common.BigToHash(new(big.Int).Add(keys[len(keys)-1].Big(), common.Big1))
+	}
+	if accounts != len(alloc) {
+		t.Fatalf("account count mismatch: have %d, want %d", accounts, len(alloc))
+	}
+	// Retrieve the large storage chunk by chunk
+	origin = common.Hash{}
+	slots := 0
+	for chunks := 0; ; chunks++ {
+		var res storageRangesData
+		peer.query(t, GetStorageRangesMsg, &getStorageRangesData{Root: root, Accounts: []common.Hash{largeKey}, Origin: origin, Limit: limit, Bytes: 4096}, &res)
+		if len(res.Slots) != 1 || len(res.Proof) == 0 {
+			t.Fatalf("chunk %d: invalid storage chunk: %d ranges, %d proof nodes", chunks, len(res.Slots), len(res.Proof))
+		}
+		keys := make([]common.Hash, len(res.Slots[0]))
+		values := make([][]byte, len(res.Slots[0]))
+		for i, slot := range res.Slots[0] {
+			keys[i], values[i] = slot.Hash, slot.Body
+		}
+		more, err := verifyRange(large.Root, origin, keys, values, res.Proof)
+		if err != nil {
+			t.Fatalf("chunk %d: invalid storage range: %v", chunks, err)
+		}
+		slots += len(keys)
+		if !more {
+			break
+		}
+		origin = common.BigToHash(new(big.Int).Add(keys[len(keys)-1].Big(), common.Big1))
+	}
+	if slots != 1000 {
+		t.Fatalf("slot count mismatch: have %d, want %d", slots, 1000)
+	}
+	// Ensure storage ranges stop at the size and the range count caps, even if the
+	// storage tries are empty
+	var (
+		plain  = crypto.Keccak256Hash(common.BigToAddress(big.NewInt(1)).Bytes())
+		hashes = make([]common.Hash, 2*maxStorageFetch)
+	)
+	for i := range hashes {
+		hashes[i] = plain
+	}
+	var capped storageRangesData
+	peer.query(t, GetStorageRangesMsg, &getStorageRangesData{Root: root, Accounts: hashes, Limit: limit, Bytes: softResponseLimit}, &capped)
+	if len(capped.Slots) != maxStorageFetch {
+		t.Fatalf("storage range count mismatch: have %d, want %d", len(capped.Slots), maxStorageFetch)
+	}
+	peer.query(t, GetStorageRangesMsg, &getStorageRangesData{Root: root, Accounts: hashes, Limit: limit, Bytes: 64 * common.HashLength}, &capped)
+	if len(capped.Slots) != 64 {
+		t.Fatalf("storage range count mismatch: have %d, want %d", len(capped.Slots), 64)
+	}
+	// Retrieve a known and an unknown bytecode
+	code := alloc[common.BigToAddress(big.NewInt(10))].Code
+
+	var res byteCodesData
+	peer.query(t, GetByteCodesMsg, &getByteCodesData{ID: 1, Hashes: []common.Hash{crypto.Keccak256Hash(code), {0x01}}, Bytes: 4096}, &res)
+	if len(res.Codes) != 1 || !bytes.Equal(res.Codes[0], code) {
+		t.Fatalf("bytecode mismatch: have %x, want [%x]", res.Codes, code)
+	}
+	// Ensure unavailable states are answered with empty responses
+	var empty accountRangeData
+	peer.query(t, GetAccountRangeMsg, &getAccountRangeData{Root: common.Hash{0x01}, Limit: limit, Bytes: 4096}, &empty)
+	if len(empty.Accounts) != 0 || len(empty.Proof) != 0 {
+		t.Fatalf("unknown state served: %d accounts, %d proof nodes", len(empty.Accounts), len(empty.Proof))
+	}
+}
+
+// msgCounter is a message pipe end counting the inbound messages.
+type msgCounter struct {
+	p2p.MsgReadWriter
+
+	codes map[uint64]int
+	lock  sync.Mutex
+}
+
+func (c *msgCounter) ReadMsg() (p2p.Msg, error) {
+	msg, err := c.MsgReadWriter.ReadMsg()
+	if err == nil {
+		c.lock.Lock()
+		c.codes[msg.Code]++
+		c.lock.Unlock()
+	}
+	return msg, err
+}
+
+func (c *msgCounter) count(code uint64) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.codes[code]
+}
+
+// Tests that a snap sync retrieves the pivot state from flat ranges, ending up
+// with the same state as the remote peer.
+func TestSnapSync(t *testing.T) {
+	defer func(limit int) { downloader.SnapResponseLimit = limit }(downloader.SnapResponseLimit)
+	downloader.SnapResponseLimit = 4096
+
+	alloc := snapTestAlloc()
+
+	pmEmpty := newSnapTestManager(t, downloader.SnapSync, alloc, 0)
+	defer pmEmpty.Stop()
+	pmFull := newSnapTestManager(t, downloader.FullSync, alloc, 1024)
+	defer pmFull.Stop()
+
+	// Drop the genesis state of the syncing node to have it retrieved too
+	statedb, _ := pmEmpty.blockchain.State()
+
+	var entries []common.Hash
+	for it := state.NewNodeIterator(statedb); it.Next(); {
+		if it.Hash != (common.Hash{}) {
+			entries = append(entries, it.Hash)
+		}
+	}
+	for _, hash := range entries {
+		pmEmpty.chaindb.Delete(hash[:])
+	}
+
+	// Connect the two peers over both the zrm and snap protocols
+	io1, io2 := p2p.MsgPipe()
+	snap1, snap2 := p2p.MsgPipe()
+	served := &msgCounter{MsgReadWriter: snap2, codes: make(map[uint64]int)}
+
+	go pmFull.handle(pmFull.newPeer(63, p2p.NewPeer(discover.NodeID{}, "empty", nil), io2))
+	go pmEmpty.handle(pmEmpty.newPeer(63, p2p.NewPeer(discover.NodeID{}, "full", nil), io1))
+	go pmFull.handleSnap(newSnapPeer(p2p.NewPeer(discover.NodeID{}, "empty", nil), served))
+	go pmEmpty.handleSnap(newSnapPeer(p2p.NewPeer(discover.NodeID{}, "full", nil), snap1))
+
+	time.Sleep(250 * time.Millisecond)
+	pmEmpty.synchronise(pmEmpty.peers.BestPeer())
+
+	if head := pmEmpty.blockchain.CurrentBlock().NumberU64(); head != 1024 {
+		t.Fatalf("head mismatch: have %d, want %d", head, 1024)
+	}
+	for _, code := range []uint64{GetAccountRangeMsg, GetStorageRangesMsg, GetByteCodesMsg} {
+		if served.count(code) == 0 {
+			t.Errorf("no snap requests of type %d served", code)
+		}
+	}
+	// Ensure the synced state contains every account of the remote one
+	synced, err := pmEmpty.blockchain.State()
+	if err != nil {
+		t.Fatalf("failed to open synced state: %v", err)
+	}
+	for addr, account := range alloc {
+		if balance := synced.GetBalance(addr); balance.Cmp(account.Balance) != 0 {
+			t.Errorf("account %x: balance mismatch: have %v, want %v", addr, balance, account.Balance)
+		}
+		if code := synced.GetCode(addr); !bytes.Equal(code, account.Code) {
+			t.Errorf("account %x: code mismatch: have %x, want %x", addr, code, account.Code)
+		}
+		for key, value := range account.Storage {
+			if have := synced.GetState(addr, key); have != value {
+				t.Errorf("account %x: slot %x mismatch: have %x, want %x", addr, key, have, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrmclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/crypto"
+	"github.com/apolo-technologies/zerium/rlp"
+	"github.com/apolo-technologies/zerium/trie"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// AccountResult is the Merkle proof of an account and some of its storage
+// slots, as returned by GetProof.
+type AccountResult struct {
+	Address      common.Address
+	AccountProof [][]byte // State trie nodes from the root down to the account
+	Balance      *big.Int
+	CodeHash     common.Hash
+	Nonce        uint64
+	StorageHash  common.Hash // Root of the account's storage trie
+	StorageProof []StorageResult
+}
+
+// StorageResult is the Merkle proof of a single storage slot of an account.
+type StorageResult struct {
+	Key   common.Hash
+	Value *big.Int
+	Proof [][]byte // Storage trie nodes from the root down to the slot
+}
+
+// Verify checks that the account proof is valid against the given state root
+// and that all the reported fields and storage values are the proven ones.
+func (r *AccountResult) Verify(root common.Hash) error {
+	value, err, _ := trie.VerifyProof(root, crypto.Keccak256(r.Address[:]), newProofDb(r.AccountProof))
+	if err != nil {
+		return fmt.Errorf("invalid account proof: %v", err)
+	}
+	if value == nil {
+		// The account doesn't exist, it must be reported empty
+		if r.Nonce != 0 || r.Balance.Sign() != 0 || r.CodeHash != (common.Hash{}) || r.StorageHash != types.EmptyRootHash {
+			return errors.New("non-empty account proven absent")
+		}
+	} else {
+		var account state.Account
+		if err := rlp.DecodeBytes(value, &account); err != nil {
+			return fmt.Errorf("invalid account encoding: %v", err)
+		}
+		switch {
+		case account.Nonce != r.Nonce:
+			return fmt.Errorf("nonce mismatch: have %d, proven %d", r.Nonce, account.Nonce)
+		case account.Balance.Cmp(r.Balance) != 0:
+			return fmt.Errorf("balance mismatch: have %v, proven %v", r.Balance, account.Balance)
+		case !bytes.Equal(account.CodeHash, r.CodeHash[:]):
+			return fmt.Errorf("code hash mismatch: have %x, proven %x", r.CodeHash, account.CodeHash)
+		case account.Root != r.StorageHash:
+			return fmt.Errorf("storage hash mismatch: have %x, proven %x", r.StorageHash, account.Root)
+		}
+	}
+	for _, slot := range r.StorageProof {
+		if err := slot.Verify(r.StorageHash); err != nil {
+			return fmt.Errorf("storage key %x: %v", slot.Key, err)
+		}
+	}
+	return nil
+}
+
+// Verify checks that the storage proof is valid against the given storage root
+// and that the reported value is the proven one.
+func (r *StorageResult) Verify(root common.Hash) error {
+	proven := new(big.Int)
+	if root != types.EmptyRootHash {
+		value, err, _ := trie.VerifyProof(root, crypto.Keccak256(r.Key[:]), newProofDb(r.Proof))
+		if err != nil {
+			return fmt.Errorf("invalid storage proof: %v", err)
+		}
+		if value != nil {
+			var content []byte
+			if err := rlp.DecodeBytes(value, &content); err != nil {
+				return fmt.Errorf("invalid storage encoding: %v", err)
+			}
+			proven.SetBytes(content)
+		}
+	}
+	if proven.Cmp(r.Value) != 0 {
+		return fmt.Errorf("value mismatch: have %v, proven %v", r.Value, proven)
+	}
+	return nil
+}
+
+// newProofDb creates an in-memory database holding the given proof nodes keyed
+// by their hashes, as needed for proof verification.
+func newProofDb(proof [][]byte) *zrmdb.MemDatabase {
+	db, _ := zrmdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrmclient
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/core/state"
+	"github.com/apolo-technologies/zerium/core/types"
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// makeProof assembles an account proof the same way the zrm_getProof endpoint
+// does, from the given state.
+func makeProof(t *testing.T, statedb *state.StateDB, addr common.Address, keys []common.Hash) *AccountResult {
+	accountProof, err := statedb.GetProof(addr)
+	if err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+	storageHash := types.EmptyRootHash
+	if storageTrie := statedb.StorageTrie(addr); storageTrie != nil {
+		storageHash = storageTrie.Hash()
+	}
+	result := &AccountResult{
+		Address:      addr,
+		AccountProof: accountProof,
+		Balance:      statedb.GetBalance(addr),
+		CodeHash:     statedb.GetCodeHash(addr),
+		Nonce:        statedb.GetNonce(addr),
+		StorageHash:  storageHash,
+	}
+	for _, key := range keys {
+		proof, err := statedb.GetStorageProof(addr, key)
+		if err != nil {
+			t.Fatalf("failed to prove storage key %x: %v", key, err)
+		}
+		result.StorageProof = append(result.StorageProof, StorageResult{key, statedb.GetState(addr, key).Big(), proof})
+	}
+	return result
+}
+
+// Tests that account and storage proofs verify against the state root, and
+// that tampering with any of the reported values is detected.
+func TestProofVerify(t *testing.T) {
+	db, _ := zrmdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	contract, other, missing := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+	statedb.SetBalance(contract, big.NewInt(100))
+	statedb.SetNonce(contract, 3)
+	statedb.SetCode(contract, []byte{0x60, 0x00})
+	statedb.SetState(contract, common.Hash{0x0a}, common.Hash{0x0b})
+	statedb.SetState(contract, common.Hash{0x0c}, common.BigToHash(big.NewInt(1)))
+	statedb.SetBalance(other, big.NewInt(7))
+
+	root, err := statedb.CommitTo(db, false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	statedb, _ = state.New(root, state.NewDatabase(db))
+
+	keys := []common.Hash{{0x0a}, {0x0c}, {0x0d}}
+	for _, addr := range []common.Address{contract, other, missing} {
+		if err := makeProof(t, statedb, addr, keys).Verify(root); err != nil {
+			t.Errorf("account %x: valid proof rejected: %v", addr, err)
+		}
+	}
+	// Tamper with the individual fields and ensure verification fails
+	tampers := map[string]func(*AccountResult){
+		"balance":     func(r *AccountResult) { r.Balance = big.NewInt(101) },
+		"nonce":       func(r *AccountResult) { r.Nonce++ },
+		"codehash":    func(r *AccountResult) { r.CodeHash = common.Hash{0xff} },
+		"storagehash": func(r *AccountResult) { r.StorageHash = common.Hash{0xff} },
+		"value":       func(r *AccountResult) { r.StorageProof[0].Value = big.NewInt(1) },
+		"absent":      func(r *AccountResult) { r.StorageProof[2].Value = big.NewInt(1) },
+		"proof":       func(r *AccountResult) { r.AccountProof = r.AccountProof[:len(r.AccountProof)-1] },
+	}
+	for name, tamper := range tampers {
+		result := makeProof(t, statedb, contract, keys)
+		tamper(result)
+		if err := result.Verify(root); err == nil {
+			t.Errorf("%s: tampered proof accepted", name)
+		}
+	}
+	// Ensure a missing account cannot be reported with a balance
+	result := makeProof(t, statedb, missing, nil)
+	result.Balance = big.NewInt(1)
+	if err := result.Verify(root); err == nil {
+		t.Errorf("funded missing account accepted")
+	}
+}
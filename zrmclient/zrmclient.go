@@ -25,6 +25,7 @@ import (
 	"math/big"
 
 	"github.com/apolo-technologies/zerium"
+	"github.com/apolo-technologies/zerium/accounts/abi"
 	"github.com/apolo-technologies/zerium/common"
 	"github.com/apolo-technologies/zerium/common/hexutil"
 	"github.com/apolo-technologies/zerium/core/types"
@@ -346,6 +347,65 @@ func (ec *Client) NonceAt(ctx context.Context, account common.Address, blockNumb
 	return uint64(result), err
 }
 
+// GetProof returns the Merkle proof of the given account and of the given storage
+// keys of it. The block number can be nil, in which case the proof is taken from
+// the latest known block. Use AccountResult.Verify to check the proof against a
+// trusted state root.
+func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []common.Hash, blockNumber *big.Int) (*AccountResult, error) {
+	type storageResult struct {
+		Key   string          `json:"key"`
+		Value *hexutil.Big    `json:"value"`
+		Proof []hexutil.Bytes `json:"proof"`
+	}
+	type accountResult struct {
+		Address      common.Address  `json:"address"`
+		AccountProof []hexutil.Bytes `json:"accountProof"`
+		Balance      *hexutil.Big    `json:"balance"`
+		CodeHash     common.Hash     `json:"codeHash"`
+		Nonce        hexutil.Uint64  `json:"nonce"`
+		StorageHash  common.Hash     `json:"storageHash"`
+		StorageProof []storageResult `json:"storageProof"`
+	}
+	if keys == nil {
+		keys = []common.Hash{}
+	}
+	var res accountResult
+	if err := ec.c.CallContext(ctx, &res, "zrm_getProof", account, keys, toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	if res.Balance == nil {
+		return nil, errors.New("missing balance in proof")
+	}
+	result := &AccountResult{
+		Address:      res.Address,
+		AccountProof: toByteSlices(res.AccountProof),
+		Balance:      res.Balance.ToInt(),
+		CodeHash:     res.CodeHash,
+		Nonce:        uint64(res.Nonce),
+		StorageHash:  res.StorageHash,
+		StorageProof: make([]StorageResult, len(res.StorageProof)),
+	}
+	for i, slot := range res.StorageProof {
+		if slot.Value == nil {
+			return nil, fmt.Errorf("missing value for storage key %s", slot.Key)
+		}
+		result.StorageProof[i] = StorageResult{
+			Key:   common.HexToHash(slot.Key),
+			Value: slot.Value.ToInt(),
+			Proof: toByteSlices(slot.Proof),
+		}
+	}
+	return result, nil
+}
+
+func toByteSlices(b []hexutil.Bytes) [][]byte {
+	r := make([][]byte, len(b))
+	for i := range b {
+		r[i] = b[i]
+	}
+	return r
+}
+
 // Filters
 
 // FilterLogs executes a filter query.
@@ -425,18 +485,57 @@ func (ec *Client) CallContract(ctx context.Context, msg zerium.CallMsg, blockNum
 	var hex hexutil.Bytes
 	err := ec.c.CallContext(ctx, &hex, "zrm_call", toCallArg(msg), toBlockNumArg(blockNumber))
 	if err != nil {
-		return nil, err
+		return nil, toRevertError(err)
 	}
 	return hex, nil
 }
 
+// revertErrorCode is the JSON-RPC error code of reverted executions.
+const revertErrorCode = 3
+
+// RevertError is returned by the contract calling and gas estimation methods if
+// the execution was reverted by the EVM.
+type RevertError struct {
+	Reason string // Decoded Solidity Error(string) reason, empty if unavailable
+	Data   []byte // Raw revert payload returned by the execution
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.Reason
+}
+
+// toRevertError converts the RPC error of a reverted execution into a RevertError,
+// returning any other error unmodified.
+func toRevertError(err error) error {
+	if rpcErr, ok := err.(rpc.Error); !ok || rpcErr.ErrorCode() != revertErrorCode {
+		return err
+	}
+	dataErr, ok := err.(rpc.DataError)
+	if !ok {
+		return err
+	}
+	encoded, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	data, decErr := hexutil.Decode(encoded)
+	if decErr != nil {
+		return err
+	}
+	reason, _ := abi.UnpackRevert(data)
+	return &RevertError{Reason: reason, Data: data}
+}
+
 // PendingCallContract executes a message call transaction using the EVM.
 // The state seen by the contract call is the pending state.
 func (ec *Client) PendingCallContract(ctx context.Context, msg zerium.CallMsg) ([]byte, error) {
 	var hex hexutil.Bytes
 	err := ec.c.CallContext(ctx, &hex, "zrm_call", toCallArg(msg), "pending")
 	if err != nil {
-		return nil, err
+		return nil, toRevertError(err)
 	}
 	return hex, nil
 }
@@ -459,7 +558,7 @@ func (ec *Client) EstimateGas(ctx context.Context, msg zerium.CallMsg) (*big.Int
 	var hex hexutil.Big
 	err := ec.c.CallContext(ctx, &hex, "zrm_estimateGas", toCallArg(msg))
 	if err != nil {
-		return nil, err
+		return nil, toRevertError(err)
 	}
 	return (*big.Int)(&hex), nil
 }
@@ -16,7 +16,16 @@
 
 package zrmclient
 
-import "github.com/apolo-technologies/zerium"
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/apolo-technologies/zerium"
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/common/hexutil"
+	"github.com/apolo-technologies/zerium/rpc"
+)
 
 // Verify that Client implements the zerium interfaces.
 var (
@@ -32,3 +41,57 @@ var (
 	// _ = zerium.PendingStateEventer(&Client{})
 	_ = zerium.PendingContractCaller(&Client{})
 )
+
+// testRevertError mimics the error returned by the API on reverted executions.
+type testRevertError struct {
+	data []byte
+}
+
+func (e testRevertError) Error() string          { return "execution reverted" }
+func (e testRevertError) ErrorCode() int         { return 3 }
+func (e testRevertError) ErrorData() interface{} { return hexutil.Encode(e.data) }
+
+// RevertService is a mock API reverting all calls and gas estimations.
+type RevertService struct {
+	data []byte
+}
+
+func (s *RevertService) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	return nil, testRevertError{s.data}
+}
+
+func (s *RevertService) EstimateGas(args map[string]interface{}) (*hexutil.Big, error) {
+	return nil, testRevertError{s.data}
+}
+
+// Tests that reverted executions are reported as RevertErrors carrying the
+// decoded reason and the raw payload.
+func TestRevertError(t *testing.T) {
+	payload := common.Hex2Bytes("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"6e6f706500000000000000000000000000000000000000000000000000000000")
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("zrm", &RevertService{payload}); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	defer server.Stop()
+
+	rpcClient := rpc.DialInProc(server)
+	defer rpcClient.Close()
+
+	client := NewClient(rpcClient)
+
+	msg := zerium.CallMsg{To: &common.Address{0xdd}}
+	if _, err := client.CallContract(context.Background(), msg, nil); err == nil {
+		t.Errorf("call: no error returned")
+	} else if revert, ok := err.(*RevertError); !ok || revert.Reason != "nope" || !bytes.Equal(revert.Data, payload) {
+		t.Errorf("call: error mismatch: have %v, want revert with reason %q", err, "nope")
+	}
+	if _, err := client.EstimateGas(context.Background(), msg); err == nil {
+		t.Errorf("estimate: no error returned")
+	} else if revert, ok := err.(*RevertError); !ok || revert.Reason != "nope" {
+		t.Errorf("estimate: error mismatch: have %v, want revert with reason %q", err, "nope")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrmdb
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	// LevelDBBackend is the name of the LevelDB key/value backend.
+	LevelDBBackend = "leveldb"
+
+	// BoltDBBackend is the name of the BoltDB key/value backend.
+	BoltDBBackend = "boltdb"
+
+	// DefaultBackend is the backend used if none is explicitly requested.
+	DefaultBackend = LevelDBBackend
+)
+
+// Opener opens a persistent key/value database stored at the given path, with
+// the requested cache (in megabytes) and file handle allowances.
+type Opener func(file string, cache int, handles int) (Database, error)
+
+var (
+	backends    = make(map[string]Opener)
+	backendLock sync.RWMutex
+)
+
+func init() {
+	RegisterBackend(LevelDBBackend, func(file string, cache int, handles int) (Database, error) {
+		return NewLDBDatabase(file, cache, handles)
+	})
+	RegisterBackend(BoltDBBackend, func(file string, cache int, handles int) (Database, error) {
+		return NewBoltDatabase(file, cache, handles)
+	})
+}
+
+// RegisterBackend makes a key/value database backend available by the given
+// name. It panics if a backend with the same name is already registered.
+func RegisterBackend(name string, open Opener) {
+	backendLock.Lock()
+	defer backendLock.Unlock()
+
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("database backend %q already registered", name))
+	}
+	backends[name] = open
+}
+
+// Backends returns the names of all the registered database backends, sorted.
+func Backends() []string {
+	backendLock.RLock()
+	defer backendLock.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens a persistent key/value database with the named backend, or with
+// the default one if no backend is specified.
+func Open(backend string, file string, cache int, handles int) (Database, error) {
+	if backend == "" {
+		backend = DefaultBackend
+	}
+	backendLock.RLock()
+	open, ok := backends[backend]
+	backendLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown database backend %q, available: %v", backend, Backends())
+	}
+	return open(file, cache, handles)
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrmdb_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/zrmdb"
+	"github.com/apolo-technologies/zerium/zrmdb/dbtest"
+)
+
+// tempDatabase wraps a persistent database, deleting its directory on close.
+type tempDatabase struct {
+	zrmdb.Database
+	dir string
+}
+
+func (db *tempDatabase) Close() {
+	db.Database.Close()
+	os.RemoveAll(db.dir)
+}
+
+// newTempBackend returns a constructor opening fresh databases of the named
+// backend within temporary directories.
+func newTempBackend(t testing.TB, backend string) func() zrmdb.Database {
+	return func() zrmdb.Database {
+		dir, err := ioutil.TempDir("", "zrmdb_"+backend+"_")
+		if err != nil {
+			t.Fatalf("failed to create temporary directory: %v", err)
+		}
+		db, err := zrmdb.Open(backend, dir, 0, 0)
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("failed to open %s database: %v", backend, err)
+		}
+		return &tempDatabase{Database: db, dir: dir}
+	}
+}
+
+func TestLDBSuite(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, newTempBackend(t, zrmdb.LevelDBBackend))
+}
+
+func TestBoltDBSuite(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, newTempBackend(t, zrmdb.BoltDBBackend))
+}
+
+func BenchmarkLDBSuite(b *testing.B) {
+	dbtest.BenchmarkDatabaseSuite(b, newTempBackend(b, zrmdb.LevelDBBackend))
+}
+
+func BenchmarkBoltDBSuite(b *testing.B) {
+	dbtest.BenchmarkDatabaseSuite(b, newTempBackend(b, zrmdb.BoltDBBackend))
+}
+
+func TestMemoryDBSuite(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, func() zrmdb.Database {
+		db, _ := zrmdb.NewMemDatabase()
+		return db
+	})
+}
+
+func TestTableSuite(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, func() zrmdb.Database {
+		db, _ := zrmdb.NewMemDatabase()
+
+		// Pollute the backing database around the table to catch prefix leaks
+		db.Put([]byte("s"), []byte("before"))
+		db.Put([]byte("u"), []byte("after"))
+		return zrmdb.NewTable(db, "t")
+	})
+}
+
+// Tests that all the built-in backends are registered and unknown ones rejected.
+func TestBackends(t *testing.T) {
+	backends := zrmdb.Backends()
+	if len(backends) != 2 || backends[0] != zrmdb.BoltDBBackend || backends[1] != zrmdb.LevelDBBackend {
+		t.Fatalf("registered backends mismatch: have %v", backends)
+	}
+	if _, err := zrmdb.Open("unknown", "", 0, 0); err == nil {
+		t.Fatalf("unknown backend opened")
+	}
+}
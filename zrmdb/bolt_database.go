@@ -0,0 +1,298 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrmdb
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apolo-technologies/zerium/common"
+	"github.com/apolo-technologies/zerium/log"
+	"github.com/boltdb/bolt"
+)
+
+// boltIteratorChunk is the number of key/value pairs a BoltDB iterator loads per
+// read transaction. Long lived read transactions block the database file from
+// growing, so iterators never keep one open between calls.
+const boltIteratorChunk = 1024
+
+var (
+	// boltBucket is the single bucket holding all the key/value pairs.
+	boltBucket = []byte("zrmdb")
+
+	// errBoltNotFound is returned if a key is requested that is not found in
+	// the database.
+	errBoltNotFound = errors.New("not found")
+)
+
+// BoltDatabase is a key/value database backed by a BoltDB B+tree file. Compared
+// to LevelDB it trades write throughput for predictable reads and no background
+// compaction.
+//
+// The file is opened without syncing every transaction to disk, which would cost
+// an fsync per written key. Like LevelDB, single writes may be lost on a crash;
+// batches and closing the database sync all the preceding writes to disk.
+type BoltDatabase struct {
+	fn string   // filename for reporting
+	db *bolt.DB // BoltDB instance
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// NewBoltDatabase opens a BoltDB database within the given directory, creating
+// it if needed. The cache and handles allowances are unused, BoltDB relies on
+// the operating system's page cache through a memory mapped file instead.
+func NewBoltDatabase(file string, cache int, handles int) (*BoltDatabase, error) {
+	logger := log.New("database", file)
+
+	if err := os.MkdirAll(file, 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(file, "bolt.db"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	db.NoSync = true
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	logger.Info("Opened BoltDB database")
+
+	return &BoltDatabase{
+		fn:  file,
+		db:  db,
+		log: logger,
+	}, nil
+}
+
+// Path returns the path to the database directory.
+func (db *BoltDatabase) Path() string {
+	return db.fn
+}
+
+// Put inserts the given key / value pair into the database. Every call commits a
+// separate BoltDB transaction without syncing it to disk.
+func (db *BoltDatabase) Put(key []byte, value []byte) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(key, common.CopyBytes(value))
+	})
+}
+
+// Has checks whether the given key is present in the database.
+func (db *BoltDatabase) Has(key []byte) (bool, error) {
+	var found bool
+	err := db.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(boltBucket).Get(key) != nil
+		return nil
+	})
+	return found, err
+}
+
+// Get returns the given key if it's present.
+func (db *BoltDatabase) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := db.db.View(func(tx *bolt.Tx) error {
+		// Values are only valid within the transaction, copy them out
+		if value = tx.Bucket(boltBucket).Get(key); value == nil {
+			return errBoltNotFound
+		}
+		value = common.CopyBytes(value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Delete deletes the key from the database. Like Put, every call commits a
+// separate transaction without syncing it to disk.
+func (db *BoltDatabase) Delete(key []byte) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete(key)
+	})
+}
+
+// Close flushes and closes the database file.
+func (db *BoltDatabase) Close() {
+	if err := db.db.Sync(); err != nil {
+		db.log.Error("Failed to sync database", "err", err)
+	}
+	if err := db.db.Close(); err != nil {
+		db.log.Error("Failed to close database", "err", err)
+		return
+	}
+	db.log.Info("Database closed")
+}
+
+// NewBatch creates a write-only batch, committed to the database in a single
+// transaction that is synced to disk along with all preceding writes.
+func (db *BoltDatabase) NewBatch() Batch {
+	return &boltBatch{db: db.db}
+}
+
+// DeleteRange deletes all the keys within the range [start, limit).
+func (db *BoltDatabase) DeleteRange(start, limit []byte) error {
+	return deleteRange(db, start, limit)
+}
+
+// NewIteratorWithPrefix returns an iterator over the subset of database content
+// with a particular key prefix.
+func (db *BoltDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return &boltIterator{
+		db:     db.db,
+		prefix: common.CopyBytes(prefix),
+		next:   append([]byte{}, prefix...),
+		index:  -1,
+	}
+}
+
+// NewIteratorWithRange returns an iterator over the subset of database content
+// with keys in the range [start, limit).
+func (db *BoltDatabase) NewIteratorWithRange(start, limit []byte) Iterator {
+	return &boltIterator{
+		db:    db.db,
+		limit: common.CopyBytes(limit),
+		next:  append([]byte{}, start...),
+		index: -1,
+	}
+}
+
+type boltBatch struct {
+	db     *bolt.DB
+	writes []kv
+	size   int
+}
+
+func (b *boltBatch) Put(key, value []byte) error {
+	b.writes = append(b.writes, kv{common.CopyBytes(key), common.CopyBytes(value), false})
+	b.size += len(value)
+	return nil
+}
+
+func (b *boltBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{common.CopyBytes(key), nil, true})
+	b.size++
+	return nil
+}
+
+func (b *boltBatch) Write() error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, kv := range b.writes {
+			var err error
+			if kv.del {
+				err = bucket.Delete(kv.k)
+			} else {
+				err = bucket.Put(kv.k, kv.v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return b.db.Sync()
+}
+
+func (b *boltBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *boltBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// boltIterator iterates over a BoltDB bucket in chunks, each loaded within its
+// own read transaction.
+type boltIterator struct {
+	db     *bolt.DB
+	prefix []byte // Key prefix to iterate over
+	limit  []byte // Key to stop the iteration at, nil if unbounded
+	next   []byte // Key to seek to when loading the next chunk, nil if done
+
+	chunk []kv  // Key/value pairs loaded in the current chunk
+	index int   // Position of the iterator within the current chunk
+	err   error // Error encountered while loading a chunk
+}
+
+func (it *boltIterator) Next() bool {
+	if it.index+1 < len(it.chunk) {
+		it.index++
+		return true
+	}
+	if it.next == nil || it.err != nil {
+		it.chunk, it.index = nil, 0
+		return false
+	}
+	it.chunk, it.index = it.chunk[:0], 0
+	it.err = it.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltBucket).Cursor()
+		for k, v := cursor.Seek(it.next); k != nil && it.contains(k); k, v = cursor.Next() {
+			if len(it.chunk) == boltIteratorChunk {
+				it.next = common.CopyBytes(k)
+				return nil
+			}
+			it.chunk = append(it.chunk, kv{common.CopyBytes(k), common.CopyBytes(v), false})
+		}
+		it.next = nil
+		return nil
+	})
+	return it.err == nil && len(it.chunk) > 0
+}
+
+// contains reports whether a key is within the iterated prefix and range.
+func (it *boltIterator) contains(key []byte) bool {
+	if !bytes.HasPrefix(key, it.prefix) {
+		return false
+	}
+	return it.limit == nil || bytes.Compare(key, it.limit) < 0
+}
+
+func (it *boltIterator) Error() error {
+	return it.err
+}
+
+func (it *boltIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.chunk) {
+		return nil
+	}
+	return it.chunk[it.index].k
+}
+
+func (it *boltIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.chunk) {
+		return nil
+	}
+	return it.chunk[it.index].v
+}
+
+func (it *boltIterator) Release() {
+	it.chunk, it.next = nil, nil
+}
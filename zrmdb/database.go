@@ -29,6 +29,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 
 	gometrics "github.com/rcrowley/go-metrics"
 )
@@ -149,6 +150,47 @@ func (db *LDBDatabase) NewIterator() iterator.Iterator {
 	return db.db.NewIterator(nil, nil)
 }
 
+// NewIteratorWithPrefix returns an iterator over the subset of database content
+// with a particular key prefix.
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return db.db.NewIterator(util.BytesPrefix(prefix), nil)
+}
+
+// NewIteratorWithRange returns an iterator over the subset of database content
+// with keys in the range [start, limit).
+func (db *LDBDatabase) NewIteratorWithRange(start, limit []byte) Iterator {
+	return db.db.NewIterator(&util.Range{Start: start, Limit: limit}, nil)
+}
+
+// DeleteRange deletes all the keys within the range [start, limit).
+func (db *LDBDatabase) DeleteRange(start, limit []byte) error {
+	return deleteRange(db, start, limit)
+}
+
+// deleteRange deletes all the keys of a database within the range [start, limit)
+// by iterating over them and flushing their deletions in batches.
+func deleteRange(db Database, start, limit []byte) error {
+	it := db.NewIteratorWithRange(start, limit)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		if err := batch.Delete(it.Key()); err != nil {
+			return err
+		}
+		if batch.ValueSize() >= IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
 func (db *LDBDatabase) Close() {
 	// Stop the metrics collection to avoid internal database races
 	db.quitLock.Lock()
@@ -291,6 +333,12 @@ func (b *ldbBatch) Put(key, value []byte) error {
 	return nil
 }
 
+func (b *ldbBatch) Delete(key []byte) error {
+	b.b.Delete(key)
+	b.size++
+	return nil
+}
+
 func (b *ldbBatch) Write() error {
 	return b.db.Write(b.b, nil)
 }
@@ -299,6 +347,11 @@ func (b *ldbBatch) ValueSize() int {
 	return b.size
 }
 
+func (b *ldbBatch) Reset() {
+	b.b.Reset()
+	b.size = 0
+}
+
 type table struct {
 	db     Database
 	prefix string
@@ -333,6 +386,75 @@ func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
 
+// NewIteratorWithPrefix returns an iterator over the subset of the table's
+// content with a particular key prefix. The table prefix is stripped from the
+// iterated keys.
+func (dt *table) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return &tableIterator{
+		iter:   dt.db.NewIteratorWithPrefix(append([]byte(dt.prefix), prefix...)),
+		prefix: dt.prefix,
+	}
+}
+
+// NewIteratorWithRange returns an iterator over the subset of the table's content
+// with keys in the range [start, limit). The table prefix is stripped from the
+// iterated keys.
+func (dt *table) NewIteratorWithRange(start, limit []byte) Iterator {
+	start, limit = dt.tableRange(start, limit)
+	return &tableIterator{
+		iter:   dt.db.NewIteratorWithRange(start, limit),
+		prefix: dt.prefix,
+	}
+}
+
+// DeleteRange deletes all the keys of the table within the range [start, limit).
+func (dt *table) DeleteRange(start, limit []byte) error {
+	start, limit = dt.tableRange(start, limit)
+	return dt.db.DeleteRange(start, limit)
+}
+
+// tableRange converts a key range within the table into the range of the
+// underlying database, with open bounds closed at the edges of the table.
+func (dt *table) tableRange(start, limit []byte) ([]byte, []byte) {
+	if limit == nil {
+		limit = util.BytesPrefix([]byte(dt.prefix)).Limit
+	} else {
+		limit = append([]byte(dt.prefix), limit...)
+	}
+	return append([]byte(dt.prefix), start...), limit
+}
+
+// tableIterator is a wrapper around a database iterator that strips the table
+// prefix from the iterated keys.
+type tableIterator struct {
+	iter   Iterator
+	prefix string
+}
+
+func (it *tableIterator) Next() bool {
+	return it.iter.Next()
+}
+
+func (it *tableIterator) Error() error {
+	return it.iter.Error()
+}
+
+func (it *tableIterator) Key() []byte {
+	key := it.iter.Key()
+	if key == nil {
+		return nil
+	}
+	return key[len(it.prefix):]
+}
+
+func (it *tableIterator) Value() []byte {
+	return it.iter.Value()
+}
+
+func (it *tableIterator) Release() {
+	it.iter.Release()
+}
+
 type tableBatch struct {
 	batch  Batch
 	prefix string
@@ -351,6 +473,10 @@ func (tb *tableBatch) Put(key, value []byte) error {
 	return tb.batch.Put(append([]byte(tb.prefix), key...), value)
 }
 
+func (tb *tableBatch) Delete(key []byte) error {
+	return tb.batch.Delete(append([]byte(tb.prefix), key...))
+}
+
 func (tb *tableBatch) Write() error {
 	return tb.batch.Write()
 }
@@ -358,3 +484,7 @@ func (tb *tableBatch) Write() error {
 func (tb *tableBatch) ValueSize() int {
 	return tb.batch.ValueSize()
 }
+
+func (tb *tableBatch) Reset() {
+	tb.batch.Reset()
+}
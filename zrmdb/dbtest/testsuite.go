@@ -0,0 +1,517 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dbtest contains a conformance test suite that every key/value database
+// backend implementing zrmdb.Database must pass.
+package dbtest
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/apolo-technologies/zerium/zrmdb"
+)
+
+// TestDatabaseSuite runs a suite of tests against a key/value database backend.
+// The New function is invoked once per test to create a fresh, empty database,
+// which is closed by the suite after the test finishes.
+func TestDatabaseSuite(t *testing.T, New func() zrmdb.Database) {
+	t.Run("PutGet", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+		testPutGet(t, db)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+		testDelete(t, db)
+	})
+	t.Run("Batch", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+		testBatch(t, db)
+	})
+	t.Run("Iterator", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+		testIterator(t, db)
+	})
+	t.Run("BatchDeleteReset", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+		testBatchDeleteReset(t, db)
+	})
+	t.Run("IteratorRange", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+		testIteratorRange(t, db)
+	})
+	t.Run("DeleteRange", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+		testDeleteRange(t, db)
+	})
+	t.Run("IteratorLarge", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+		testIteratorLarge(t, db)
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+		testConcurrent(t, db)
+	})
+}
+
+// BenchmarkDatabaseSuite runs a suite of benchmarks against a key/value database
+// backend. The New function is invoked once per benchmark to create a fresh,
+// empty database, which is closed by the suite after the benchmark finishes.
+func BenchmarkDatabaseSuite(b *testing.B, New func() zrmdb.Database) {
+	// Chain import writes and deletes keys one by one, so backends syncing every
+	// single write to disk show up here
+	b.Run("Put", func(b *testing.B) {
+		db := New()
+		defer db.Close()
+		benchPut(b, db)
+	})
+	b.Run("Delete", func(b *testing.B) {
+		db := New()
+		defer db.Close()
+		benchDelete(b, db)
+	})
+}
+
+// Note, empty keys are deliberately not tested, as not all backends support them.
+var testKeys = []string{"a", "1251", "\x00123\x00", "\xff"}
+
+func testPutGet(t *testing.T, db zrmdb.Database) {
+	for _, k := range testKeys {
+		if has, err := db.Has([]byte(k)); err != nil || has {
+			t.Fatalf("has %q before put: have %v, err %v", k, has, err)
+		}
+		if _, err := db.Get([]byte(k)); err == nil {
+			t.Fatalf("get %q before put succeeded", k)
+		}
+		if err := db.Put([]byte(k), []byte(k)); err != nil {
+			t.Fatalf("put %q failed: %v", k, err)
+		}
+	}
+	for _, k := range testKeys {
+		if has, err := db.Has([]byte(k)); err != nil || !has {
+			t.Fatalf("has %q after put: have %v, err %v", k, has, err)
+		}
+		data, err := db.Get([]byte(k))
+		if err != nil {
+			t.Fatalf("get %q failed: %v", k, err)
+		}
+		if !bytes.Equal(data, []byte(k)) {
+			t.Fatalf("get %q mismatch: have %q, want %q", k, data, k)
+		}
+	}
+	// Overwrite every value, ensuring neither the stored nor the returned slices
+	// alias any memory owned by the caller
+	for _, k := range testKeys {
+		value := []byte("?")
+		if err := db.Put([]byte(k), value); err != nil {
+			t.Fatalf("put override %q failed: %v", k, err)
+		}
+		value[0] = '!'
+
+		data, err := db.Get([]byte(k))
+		if err != nil {
+			t.Fatalf("get %q failed: %v", k, err)
+		}
+		data[0] = '!'
+
+		if data, err = db.Get([]byte(k)); err != nil || !bytes.Equal(data, []byte("?")) {
+			t.Fatalf("get %q after override: have %q, err %v, want %q", k, data, err, "?")
+		}
+	}
+}
+
+func testDelete(t *testing.T, db zrmdb.Database) {
+	for _, k := range testKeys {
+		if err := db.Put([]byte(k), []byte(k)); err != nil {
+			t.Fatalf("put %q failed: %v", k, err)
+		}
+	}
+	for _, k := range testKeys {
+		if err := db.Delete([]byte(k)); err != nil {
+			t.Fatalf("delete %q failed: %v", k, err)
+		}
+		if has, err := db.Has([]byte(k)); err != nil || has {
+			t.Fatalf("has %q after delete: have %v, err %v", k, has, err)
+		}
+		if _, err := db.Get([]byte(k)); err == nil {
+			t.Fatalf("get %q after delete succeeded", k)
+		}
+	}
+	// Deleting missing keys is not an error
+	if err := db.Delete([]byte("missing")); err != nil {
+		t.Fatalf("delete of missing key failed: %v", err)
+	}
+	if it := iterate(t, db, nil); len(it) != 0 {
+		t.Fatalf("database not empty after deletes: %v", it)
+	}
+}
+
+func testBatch(t *testing.T, db zrmdb.Database) {
+	if err := db.Put([]byte("a"), []byte("old")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	batch := db.NewBatch()
+	for i, k := range testKeys {
+		if err := batch.Put([]byte(k), []byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("batch put %q failed: %v", k, err)
+		}
+	}
+	if size, want := batch.ValueSize(), len(testKeys); size != want {
+		t.Fatalf("batch value size mismatch: have %d, want %d", size, want)
+	}
+	// Nothing may be visible until the batch is written
+	if data, err := db.Get([]byte("a")); err != nil || !bytes.Equal(data, []byte("old")) {
+		t.Fatalf("batch leaked before write: have %q, err %v", data, err)
+	}
+	for _, k := range testKeys[1:] {
+		if has, _ := db.Has([]byte(k)); has {
+			t.Fatalf("batch leaked %q before write", k)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	for i, k := range testKeys {
+		if data, err := db.Get([]byte(k)); err != nil || !bytes.Equal(data, []byte(strconv.Itoa(i))) {
+			t.Fatalf("get %q after batch: have %q, err %v, want %q", k, data, err, strconv.Itoa(i))
+		}
+	}
+}
+
+func testBatchDeleteReset(t *testing.T, db zrmdb.Database) {
+	for _, k := range testKeys {
+		if err := db.Put([]byte(k), []byte(k)); err != nil {
+			t.Fatalf("put %q failed: %v", k, err)
+		}
+	}
+	// Queue up some deletions and a put, then discard them
+	batch := db.NewBatch()
+	for _, k := range testKeys {
+		if err := batch.Delete([]byte(k)); err != nil {
+			t.Fatalf("batch delete %q failed: %v", k, err)
+		}
+	}
+	batch.Put([]byte("new"), []byte("new"))
+	batch.Reset()
+
+	if size := batch.ValueSize(); size != 0 {
+		t.Fatalf("batch value size after reset: have %d, want 0", size)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("empty batch write failed: %v", err)
+	}
+	for _, k := range testKeys {
+		if has, err := db.Has([]byte(k)); err != nil || !has {
+			t.Fatalf("has %q after reset batch: have %v, err %v", k, has, err)
+		}
+	}
+	if has, _ := db.Has([]byte("new")); has {
+		t.Fatalf("reset batch put written")
+	}
+	// Reuse the batch to delete half the keys, overriding a queued put
+	for i, k := range testKeys {
+		if i%2 == 0 {
+			batch.Put([]byte(k), []byte("?"))
+			if err := batch.Delete([]byte(k)); err != nil {
+				t.Fatalf("batch delete %q failed: %v", k, err)
+			}
+		}
+	}
+	if size := batch.ValueSize(); size == 0 {
+		t.Fatalf("batch value size zero after deletes")
+	}
+	if has, _ := db.Has([]byte(testKeys[0])); !has {
+		t.Fatalf("batch deletion leaked before write")
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	for i, k := range testKeys {
+		if has, err := db.Has([]byte(k)); err != nil || has != (i%2 == 1) {
+			t.Fatalf("has %q after batch delete: have %v, err %v, want %v", k, has, err, i%2 == 1)
+		}
+	}
+}
+
+// rangeKeys are the keys inserted for the range iteration and deletion tests.
+var rangeKeys = []string{"a", "ab", "b", "b1", "b10", "b2", "bb", "c3", "\xffz"}
+
+func testIteratorRange(t *testing.T, db zrmdb.Database) {
+	for _, k := range rangeKeys {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put %q failed: %v", k, err)
+		}
+	}
+	tests := []struct {
+		start, limit []byte
+		want         []string
+	}{
+		{nil, nil, rangeKeys},
+		{[]byte("b"), nil, rangeKeys[2:]},
+		{nil, []byte("b"), rangeKeys[:2]},
+		{[]byte("b"), []byte("b2"), []string{"b", "b1", "b10"}},
+		{[]byte("aa"), []byte("b10"), []string{"ab", "b", "b1"}},
+		{[]byte("b10"), []byte("b10"), nil},
+		{[]byte("c"), []byte("b"), nil},
+		{[]byte("\xff\xff"), nil, nil},
+	}
+	for _, tt := range tests {
+		have := iterateRange(t, db, tt.start, tt.limit)
+		if fmt.Sprint(have) != fmt.Sprint(tt.want) {
+			t.Errorf("range [%q, %q): iterated keys mismatch: have %q, want %q", tt.start, tt.limit, have, tt.want)
+		}
+	}
+}
+
+func testDeleteRange(t *testing.T, db zrmdb.Database) {
+	for _, k := range rangeKeys {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put %q failed: %v", k, err)
+		}
+	}
+	deletions := []struct {
+		start, limit []byte
+		want         []string
+	}{
+		{[]byte("b1"), []byte("b2"), []string{"a", "ab", "b", "b2", "bb", "c3", "\xffz"}},
+		{[]byte("c"), []byte("b"), []string{"a", "ab", "b", "b2", "bb", "c3", "\xffz"}},
+		{nil, []byte("ab"), []string{"ab", "b", "b2", "bb", "c3", "\xffz"}},
+		{[]byte("c"), nil, []string{"ab", "b", "b2", "bb"}},
+		{nil, nil, nil},
+	}
+	for i, tt := range deletions {
+		if err := db.DeleteRange(tt.start, tt.limit); err != nil {
+			t.Fatalf("deletion %d: range [%q, %q) failed: %v", i, tt.start, tt.limit, err)
+		}
+		have := iterate(t, db, nil)
+		if fmt.Sprint(have) != fmt.Sprint(tt.want) {
+			t.Fatalf("deletion %d: remaining keys mismatch: have %q, want %q", i, have, tt.want)
+		}
+		for _, k := range tt.want {
+			if has, err := db.Has([]byte(k)); err != nil || !has {
+				t.Fatalf("deletion %d: has %q: have %v, err %v", i, k, has, err)
+			}
+		}
+	}
+}
+
+func testIterator(t *testing.T, db zrmdb.Database) {
+	if it := iterate(t, db, nil); len(it) != 0 {
+		t.Fatalf("empty database iterated: %v", it)
+	}
+	// Insert a few groups of keys out of order, with values derived from them
+	keys := []string{"b2", "a", "b1", "ab", "b", "c3", "b10", "\xffz", "bb"}
+	for _, k := range keys {
+		if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatalf("put %q failed: %v", k, err)
+		}
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", keys},
+		{"b", []string{"b", "b1", "b10", "b2", "bb"}},
+		{"b1", []string{"b1", "b10"}},
+		{"a", []string{"a", "ab"}},
+		{"\xff", []string{"\xffz"}},
+		{"d", nil},
+		{"b100", nil},
+	}
+	for _, tt := range tests {
+		want := append([]string{}, tt.want...)
+		sort.Strings(want)
+
+		have := iterate(t, db, []byte(tt.prefix))
+		if fmt.Sprint(have) != fmt.Sprint(want) {
+			t.Errorf("prefix %q: iterated keys mismatch: have %q, want %q", tt.prefix, have, want)
+		}
+	}
+	// Exhausted iterators must stay exhausted
+	it := db.NewIteratorWithPrefix([]byte("a"))
+	for it.Next() {
+	}
+	if it.Next() || it.Key() != nil || it.Value() != nil {
+		t.Errorf("exhausted iterator resumed: key %q, value %q", it.Key(), it.Value())
+	}
+	it.Release()
+	it.Release()
+}
+
+// testIteratorLarge checks iteration over more items than backends are likely
+// to buffer internally at once.
+func testIteratorLarge(t *testing.T, db zrmdb.Database) {
+	const n = 5000
+
+	batch := db.NewBatch()
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("k%05d", i)
+		if err := batch.Put([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatalf("batch put %q failed: %v", key, err)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	if keys := iterate(t, db, []byte("k")); len(keys) != n {
+		t.Fatalf("iterated item count mismatch: have %d, want %d", len(keys), n)
+	}
+	if keys := iterate(t, db, []byte("k01")); len(keys) != 1000 {
+		t.Fatalf("iterated prefix item count mismatch: have %d, want %d", len(keys), 1000)
+	}
+}
+
+// iterate collects the keys of all the database entries with the given prefix,
+// checking that they come in order and each value is the key prefixed with "v".
+func iterate(t *testing.T, db zrmdb.Database, prefix []byte) []string {
+	keys := collect(t, db.NewIteratorWithPrefix(prefix))
+	for _, key := range keys {
+		if !bytes.HasPrefix([]byte(key), prefix) {
+			t.Fatalf("key %q without prefix %q", key, prefix)
+		}
+	}
+	return keys
+}
+
+// iterateRange collects the keys of all the database entries within the range
+// [start, limit), with the same checks as iterate.
+func iterateRange(t *testing.T, db zrmdb.Database, start, limit []byte) []string {
+	keys := collect(t, db.NewIteratorWithRange(start, limit))
+	for _, key := range keys {
+		if key < string(start) || (limit != nil && key >= string(limit)) {
+			t.Fatalf("key %q outside of range [%q, %q)", key, start, limit)
+		}
+	}
+	return keys
+}
+
+// collect drains an iterator, checking that the keys come in order and each
+// value is the key prefixed with "v".
+func collect(t *testing.T, it zrmdb.Iterator) []string {
+	defer it.Release()
+
+	var keys []string
+	for it.Next() {
+		key := string(it.Key())
+		if len(keys) > 0 && keys[len(keys)-1] >= key {
+			t.Fatalf("keys out of order: %q after %q", key, keys[len(keys)-1])
+		}
+		if value := it.Value(); !bytes.Equal(value, []byte("v"+key)) {
+			t.Fatalf("value of %q mismatch: have %q, want %q", key, value, "v"+key)
+		}
+		keys = append(keys, key)
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	return keys
+}
+
+func testConcurrent(t *testing.T, db zrmdb.Database) {
+	const n = 8
+	var pending sync.WaitGroup
+
+	errc := make(chan error, n)
+	run := func(op func(key string) error) {
+		pending.Add(n)
+		for i := 0; i < n; i++ {
+			go func(key string) {
+				defer pending.Done()
+				if err := op(key); err != nil {
+					errc <- err
+				}
+			}(strconv.Itoa(i))
+		}
+		pending.Wait()
+
+		select {
+		case err := <-errc:
+			t.Fatal(err)
+		default:
+		}
+	}
+	run(func(key string) error {
+		return db.Put([]byte(key), []byte("v"+key))
+	})
+	run(func(key string) error {
+		data, err := db.Get([]byte(key))
+		if err != nil {
+			return fmt.Errorf("get %q failed: %v", key, err)
+		}
+		if !bytes.Equal(data, []byte("v"+key)) {
+			return fmt.Errorf("get %q mismatch: have %q, want %q", key, data, "v"+key)
+		}
+		return nil
+	})
+	run(func(key string) error {
+		it := db.NewIteratorWithPrefix(nil)
+		defer it.Release()
+
+		count := 0
+		for it.Next() {
+			count++
+		}
+		if count != n {
+			return fmt.Errorf("iterated %d items, want %d", count, n)
+		}
+		return it.Error()
+	})
+	run(func(key string) error {
+		return db.Delete([]byte(key))
+	})
+	run(func(key string) error {
+		if _, err := db.Get([]byte(key)); err == nil {
+			return fmt.Errorf("get %q after delete succeeded", key)
+		}
+		return nil
+	})
+}
+
+func benchPut(b *testing.B, db zrmdb.Database) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.Put([]byte(strconv.Itoa(i)), []byte("v")); err != nil {
+			b.Fatalf("put %d failed: %v", i, err)
+		}
+	}
+}
+
+func benchDelete(b *testing.B, db zrmdb.Database) {
+	for i := 0; i < b.N; i++ {
+		if err := db.Put([]byte(strconv.Itoa(i)), []byte("v")); err != nil {
+			b.Fatalf("put %d failed: %v", i, err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.Delete([]byte(strconv.Itoa(i))); err != nil {
+			b.Fatalf("delete %d failed: %v", i, err)
+		}
+	}
+}
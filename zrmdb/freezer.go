@@ -0,0 +1,255 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrmdb
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apolo-technologies/zerium/log"
+)
+
+const (
+	// FreezerHashTable indicates the name of the freezer canonical hash table.
+	FreezerHashTable = "hashes"
+
+	// FreezerHeaderTable indicates the name of the freezer header table.
+	FreezerHeaderTable = "headers"
+
+	// FreezerBodiesTable indicates the name of the freezer block body table.
+	FreezerBodiesTable = "bodies"
+
+	// FreezerReceiptTable indicates the name of the freezer receipts table.
+	FreezerReceiptTable = "receipts"
+
+	// FreezerDifficultyTable indicates the name of the freezer total difficulty table.
+	FreezerDifficultyTable = "diffs"
+)
+
+// freezerTables are the tables making up a freezer, one item per block each.
+var freezerTables = []string{FreezerHashTable, FreezerHeaderTable, FreezerBodiesTable, FreezerReceiptTable, FreezerDifficultyTable}
+
+// errUnknownTable is returned if the user attempts to read from a table that is
+// not tracked by the freezer.
+var errUnknownTable = errors.New("unknown table")
+
+// Freezer is an append-only database storing immutable chain data in flat files.
+// Every table holds one item per block, indexed by the block number, so only the
+// canonical chain can be frozen. Unlike a key-value store, the flat files need
+// no compaction, keeping disk writes to a minimum.
+type Freezer struct {
+	frozen uint64 // Number of blocks already frozen (atomic, keep first for alignment)
+
+	tables map[string]*freezerTable // Data tables for storing everything
+	lock   sync.Mutex               // Mutex serializing appends and truncations
+}
+
+// NewFreezer creates a chain freezer that moves ancient chain data into flat
+// append-only file tables within the given directory.
+func NewFreezer(datadir string) (*Freezer, error) {
+	freezer := &Freezer{
+		tables: make(map[string]*freezerTable),
+	}
+	for _, name := range freezerTables {
+		table, err := newFreezerTable(datadir, name)
+		if err != nil {
+			freezer.Close()
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		freezer.Close()
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "blocks", freezer.Ancients())
+	return freezer, nil
+}
+
+// repair truncates all data tables to the same length, dropping any partially
+// frozen block left by an unclean shutdown.
+func (f *Freezer) repair() error {
+	min := ^uint64(0)
+	for _, table := range f.tables {
+		if items := table.Items(); items < min {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// Close terminates the chain freezer, closing all the data files.
+func (f *Freezer) Close() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *Freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if _, ok := f.tables[kind]; !ok {
+		return false, errUnknownTable
+	}
+	return number < atomic.LoadUint64(&f.frozen), nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *Freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	table, ok := f.tables[kind]
+	if !ok {
+		return nil, errUnknownTable
+	}
+	if number >= atomic.LoadUint64(&f.frozen) {
+		return nil, errOutOfBounds
+	}
+	return table.Retrieve(number)
+}
+
+// Ancients returns the number of blocks frozen, which is also the number of the
+// first block not yet frozen.
+func (f *Freezer) Ancients() uint64 {
+	return atomic.LoadUint64(&f.frozen)
+}
+
+// AppendAncient injects all binary blobs belonging to a block at the end of the
+// append-only immutable table files. Out-of-order injections are rejected. If
+// any of the tables fails to accept its item, all of them are rolled back to
+// their previous state.
+func (f *Freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Roll back all tables to the starting position in case of error
+	defer func() {
+		if err != nil {
+			for _, table := range f.tables {
+				if rerr := table.truncate(number); rerr != nil {
+					log.Error("Failed to roll back ancient table", "table", table.name, "number", number, "err", rerr)
+				}
+			}
+		}
+	}()
+	blobs := map[string][]byte{
+		FreezerHashTable:       hash,
+		FreezerHeaderTable:     header,
+		FreezerBodiesTable:     body,
+		FreezerReceiptTable:    receipts,
+		FreezerDifficultyTable: td,
+	}
+	for _, name := range freezerTables {
+		if err := f.tables[name].Append(number, blobs[name]); err != nil {
+			return fmt.Errorf("failed to append ancient %s #%d: %v", name, number, err)
+		}
+	}
+	atomic.AddUint64(&f.frozen, 1)
+	return nil
+}
+
+// TruncateAncients discards any recent data above the provided threshold number.
+func (f *Freezer) TruncateAncients(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// Sync flushes all data tables to disk.
+func (f *Freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// AncientDatabase is a key-value database paired with a freezer holding the
+// immutable ancient chain data that was migrated out of it.
+type AncientDatabase struct {
+	Database
+	*Freezer
+}
+
+// NewDatabaseWithFreezer attaches a freezer to a persistent key-value database.
+// If no freezer directory is given, it's placed into the database directory.
+func NewDatabaseWithFreezer(db Database, freezer string) (*AncientDatabase, error) {
+	if freezer == "" {
+		pather, ok := db.(interface{ Path() string })
+		if !ok {
+			return nil, errors.New("freezer directory required for non-persistent database")
+		}
+		freezer = filepath.Join(pather.Path(), "ancient")
+	}
+	frdb, err := NewFreezer(freezer)
+	if err != nil {
+		return nil, err
+	}
+	return &AncientDatabase{
+		Database: db,
+		Freezer:  frdb,
+	}, nil
+}
+
+// Close closes both the key-value database and the freezer.
+func (db *AncientDatabase) Close() {
+	if err := db.Freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	db.Database.Close()
+}
+
+// LevelDB returns the LevelDB database backing db, unwrapping any freezer that
+// might be attached to it. Nil is returned for non-LevelDB databases.
+func LevelDB(db Database) *LDBDatabase {
+	switch db := db.(type) {
+	case *LDBDatabase:
+		return db
+	case *AncientDatabase:
+		return LevelDB(db.Database)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2017 The zerium Authors
+// This file is part of the zerium library.
+//
+// The zerium library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The zerium library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the zerium library. If not, see <http://www.gnu.org/licenses/>.
+
+package zrmdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// getChunk returns a chunk of data of the given size, filled with the byte b.
+func getChunk(size int, b byte) []byte {
+	return bytes.Repeat([]byte{b}, size)
+}
+
+// Tests that items appended to a freezer table can be retrieved, also after
+// reopening the table.
+func TestFreezerTableBasics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table, err := newFreezerTable(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 255; i++ {
+		if err := table.Append(uint64(i), getChunk(i*3, byte(i))); err != nil {
+			t.Fatalf("failed to append item %d: %v", i, err)
+		}
+	}
+	if err := table.Append(300, nil); err == nil {
+		t.Fatalf("out of order append succeeded")
+	}
+	table.Close()
+
+	if table, err = newFreezerTable(dir, "test"); err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if items := table.Items(); items != 255 {
+		t.Fatalf("item count mismatch: have %d, want %d", items, 255)
+	}
+	for i := 0; i < 255; i++ {
+		blob, err := table.Retrieve(uint64(i))
+		if err != nil {
+			t.Fatalf("failed to retrieve item %d: %v", i, err)
+		}
+		if want := getChunk(i*3, byte(i)); !bytes.Equal(blob, want) {
+			t.Fatalf("item %d mismatch: have %x, want %x", i, blob, want)
+		}
+	}
+	if _, err := table.Retrieve(255); err != errOutOfBounds {
+		t.Fatalf("out of bounds retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+	}
+}
+
+// Tests that a freezer table with a data file shorter than what its index
+// references is repaired on open, dropping the unbacked items.
+func TestFreezerTableRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table, err := newFreezerTable(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := table.Append(uint64(i), getChunk(20, byte(i))); err != nil {
+			t.Fatalf("failed to append item %d: %v", i, err)
+		}
+	}
+	end, _ := table.offset(7)
+	table.Close()
+
+	// Chop off the last item and a half from the data file, and a few bytes of
+	// a partial entry from the index file
+	if err := os.Truncate(filepath.Join(dir, "test.cdat"), int64(end)+5); err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.OpenFile(filepath.Join(dir, "test.cidx"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Write([]byte{0x00, 0x01})
+	index.Close()
+
+	if table, err = newFreezerTable(dir, "test"); err != nil {
+		t.Fatal(err)
+	}
+	defer table.Close()
+
+	if items := table.Items(); items != 8 {
+		t.Fatalf("item count mismatch after repair: have %d, want %d", items, 8)
+	}
+	for i := 0; i < 8; i++ {
+		if blob, err := table.Retrieve(uint64(i)); err != nil || !bytes.Equal(blob, getChunk(20, byte(i))) {
+			t.Fatalf("item %d mismatch after repair: have %x, err %v", i, blob, err)
+		}
+	}
+	// Ensure the table can be appended to again after the repair
+	if err := table.Append(8, getChunk(20, 0xff)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+	if blob, _ := table.Retrieve(8); !bytes.Equal(blob, getChunk(20, 0xff)) {
+		t.Fatalf("appended item mismatch after repair: have %x", blob)
+	}
+}
+
+// Tests that the freezer keeps its tables aligned, across appends, truncations
+// and reopening with unevenly sized tables.
+func TestFreezerAncients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	freezer, err := NewFreezer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(0); i < 10; i++ {
+		blob := []byte(fmt.Sprintf("block-%d", i))
+		if err := freezer.AppendAncient(i, blob, blob, blob, blob, blob); err != nil {
+			t.Fatalf("failed to freeze block %d: %v", i, err)
+		}
+	}
+	if err := freezer.AppendAncient(11, nil, nil, nil, nil, nil); err == nil {
+		t.Fatalf("out of order freeze succeeded")
+	}
+	if n := freezer.Ancients(); n != 10 {
+		t.Fatalf("ancient count mismatch: have %d, want %d", n, 10)
+	}
+	if err := freezer.TruncateAncients(8); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	if _, err := freezer.Ancient(FreezerHeaderTable, 8); err != errOutOfBounds {
+		t.Fatalf("truncated block error mismatch: have %v, want %v", err, errOutOfBounds)
+	}
+	if _, err := freezer.Ancient("unknown", 0); err != errUnknownTable {
+		t.Fatalf("unknown table error mismatch: have %v, want %v", err, errUnknownTable)
+	}
+	// Simulate a crash mid-append by extending a single table
+	if err := freezer.tables[FreezerBodiesTable].Append(8, []byte("dangling")); err != nil {
+		t.Fatal(err)
+	}
+	freezer.Close()
+
+	if freezer, err = NewFreezer(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer freezer.Close()
+
+	if n := freezer.Ancients(); n != 8 {
+		t.Fatalf("ancient count mismatch after reopen: have %d, want %d", n, 8)
+	}
+	for _, kind := range freezerTables {
+		if items := freezer.tables[kind].Items(); items != 8 {
+			t.Errorf("table %s size mismatch: have %d, want %d", kind, items, 8)
+		}
+		blob, err := freezer.Ancient(kind, 7)
+		if err != nil || string(blob) != "block-7" {
+			t.Errorf("table %s item mismatch: have %q, err %v", kind, blob, err)
+		}
+	}
+}
@@ -25,20 +25,97 @@ type Putter interface {
 	Put(key []byte, value []byte) error
 }
 
+// Deleter wraps the database delete operation supported by both batches and regular databases.
+type Deleter interface {
+	Delete(key []byte) error
+}
+
 // Database wraps all database operations. All methods are safe for concurrent use.
 type Database interface {
 	Putter
+	Deleter
 	Get(key []byte) ([]byte, error)
 	Has(key []byte) (bool, error)
-	Delete(key []byte) error
 	Close()
 	NewBatch() Batch
+	Iteratee
+
+	// DeleteRange deletes all the keys within the range [start, limit). A nil
+	// start means no lower bound and a nil limit means no upper bound.
+	DeleteRange(start, limit []byte) error
 }
 
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch interface {
 	Putter
+	Deleter
 	ValueSize() int // amount of data in the batch
 	Write() error
+
+	// Reset resets the batch for reuse, discarding all the queued operations.
+	Reset()
+}
+
+// Iterator iterates over a database's key/value pairs in ascending key order.
+// The iterator must be released after use, by calling Release.
+type Iterator interface {
+	// Next moves the iterator to the next key/value pair. It returns whether the
+	// iterator is exhausted.
+	Next() bool
+
+	// Error returns any accumulated error. Exhausting all the key/value pairs
+	// is not considered to be an error.
+	Error() error
+
+	// Key returns the key of the current key/value pair, or nil if done. The
+	// caller should not modify the contents of the returned slice, and its
+	// contents may change on the next call to Next.
+	Key() []byte
+
+	// Value returns the value of the current key/value pair, or nil if done. The
+	// caller should not modify the contents of the returned slice, and its
+	// contents may change on the next call to Next.
+	Value() []byte
+
+	// Release releases associated resources. Release should always succeed and
+	// can be called multiple times without causing error.
+	Release()
+}
+
+// Iteratee wraps the iterator constructors of a backing data store.
+type Iteratee interface {
+	// NewIteratorWithPrefix creates a binary-alphabetical iterator over the
+	// subset of database content with a particular key prefix.
+	NewIteratorWithPrefix(prefix []byte) Iterator
+
+	// NewIteratorWithRange creates a binary-alphabetical iterator over the
+	// subset of database content with keys in the range [start, limit). A nil
+	// start means no lower bound and a nil limit means no upper bound.
+	NewIteratorWithRange(start, limit []byte) Iterator
+}
+
+// AncientReader wraps the read methods of a store of immutable ancient chain
+// data, indexed by block number.
+type AncientReader interface {
+	// Ancient retrieves an ancient binary blob of the given kind.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of blocks in the ancient store.
+	Ancients() uint64
+}
+
+// AncientStore wraps all the methods of a store of immutable ancient chain data.
+type AncientStore interface {
+	AncientReader
+
+	// AppendAncient injects all binary blobs belonging to a block at the end of
+	// the ancient store.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first n ancient blocks.
+	TruncateAncients(n uint64) error
+
+	// Sync flushes all the in-memory ancient data to disk.
+	Sync() error
 }